@@ -0,0 +1,65 @@
+// Command strava-mock serves the fake Strava API from internal/stravatest
+// so the CLI can be run locally without real credentials.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/stravatest"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	var addr, fixtures string
+	var latency time.Duration
+	var shortLimit, dailyLimit int
+
+	cmd := &cobra.Command{
+		Use:   "strava-mock",
+		Short: "Serve a fake Strava API for development",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+
+			opts := []stravatest.Option{
+				stravatest.WithLatency(latency),
+				stravatest.WithRateLimit(shortLimit, dailyLimit),
+			}
+			if fixtures != "" {
+				data, err := os.ReadFile(fixtures)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				var f stravatest.Fixtures
+				if err := json.Unmarshal(data, &f); err != nil {
+					logger.Fatalf("%s: %v\n", fixtures, err)
+				}
+				opts = append(opts, stravatest.WithFixtures(f))
+			}
+
+			srv, err := stravatest.New(opts...)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			fmt.Printf("Serving fake Strava API on http://%s\n", addr)
+			fmt.Printf("STRAVA_CLIENT_ID=%s\nSTRAVA_CLIENT_SECRET=%s\nSTRAVA_REFRESH_TOKEN=%s\n",
+				stravatest.ClientID, stravatest.ClientSecret, stravatest.RefreshToken)
+			logger.Fatal(http.ListenAndServe(addr, srv))
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8089", "address to listen on")
+	cmd.Flags().StringVar(&fixtures, "fixtures", "", "JSON file with athlete, activities, and streams fixtures")
+	cmd.Flags().DurationVar(&latency, "latency", 0, "delay added to every response")
+	cmd.Flags().IntVar(&shortLimit, "rate-limit-short", 100, "requests allowed per fifteen minute window before returning 429")
+	cmd.Flags().IntVar(&dailyLimit, "rate-limit-daily", 1000, "requests allowed per day before returning 429")
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
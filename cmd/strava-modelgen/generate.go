@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// namedType is one Go type generated from a definition of the spec
+type namedType struct {
+	Name string
+	Doc  string
+	// Fields are set for objects, Values for string enums, and Underlying
+	// for everything else
+	Fields     []field
+	Values     []string
+	Underlying string
+	isStruct   bool
+}
+
+type field struct {
+	Name string
+	Type string
+	JSON string
+	Doc  string
+}
+
+// generator turns the definitions reachable from the spec into Go types
+type generator struct {
+	loader *loader
+	types  map[string]*namedType
+}
+
+func newGenerator(l *loader) *generator {
+	return &generator{loader: l, types: make(map[string]*namedType)}
+}
+
+// load defines the types of every definition referenced by the spec at
+// location, and of the definitions they reference in turn
+func (g *generator) load(location string) error {
+	doc, err := g.loader.document(location)
+	if err != nil {
+		return err
+	}
+	refs := make([]string, 0)
+	for _, raw := range doc {
+		refs = append(refs, references(raw)...)
+	}
+	// Definitions nothing refers to are still part of the API
+	if raw, ok := doc["definitions"]; ok {
+		var names map[string]interface{}
+		if err := json.Unmarshal(raw, &names); err != nil {
+			return err
+		}
+		for name := range names {
+			refs = append(refs, "#/definitions/"+name)
+		}
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		if _, err := g.reference(location, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reference defines the type ref points to and returns how fields refer
+// to it: objects by pointer, so they can be left out and nest recursively
+func (g *generator) reference(base, ref string) (string, error) {
+	s, name, location, err := g.loader.lookup(base, ref)
+	if err != nil {
+		return "", err
+	}
+	t, err := g.define(s, exported(name), location)
+	if err != nil {
+		return "", err
+	}
+	if t.isStruct {
+		return "*" + t.Name, nil
+	}
+	return t.Name, nil
+}
+
+// define adds the type named name for s, once
+func (g *generator) define(s *schema, name, location string) (*namedType, error) {
+	if t, ok := g.types[name]; ok {
+		return t, nil
+	}
+	t := &namedType{Name: name, Doc: s.Description, isStruct: isObject(s)}
+	g.types[name] = t
+
+	switch {
+	case t.isStruct:
+		fields, err := g.fields(s, name, location)
+		if err != nil {
+			return nil, err
+		}
+		t.Fields = fields
+	case s.Type == "string" && len(s.Enum) > 0:
+		for _, v := range s.Enum {
+			t.Values = append(t.Values, fmt.Sprint(v))
+		}
+	default:
+		underlying, err := g.typeOf(s, name, location)
+		if err != nil {
+			return nil, err
+		}
+		t.Underlying = strings.TrimPrefix(underlying, "*")
+	}
+	return t, nil
+}
+
+// fields returns the properties of an object, including those of every
+// schema it is composed of with allOf
+func (g *generator) fields(s *schema, name, location string) ([]field, error) {
+	fields := make([]field, 0)
+	add := func(f field) {
+		for i := range fields {
+			if fields[i].JSON == f.JSON {
+				fields[i] = f
+				return
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	for _, part := range s.AllOf {
+		if part.Ref != "" {
+			// Inline types of the inherited fields keep the names they
+			// were given in the schema defining them
+			sub, subName, subLocation, err := g.loader.lookup(location, part.Ref)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := g.reference(location, part.Ref); err != nil {
+				return nil, err
+			}
+			inherited, err := g.fields(sub, exported(subName), subLocation)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range inherited {
+				add(f)
+			}
+			continue
+		}
+		inline, err := g.fields(part, name, location)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range inline {
+			add(f)
+		}
+	}
+
+	keys := make([]string, 0, len(s.Properties))
+	for key := range s.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p := s.Properties[key]
+		typ, err := g.typeOf(p, name+exported(key), location)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", name, key, err)
+		}
+		doc := p.Description
+		if len(p.Enum) > 0 && p.Ref == "" {
+			values := make([]string, len(p.Enum))
+			for i, v := range p.Enum {
+				values[i] = fmt.Sprint(v)
+			}
+			doc = strings.TrimSpace(doc + " One of " + strings.Join(values, ", ") + ".")
+		}
+		add(field{Name: exported(key), Type: typ, JSON: key, Doc: doc})
+	}
+	return fields, nil
+}
+
+// typeOf returns the Go type of s. Inline objects become types named
+// context, e.g. ActivityStatsRecentRideTotals.
+func (g *generator) typeOf(s *schema, context, location string) (string, error) {
+	switch {
+	case s.Ref != "":
+		return g.reference(location, s.Ref)
+	case isObject(s):
+		t, err := g.define(s, context, location)
+		if err != nil {
+			return "", err
+		}
+		return "*" + t.Name, nil
+	}
+
+	switch s.Type {
+	case "array":
+		if s.Items == nil {
+			return "[]json.RawMessage", nil
+		}
+		item, err := g.typeOf(s.Items, context+"Item", location)
+		return "[]" + item, err
+	case "object":
+		if s.AdditionalProperties == nil {
+			return "map[string]json.RawMessage", nil
+		}
+		value, err := g.typeOf(s.AdditionalProperties, context+"Value", location)
+		return "map[string]" + value, err
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	}
+	return "json.RawMessage", nil
+}
+
+func isObject(s *schema) bool {
+	return s.Ref == "" && (len(s.AllOf) > 0 || len(s.Properties) > 0)
+}
+
+// render writes the types as a formatted Go file
+func (g *generator) render(pkg, source string) ([]byte, error) {
+	names := make([]string, 0, len(g.types))
+	for name := range g.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var body bytes.Buffer
+	for _, name := range names {
+		t := g.types[name]
+		writeDoc(&body, "", t.Name, t.Doc)
+		switch {
+		case t.isStruct:
+			fmt.Fprintf(&body, "type %s struct {\n", t.Name)
+			for _, f := range t.Fields {
+				writeDoc(&body, "\t", "", f.Doc)
+				fmt.Fprintf(&body, "\t%s %s `json:\"%s,omitempty\"`\n", f.Name, f.Type, f.JSON)
+			}
+			fmt.Fprint(&body, "}\n\n")
+		case t.Values != nil:
+			fmt.Fprintf(&body, "type %s string\n\n", t.Name)
+			fmt.Fprintf(&body, "// The values of %s\nconst (\n", t.Name)
+			for _, v := range t.Values {
+				fmt.Fprintf(&body, "\t%s%s %s = %q\n", t.Name, exported(v), t.Name, v)
+			}
+			fmt.Fprint(&body, ")\n\n")
+		default:
+			fmt.Fprintf(&body, "type %s %s\n\n", t.Name, t.Underlying)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by strava-modelgen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	imports := make([]string, 0)
+	for pkg, typ := range map[string]string{"encoding/json": "json.RawMessage", "time": "time.Time"} {
+		if g.uses(typ) {
+			imports = append(imports, pkg)
+		}
+	}
+	sort.Strings(imports)
+	if len(imports) > 0 {
+		fmt.Fprint(&out, "import (\n")
+		for _, pkg := range imports {
+			fmt.Fprintf(&out, "\t%q\n", pkg)
+		}
+		fmt.Fprint(&out, ")\n\n")
+	}
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// uses reports whether any field or type refers to typ
+func (g *generator) uses(typ string) bool {
+	for _, t := range g.types {
+		if strings.Contains(t.Underlying, typ) {
+			return true
+		}
+		for _, f := range t.Fields {
+			if strings.Contains(f.Type, typ) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeDoc writes a description as a comment, starting with name for
+// types as godoc expects
+func writeDoc(w *bytes.Buffer, indent, name, doc string) {
+	doc = strings.Join(strings.Fields(doc), " ")
+	if doc == "" {
+		if name == "" {
+			return
+		}
+		doc = "is generated from the spec"
+	}
+	switch {
+	case name == "":
+	case strings.HasPrefix(doc, "A ") || strings.HasPrefix(doc, "An ") || strings.HasPrefix(doc, "The ") || strings.HasPrefix(doc, "is "):
+		doc = name + " is " + strings.TrimPrefix(lowerFirst(doc), "is ")
+	default:
+		doc = name + ": " + doc
+	}
+	for _, line := range wrap(doc, 76-len(indent)) {
+		fmt.Fprintf(w, "%s// %s\n", indent, line)
+	}
+}
+
+func wrap(text string, width int) []string {
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+func lowerFirst(s string) string {
+	if s == "" || strings.HasPrefix(s, "ID") {
+		return s
+	}
+	r := []rune(s)
+	if len(r) > 1 && unicode.IsUpper(r[1]) {
+		return s
+	}
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// exported turns a definition, property, or enum value name such as
+// moving_time, start_latlng, or AlpineSki into a Go identifier
+func exported(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	s := b.String()
+	if s == "Url" || strings.HasSuffix(s, "Url") {
+		s = strings.TrimSuffix(s, "Url") + "URL"
+	}
+	if s == "" || unicode.IsDigit([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// TestGenerateGolden generates models from a spec split across two
+// documents, like Strava's, and compares them with testdata/models.golden.
+// Run go test ./cmd/strava-modelgen -update after a deliberate change to
+// the output.
+func TestGenerateGolden(t *testing.T) {
+	spec := filepath.Join("testdata", "spec", "swagger.json")
+	g := newGenerator(newLoader())
+	if err := g.load(spec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.render("models", "testdata/spec/swagger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "models.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("generated models differ from %s:\n%s", golden, got)
+	}
+}
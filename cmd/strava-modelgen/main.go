@@ -0,0 +1,72 @@
+// Command strava-modelgen generates Go types for every model in Strava's
+// Swagger spec, so pkg/strava/models covers the whole API and can be kept
+// in sync by rerunning it:
+//
+//	go generate ./pkg/strava/models
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultSpec is where Strava publishes the spec of the v3 API
+const defaultSpec = "https://developers.strava.com/swagger/swagger.json"
+
+func main() {
+	var spec, out, pkg string
+	var check bool
+
+	cmd := &cobra.Command{
+		Use:   "strava-modelgen",
+		Short: "Generate Go models from the Strava API spec",
+		Long: `Reads the Swagger spec at --spec, a URL or file, follows its references into
+the per-resource documents Strava splits it into, and writes a Go type for
+every definition to --out. Objects become structs whose fields are all
+optional, string enums become string types with a constant per value.
+
+--check writes nothing and fails when --out differs from what the spec
+generates, for CI to catch models that fell behind the spec.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+
+			g := newGenerator(newLoader())
+			if err := g.load(spec); err != nil {
+				logger.Fatal(err)
+			}
+			source, err := g.render(pkg, spec)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			if check {
+				current, err := os.ReadFile(out)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				if !bytes.Equal(current, source) {
+					logger.Fatalf("%s is out of date with %s, run go generate ./pkg/strava/models\n", out, spec)
+				}
+				return
+			}
+			if err := os.WriteFile(out, source, 0o644); err != nil {
+				logger.Fatal(err)
+			}
+			fmt.Fprintf(os.Stderr, "Wrote %d types to %s\n", len(g.types), out)
+		},
+	}
+
+	cmd.Flags().StringVar(&spec, "spec", defaultSpec, "URL or path of the Swagger spec")
+	cmd.Flags().StringVar(&out, "out", "models.go", "Go file to write")
+	cmd.Flags().StringVar(&pkg, "package", "models", "package name of the generated file")
+	cmd.Flags().BoolVar(&check, "check", false, "fail if --out is not what the spec generates, without writing it")
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
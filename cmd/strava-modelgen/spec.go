@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// schema is the part of a Swagger 2.0 schema object the models are built
+// from
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*schema `json:"properties"`
+	Items                *schema            `json:"items"`
+	AllOf                []*schema          `json:"allOf"`
+	Enum                 []interface{}      `json:"enum"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+}
+
+// loader reads the spec and the documents its references point to. Strava
+// splits its spec into a file per resource, e.g. activity.json, referenced
+// by URL from swagger.json.
+type loader struct {
+	httpClient *http.Client
+	documents  map[string]map[string]json.RawMessage
+}
+
+func newLoader() *loader {
+	return &loader{
+		httpClient: &http.Client{Timeout: time.Minute},
+		documents:  make(map[string]map[string]json.RawMessage),
+	}
+}
+
+// document returns the top level of the document at location, a URL or a
+// file path
+func (l *loader) document(location string) (map[string]json.RawMessage, error) {
+	if doc, ok := l.documents[location]; ok {
+		return doc, nil
+	}
+
+	var data []byte
+	var err error
+	if isURL(location) {
+		data, err = l.fetch(location)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	l.documents[location] = doc
+	return doc, nil
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func (l *loader) fetch(u string) ([]byte, error) {
+	res, err := l.httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", u, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// resolve returns the document location and JSON pointer of ref, relative
+// to the document at base
+func resolve(base, ref string) (string, []string, error) {
+	location, pointer, _ := strings.Cut(ref, "#")
+	switch {
+	case location == "":
+		location = base
+	case !isURL(base) && !isURL(location):
+		// URL resolution would root a relative file path
+		location = filepath.Join(filepath.Dir(base), location)
+	default:
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", nil, err
+		}
+		r, err := url.Parse(location)
+		if err != nil {
+			return "", nil, err
+		}
+		location = b.ResolveReference(r).String()
+	}
+	return location, strings.Split(strings.Trim(pointer, "/"), "/"), nil
+}
+
+// lookup returns the schema ref points to and the name of its type, the
+// last element of its pointer, e.g. DetailedActivity for
+// activity.json#/DetailedActivity
+func (l *loader) lookup(base, ref string) (*schema, string, string, error) {
+	location, pointer, err := resolve(base, ref)
+	if err != nil {
+		return nil, "", "", err
+	}
+	doc, err := l.document(location)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	raw, ok := doc[pointer[0]]
+	for _, key := range pointer[1:] {
+		if !ok {
+			break
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, "", "", fmt.Errorf("%s: %w", ref, err)
+		}
+		raw, ok = object[key]
+	}
+	if !ok {
+		return nil, "", "", fmt.Errorf("%s: not found in %s", ref, location)
+	}
+
+	var s schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, "", "", fmt.Errorf("%s: %w", ref, err)
+	}
+	return &s, pointer[len(pointer)-1], location, nil
+}
+
+// references returns every $ref in a document, in the order found
+func references(raw json.RawMessage) []string {
+	refs := make([]string, 0)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				refs = append(refs, ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	var v interface{}
+	if json.Unmarshal(raw, &v) == nil {
+		walk(v)
+	}
+	return refs
+}
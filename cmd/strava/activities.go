@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// derivedTables hold rows computed from a single cached activity, removed
+// along with it
+var derivedTables = []string{"segment_prs", "best_efforts", "best_effort_checks", "training_load",
+	"stream_peaks", "power_curves", "activity_calories", "activity_devices", "activity_weather",
+	"activity_splits", "activity_streams", "stream_samples", "hydration_queue", "deleted_activities"}
+
+// deleteActivity removes an activity and everything derived from it
+func (c *activityCache) deleteActivity(id int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM activities WHERE id = ?`, id); err != nil {
+		return err
+	}
+	for _, table := range derivedTables {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE activity_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// deleteActivities deletes each activity from Strava and then the cache,
+// stopping at the first failure
+func deleteActivities(ctx context.Context, client strava.ClientInterface, cache *activityCache, activities []strava.Activity) error {
+	for _, a := range activities {
+		if err := client.DeleteActivity(ctx, int64(a.Id)); err != nil {
+			return fmt.Errorf("deleting activity %d: %w", a.Id, err)
+		}
+		if err := cache.deleteActivity(a.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readIDs parses activity IDs from args and, when path is set, from a file
+// with one ID per line. Blank lines and lines starting with # are skipped.
+func readIDs(args []string, path string) ([]int, error) {
+	lines := append([]string{}, args...)
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]int, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// accept "ID anything" so the output of `activities short` can be
+		// used as the list
+		field := strings.Fields(line)[0]
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid activity ID %q", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// shortActivities returns activities with an elapsed time under limit,
+// usually a recording started and stopped by accident
+func shortActivities(activities []strava.Activity, limit time.Duration) []strava.Activity {
+	short := make([]strava.Activity, 0)
+	for _, a := range activities {
+		if time.Duration(a.ElapsedTime)*time.Second < limit {
+			short = append(short, a)
+		}
+	}
+	return short
+}
+
+func newActivitiesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "activities",
+		Short: "Manage activities on Strava",
+	}
+
+	cmd.AddCommand(newActivitiesListCmd())
+	cmd.AddCommand(newActivitiesCreateCmd())
+	cmd.AddCommand(newActivitiesDeleteCmd())
+	cmd.AddCommand(newActivitiesShortCmd())
+
+	return cmd
+}
+
+func newActivitiesListCmd() *cobra.Command {
+	var formatTemplate string
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List cached activities, optionally matching a --filter expression",
+		Long: `Lists the cached activities selected by --sport, --gear, --after, --before,
+--match, and --filter, an expression over the activity's API fields such as
+
+  type == "Run" && distance > 10000 && start_date_local.year() == 2024
+
+See the README for the operators and methods available. --format-template
+prints each activity with a Go template instead of the table, for example
+--format-template '{{.Id}},{{.Type}},{{distance .Distance}}'.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			var tmpl *template.Template
+			if formatTemplate != "" {
+				if tmpl, err = parseFormatTemplate(formatTemplate, config.Settings.Output); err != nil {
+					logger.Fatal(err)
+				}
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			activities, err := cache.activities()
+			cache.Close()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			selected, err := filterActivities(expr, activities)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			if tmpl != nil {
+				for _, a := range selected {
+					if err := writeTemplate(os.Stdout, tmpl, a); err != nil {
+						logger.Fatal(err)
+					}
+				}
+				logger.Printf("Listed %d activities\n", len(selected))
+				return
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tDATE\tTYPE\tNAME\tDISTANCE\tMOVING")
+			for _, a := range selected {
+				distance, unit := config.Settings.Output.convert(a.Distance)
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.2f %s\t%s\n", a.Id, a.StartDate, a.Type, a.Name, distance, unit, formatDuration(a.MovingTime))
+			}
+			w.Flush()
+			logger.Printf("Listed %d activities\n", len(selected))
+		},
+	}
+
+	selection = addSelectionFlags(cmd)
+	cmd.Flags().StringVar(&formatTemplate, "format-template", "", "Go template executed per activity instead of the table")
+
+	return cmd
+}
+
+func newActivitiesDeleteCmd() *cobra.Command {
+	var idsFile string
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "delete [id...]",
+		Short: "Permanently delete activities from Strava",
+		Long: `Deletes the activities listed as arguments or in --ids-file, one ID per
+line, from Strava and the cache. Only activities named explicitly are
+deleted, and each must be in the cache, so sync first. The activities are
+listed and confirmation is asked for, unless --yes.
+
+Deleting cannot be undone and needs the activity:write scope. Strava only
+allows some applications to delete activities; others get an error.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			ids, err := readIDs(args, idsFile)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if len(ids) == 0 {
+				logger.Fatal("List the activities to delete as arguments or with --ids-file")
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			byID := make(map[int]strava.Activity, len(activities))
+			for _, a := range activities {
+				byID[a.Id] = a
+			}
+			selected := make([]strava.Activity, 0, len(ids))
+			seen := make(map[int]bool)
+			for _, id := range ids {
+				a, ok := byID[id]
+				if !ok {
+					logger.Fatalf("Activity %d is not in the cache\n", id)
+				}
+				if !seen[id] {
+					selected = append(selected, a)
+					seen[id] = true
+				}
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tDATE\tTYPE\tNAME\tELAPSED")
+			for _, a := range selected {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", a.Id, a.StartDate, a.Type, a.Name, formatDuration(a.ElapsedTime))
+			}
+			w.Flush()
+
+			if !yes && !confirm(os.Stdin, os.Stderr, fmt.Sprintf("Permanently delete %d activities from Strava?", len(selected))) {
+				logger.Fatal("Aborted")
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			if err := deleteActivities(ctx, client, cache, selected); err != nil {
+				logger.Fatal(err)
+			}
+			if viper.GetBool("dry-run") {
+				logger.Printf("Would delete %d activities\n", len(selected))
+			} else {
+				logger.Printf("Deleted %d activities\n", len(selected))
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&idsFile, "ids-file", "", "file with one activity ID per line")
+	cmd.Flags().BoolVar(&yes, "yes", false, "do not ask for confirmation")
+
+	return cmd
+}
+
+func newActivitiesShortCmd() *cobra.Command {
+	var under time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "short",
+		Short: "List cached activities shorter than --under, such as accidental uploads",
+		Long: `Lists cached activities whose elapsed time is under --under, one per line
+starting with the activity ID, so the output can be reviewed and passed to
+activities delete --ids-file.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			short := shortActivities(activities, under)
+			for _, a := range short {
+				fmt.Printf("%d %s %s %q %s\n", a.Id, a.StartDate, a.Type, a.Name, formatDuration(a.ElapsedTime))
+			}
+			logger.Printf("Found %d activities under %s\n", len(short), under)
+		},
+	}
+
+	cmd.Flags().DurationVar(&under, "under", 10*time.Second, "largest elapsed time to list")
+
+	return cmd
+}
+
+func newActivitiesCreateCmd() *cobra.Command {
+	var name, sportType, start, description string
+	var elapsed time.Duration
+	var dist float64
+	var trainer, commute bool
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Log a manual activity on Strava, such as a treadmill session",
+		Long: `Creates a manual activity on Strava and adds it to the cache, printing its
+ID to stdout so scripts can pick it up. --start is the local start time and
+defaults to --elapsed before now, for logging a session that just ended.
+--distance is in the configured output units.
+
+Creating activities needs the activity:write scope.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			if name == "" || sportType == "" || elapsed <= 0 {
+				logger.Fatal("--name, --type, and --elapsed are required")
+			}
+
+			started := time.Now().Add(-elapsed)
+			if start != "" {
+				var err error
+				started, err = parseLocalTime(start)
+				if err != nil {
+					logger.Fatal(err)
+				}
+			}
+			meters := config.Settings.Output.toMeters(dist)
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			created, err := createActivity(ctx, client, cache, strava.CreatableActivity{
+				Name:           name,
+				SportType:      sportType,
+				StartDateLocal: started.Format("2006-01-02T15:04:05"),
+				ElapsedTime:    int(elapsed.Seconds()),
+				Distance:       meters,
+				Description:    description,
+				Trainer:        trainer,
+				Commute:        commute,
+			})
+			if err != nil {
+				logger.Fatal(err)
+			}
+			fmt.Println(created.Id)
+			if viper.GetBool("dry-run") {
+				logger.Printf("Would create %q\n", created.Name)
+			} else {
+				logger.Printf("Created %q (%d)\n", created.Name, created.Id)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "activity title")
+	cmd.Flags().StringVar(&sportType, "type", "", "sport type, e.g. Run, Walk, or VirtualRide")
+	cmd.RegisterFlagCompletionFunc("type", completeSport)
+	cmd.Flags().StringVar(&start, "start", "", "local start time, YYYY-MM-DDTHH:MM[:SS] (default --elapsed ago)")
+	cmd.Flags().DurationVar(&elapsed, "elapsed", 0, "elapsed time, e.g. 45m")
+	cmd.Flags().Float64Var(&dist, "distance", 0, "distance in the configured units")
+	cmd.Flags().StringVar(&description, "description", "", "activity description")
+	cmd.Flags().BoolVar(&trainer, "trainer", false, "mark as a trainer or treadmill activity")
+	cmd.Flags().BoolVar(&commute, "commute", false, "mark as a commute")
+
+	return cmd
+}
+
+// createActivity creates the manual activity on Strava and adds it to the
+// cache
+func createActivity(ctx context.Context, client strava.ClientInterface, cache *activityCache, activity strava.CreatableActivity) (strava.Activity, error) {
+	created, err := client.CreateActivity(ctx, activity)
+	if err != nil {
+		return strava.Activity{}, fmt.Errorf("creating %q: %w", activity.Name, err)
+	}
+	return created.Activity, cache.upsertActivities([]strava.Activity{created.Activity})
+}
+
+// parseLocalTime reads a local time with or without seconds
+func parseLocalTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("start must be YYYY-MM-DDTHH:MM[:SS], got %q", s)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// kilogramsPerPound converts the weights given with miles as the units
+const kilogramsPerPound = 0.45359237
+
+func newAthleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "athlete",
+		Short: "Show or update your Strava profile",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print your profile, with weight, FTP, clubs, and gear, as JSON",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			athlete, err := client.GetDetailedAthlete(ctx)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			printJSON(logger, athlete)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "weight <weight>",
+		Short: "Set your weight on Strava",
+		Long: `Sets the weight on your Strava profile, which Strava uses for calorie and
+power estimates, e.g. from a smart scale script. The weight is in pounds
+when output.units is miles and kilograms otherwise, or add kg or lb to say
+which, as in 72.5kg. FTP cannot be changed through the API.
+
+Updating the profile needs the profile:write scope.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			kg, err := parseWeight(args[0], config.Settings.Output)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			athlete, err := client.UpdateAthlete(ctx, strava.UpdatableAthlete{Weight: kg})
+			if err != nil {
+				logger.Fatalf("updating weight: %v", err)
+			}
+			logger.Printf("Weight set to %.1f kg (%.1f lb)\n", athlete.Weight, athlete.Weight/kilogramsPerPound)
+		},
+	})
+
+	return cmd
+}
+
+// parseWeight returns a weight such as 72.5, 72.5kg, or 160lb in kilograms,
+// taking a bare number in the configured units
+func parseWeight(s string, output outputSettings) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	pounds := output.Units != "km"
+	switch {
+	case strings.HasSuffix(s, "kg"):
+		s, pounds = strings.TrimSuffix(s, "kg"), false
+	case strings.HasSuffix(s, "lbs"):
+		s, pounds = strings.TrimSuffix(s, "lbs"), true
+	case strings.HasSuffix(s, "lb"):
+		s, pounds = strings.TrimSuffix(s, "lb"), true
+	}
+	weight, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || weight <= 0 {
+		return 0, fmt.Errorf("weight must be a positive number, optionally followed by kg or lb, got %q", s)
+	}
+	if pounds {
+		weight *= kilogramsPerPound
+	}
+	return weight, nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// earthRadius is the mean radius of the Earth in meters
+	earthRadius = 6371000.0
+	// maxSyncUpdates bounds the activity updates each rewrite makes in one
+	// sync, such as the first sync of a long history
+	maxSyncUpdates = 20
+)
+
+// tagRule marks the activities it matches as commutes or trainer rides,
+// mutes them by hiding them from followers' feeds, or sets their workout
+// type. Every criterion set on a rule must match.
+type tagRule struct {
+	Label   string `mapstructure:"label"`
+	Commute bool   `mapstructure:"commute"`
+	Trainer bool   `mapstructure:"trainer"`
+	Mute    bool   `mapstructure:"mute"`
+	// Workout is the workout type to set: race, long_run, or workout. It
+	// leaves activities that already have one alone.
+	Workout string `mapstructure:"workout"`
+
+	Name  string   `mapstructure:"name"`
+	Types []string `mapstructure:"types"`
+	// MinDistance and MaxDistance match activities at least and shorter
+	// than this many meters
+	MinDistance float64 `mapstructure:"min_distance"`
+	MaxDistance float64 `mapstructure:"max_distance"`
+	// MaxPace matches activities with a moving pace this fast or faster,
+	// as M:SS per kilometre
+	MaxPace string `mapstructure:"max_pace"`
+	// From and To bound the local start time as HH:MM. A To before From
+	// wraps past midnight.
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+	// Start and End must contain the first and last points of the route
+	Start *geofence `mapstructure:"start"`
+	End   *geofence `mapstructure:"end"`
+
+	namePattern *regexp.Regexp
+	from, to    int
+	// maxPace is MaxPace in seconds per kilometre
+	maxPace int
+}
+
+// geofence is a circle of radius meters around a point
+type geofence struct {
+	Lat    float64 `mapstructure:"lat"`
+	Lng    float64 `mapstructure:"lng"`
+	Radius float64 `mapstructure:"radius"`
+}
+
+func (r *tagRule) compile() error {
+	if !r.Commute && !r.Trainer && !r.Mute && r.Workout == "" {
+		return fmt.Errorf("autotag rule %q: set commute, trainer, mute, or workout", r.Label)
+	}
+	if r.Workout != "" && (!validWorkout(r.Workout) || r.Workout == strava.WorkoutDefault) {
+		return fmt.Errorf("autotag rule %q: unknown workout %q, expected race, long_run, or workout", r.Label, r.Workout)
+	}
+	if r.MinDistance < 0 || r.MaxDistance < 0 {
+		return fmt.Errorf("autotag rule %q: min_distance and max_distance must be positive", r.Label)
+	}
+	if r.MaxPace != "" {
+		var err error
+		if r.maxPace, err = parsePace(r.MaxPace); err != nil {
+			return fmt.Errorf("autotag rule %q: max_pace: %w", r.Label, err)
+		}
+	}
+	if r.Name != "" {
+		pattern, err := regexp.Compile("(?i)" + r.Name)
+		if err != nil {
+			return fmt.Errorf("autotag rule %q: invalid name pattern: %w", r.Label, err)
+		}
+		r.namePattern = pattern
+	}
+
+	r.from, r.to = -1, -1
+	if (r.From == "") != (r.To == "") {
+		return fmt.Errorf("autotag rule %q: from and to must be set together", r.Label)
+	}
+	if r.From != "" {
+		var err error
+		if r.from, err = parseClock(r.From); err != nil {
+			return fmt.Errorf("autotag rule %q: from: %w", r.Label, err)
+		}
+		if r.to, err = parseClock(r.To); err != nil {
+			return fmt.Errorf("autotag rule %q: to: %w", r.Label, err)
+		}
+	}
+
+	for _, fence := range []*geofence{r.Start, r.End} {
+		if fence != nil && fence.Radius <= 0 {
+			return fmt.Errorf("autotag rule %q: geofence radius must be positive", r.Label)
+		}
+	}
+
+	if r.namePattern == nil && len(r.Types) == 0 && r.MinDistance == 0 && r.MaxDistance == 0 && r.maxPace == 0 &&
+		r.from < 0 && r.Start == nil && r.End == nil {
+		return fmt.Errorf("autotag rule %q: set at least one of name, types, min_distance, max_distance, max_pace, from/to, start, or end", r.Label)
+	}
+	return nil
+}
+
+// parseClock returns the minutes since midnight of an HH:MM time
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("must be HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parsePace returns the seconds of an M:SS pace
+func parsePace(s string) (int, error) {
+	m, sec, ok := strings.Cut(s, ":")
+	minutes, err := strconv.Atoi(m)
+	if !ok || err != nil || minutes < 0 || len(sec) != 2 {
+		return 0, fmt.Errorf("must be M:SS, got %q", s)
+	}
+	seconds, err := strconv.Atoi(sec)
+	if err != nil || seconds < 0 || seconds > 59 || minutes*60+seconds == 0 {
+		return 0, fmt.Errorf("must be M:SS, got %q", s)
+	}
+	return minutes*60 + seconds, nil
+}
+
+// matches reports whether the rule applies to a
+func (r tagRule) matches(a strava.Activity) (bool, error) {
+	if r.namePattern != nil && !r.namePattern.MatchString(strings.TrimSpace(a.Name)) {
+		return false, nil
+	}
+	if len(r.Types) > 0 && !containsFold(r.Types, a.Type) {
+		return false, nil
+	}
+	if r.MinDistance > 0 && a.Distance < r.MinDistance {
+		return false, nil
+	}
+	if r.MaxDistance > 0 && a.Distance >= r.MaxDistance {
+		return false, nil
+	}
+	if r.maxPace > 0 && (a.Distance <= 0 || float64(a.MovingTime)/(a.Distance/1000) > float64(r.maxPace)) {
+		return false, nil
+	}
+
+	if r.from >= 0 {
+		// start_date_local carries the wall clock time of the activity
+		start, err := time.Parse(time.RFC3339, a.StartDateLocal)
+		if err != nil {
+			return false, nil
+		}
+		minute := start.Hour()*60 + start.Minute()
+		inside := minute >= r.from && minute <= r.to
+		if r.to < r.from {
+			inside = minute >= r.from || minute <= r.to
+		}
+		if !inside {
+			return false, nil
+		}
+	}
+
+	if r.Start != nil || r.End != nil {
+		points, err := a.Map.Points()
+		if err != nil {
+			return false, fmt.Errorf("activity %d: %w", a.Id, err)
+		}
+		if len(points) == 0 {
+			return false, nil
+		}
+		if r.Start != nil && !r.Start.contains(points[0]) {
+			return false, nil
+		}
+		if r.End != nil && !r.End.contains(points[len(points)-1]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (g geofence) contains(p strava.LatLng) bool {
+	return distance(strava.LatLng{g.Lat, g.Lng}, p) <= g.Radius
+}
+
+// distance is the great circle distance between two points in meters
+func distance(a, b strava.LatLng) float64 {
+	lat1, lat2 := a[0]*math.Pi/180, b[0]*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b[1] - a[1]) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// tagChange is an update an autotag rule would make to an activity
+type tagChange struct {
+	Activity strava.Activity
+	Rule     string
+	Update   strava.UpdatableActivity
+}
+
+func (c tagChange) String() string {
+	flags := make([]string, 0, 4)
+	if c.Update.Commute != nil {
+		flags = append(flags, "commute")
+	}
+	if c.Update.Trainer != nil {
+		flags = append(flags, "trainer")
+	}
+	if c.Update.HideFromHome != nil {
+		flags = append(flags, "muted")
+	}
+	if c.Update.WorkoutType != nil {
+		flags = append(flags, strava.Activity{WorkoutType: *c.Update.WorkoutType}.Workout())
+	}
+	return fmt.Sprintf("%d %s %q: %s (%s)", c.Activity.Id, c.Activity.StartDate, c.Activity.Name, strings.Join(flags, ", "), c.Rule)
+}
+
+// planTags returns the changes the first matching rule makes to each
+// activity, skipping flags that are already set
+func planTags(rules []tagRule, activities []strava.Activity) ([]tagChange, error) {
+	changes := make([]tagChange, 0)
+	yes := true
+	for _, a := range activities {
+		for _, rule := range rules {
+			ok, err := rule.matches(a)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			change := tagChange{Activity: a, Rule: rule.Label}
+			if rule.Commute && !a.Commute {
+				change.Update.Commute = &yes
+			}
+			if rule.Trainer && !a.Trainer {
+				change.Update.Trainer = &yes
+			}
+			if rule.Mute && !a.HideFromHome {
+				change.Update.HideFromHome = &yes
+			}
+			if rule.Workout != "" && a.Workout() == strava.WorkoutDefault {
+				if workoutType, ok := strava.WorkoutTypeFor(a.Type, rule.Workout); ok {
+					change.Update.WorkoutType = &workoutType
+				}
+			}
+			if change.Update.Commute != nil || change.Update.Trainer != nil || change.Update.HideFromHome != nil || change.Update.WorkoutType != nil {
+				changes = append(changes, change)
+			}
+			break
+		}
+	}
+	return changes, nil
+}
+
+// applyTags sends each change to Strava and refreshes the cached activity
+func applyTags(ctx context.Context, client strava.ClientInterface, cache *activityCache, changes []tagChange) error {
+	for _, change := range changes {
+		updated, err := client.UpdateActivity(ctx, int64(change.Activity.Id), change.Update)
+		if err != nil {
+			return fmt.Errorf("tagging activity %d: %w", change.Activity.Id, err)
+		}
+		if err := cache.upsertActivities([]strava.Activity{updated.Activity}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// autoTag applies the autotag rules to activities new in this sync.
+// Failures are logged rather than fatal, like PR tracking.
+func autoTag(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, rules []tagRule, added []strava.Activity) {
+	changes, err := planTags(rules, added)
+	if err == nil && len(changes) > maxSyncUpdates {
+		logger.Printf("Skipping auto-tagging of %d activities, run `tag` to catch up\n", len(changes))
+		return
+	}
+	if err == nil {
+		err = applyTags(ctx, client, cache, changes)
+	}
+	if err != nil {
+		logger.Printf("Auto-tagging: %v\n", err)
+		return
+	}
+	for _, change := range changes {
+		logger.Printf("Tagged %s\n", change)
+	}
+}
+
+func newTagCmd() *cobra.Command {
+	var after, before string
+
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "Mark cached activities as commutes, trainer rides, or races, or mute them, using the autotag rules",
+		Long: `Applies the autotag rules from the settings file to every cached activity
+started within --after and --before, setting commute, trainer,
+hide_from_home, or workout_type through the activity update endpoint. New activities are tagged during every sync; use
+this to preview rules with --dry-run, which prints the updates instead of
+sending them, or to tag older activities.
+
+Updating activities needs a refresh token with the activity:write scope.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			if len(config.Settings.Tags) == 0 {
+				logger.Fatal("No autotag rules in the settings file")
+			}
+
+			from, to, err := parseDateRange(after, before)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			selected := make([]strava.Activity, 0)
+			for _, a := range activities {
+				if inDateRange(a, from, to) {
+					selected = append(selected, a)
+				}
+			}
+
+			changes, err := planTags(config.Settings.Tags, selected)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			for _, change := range changes {
+				fmt.Println(change)
+			}
+			if len(changes) == 0 {
+				logger.Printf("%d activities to tag\n", len(changes))
+				return
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			if err := applyTags(ctx, client, cache, changes); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Tagged %d activities\n", len(changes))
+		},
+	}
+
+	cmd.Flags().StringVar(&after, "after", "", "first day to include, YYYY-MM-DD")
+	cmd.Flags().StringVar(&before, "before", "", "last day to include, YYYY-MM-DD")
+
+	return cmd
+}
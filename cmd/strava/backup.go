@@ -0,0 +1,317 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+func newBackupCmd() *cobra.Command {
+	var dir, archive string
+	var photos, refresh bool
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up activities, streams, photos, gear, and routes to a local directory",
+		Long: `Syncs the activity cache, then writes one folder per activity under
+<dir>/<athlete id>/activities/<id>/ with the detailed activity, its streams,
+a GPX track, and photos. Gear and routes (GPX and TCX) are saved alongside.
+Streams are kept at the resolution set under streams in the settings file.
+Activities already backed up are skipped, so re-runs only fetch what is new;
+--refresh fetches everything again. An interrupted backup resumes on the
+next run.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, _, _, err := getActivities(ctx, logger, client, cache, config.Settings.Fetch)
+			if err != nil {
+				fatal(logger, err)
+			}
+
+			athlete, err := client.GetAthlete(ctx)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			root := filepath.Join(dir, strconv.FormatInt(athlete.Id, 10))
+			if err := writeJSONFile(filepath.Join(root, "athlete.json"), athlete); err != nil {
+				logger.Fatal(err)
+			}
+
+			transport, err := newTransport(config.Settings.HTTP)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			b := &backup{
+				client:     client,
+				cache:      cache,
+				httpClient: &http.Client{Transport: transport},
+				root:       root,
+				photos:     photos,
+				refresh:    refresh,
+				streams:    config.Settings.Streams,
+				gear:       make(map[string]bool),
+			}
+
+			saved := 0
+			bulk := strava.WithPriority(ctx, strava.PriorityBulk)
+			for _, a := range activities {
+				done, err := b.activity(bulk, a)
+				if err != nil {
+					if ctx.Err() != nil {
+						logger.Fatal("Interrupted - rerun to resume the backup")
+					}
+					if errors.Is(err, strava.ErrDeferred) {
+						logger.Fatalf("Backed up %d new activities, %v - rerun to resume the backup\n", saved, err)
+					}
+					logger.Fatalf("activity %d: %v\n", a.Id, err)
+				}
+				if done {
+					saved++
+				}
+			}
+			logger.Printf("Backed up %d new activities (%d total)\n", saved, len(activities))
+
+			if err := b.saveGear(ctx); err != nil {
+				logger.Fatal(err)
+			}
+			routes, err := b.saveRoutes(ctx, logger)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Backed up %d gear and %d routes\n", len(b.gear), routes)
+
+			if archive != "" {
+				if err := writeTarGz(archive, dir); err != nil {
+					logger.Fatal(err)
+				}
+				logger.Printf("Archive written to %s\n", archive)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "backup", "directory to write the backup to")
+	cmd.Flags().StringVar(&archive, "archive", "", "also pack the backup directory into this .tar.gz file")
+	cmd.Flags().BoolVar(&photos, "photos", true, "download activity photos")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "fetch activities and routes that are already backed up again")
+
+	return cmd
+}
+
+type backup struct {
+	client     strava.ClientInterface
+	cache      *activityCache
+	httpClient *http.Client
+	root       string
+	photos     bool
+	refresh    bool
+	// streams sets the resolution of the streams written
+	streams streamSettings
+	// gear collects the gear used by backed up activities
+	gear map[string]bool
+}
+
+// activity backs up one activity, reporting whether anything was fetched.
+// activity.json is written last, so its presence marks a complete backup.
+func (b *backup) activity(ctx context.Context, a strava.Activity) (bool, error) {
+	dir := filepath.Join(b.root, "activities", strconv.Itoa(a.Id))
+	marker := filepath.Join(dir, "activity.json")
+
+	if !b.refresh {
+		if data, err := os.ReadFile(marker); err == nil {
+			var stored strava.DetailedActivity
+			if json.Unmarshal(data, &stored) == nil && stored.GearId != "" {
+				b.gear[stored.GearId] = true
+			}
+			return false, nil
+		}
+	}
+
+	detail, err := b.client.GetActivity(ctx, int64(a.Id), true)
+	if err != nil {
+		return false, err
+	}
+	if detail.GearId != "" {
+		b.gear[detail.GearId] = true
+	}
+
+	if b.refresh {
+		if err := b.cache.clearStreams(int64(a.Id)); err != nil {
+			return false, err
+		}
+	}
+	streams, err := storedStreams(ctx, b.client, b.cache, b.streams, int64(a.Id))
+	if err != nil {
+		return false, err
+	}
+	if err := writeJSONFile(filepath.Join(dir, "streams.json"), streams); err != nil {
+		return false, err
+	}
+
+	var gpx bytes.Buffer
+	switch err := writeActivityGPX(&gpx, detail, streams); {
+	case err == nil:
+		if err := writeFile(filepath.Join(dir, "track.gpx"), gpx.Bytes()); err != nil {
+			return false, err
+		}
+	case !errors.Is(err, errNoTrack):
+		return false, err
+	}
+
+	if detail.TotalPhotoCount > 0 {
+		photos, err := b.client.ListActivityPhotos(ctx, int64(a.Id), defaultPhotoSize)
+		if err != nil {
+			return false, err
+		}
+		if err := writeJSONFile(filepath.Join(dir, "photos.json"), photos); err != nil {
+			return false, err
+		}
+		if b.photos {
+			if _, err := downloadPhotos(ctx, b.httpClient, photos, filepath.Join(dir, "photos")); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, writeFile(marker, detail.Raw)
+}
+
+// saveGear refreshes the details of every gear seen, since distances change
+func (b *backup) saveGear(ctx context.Context) error {
+	for id := range b.gear {
+		gear, err := b.client.GetGear(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := writeJSONFile(filepath.Join(b.root, "gear", id+".json"), gear); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveRoutes exports every route as GPX and TCX, skipping routes already
+// saved unless refreshing
+func (b *backup) saveRoutes(ctx context.Context, logger *log.Logger) (int, error) {
+	routes := listAllRoutes(ctx, logger, b.client)
+	dir := filepath.Join(b.root, "routes")
+
+	for _, r := range routes {
+		for _, format := range []string{"gpx", "tcx"} {
+			path := filepath.Join(dir, routeFileName(r, format))
+			if _, err := os.Stat(path); err == nil && !b.refresh {
+				continue
+			}
+			file, err := b.client.ExportRoute(ctx, r.Id, format)
+			if err != nil {
+				return 0, err
+			}
+			if err := writeFile(path, file); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(routes), nil
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(data, '\n'))
+}
+
+// writeFile creates parent directories and replaces path atomically
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeTarGz packs the files under dir into a gzip compressed tarball
+func writeTarGz(path, dir string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// defaultBackfillLimit bounds the detail requests of one backfill run
+const defaultBackfillLimit = 100
+
+// runTypes are the activity types Strava computes best efforts for
+var runTypes = []string{"Run", "TrailRun", "VirtualRun"}
+
+// bestEffortRecord is a best effort over a standard distance, such as the
+// fastest 5k within a run
+type bestEffortRecord struct {
+	Name        string  `json:"name"`
+	Distance    float64 `json:"distance"`
+	ActivityId  int64   `json:"activity_id"`
+	EffortId    int64   `json:"effort_id"`
+	ElapsedTime int     `json:"elapsed_time"`
+	StartDate   string  `json:"start_date"`
+	// Previous is the record this effort beat, 0 for the first at the distance
+	Previous int `json:"previous,omitempty"`
+}
+
+// recordBestEfforts stores every best effort of a detailed activity and
+// returns the ones faster than all earlier efforts at the same distance.
+// The activity is marked as checked so backfills skip it.
+func (c *activityCache) recordBestEfforts(activity strava.DetailedActivity) ([]bestEffortRecord, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	records := make([]bestEffortRecord, 0)
+	for _, e := range activity.BestEfforts {
+		record := bestEffortRecord{
+			Name:        e.Name,
+			Distance:    e.Distance,
+			ActivityId:  int64(activity.Id),
+			EffortId:    e.Id,
+			ElapsedTime: e.ElapsedTime,
+			StartDate:   e.StartDate,
+		}
+
+		var best sql.NullInt64
+		err := tx.QueryRow(`SELECT MIN(elapsed_time) FROM best_efforts WHERE name = ? AND start_date < ?`,
+			record.Name, record.StartDate).Scan(&best)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := tx.Exec(`INSERT OR IGNORE INTO best_efforts (effort_id, name, distance, activity_id, elapsed_time, start_date)
+			VALUES (?, ?, ?, ?, ?, ?)`, record.EffortId, record.Name, record.Distance, record.ActivityId, record.ElapsedTime, record.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if n > 0 && (!best.Valid || int64(record.ElapsedTime) < best.Int64) {
+			if best.Valid {
+				record.Previous = int(best.Int64)
+			}
+			records = append(records, record)
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO best_effort_checks (activity_id) VALUES (?)`, activity.Id); err != nil {
+		return nil, err
+	}
+	return records, tx.Commit()
+}
+
+// bestEfforts returns the current record at every distance, shortest
+// first
+func (c *activityCache) bestEfforts() ([]bestEffortRecord, error) {
+	rows, err := c.db.Query(`SELECT b.name, b.distance, b.activity_id, b.effort_id, b.elapsed_time, b.start_date
+		FROM best_efforts b
+		WHERE b.effort_id = (SELECT effort_id FROM best_efforts WHERE name = b.name ORDER BY elapsed_time, start_date LIMIT 1)
+		ORDER BY b.distance`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]bestEffortRecord, 0)
+	for rows.Next() {
+		var r bestEffortRecord
+		if err := rows.Scan(&r.Name, &r.Distance, &r.ActivityId, &r.EffortId, &r.ElapsedTime, &r.StartDate); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// uncheckedRuns returns up to limit cached runs whose best efforts have not
+// been fetched yet, newest first
+func (c *activityCache) uncheckedRuns(limit int) ([]int64, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(runTypes)), ",")
+	args := make([]interface{}, 0, len(runTypes)+1)
+	for _, t := range runTypes {
+		args = append(args, t)
+	}
+	args = append(args, limit)
+
+	rows, err := c.db.Query(`SELECT id FROM activities
+		WHERE type IN (`+placeholders+`) AND id NOT IN (SELECT activity_id FROM best_effort_checks)
+			AND id NOT IN (SELECT activity_id FROM deleted_activities)
+		ORDER BY start_date DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func newRunningPRsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "running",
+		Short: "Show your fastest times over standard running distances",
+		Long: `Shows the fastest 400m, 1k, mile, 5k, 10k, half marathon and other
+standard distances found in your runs, with the activity that set each one.
+Records are collected from new runs when fetch.track_prs is enabled; run
+backfill once to cover older runs.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			records, err := cache.bestEfforts()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			printBestEfforts(records, config.Settings.Output)
+		},
+	}
+
+	var limit int
+	backfill := &cobra.Command{
+		Use:   "backfill",
+		Short: "Fetch best efforts for cached runs that have not been checked yet",
+		Long: `Fetches the details of cached runs, newest first, to collect their best
+efforts. Each run costs one request, so at most --limit runs are checked
+per invocation; repeat until it reports nothing left.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			ids, err := cache.uncheckedRuns(limit)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			efforts, checked := 0, 0
+			bulk := strava.WithPriority(ctx, strava.PriorityBulk)
+			for _, id := range ids {
+				activity, err := client.GetActivity(bulk, id, false)
+				if errors.Is(err, strava.ErrDeferred) {
+					logger.Println(err)
+					break
+				}
+				if err != nil {
+					logger.Fatalf("activity %d: %v\n", id, err)
+				}
+				if _, err := cache.recordBestEfforts(activity); err != nil {
+					logger.Fatal(err)
+				}
+				if err := cache.setCalories(activity); err != nil {
+					logger.Fatal(err)
+				}
+				if err := cache.setDevice(activity); err != nil {
+					logger.Fatal(err)
+				}
+				efforts += len(activity.BestEfforts)
+				checked++
+			}
+
+			remaining, err := cache.uncheckedRuns(1)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Checked %d runs with %d best efforts\n", checked, efforts)
+			if len(remaining) > 0 {
+				logger.Println("More runs remain, run backfill again")
+			}
+		},
+	}
+	backfill.Flags().IntVar(&limit, "limit", defaultBackfillLimit, "maximum runs to check")
+	cmd.AddCommand(backfill)
+
+	return cmd
+}
+
+func printBestEfforts(records []bestEffortRecord, output outputSettings) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DISTANCE\tTIME\tPACE\tDATE\tACTIVITY")
+	for _, r := range records {
+		distance, unit := output.convert(r.Distance)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", r.Name, formatDuration(r.ElapsedTime), formatPace(r.ElapsedTime, distance, unit), r.StartDate, r.ActivityId)
+	}
+	w.Flush()
+}
+
+// bestEffortSummary renders new running records for chat notifications
+func bestEffortSummary(records []bestEffortRecord) string {
+	s := ""
+	for _, r := range records {
+		s += fmt.Sprintf("\nNew %s PR: %s", r.Name, formatDuration(r.ElapsedTime))
+		if r.Previous > 0 {
+			s += fmt.Sprintf(" (was %s)", formatDuration(r.Previous))
+		}
+	}
+	return s
+}
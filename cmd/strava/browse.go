@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// browseSorts are the orders the browser cycles through with s
+var browseSorts = []struct {
+	name string
+	less func(a, b strava.Activity) bool
+}{
+	{"newest", func(a, b strava.Activity) bool { return a.StartDate > b.StartDate }},
+	{"distance", func(a, b strava.Activity) bool { return a.Distance > b.Distance }},
+	{"moving time", func(a, b strava.Activity) bool { return a.MovingTime > b.MovingTime }},
+	{"name", func(a, b strava.Activity) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }},
+}
+
+// browser is the state of the interactive activity browser. It reads
+// from the cache and only calls the API to show details or make changes.
+type browser struct {
+	ctx    context.Context
+	in     *bufio.Reader
+	out    *bufio.Writer
+	cache  *activityCache
+	output outputSettings
+	client strava.ClientInterface
+	authed bool
+
+	all, shown     []strava.Activity
+	cursor, offset int
+	sort           int
+	filter         string
+	status         string
+	width, height  int
+
+	// detail is the activity being viewed, nil while in the list
+	detail *strava.DetailedActivity
+}
+
+func newBrowseCmd() *cobra.Command {
+	var filter string
+
+	cmd := &cobra.Command{
+		Use:   "browse",
+		Short: "Browse cached activities in an interactive terminal UI",
+		Long: `Opens a full screen list of the cached activities. Move with the arrow keys
+or j and k, press / to filter with an expression (see activities list), s
+to change the sort order, and enter to view an activity with its laps.
+From the detail view, r renames the activity and g saves its GPS track as
+<id>.gpx. q goes back or quits.
+
+Details, renames, and GPX tracks call the API; browsing works offline.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			b := &browser{
+				ctx:    ctx,
+				in:     bufio.NewReader(os.Stdin),
+				out:    bufio.NewWriter(os.Stdout),
+				cache:  cache,
+				output: config.Settings.Output,
+				client: newClient(ctx, logger, config),
+				all:    activities,
+			}
+			if err := b.setFilter(filter); err != nil {
+				logger.Fatal(err)
+			}
+
+			restore, err := makeRaw(int(os.Stdin.Fd()))
+			if err != nil {
+				logger.Fatal(err)
+			}
+			err = b.run()
+			restore()
+			if err != nil {
+				logger.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&filter, "filter", "", "only show activities matching this expression")
+
+	return cmd
+}
+
+// run draws the screen and handles keys until the user quits
+func (b *browser) run() error {
+	// use the alternate screen and hide the cursor
+	fmt.Fprint(b.out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(b.out, "\x1b[?25h\x1b[?1049l")
+		b.out.Flush()
+	}()
+
+	for {
+		if w, h, err := terminalSize(int(os.Stdout.Fd())); err == nil {
+			b.width, b.height = w, h
+		} else {
+			b.width, b.height = 80, 24
+		}
+		if b.detail != nil {
+			b.drawDetail()
+		} else {
+			b.drawList()
+		}
+		if err := b.out.Flush(); err != nil {
+			return err
+		}
+
+		key, err := b.readKey()
+		if err != nil {
+			return err
+		}
+		if key == "ctrl-c" || key == "q" && b.detail == nil {
+			return nil
+		}
+		b.status = ""
+		if b.detail != nil {
+			b.detailKey(key)
+		} else {
+			b.listKey(key)
+		}
+	}
+}
+
+func (b *browser) listKey(key string) {
+	rows := b.listRows()
+	switch key {
+	case "up", "k":
+		b.cursor--
+	case "down", "j":
+		b.cursor++
+	case "pgup":
+		b.cursor -= rows
+	case "pgdown", " ":
+		b.cursor += rows
+	case "home", "g":
+		b.cursor = 0
+	case "end", "G":
+		b.cursor = len(b.shown) - 1
+	case "s":
+		b.sort = (b.sort + 1) % len(browseSorts)
+		b.applySort()
+	case "/":
+		if expr, ok := b.prompt("filter: ", b.filter); ok {
+			if err := b.setFilter(expr); err != nil {
+				// the first line, without the caret under the expression
+				b.status = strings.SplitN(err.Error(), "\n", 2)[0]
+			}
+		}
+	case "enter":
+		if len(b.shown) > 0 {
+			b.open(b.shown[b.cursor])
+		}
+	}
+	b.cursor = max(0, min(b.cursor, len(b.shown)-1))
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+rows {
+		b.offset = b.cursor - rows + 1
+	}
+}
+
+func (b *browser) detailKey(key string) {
+	switch key {
+	case "q", "esc", "backspace":
+		b.detail = nil
+	case "r":
+		name, ok := b.prompt("new name: ", b.detail.Name)
+		if !ok || name == "" || name == b.detail.Name {
+			return
+		}
+		updated, err := b.client.UpdateActivity(b.ctx, int64(b.detail.Id), strava.UpdatableActivity{Name: &name})
+		if err == nil {
+			err = b.cache.upsertActivities([]strava.Activity{updated.Activity})
+		}
+		if err != nil {
+			b.status = err.Error()
+			return
+		}
+		b.detail.Name = updated.Name
+		b.replace(updated.Activity)
+		b.status = "Renamed"
+	case "g":
+		b.status = b.saveGPX()
+	}
+}
+
+// connect authenticates on the first API call
+func (b *browser) connect() error {
+	if b.authed {
+		return nil
+	}
+	if err := b.client.Authenticate(b.ctx); err != nil {
+		return err
+	}
+	b.authed = true
+	return nil
+}
+
+// open fetches an activity with its laps for the detail view
+func (b *browser) open(a strava.Activity) {
+	b.status = "Loading..."
+	b.drawList()
+	b.out.Flush()
+
+	if err := b.connect(); err != nil {
+		b.status = err.Error()
+		return
+	}
+	detail, err := b.client.GetActivity(b.ctx, int64(a.Id), false)
+	if err != nil {
+		b.status = err.Error()
+		return
+	}
+	if len(detail.Laps) == 0 {
+		if laps, err := b.client.ListActivityLaps(b.ctx, int64(a.Id)); err == nil {
+			detail.Laps = laps
+		}
+	}
+	b.status = ""
+	b.detail = &detail
+}
+
+func (b *browser) saveGPX() string {
+	if err := b.connect(); err != nil {
+		return err.Error()
+	}
+	streams, err := b.client.GetActivityStreams(b.ctx, int64(b.detail.Id))
+	if err != nil {
+		return err.Error()
+	}
+	var gpx bytes.Buffer
+	if err := writeActivityGPX(&gpx, *b.detail, streams); err != nil {
+		if errors.Is(err, errNoTrack) {
+			return "This activity has no GPS track"
+		}
+		return err.Error()
+	}
+	path := fmt.Sprintf("%d.gpx", b.detail.Id)
+	if err := writeFile(path, gpx.Bytes()); err != nil {
+		return err.Error()
+	}
+	return "Saved " + path
+}
+
+// replace swaps an updated activity into the lists
+func (b *browser) replace(a strava.Activity) {
+	for _, list := range [][]strava.Activity{b.all, b.shown} {
+		for i := range list {
+			if list[i].Id == a.Id {
+				list[i] = a
+			}
+		}
+	}
+}
+
+func (b *browser) setFilter(source string) error {
+	expr, err := parseFilter(source)
+	if err != nil {
+		return err
+	}
+	shown, err := filterActivities(expr, b.all)
+	if err != nil {
+		return err
+	}
+	b.filter, b.shown = source, shown
+	b.cursor, b.offset = 0, 0
+	b.applySort()
+	return nil
+}
+
+func (b *browser) applySort() {
+	less := browseSorts[b.sort].less
+	sort.SliceStable(b.shown, func(i, j int) bool { return less(b.shown[i], b.shown[j]) })
+}
+
+// listRows is the number of activities that fit below the headers
+func (b *browser) listRows() int {
+	return max(1, b.height-4)
+}
+
+func (b *browser) drawList() {
+	fmt.Fprint(b.out, "\x1b[H\x1b[2J")
+	title := fmt.Sprintf("%d of %d activities, sorted by %s", len(b.shown), len(b.all), browseSorts[b.sort].name)
+	if b.filter != "" {
+		title += ", filter: " + b.filter
+	}
+	b.line("\x1b[1m" + b.fit(title) + "\x1b[0m")
+	b.line("\x1b[4m" + b.fit(fmt.Sprintf("%-20s  %-12s  %10s  %8s  %s", "DATE", "TYPE", "DISTANCE", "MOVING", "NAME")) + "\x1b[0m")
+
+	end := min(len(b.shown), b.offset+b.listRows())
+	for i := b.offset; i < end; i++ {
+		a := b.shown[i]
+		distance, unit := b.output.convert(a.Distance)
+		row := b.fit(fmt.Sprintf("%-20s  %-12s  %7.2f %-2s  %8s  %s", a.StartDate, a.Type, distance, unit, formatDuration(a.MovingTime), a.Name))
+		if i == b.cursor {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		b.line(row)
+	}
+	b.footer("↑/↓ move  enter details  / filter  s sort  q quit")
+}
+
+func (b *browser) drawDetail() {
+	a := b.detail
+	fmt.Fprint(b.out, "\x1b[H\x1b[2J")
+	b.line("\x1b[1m" + b.fit(a.Name) + "\x1b[0m")
+
+	distance, unit := b.output.convert(a.Distance)
+	elevation, elevationUnit := b.output.convertElevation(a.TotalElevationGain)
+	b.line(fmt.Sprintf("%s on %s", a.Type, a.StartDate))
+	b.line(fmt.Sprintf("Distance %.2f %s  Moving %s  Elapsed %s  Pace %s  Climbing %.0f %s", distance, unit,
+		formatDuration(a.MovingTime), formatDuration(a.ElapsedTime), formatPace(a.MovingTime, distance, unit), elevation, elevationUnit))
+	if a.Description != "" {
+		b.line(b.fit(strings.ReplaceAll(a.Description, "\n", " ")))
+	}
+	b.line("")
+
+	if len(a.Laps) > 0 {
+		b.line("\x1b[4m" + b.fit(fmt.Sprintf("%-4s  %10s  %8s  %10s  %s", "LAP", "DISTANCE", "MOVING", "PACE", "NAME")) + "\x1b[0m")
+		for i, lap := range a.Laps {
+			if i >= b.height-10 {
+				b.line(fmt.Sprintf("... %d more laps", len(a.Laps)-i))
+				break
+			}
+			d, u := b.output.convert(lap.Distance)
+			b.line(b.fit(fmt.Sprintf("%-4d  %7.2f %-2s  %8s  %10s  %s", lap.LapIndex, d, u, formatDuration(lap.MovingTime),
+				formatPace(lap.MovingTime, d, u), lap.Name)))
+		}
+	} else {
+		b.line("No laps")
+	}
+	b.footer("r rename  g save GPX  q back")
+}
+
+// line writes one screen line, ending raw mode's bare line feed with a
+// carriage return
+func (b *browser) line(s string) {
+	fmt.Fprint(b.out, s, "\r\n")
+}
+
+// footer writes the status and key help on the last two rows
+func (b *browser) footer(help string) {
+	fmt.Fprintf(b.out, "\x1b[%d;1H\x1b[K%s", b.height-1, b.fit(b.status))
+	fmt.Fprintf(b.out, "\x1b[%d;1H\x1b[K\x1b[2m%s\x1b[0m", b.height, b.fit(help))
+}
+
+// fit truncates s to the terminal width
+func (b *browser) fit(s string) string {
+	if utf8.RuneCountInString(s) <= b.width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max(0, b.width-1)]) + "…"
+}
+
+// prompt reads a line on the status row, starting from initial. It
+// reports false when cancelled with escape.
+func (b *browser) prompt(label, initial string) (string, bool) {
+	input := []rune(initial)
+	fmt.Fprint(b.out, "\x1b[?25h")
+	defer fmt.Fprint(b.out, "\x1b[?25l")
+	for {
+		fmt.Fprintf(b.out, "\x1b[%d;1H\x1b[K%s%s", b.height-1, label, string(input))
+		b.out.Flush()
+		key, err := b.readKey()
+		if err != nil {
+			return "", false
+		}
+		switch {
+		case key == "enter":
+			return strings.TrimSpace(string(input)), true
+		case key == "esc" || key == "ctrl-c":
+			return "", false
+		case key == "backspace":
+			if len(input) > 0 {
+				input = input[:len(input)-1]
+			}
+		case utf8.RuneCountInString(key) == 1:
+			input = append(input, []rune(key)...)
+		}
+	}
+}
+
+// readKey reads one key press, naming the special keys
+func (b *browser) readKey() (string, error) {
+	r, _, err := b.in.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch r {
+	case '\r', '\n':
+		return "enter", nil
+	case 3:
+		return "ctrl-c", nil
+	case 127, 8:
+		return "backspace", nil
+	case 27:
+		// a lone escape, or the start of an arrow or paging sequence
+		if b.in.Buffered() == 0 {
+			return "esc", nil
+		}
+		seq := make([]byte, 0, 4)
+		for b.in.Buffered() > 0 && len(seq) < 4 {
+			c, _ := b.in.ReadByte()
+			seq = append(seq, c)
+			if c >= 'A' && c <= 'Z' || c == '~' {
+				break
+			}
+		}
+		switch string(seq) {
+		case "[A", "OA":
+			return "up", nil
+		case "[B", "OB":
+			return "down", nil
+		case "[5~":
+			return "pgup", nil
+		case "[6~":
+			return "pgdown", nil
+		case "[H", "[1~", "OH":
+			return "home", nil
+		case "[F", "[4~", "OF":
+			return "end", nil
+		}
+		return "esc", nil
+	}
+	return string(r), nil
+}
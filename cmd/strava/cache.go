@@ -0,0 +1,434 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/viper"
+	_ "modernc.org/sqlite"
+)
+
+const defaultCachePath = "strava.db"
+
+// activityCache is the local SQLite copy of every activity fetched from
+// Strava. The raw API payload is kept alongside the indexed columns so
+// exports can include fields the activity struct does not model.
+type activityCache struct {
+	db *sql.DB
+	// includeDeleted keeps activities gone from Strava in eachActivity,
+	// as set by --include-deleted
+	includeDeleted bool
+	// copyDir holds the copy a --dry-run works on, removed on Close
+	copyDir string
+}
+
+func openCache(path string) (*activityCache, error) {
+	if path == "" {
+		path = defaultCachePath
+	}
+
+	// A dry run works on a copy, so nothing it records is kept
+	var copyDir string
+	if viper.GetBool("dry-run") {
+		var err error
+		if path, copyDir, err = copyCache(path); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS activities (
+		id           INTEGER PRIMARY KEY,
+		name         TEXT NOT NULL,
+		type         TEXT NOT NULL,
+		start_date   TEXT NOT NULL,
+		distance     REAL NOT NULL,
+		moving_time  INTEGER NOT NULL,
+		elapsed_time INTEGER NOT NULL,
+		raw          TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS segments (
+		id              INTEGER PRIMARY KEY,
+		name            TEXT NOT NULL,
+		activity_type   TEXT NOT NULL,
+		distance        REAL NOT NULL,
+		pr_elapsed_time INTEGER,
+		pr_date         TEXT,
+		effort_count    INTEGER NOT NULL DEFAULT 0,
+		updated_at      TEXT NOT NULL,
+		raw             TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS segment_prs (
+		effort_id     INTEGER PRIMARY KEY,
+		segment_id    INTEGER NOT NULL,
+		segment_name  TEXT NOT NULL,
+		activity_id   INTEGER NOT NULL,
+		elapsed_time  INTEGER NOT NULL,
+		start_date    TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS segment_prs_segment ON segment_prs (segment_id, start_date);
+	CREATE TABLE IF NOT EXISTS best_efforts (
+		effort_id     INTEGER PRIMARY KEY,
+		name          TEXT NOT NULL,
+		distance      REAL NOT NULL,
+		activity_id   INTEGER NOT NULL,
+		elapsed_time  INTEGER NOT NULL,
+		start_date    TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS best_efforts_name ON best_efforts (name, start_date);
+	CREATE TABLE IF NOT EXISTS best_effort_checks (
+		activity_id   INTEGER PRIMARY KEY
+	);
+	CREATE TABLE IF NOT EXISTS training_load (
+		activity_id   INTEGER PRIMARY KEY,
+		method        TEXT NOT NULL,
+		score         REAL NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS stream_peaks (
+		activity_id   INTEGER PRIMARY KEY,
+		start_date    TEXT NOT NULL,
+		watts_20m     REAL,
+		heartrate_20m REAL
+	);
+	CREATE TABLE IF NOT EXISTS power_curves (
+		activity_id   INTEGER PRIMARY KEY,
+		start_date    TEXT NOT NULL,
+		curve         TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS activity_calories (
+		activity_id   INTEGER PRIMARY KEY,
+		calories      REAL NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS activity_devices (
+		activity_id   INTEGER PRIMARY KEY,
+		device_name   TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS activity_weather (
+		activity_id   INTEGER PRIMARY KEY,
+		provider      TEXT NOT NULL,
+		temperature   REAL NOT NULL,
+		wind_speed    REAL NOT NULL,
+		conditions    TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS activity_splits (
+		activity_id   INTEGER PRIMARY KEY,
+		splits        TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS club_activities (
+		club_id      INTEGER NOT NULL,
+		key          TEXT NOT NULL,
+		first_seen   TEXT NOT NULL,
+		athlete      TEXT NOT NULL,
+		raw          TEXT NOT NULL,
+		PRIMARY KEY (club_id, key)
+	);
+	CREATE TABLE IF NOT EXISTS deleted_activities (
+		activity_id   INTEGER PRIMARY KEY,
+		deleted_at    TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS sync_runs (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind         TEXT NOT NULL,
+		started_at   TEXT NOT NULL,
+		finished_at  TEXT NOT NULL,
+		pages        INTEGER NOT NULL,
+		added        INTEGER NOT NULL,
+		updated      INTEGER NOT NULL,
+		api_calls    INTEGER NOT NULL,
+		error        TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS activity_changes (
+		id              INTEGER PRIMARY KEY AUTOINCREMENT,
+		sync_started_at TEXT NOT NULL,
+		activity_id     INTEGER NOT NULL,
+		name            TEXT NOT NULL,
+		kind            TEXT NOT NULL,
+		fields          TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS activity_changes_sync ON activity_changes (sync_started_at);
+	CREATE TABLE IF NOT EXISTS activity_streams (
+		activity_id  INTEGER PRIMARY KEY,
+		keys         TEXT NOT NULL,
+		resolution   TEXT NOT NULL,
+		samples      INTEGER NOT NULL,
+		fetched_at   TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS stream_samples (
+		activity_id     INTEGER NOT NULL,
+		idx             INTEGER NOT NULL,
+		time            INTEGER,
+		distance        REAL,
+		lat             REAL,
+		lng             REAL,
+		altitude        REAL,
+		velocity_smooth REAL,
+		heartrate       INTEGER,
+		cadence         INTEGER,
+		watts           INTEGER,
+		temp            INTEGER,
+		moving          INTEGER,
+		grade_smooth    REAL,
+		PRIMARY KEY (activity_id, idx)
+	) WITHOUT ROWID;
+	CREATE TABLE IF NOT EXISTS hydration_queue (
+		activity_id  INTEGER NOT NULL,
+		kind         TEXT NOT NULL,
+		queued_at    TEXT NOT NULL,
+		attempts     INTEGER NOT NULL DEFAULT 0,
+		error        TEXT NOT NULL DEFAULT '',
+		done_at      TEXT,
+		PRIMARY KEY (activity_id, kind)
+	);
+	CREATE TABLE IF NOT EXISTS sync_state (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	c := &activityCache{db: db, includeDeleted: viper.GetBool("include-deleted"), copyDir: copyDir}
+	apiUsage.attach(c)
+	return c, nil
+}
+
+// copyCache copies the cache at path into a temporary directory, returning
+// the copy's path and the directory
+func copyCache(path string) (string, string, error) {
+	dir, err := os.MkdirTemp("", "strava-dry-run")
+	if err != nil {
+		return "", "", err
+	}
+	copyPath := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return copyPath, dir, nil
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	defer db.Close()
+	if _, err := db.Exec(`VACUUM INTO ?`, copyPath); err != nil {
+		os.RemoveAll(dir)
+		return "", "", fmt.Errorf("copying the cache for a dry run: %w", err)
+	}
+	return copyPath, dir, nil
+}
+
+func (c *activityCache) Close() error {
+	apiUsage.detach(c)
+	err := c.db.Close()
+	if c.copyDir != "" {
+		os.RemoveAll(c.copyDir)
+	}
+	return err
+}
+
+// upsertActivities inserts new activities and refreshes existing ones in a
+// single transaction
+func (c *activityCache) upsertActivities(activities []strava.Activity) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO activities (id, name, type, start_date, distance, moving_time, elapsed_time, raw)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			type = excluded.type,
+			start_date = excluded.start_date,
+			distance = excluded.distance,
+			moving_time = excluded.moving_time,
+			elapsed_time = excluded.elapsed_time,
+			raw = excluded.raw`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	// An activity seen again was made public again or was never deleted
+	undelete, err := tx.Prepare(`DELETE FROM deleted_activities WHERE activity_id = ?`)
+	if err != nil {
+		return err
+	}
+	defer undelete.Close()
+
+	for _, a := range activities {
+		if _, err := undelete.Exec(a.Id); err != nil {
+			return err
+		}
+		raw := a.Raw
+		if len(raw) == 0 {
+			raw, err = json.Marshal(a)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := stmt.Exec(a.Id, a.Name, a.Type, a.StartDate, a.Distance, a.MovingTime, a.ElapsedTime, string(raw)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// eachActivity streams cached activities ordered by start date, calling fn
+// for each one without loading the whole table into memory. Activities no
+// longer on Strava are left out unless includeDeleted is set.
+func (c *activityCache) eachActivity(fn func(strava.Activity) error) error {
+	rows, err := c.db.Query(`SELECT raw FROM activities
+		WHERE ? OR id NOT IN (SELECT activity_id FROM deleted_activities)
+		ORDER BY start_date, id`, c.includeDeleted)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return err
+		}
+
+		var a strava.Activity
+		if err := json.Unmarshal([]byte(raw), &a); err != nil {
+			return err
+		}
+		a.Raw = json.RawMessage(raw)
+
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// missingActivities returns the activities that are not cached yet
+func (c *activityCache) missingActivities(activities []strava.Activity) ([]strava.Activity, error) {
+	missing := make([]strava.Activity, 0)
+	for _, a := range activities {
+		var exists int
+		err := c.db.QueryRow(`SELECT 1 FROM activities WHERE id = ?`, a.Id).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			missing = append(missing, a)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// upsertSegments stores the latest copy of each segment, including the
+// athlete's current PR on it
+func (c *activityCache) upsertSegments(segments []strava.Segment) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO segments (id, name, activity_type, distance, pr_elapsed_time, pr_date, effort_count, updated_at, raw)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			activity_type = excluded.activity_type,
+			distance = excluded.distance,
+			pr_elapsed_time = excluded.pr_elapsed_time,
+			pr_date = excluded.pr_date,
+			effort_count = excluded.effort_count,
+			updated_at = excluded.updated_at,
+			raw = excluded.raw`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, s := range segments {
+		raw, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		var prTime sql.NullInt64
+		var prDate sql.NullString
+		efforts := 0
+		if pr := s.AthletePREffort; pr != nil {
+			prTime = sql.NullInt64{Int64: int64(pr.PRElapsedTime), Valid: true}
+			prDate = sql.NullString{String: pr.PRDate, Valid: pr.PRDate != ""}
+			efforts = pr.EffortCount
+		}
+
+		if _, err := stmt.Exec(s.Id, s.Name, s.ActivityType, s.Distance, prTime, prDate, efforts, now, string(raw)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// activities returns every cached activity ordered by start date
+func (c *activityCache) activities() ([]strava.Activity, error) {
+	activities := make([]strava.Activity, 0)
+	err := c.eachActivity(func(a strava.Activity) error {
+		activities = append(activities, a)
+		return nil
+	})
+	return activities, err
+}
+
+// newestStart returns the start date of the newest cached activity, or ""
+// when the cache is empty
+func (c *activityCache) newestStart() (string, error) {
+	var start sql.NullString
+	err := c.db.QueryRow(`SELECT MAX(start_date) FROM activities`).Scan(&start)
+	return start.String, err
+}
+
+// state returns the sync state value stored under key, or "" when unset
+func (c *activityCache) state(key string) (string, error) {
+	var value string
+	err := c.db.QueryRow(`SELECT value FROM sync_state WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// addState adds n to the integer stored under key, in a single statement so
+// processes sharing the cache do not lose each other's counts
+func (c *activityCache) addState(key string, n int) error {
+	_, err := c.db.Exec(`INSERT INTO sync_state (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = CAST(value AS INTEGER) + CAST(excluded.value AS INTEGER)`, key, strconv.Itoa(n))
+	return err
+}
+
+// setState stores value under key, removing the key when value is empty
+func (c *activityCache) setState(key, value string) error {
+	if value == "" {
+		_, err := c.db.Exec(`DELETE FROM sync_state WHERE key = ?`, key)
+		return err
+	}
+	_, err := c.db.Exec(`INSERT INTO sync_state (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
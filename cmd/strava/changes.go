@@ -0,0 +1,260 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// fieldChange is one field of an activity that differs from the cached copy
+type fieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// activityChange is an activity edited or deleted on Strava since it was
+// cached. Kind is updated, deleted, or restored for one seen again after
+// it was marked deleted.
+type activityChange struct {
+	ID     int           `json:"id"`
+	Name   string        `json:"name"`
+	Kind   string        `json:"kind"`
+	Fields []fieldChange `json:"fields,omitempty"`
+}
+
+func (c activityChange) String() string {
+	return fmt.Sprintf("%q (%d) %s", c.Name, c.ID, c.describe())
+}
+
+// describe is the kind or the changed fields, e.g. "name Run -> Tempo run"
+func (c activityChange) describe() string {
+	fields := make([]string, len(c.Fields))
+	for i, f := range c.Fields {
+		fields[i] = fmt.Sprintf("%s %v -> %v", f.Field, f.Old, f.New)
+	}
+	switch {
+	case c.Kind != "updated" && len(fields) > 0:
+		return c.Kind + ", " + strings.Join(fields, ", ")
+	case c.Kind != "updated":
+		return c.Kind
+	}
+	return strings.Join(fields, ", ")
+}
+
+// diffActivity lists the fields that matter to the totals and titles that
+// differ between the cached and fetched copies. Social counts are left
+// out, as they change all the time.
+func diffActivity(old, updated strava.Activity) []fieldChange {
+	changes := make([]fieldChange, 0)
+	add := func(field string, o, n interface{}) {
+		if o != n {
+			changes = append(changes, fieldChange{Field: field, Old: o, New: n})
+		}
+	}
+	add("name", old.Name, updated.Name)
+	add("type", old.Type, updated.Type)
+	add("start_date", old.StartDate, updated.StartDate)
+	add("distance", old.Distance, updated.Distance)
+	add("moving_time", old.MovingTime, updated.MovingTime)
+	add("elapsed_time", old.ElapsedTime, updated.ElapsedTime)
+	add("total_elevation_gain", old.TotalElevationGain, updated.TotalElevationGain)
+	return changes
+}
+
+// activityChanges compares the activities against their cached copies and
+// returns the ones that were edited or were marked deleted. Activities not
+// cached yet are skipped.
+func (c *activityCache) activityChanges(activities []strava.Activity) ([]activityChange, error) {
+	changes := make([]activityChange, 0)
+	for _, a := range activities {
+		var raw string
+		var deleted bool
+		err := c.db.QueryRow(`SELECT raw, id IN (SELECT activity_id FROM deleted_activities) FROM activities WHERE id = ?`, a.Id).Scan(&raw, &deleted)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var cached strava.Activity
+		if err := json.Unmarshal([]byte(raw), &cached); err != nil {
+			return nil, err
+		}
+		fields := diffActivity(cached, a)
+		switch {
+		case deleted:
+			changes = append(changes, activityChange{ID: a.Id, Name: a.Name, Kind: "restored", Fields: fields})
+		case len(fields) > 0:
+			changes = append(changes, activityChange{ID: a.Id, Name: a.Name, Kind: "updated", Fields: fields})
+		}
+	}
+	return changes, nil
+}
+
+// unseenActivities returns the cached activities not yet marked deleted
+// that are missing from seen as deletions, along with the number of those
+// cached
+func (c *activityCache) unseenActivities(seen map[int]bool) ([]activityChange, int, error) {
+	rows, err := c.db.Query(`SELECT id, name FROM activities WHERE id NOT IN (SELECT activity_id FROM deleted_activities)`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	changes := make([]activityChange, 0)
+	cached := 0
+	for rows.Next() {
+		var change activityChange
+		if err := rows.Scan(&change.ID, &change.Name); err != nil {
+			return nil, 0, err
+		}
+		cached++
+		if !seen[change.ID] {
+			change.Kind = "deleted"
+			changes = append(changes, change)
+		}
+	}
+	return changes, cached, rows.Err()
+}
+
+// markDeleted soft-deletes the activities
+func (c *activityCache) markDeleted(changes []activityChange, now time.Time) error {
+	for _, change := range changes {
+		_, err := c.db.Exec(`INSERT INTO deleted_activities (activity_id, deleted_at) VALUES (?, ?) ON CONFLICT(activity_id) DO NOTHING`,
+			change.ID, now.UTC().Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markUnseenDeleted marks the cached activities a complete sync did not
+// return as deleted, since they were deleted or made private on Strava,
+// and returns them. They stay in the cache but are left out of reports
+// and exports unless --include-deleted is given. Nothing is marked when
+// that would be most of the cache, which points at another athlete's token
+// or one missing the activity:read_all scope rather than at deletions.
+func markUnseenDeleted(logger *log.Logger, cache *activityCache, seen map[int]bool) ([]activityChange, error) {
+	deleted, cached, err := cache.unseenActivities(seen)
+	if err != nil {
+		return nil, err
+	}
+	if len(deleted) > cached/2 {
+		logger.Printf("Sync did not return %d of %d cached activities, keeping them\n", len(deleted), cached)
+		return nil, nil
+	}
+	return deleted, cache.markDeleted(deleted, time.Now())
+}
+
+// recordChanges adds the changes found by the sync started at started to
+// the changelog
+func (c *activityCache) recordChanges(started time.Time, changes []activityChange) error {
+	for _, change := range changes {
+		fields, err := json.Marshal(change.Fields)
+		if err != nil {
+			return err
+		}
+		_, err = c.db.Exec(`INSERT INTO activity_changes (sync_started_at, activity_id, name, kind, fields) VALUES (?, ?, ?, ?, ?)`,
+			started.UTC().Format(time.RFC3339Nano), change.ID, change.Name, change.Kind, string(fields))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changelogEntry is a recorded change with the sync that found it
+type changelogEntry struct {
+	SyncStarted time.Time
+	activityChange
+}
+
+// recentChanges returns the changes found by the last syncs that found any,
+// newest first
+func (c *activityCache) recentChanges(syncs int) ([]changelogEntry, error) {
+	rows, err := c.db.Query(`SELECT sync_started_at, activity_id, name, kind, fields FROM activity_changes
+		WHERE sync_started_at IN (SELECT DISTINCT sync_started_at FROM activity_changes ORDER BY sync_started_at DESC LIMIT ?)
+		ORDER BY sync_started_at DESC, id`, syncs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]changelogEntry, 0)
+	for rows.Next() {
+		var e changelogEntry
+		var started, fields string
+		if err := rows.Scan(&started, &e.ID, &e.Name, &e.Kind, &fields); err != nil {
+			return nil, err
+		}
+		e.SyncStarted, _ = time.Parse(time.RFC3339Nano, started)
+		if err := json.Unmarshal([]byte(fields), &e.Fields); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func newChangelogCmd() *cobra.Command {
+	var syncs int
+
+	cmd := &cobra.Command{
+		Use:   "changelog",
+		Short: "List activities edited or deleted on Strava, by the sync that noticed",
+		Long: `Every sync compares the activities it fetches with the cached copies and
+records the ones renamed or whose type, start, distance, times, or climbing
+changed. A full sync, which fetches every activity, also marks the
+cached activities Strava no longer returns as deleted, and ones that come
+back as restored.
+This lists those changes for the last syncs that found any.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			entries, err := cache.recentChanges(syncs)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SYNC\tID\tNAME\tCHANGE")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.SyncStarted.Local().Format("2006-01-02 15:04"), e.ID, e.Name, e.describe())
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().IntVarP(&syncs, "syncs", "n", 10, "number of syncs with changes to show")
+
+	return cmd
+}
+
+// changeSummary lists the changes for a Slack message
+func changeSummary(changes []activityChange) string {
+	s := ""
+	for _, c := range changes {
+		s += "\nChanged on Strava: " + c.String()
+	}
+	return s
+}
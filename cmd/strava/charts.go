@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/charts"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// Series colors for the fitness chart
+const (
+	fitnessColor = "#1f77b4"
+	fatigueColor = "#d62728"
+	formColor    = "#2ca02c"
+	targetColor  = "#7f7f7f"
+)
+
+// weeklyDistanceChart sums distance per week over the weeks ending with the
+// one containing now
+func weeklyDistanceChart(activities []strava.Activity, output outputSettings, weeks int, now time.Time) charts.Chart {
+	byStart := make(map[time.Time]float64)
+	for _, t := range aggregate(activities, periodWeek, nil) {
+		byStart[t.Start] = t.Distance
+	}
+
+	_, unit := output.convert(0)
+	chart := charts.Chart{Title: fmt.Sprintf("Weekly distance, last %d weeks", weeks), Unit: unit}
+	values := make([]float64, 0, weeks)
+	first := periodStart(now.Local(), periodWeek).AddDate(0, 0, -7*(weeks-1))
+	for i := 0; i < weeks; i++ {
+		start := first.AddDate(0, 0, 7*i)
+		distance, _ := output.convert(byStart[start])
+		chart.Labels = append(chart.Labels, start.Format("Jan 2"))
+		values = append(values, distance)
+	}
+	chart.Series = []charts.Series{{Name: "Distance", Values: values, Bars: true}}
+	return chart
+}
+
+// goalChart plots the cumulative distance or climbing of the current
+// period against a straight line to the goal's target. It reports false
+// for goals on other metrics.
+func goalChart(g goalSettings, activities []strava.Activity, output outputSettings, now time.Time) (charts.Chart, bool) {
+	if g.Metric != goalDistance && g.Metric != goalElevation {
+		return charts.Chart{}, false
+	}
+	start := periodStart(now.Local(), g.Period)
+	end := start.AddDate(0, 0, 7)
+	switch g.Period {
+	case periodMonth:
+		end = start.AddDate(0, 1, 0)
+	case periodYear:
+		end = start.AddDate(1, 0, 0)
+	}
+
+	daily := make(map[string]float64)
+	for _, a := range activities {
+		if len(g.Types) > 0 && !containsFold(g.Types, a.Type) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			continue
+		}
+		amount := a.Distance
+		if g.Metric == goalElevation {
+			amount = a.TotalElevationGain
+		}
+		daily[t.Local().Format(time.DateOnly)] += amount
+	}
+
+	label := g.Label
+	if label == "" {
+		label = g.Metric + " per " + g.Period
+	}
+	convert := output.convert
+	if g.Metric == goalElevation {
+		convert = output.convertElevation
+	}
+	_, unit := convert(0)
+	chart := charts.Chart{Title: label, Unit: unit}
+
+	days := int(math.Round(end.Sub(start).Hours() / 24))
+	actual := make([]float64, 0, days)
+	target := make([]float64, 0, days)
+	total := 0.0
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		chart.Labels = append(chart.Labels, day.Format("Jan 2"))
+		target = append(target, g.Target*float64(i+1)/float64(days))
+		if day.After(now) {
+			actual = append(actual, math.NaN())
+			continue
+		}
+		total += daily[day.Format(time.DateOnly)]
+		value, _ := convert(total)
+		actual = append(actual, value)
+	}
+	chart.Series = []charts.Series{
+		{Name: "Actual", Values: actual},
+		{Name: "Target", Values: target, Color: targetColor, Dashed: true},
+	}
+	return chart, true
+}
+
+// fitnessChart plots fitness, fatigue, and form over the given days
+func fitnessChart(curve []fitnessDay) charts.Chart {
+	chart := charts.Chart{Title: fmt.Sprintf("Fitness and fatigue, last %d days", len(curve)), Unit: "load"}
+	ctl := make([]float64, len(curve))
+	atl := make([]float64, len(curve))
+	tsb := make([]float64, len(curve))
+	for i, d := range curve {
+		chart.Labels = append(chart.Labels, d.Date.Format("Jan 2"))
+		ctl[i], atl[i], tsb[i] = d.CTL, d.ATL, d.TSB
+	}
+	chart.Series = []charts.Series{
+		{Name: "Fitness", Values: ctl, Color: fitnessColor},
+		{Name: "Fatigue", Values: atl, Color: fatigueColor},
+		{Name: "Form", Values: tsb, Color: formColor, Dashed: true},
+	}
+	return chart
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug turns a label into a file name
+func slug(label string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(label), "-"), "-")
+}
+
+// writeChart renders a chart to path in the format given by its extension
+func writeChart(path string, chart charts.Chart) error {
+	if filepath.Ext(path) == ".png" {
+		data, err := chart.PNG()
+		if err != nil {
+			return err
+		}
+		return writeFile(path, data)
+	}
+	return writeFile(path, chart.SVG())
+}
+
+func newChartsReportCmd() *cobra.Command {
+	var dir, format string
+	var visibility []string
+	var weeks, days int
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "charts",
+		Short: "Draw weekly distance, goal, and fitness charts as SVG or PNG",
+		Long: `Writes charts of the cached activities to --dir for embedding in Markdown
+reports and web pages:
+
+  weekly-distance   distance per week over the last --weeks weeks
+  goal-<label>      cumulative progress against each distance or elevation
+                    goal for the current period
+  fitness           fitness, fatigue, and form over the last --days days,
+                    from the training loads stored by the fitness command
+
+Charts are drawn from the cache without calling the API.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+			if format != "svg" && format != "png" {
+				logger.Fatalf("unknown chart format %q, expected svg or png\n", format)
+			}
+			if weeks < 1 || days < 1 {
+				logger.Fatal("--weeks and --days must be positive")
+			}
+
+			expr, err := selection.filter()
+			if err == nil {
+				expr, err = expr.withVisibility(visibility)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if activities, err = filterActivities(expr, activities); err != nil {
+				logger.Fatal(err)
+			}
+
+			now := time.Now()
+			output := config.Settings.Output
+			written := make([]string, 0)
+			write := func(name string, chart charts.Chart) {
+				path := filepath.Join(dir, name+"."+format)
+				if err := writeChart(path, chart); err != nil {
+					logger.Fatal(err)
+				}
+				written = append(written, path)
+			}
+
+			write("weekly-distance", weeklyDistanceChart(activities, output, weeks, now))
+			for _, g := range config.Settings.Goals {
+				if chart, ok := goalChart(g, activities, output, now); ok {
+					write("goal-"+slug(chart.Title), chart)
+				}
+			}
+
+			loads, err := cache.trainingLoads()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if len(loads) > 0 {
+				curve := fitnessCurve(activities, loads, now.UTC())
+				if len(curve) > days {
+					curve = curve[len(curve)-days:]
+				}
+				write("fitness", fitnessChart(curve))
+			}
+
+			for _, path := range written {
+				fmt.Println(path)
+			}
+			logger.Printf("Drew %d charts in %s\n", len(written), dir)
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "charts", "directory to write the charts to")
+	cmd.Flags().StringVar(&format, "format", "svg", "image format: svg or png")
+	cmd.Flags().IntVar(&weeks, "weeks", 12, "weeks of weekly distance to draw")
+	cmd.Flags().IntVar(&days, "days", 90, "days of fitness and fatigue to draw")
+	selection = addSelectionFlags(cmd)
+	cmd.Flags().StringSliceVar(&visibility, "visibility", nil, "only include activities with these visibilities: everyone, followers_only, only_me (default all)")
+
+	return cmd
+}
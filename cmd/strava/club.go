@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+func newClubCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "club",
+		Short: "List your clubs and their members and activities",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the clubs you belong to",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tSPORT\tMEMBERS\tCITY")
+			for page := 1; ; page++ {
+				clubs, err := client.ListAthleteClubs(ctx, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+				if err != nil {
+					logger.Fatal(err)
+				}
+				for _, c := range clubs {
+					fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", c.Id, c.Name, c.SportType, c.MemberCount, c.City)
+				}
+				if len(clubs) < strava.MaxPerPage {
+					break
+				}
+			}
+			w.Flush()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <club id>",
+		Short: "Print a club as JSON",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			id := parseClubID(logger, args[0])
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			club, err := client.GetClub(ctx, id)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			printJSON(logger, club)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "members <club id>",
+		Short: "List a club's members",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			id := parseClubID(logger, args[0])
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tROLE")
+			for page := 1; ; page++ {
+				members, err := client.ListClubMembers(ctx, id, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+				if err != nil {
+					logger.Fatal(err)
+				}
+				for _, m := range members {
+					role := "member"
+					if m.Owner {
+						role = "owner"
+					} else if m.Admin {
+						role = "admin"
+					}
+					fmt.Fprintf(w, "%s %s\t%s\n", m.Firstname, m.Lastname, role)
+				}
+				if len(members) < strava.MaxPerPage {
+					break
+				}
+			}
+			w.Flush()
+		},
+	})
+
+	var limit int
+	activities := &cobra.Command{
+		Use:   "activities <club id>",
+		Short: "List recent activities by club members",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			id := parseClubID(logger, args[0])
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			feed := listClubActivities(ctx, logger, client, id, limit)
+
+			// Remember the feed so leaderboards can date these activities
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+			if err := cache.recordClubActivities(id, feed, time.Now()); err != nil {
+				logger.Fatal(err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ATHLETE\tNAME\tTYPE\tDISTANCE\tMOVING TIME\tELEVATION")
+			for _, a := range feed {
+				distance, unit := config.Settings.Output.convert(a.Distance)
+				fmt.Fprintf(w, "%s %s\t%s\t%s\t%.2f %s\t%s\t%.0f m\n", a.Athlete.Firstname, a.Athlete.Lastname, a.Name, a.Type,
+					distance, unit, formatDuration(a.MovingTime), a.TotalElevationGain)
+			}
+			w.Flush()
+		},
+	}
+	activities.Flags().IntVar(&limit, "limit", 200, "most recent activities to list")
+	cmd.AddCommand(activities)
+	cmd.AddCommand(newLeaderboardCmd())
+
+	return cmd
+}
+
+func parseClubID(logger *log.Logger, arg string) int64 {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		logger.Fatalf("invalid club id %q\n", arg)
+	}
+	return id
+}
+
+// listClubActivities pages through a club's feed until limit activities
+// have been read or the feed ends
+func listClubActivities(ctx context.Context, logger *log.Logger, client strava.ClientInterface, id int64, limit int) []strava.ClubActivity {
+	all := make([]strava.ClubActivity, 0)
+	for page := 1; len(all) < limit; page++ {
+		batch, err := client.ListClubActivities(ctx, id, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		all = append(all, batch...)
+		if len(batch) < strava.MaxPerPage {
+			break
+		}
+	}
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all
+}
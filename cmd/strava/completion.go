@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+	"github.com/spf13/viper"
+)
+
+// sportTypes are the sport types Strava records, offered for completion
+// along with any other type found in the cache
+var sportTypes = []string{
+	"AlpineSki", "BackcountrySki", "Badminton", "Canoeing", "Crossfit", "EBikeRide", "Elliptical",
+	"EMountainBikeRide", "Golf", "GravelRide", "Handcycle", "HighIntensityIntervalTraining", "Hike",
+	"IceSkate", "InlineSkate", "Kayaking", "Kitesurf", "MountainBikeRide", "NordicSki", "Pickleball",
+	"Pilates", "Racquetball", "Ride", "RockClimbing", "RollerSki", "Rowing", "Run", "Sail", "Skateboard",
+	"Snowboard", "Snowshoe", "Soccer", "Squash", "StairStepper", "StandUpPaddling", "Surfing", "Swim",
+	"TableTennis", "Tennis", "TrailRun", "Velomobile", "VirtualRide", "VirtualRow", "VirtualRun", "Walk",
+	"WeightTraining", "Wheelchair", "Windsurf", "Workout", "Yoga",
+}
+
+// completeProfiles offers the profiles defined in the settings file
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	s, err := loadSettings(viper.GetString("config"))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	profiles := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	return profiles, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSport offers sport types, including those of the cached
+// activities
+func completeSport(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return sportCompletions(""), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSports completes the last entry of a list such as Run,Vi
+func completeSports(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := toComplete[:strings.LastIndex(toComplete, ",")+1]
+	return sportCompletions(prefix), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// sportCompletions returns the sport types not listed in prefix yet, each
+// appended to it
+func sportCompletions(prefix string) []string {
+	types := make(map[string]bool)
+	for _, sport := range sportTypes {
+		types[sport] = true
+	}
+	for _, sport := range cachedSports() {
+		types[sport] = true
+	}
+	for _, sport := range strings.Split(prefix, ",") {
+		delete(types, sport)
+	}
+
+	completions := make([]string, 0, len(types))
+	for sport := range types {
+		if sport != "" {
+			completions = append(completions, prefix+sport)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// cachedSports returns the types of the cached activities, or nothing when
+// there is no cache yet. Completion must not create one or sign in, so the
+// cache is only looked for where the environment or profile puts it.
+func cachedSports() []string {
+	path := os.Getenv("STRAVA_CACHE_PATH")
+	if path == "" {
+		if s, err := loadSettings(viper.GetString("config")); err == nil {
+			if profile := viper.GetString("profile"); profile != "" {
+				s.Profile = profile
+			}
+			path = s.activeProfile().CachePath
+		}
+	}
+	if path == "" {
+		path = defaultCachePath
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	cache, err := openCache(path)
+	if err != nil {
+		return nil
+	}
+	defer cache.Close()
+	activities, err := cache.activities()
+	if err != nil {
+		return nil
+	}
+	sports := make([]string, 0)
+	for _, a := range activities {
+		sports = append(sports, a.SportType, a.Type)
+	}
+	return sports
+}
+
+func newManCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "man",
+		Short: "Generate man pages for every command",
+		Long: `Writes a man page per command into --dir, strava-api.1 for the root command
+and strava-api-report-totals.1 and so on for the others, for packaging or
+for man -l. Shell completions come from the completion command, e.g.
+strava-api completion bash > /etc/bash_completion.d/strava-api.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				logger.Fatal(err)
+			}
+			root := cmd.Root()
+			root.DisableAutoGenTag = true
+			header := &doc.GenManHeader{Title: strings.ToUpper(root.Name()), Section: "1", Source: root.Name()}
+			if err := doc.GenManTree(root, header, dir); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Wrote man pages to %s\n", dir)
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "man", "directory to write the man pages to")
+
+	return cmd
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// duplicateWindow is how far apart two recordings of the same
+	// activity may start
+	duplicateWindow = 2 * time.Minute
+	// duplicateDistance is the largest relative difference in distance
+	// between two recordings of the same activity
+	duplicateDistance = 0.1
+)
+
+// duplicatePair is an activity recorded twice. Keep is the recording with
+// the most data; Duplicate is the one to hide or remove.
+type duplicatePair struct {
+	Keep      strava.Activity
+	Duplicate strava.Activity
+}
+
+// findDuplicates pairs activities that start within duplicateWindow of
+// each other with distances within duplicateDistance, such as a watch and
+// a phone recording the same run
+func findDuplicates(activities []strava.Activity) []duplicatePair {
+	type started struct {
+		activity strava.Activity
+		start    time.Time
+	}
+	sorted := make([]started, 0, len(activities))
+	for _, a := range activities {
+		start, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			continue
+		}
+		sorted = append(sorted, started{a, start})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start.Before(sorted[j].start) })
+
+	pairs := make([]duplicatePair, 0)
+	paired := make(map[int]bool)
+	for i := range sorted {
+		if paired[sorted[i].activity.Id] {
+			continue
+		}
+		for j := i + 1; j < len(sorted) && sorted[j].start.Sub(sorted[i].start) <= duplicateWindow; j++ {
+			a, b := sorted[i].activity, sorted[j].activity
+			if paired[b.Id] || !similarDistance(a.Distance, b.Distance) {
+				continue
+			}
+			if recordingScore(b) > recordingScore(a) {
+				a, b = b, a
+			}
+			pairs = append(pairs, duplicatePair{Keep: a, Duplicate: b})
+			paired[a.Id], paired[b.Id] = true, true
+			break
+		}
+	}
+	return pairs
+}
+
+func similarDistance(a, b float64) bool {
+	longest := math.Max(a, b)
+	if longest == 0 {
+		return true
+	}
+	return math.Abs(a-b)/longest <= duplicateDistance
+}
+
+// recordingScore ranks recordings of the same activity by the data they
+// carry: sensors first, then a GPS track, then the longer recording
+func recordingScore(a strava.Activity) float64 {
+	score := a.Distance/1e6 + float64(a.ElapsedTime)/1e9
+	if a.DeviceWatts {
+		score += 4
+	}
+	if a.HasHeartrate {
+		score += 2
+	}
+	if a.Map != nil && a.Map.SummaryPolyline != "" {
+		score++
+	}
+	return score
+}
+
+// confirm asks a yes or no question on out and reads the answer from in
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func newDedupeCmd() *cobra.Command {
+	var after, before string
+	var hide, remove, yes bool
+
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find activities recorded twice, such as by a watch and a phone",
+		Long: `Lists pairs of cached activities that started within two minutes of each
+other with distances within 10%, started within --after and --before. The
+recording with power, heart rate, or GPS data, then the longer one, is
+kept; the other is the duplicate.
+
+--hide hides each duplicate from followers' feeds through the activity
+update endpoint after asking for confirmation, or without asking with
+--yes. --delete permanently deletes each duplicate instead, where Strava
+allows the application to. Both need the activity:write scope.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			from, to, err := parseDateRange(after, before)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			selected := make([]strava.Activity, 0)
+			for _, a := range activities {
+				if inDateRange(a, from, to) {
+					selected = append(selected, a)
+				}
+			}
+			pairs := findDuplicates(selected)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "DATE\tKEEP\tDUPLICATE\tDISTANCE\tDUPLICATE DISTANCE")
+			for _, p := range pairs {
+				keep, unit := config.Settings.Output.convert(p.Keep.Distance)
+				dup, _ := config.Settings.Output.convert(p.Duplicate.Distance)
+				fmt.Fprintf(w, "%s\t%d %s\t%d %s\t%.2f %s\t%.2f %s\n", p.Keep.StartDate, p.Keep.Id, p.Keep.Name,
+					p.Duplicate.Id, p.Duplicate.Name, keep, unit, dup, unit)
+			}
+			w.Flush()
+			logger.Printf("Found %d likely duplicates\n", len(pairs))
+
+			if hide && remove {
+				logger.Fatal("Use one of --hide and --delete")
+			}
+			if !hide && !remove || len(pairs) == 0 {
+				return
+			}
+			question := fmt.Sprintf("Hide %d duplicates from your followers' feeds?", len(pairs))
+			if remove {
+				question = fmt.Sprintf("Permanently delete %d duplicates from Strava?", len(pairs))
+			}
+			if !yes && !confirm(os.Stdin, os.Stderr, question) {
+				logger.Fatal("Aborted")
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			if remove {
+				duplicates := make([]strava.Activity, 0, len(pairs))
+				for _, p := range pairs {
+					duplicates = append(duplicates, p.Duplicate)
+				}
+				if err := deleteActivities(ctx, client, cache, duplicates); err != nil {
+					logger.Fatal(err)
+				}
+				if viper.GetBool("dry-run") {
+					logger.Printf("Would delete %d duplicates\n", len(pairs))
+				} else {
+					logger.Printf("Deleted %d duplicates\n", len(pairs))
+				}
+				return
+			}
+			hidden := true
+			for _, p := range pairs {
+				updated, err := client.UpdateActivity(ctx, int64(p.Duplicate.Id), strava.UpdatableActivity{HideFromHome: &hidden})
+				if err != nil {
+					logger.Fatalf("hiding activity %d: %v\n", p.Duplicate.Id, err)
+				}
+				if err := cache.upsertActivities([]strava.Activity{updated.Activity}); err != nil {
+					logger.Fatal(err)
+				}
+			}
+			logger.Printf("Hid %d duplicates\n", len(pairs))
+		},
+	}
+
+	cmd.Flags().StringVar(&after, "after", "", "first day to include, YYYY-MM-DD")
+	cmd.Flags().StringVar(&before, "before", "", "last day to include, YYYY-MM-DD")
+	cmd.Flags().BoolVar(&hide, "hide", false, "hide each duplicate from followers' feeds")
+	cmd.Flags().BoolVar(&remove, "delete", false, "permanently delete each duplicate")
+	cmd.Flags().BoolVar(&yes, "yes", false, "do not ask for confirmation")
+
+	return cmd
+}
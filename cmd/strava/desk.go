@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// deskSettings are the defaults for activities logged by log desk
+type deskSettings struct {
+	Name string `mapstructure:"name"`
+	// Type is the sport type, Walk by default
+	Type string `mapstructure:"type"`
+}
+
+func newLogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Log sessions recorded without a file as manual activities",
+	}
+
+	cmd.AddCommand(newLogDeskCmd())
+
+	return cmd
+}
+
+func newLogDeskCmd() *cobra.Command {
+	var minutes int
+	var miles, km float64
+	var name, sportType, start string
+
+	cmd := &cobra.Command{
+		Use:   "desk",
+		Short: "Log a desk treadmill session and report the updated streak and goals",
+		Long: `Creates a manual trainer activity for a desk treadmill session that ended
+now, or started at --start, and adds it to the cache. The name and type
+default to desk.name and desk.type in the settings file, "Desk Treadmill"
+and Walk unless set, which the default rules count. The streak and goal
+progress are then worked out from the cache straight away, without a sync.
+
+Creating activities needs the activity:write scope.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			if minutes <= 0 {
+				logger.Fatal("--minutes is required")
+			}
+			if miles > 0 && km > 0 {
+				logger.Fatal("Pass --miles or --km, not both")
+			}
+			if name == "" {
+				name = config.Settings.Desk.Name
+			}
+			if sportType == "" {
+				sportType = config.Settings.Desk.Type
+			}
+
+			elapsed := time.Duration(minutes) * time.Minute
+			started := time.Now().Add(-elapsed)
+			if start != "" {
+				var err error
+				if started, err = parseLocalTime(start); err != nil {
+					logger.Fatal(err)
+				}
+			}
+			meters := miles / 0.000621371
+			if km > 0 {
+				meters = km * 1000
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			created, err := createActivity(ctx, client, cache, strava.CreatableActivity{
+				Name:           name,
+				SportType:      sportType,
+				StartDateLocal: started.Format("2006-01-02T15:04:05"),
+				ElapsedTime:    int(elapsed.Seconds()),
+				Distance:       meters,
+				Trainer:        true,
+			})
+			if err != nil {
+				logger.Fatal(err)
+			}
+			fmt.Println(created.Id)
+			if viper.GetBool("dry-run") {
+				logger.Printf("Would log %q: %s in %s\n", created.Name, config.Settings.Output.formatDistance(created.Distance), formatDuration(created.ElapsedTime))
+			} else {
+				logger.Printf("Logged %q (%d): %s in %s\n", created.Name, created.Id, config.Settings.Output.formatDistance(created.Distance), formatDuration(created.ElapsedTime))
+			}
+			if matched, err := matchesAnyRule(config.Settings.Rules, created); err == nil && !matched {
+				logger.Println("The activity does not match any rule, so it is not counted in the totals")
+			}
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			// Metric plugins are left to the next sync
+			sum, err := summarize(ctx, activities, aggregations(config.Settings.Rules, nil), time.Now())
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Matched Activities: %d\n", sum.Count)
+			logger.Printf("Total Distance: %s\n", config.Settings.Output.formatDistance(sum.Distance))
+			logger.Printf("Current Streak: %d days\n", sum.Streak)
+			if len(config.Settings.Goals) > 0 {
+				calories, err := cache.calories()
+				if err != nil {
+					logger.Fatal(err)
+				}
+				for _, goal := range trackGoals(config.Settings.Goals, activities, calories, config.Settings.Output, time.Now()) {
+					logger.Printf("Goal %s\n", goal)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().IntVar(&minutes, "minutes", 0, "minutes walked")
+	cmd.Flags().Float64Var(&miles, "miles", 0, "distance in miles")
+	cmd.Flags().Float64Var(&km, "km", 0, "distance in kilometers, instead of --miles")
+	cmd.Flags().StringVar(&name, "name", "", "activity title (default desk.name)")
+	cmd.Flags().StringVar(&sportType, "type", "", "sport type (default desk.type)")
+	cmd.Flags().StringVar(&start, "start", "", "local start time, YYYY-MM-DDTHH:MM[:SS] (default --minutes ago)")
+
+	return cmd
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// maxClockSkew is how far the local clock may drift from Strava's before
+// token expiry checks become unreliable
+const maxClockSkew = 2 * time.Minute
+
+var (
+	clientIdPattern = regexp.MustCompile(`^[0-9]+$`)
+	hexTokenPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// doctor collects check results and prints them as they complete
+type doctor struct {
+	failures int
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("[ok]   %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) warn(remedy string, format string, args ...interface{}) {
+	fmt.Printf("[warn] %s\n       -> %s\n", fmt.Sprintf(format, args...), remedy)
+}
+
+func (d *doctor) fail(remedy string, format string, args ...interface{}) {
+	d.failures++
+	fmt.Printf("[fail] %s\n       -> %s\n", fmt.Sprintf(format, args...), remedy)
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "doctor",
+		Short: "Validate configuration, connectivity, and token health",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.New(os.Stderr, "", log.LstdFlags)
+			config := loadConfig(cmd.Context(), logger)
+
+			d := &doctor{}
+			d.run(cmd.Context(), logger, config)
+
+			if d.failures > 0 {
+				fmt.Printf("\n%d check(s) failed\n", d.failures)
+				os.Exit(1)
+			}
+			fmt.Println("\nAll checks passed")
+		},
+	})
+
+	return cmd
+}
+
+func (d *doctor) run(ctx context.Context, logger *log.Logger, config envVars) {
+	valuesOk := d.checkValues(config)
+
+	if !d.checkConnectivity(ctx, config) || !valuesOk {
+		// Token checks need both valid values and a reachable API
+		return
+	}
+
+	client := newClient(ctx, logger, config)
+	if err := client.Authenticate(ctx); err != nil {
+		var apiErr *strava.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+			d.fail("Repeat the one time manual authorization in the README to get a new refresh token", "Token refresh rejected: %v", err)
+		} else {
+			d.fail("Check STRAVA_CLIENT_ID and STRAVA_CLIENT_SECRET match https://www.strava.com/settings/api", "Token refresh failed: %v", err)
+		}
+		return
+	}
+	d.ok("Access token valid until %s", time.Unix(client.Token().ExpiresAt, 0).Format(time.RFC1123))
+
+	if err := client.Probe(ctx); err != nil {
+		d.fail("Repeat the one time manual authorization in the README", "Token rejected by /athlete: %v", err)
+		return
+	}
+	d.ok("Token accepted by /athlete")
+
+	if scopes, ok := client.Scopes(); ok {
+		if strava.HasScope(scopes, strava.ScopeActivityWrite) {
+			d.ok("Token scopes: %s", strings.Join(scopes, ", "))
+		} else {
+			d.ok("Token scopes: %s; creating, editing, and deleting activities needs activity:write", strings.Join(scopes, ", "))
+		}
+	}
+
+	_, err := client.ListActivities(ctx, strava.ListActivitiesOptions{Page: 1, PerPage: 1})
+	var apiErr *strava.APIError
+	switch {
+	case err == nil:
+		d.ok("Token has activity read scope")
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized:
+		d.fail("Re-authorize with scope=activity:read_all using the authorize URL in the README", "Token is missing the activity:read scope: %v", err)
+	default:
+		d.fail("Retry later; Strava may be having problems", "Listing activities failed: %v", err)
+	}
+}
+
+// checkValues validates presence and format of the required settings
+func (d *doctor) checkValues(config envVars) bool {
+	before := d.failures
+
+	checks := []struct {
+		key     string
+		value   string
+		pattern *regexp.Regexp
+		remedy  string
+	}{
+		{"STRAVA_CLIENT_ID", config.StravaClientId, clientIdPattern, "Copy the numeric Client ID from https://www.strava.com/settings/api"},
+		{"STRAVA_CLIENT_SECRET", config.StravaClientSecret, hexTokenPattern, "Copy the Client Secret from https://www.strava.com/settings/api"},
+		{"STRAVA_REFRESH_TOKEN", config.StravaRefreshToken, hexTokenPattern, "Run refresh.sh with the code from the one time manual authorization"},
+	}
+
+	for _, check := range checks {
+		value := strings.TrimSpace(check.value)
+		switch {
+		case value == "" && check.key == "STRAVA_CLIENT_SECRET" && config.StravaTokenStore == "keyring":
+			d.ok("%s not set, expected in the keyring", check.key)
+		case value == "" && check.key == "STRAVA_REFRESH_TOKEN" && config.StravaTokenStore != "":
+			d.ok("%s not set, expected in the %s token store", check.key, config.StravaTokenStore)
+		case value == "":
+			d.fail(check.remedy, "%s is missing", check.key)
+		case value != check.value:
+			d.fail("Remove the surrounding whitespace in strava.env", "%s has leading or trailing whitespace", check.key)
+		case !check.pattern.MatchString(value):
+			d.fail(check.remedy, "%s does not look valid", check.key)
+		default:
+			d.ok("%s is set", check.key)
+		}
+	}
+
+	return d.failures == before
+}
+
+// checkConnectivity confirms the API is reachable through the configured
+// proxy and TLS settings, as a sync would reach it, and compares the server
+// clock with the local one
+func (d *doctor) checkConnectivity(ctx context.Context, config envVars) bool {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	baseURL := strava.DefaultBaseURL
+	if config.StravaBaseURL != "" {
+		baseURL = config.StravaBaseURL
+	}
+	transport, err := newTransport(config.Settings.HTTP)
+	if err != nil {
+		d.fail("Fix the http settings in the settings file", "Building the HTTP transport: %v", err)
+		return false
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+	if err != nil {
+		d.fail("Check STRAVA_BASE_URL", "Building connectivity request: %v", err)
+		return false
+	}
+
+	start := time.Now()
+	// Any response, even a 401 for the missing token, means the API is
+	// reachable
+	res, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		d.fail("Check your network, DNS, and http.proxy and http.ca_file settings", "Cannot reach %s: %v", baseURL, err)
+		return false
+	}
+	res.Body.Close()
+	d.ok("Reached %s in %s", baseURL, time.Since(start).Round(time.Millisecond))
+
+	serverTime, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		d.warn("Nothing to do unless token expiry looks wrong", "Could not read the server time to check clock skew")
+		return true
+	}
+	skew := time.Since(serverTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		d.fail("Enable NTP time sync on this machine", "Local clock is %s off from Strava", skew)
+	} else {
+		d.ok("Clock skew %s", skew)
+	}
+	return true
+}
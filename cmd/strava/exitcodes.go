@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/viper"
+)
+
+// Exit codes for the failures a scheduler may want to handle differently,
+// for example retrying network errors but alerting on auth ones
+const (
+	exitFailure     = 1
+	exitUsage       = 2
+	exitAuth        = 3
+	exitRateLimit   = 4
+	exitNetwork     = 5
+	exitPartialSync = 6
+)
+
+// failureCategories name the exit codes in --error-json output
+var failureCategories = map[int]string{
+	exitFailure:     "error",
+	exitUsage:       "usage",
+	exitAuth:        "auth",
+	exitRateLimit:   "rate_limit",
+	exitNetwork:     "network",
+	exitPartialSync: "partial_sync",
+}
+
+// authError marks a failure to obtain a usable access token
+type authError struct {
+	err error
+}
+
+func (e authError) Error() string { return e.err.Error() }
+func (e authError) Unwrap() error { return e.err }
+
+// partialSyncError is a sync that stored some pages before it failed, so
+// the next run resumes after them
+type partialSyncError struct {
+	err   error
+	pages int
+}
+
+func (e partialSyncError) Error() string {
+	return fmt.Sprintf("%v - progress saved, rerun to resume the fetch", e.err)
+}
+
+func (e partialSyncError) Unwrap() error { return e.err }
+
+// exitCode classifies err. The cause of a partial sync is classified on
+// its own, as it is reported alongside.
+func exitCode(err error) int {
+	if errors.As(err, new(partialSyncError)) {
+		return exitPartialSync
+	}
+	return causeCode(err)
+}
+
+func causeCode(err error) int {
+	var apiErr *strava.APIError
+	var budget budgetError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests, errors.As(err, &budget), errors.Is(err, strava.ErrRateLimited), errors.Is(err, strava.ErrDeferred):
+		return exitRateLimit
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		return exitAuth
+	case errors.As(err, &netErr), errors.Is(err, strava.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return exitNetwork
+	case errors.As(err, new(authError)), errors.As(err, new(*strava.ScopeError)):
+		return exitAuth
+	}
+	return exitFailure
+}
+
+// fatal reports err and exits with the code for its category. With
+// --error-json the report is a JSON object on stderr instead of a log line:
+//
+//	{"error": "...", "category": "partial_sync", "exit_code": 6, "cause": "rate_limit", "pages_saved": 2}
+func fatal(logger *log.Logger, err error) {
+	code := exitCode(err)
+	commandSpan.RecordError(err)
+	stopTracing(code)
+	if !viper.GetBool("error-json") {
+		logger.Println(err)
+		os.Exit(code)
+	}
+
+	report := map[string]interface{}{"error": err.Error(), "category": failureCategories[code], "exit_code": code}
+	var partial partialSyncError
+	if errors.As(err, &partial) {
+		report["cause"] = failureCategories[causeCode(err)]
+		report["pages_saved"] = partial.pages
+	}
+	json.NewEncoder(os.Stderr).Encode(report)
+	os.Exit(code)
+}
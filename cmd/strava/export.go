@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// parquetRowGroupSize bounds how many rows are buffered before a row group
+// is flushed to the output
+const parquetRowGroupSize = 10000
+
+// parquetActivityColumns is the flattened column layout of a parquet
+// export. raw carries the full API payload for fields without a dedicated
+// column.
+var parquetActivityColumns = []parquetColumn{
+	{Name: "id", Type: parquetInt64},
+	{Name: "name", Type: parquetByteArray, Converted: parquetUTF8},
+	{Name: "description", Type: parquetByteArray, Converted: parquetUTF8, Optional: true},
+	{Name: "type", Type: parquetByteArray, Converted: parquetUTF8},
+	{Name: "start_date", Type: parquetByteArray, Converted: parquetUTF8},
+	{Name: "distance", Type: parquetDouble},
+	{Name: "moving_time", Type: parquetInt64},
+	{Name: "elapsed_time", Type: parquetInt64},
+	{Name: "raw", Type: parquetByteArray, Converted: parquetJSON},
+}
+
+func newExportCmd() *cobra.Command {
+	var format string
+	var out string
+	var visibility []string
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump the local activity cache as JSON Lines or Parquet",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			expr, err := selection.filter()
+			if err == nil {
+				expr, err = expr.withVisibility(visibility)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			var w io.Writer = os.Stdout
+			if out != "-" {
+				f, err := os.Create(out)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			bw := bufio.NewWriter(w)
+			var count int
+			switch format {
+			case "jsonl":
+				count, err = exportJSONL(cache, bw, expr)
+			case "parquet":
+				count, err = exportParquet(cache, bw, expr)
+			default:
+				err = fmt.Errorf("unknown export format %q, expected jsonl or parquet", format)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if err := bw.Flush(); err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Printf("Exported %d activities as %s\n", count, format)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "jsonl", "export format: jsonl or parquet")
+	cmd.Flags().StringVarP(&out, "out", "o", "-", "output file, - for stdout")
+	selection = addSelectionFlags(cmd)
+	cmd.Flags().StringSliceVar(&visibility, "visibility", nil, "only include activities with these visibilities: everyone, followers_only, only_me (default all)")
+
+	cmd.AddCommand(newExportGeoJSONCmd())
+	cmd.AddCommand(newExportICalCmd())
+
+	return cmd
+}
+
+// exportJSONL writes one raw activity payload per line
+func exportJSONL(cache *activityCache, w io.Writer, filter *activityFilter) (int, error) {
+	count := 0
+	err := cache.eachActivity(func(a strava.Activity) error {
+		if ok, err := filter.match(a); err != nil || !ok {
+			return err
+		}
+		if _, err := w.Write(a.Raw); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("\n")); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, filter.checkFields()
+}
+
+// exportParquet writes the cache in row groups of parquetRowGroupSize so
+// memory use stays flat regardless of the number of activities
+func exportParquet(cache *activityCache, w io.Writer, filter *activityFilter) (int, error) {
+	writer, err := newParquetWriter(w, parquetActivityColumns)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = cache.eachActivity(func(a strava.Activity) error {
+		if ok, err := filter.match(a); err != nil || !ok {
+			return err
+		}
+		// The list endpoint has no description, so an empty one is unknown
+		var description interface{}
+		if a.Description != "" {
+			description = a.Description
+		}
+		err := writer.WriteRow(
+			int64(a.Id),
+			a.Name,
+			description,
+			a.Type,
+			a.StartDate,
+			a.Distance,
+			int64(a.MovingTime),
+			int64(a.ElapsedTime),
+			string(a.Raw),
+		)
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%parquetRowGroupSize == 0 {
+			return writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := filter.checkFields(); err != nil {
+		return count, err
+	}
+
+	return count, writer.Close()
+}
@@ -0,0 +1,812 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// activityFilter is a compiled --filter expression such as
+//
+//	type == "Run" && distance > 10000 && start_date_local.year() == 2024
+//
+// Identifiers are fields of the activity as returned by the API, with
+// distances in meters and times in seconds. Fields an activity lacks are
+// null, which equals only null, fails every ordering, and counts as false.
+type activityFilter struct {
+	source string
+	root   filterNode
+	// fields are the identifiers in the expression, seen those found on
+	// at least one activity
+	fields map[string]bool
+	seen   map[string]bool
+	// visibility, when set, holds the only visibility levels that match
+	visibility map[string]bool
+	// require are checks from the selection flags an activity must pass
+	require []func(strava.Activity) bool
+}
+
+// filterValue is a float64, string, bool, []filterValue, or nil
+type filterValue = interface{}
+
+type filterNode interface {
+	eval(fields map[string]interface{}) (filterValue, error)
+}
+
+// parseFilter compiles an expression. The empty expression matches every
+// activity and yields a nil filter.
+func parseFilter(source string) (*activityFilter, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, nil
+	}
+	tokens, err := lexFilter(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{source: source, tokens: tokens, fields: make(map[string]bool)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return &activityFilter{source: source, root: root, fields: p.fields, seen: make(map[string]bool)}, nil
+}
+
+// match evaluates the filter on an activity. A nil filter matches all.
+func (f *activityFilter) match(a strava.Activity) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+	if f.visibility != nil && !f.visibility[a.VisibilityLevel()] {
+		return false, nil
+	}
+	for _, check := range f.require {
+		if !check(a) {
+			return false, nil
+		}
+	}
+	if f.root == nil {
+		return true, nil
+	}
+	raw := a.Raw
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(a); err != nil {
+			return false, err
+		}
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false, fmt.Errorf("activity %d: %w", a.Id, err)
+	}
+	for name := range f.fields {
+		if _, ok := fields[name]; ok {
+			f.seen[name] = true
+		}
+	}
+
+	v, err := f.root.eval(fields)
+	if err != nil {
+		return false, fmt.Errorf("filter %q on activity %d: %w", f.source, a.Id, err)
+	}
+	ok, isBool := v.(bool)
+	if !isBool && v != nil {
+		return false, fmt.Errorf("filter %q must be true or false, got %s", f.source, describe(v))
+	}
+	return ok, nil
+}
+
+// visibilityLevels are the values --visibility accepts
+var visibilityLevels = []string{strava.VisibilityEveryone, strava.VisibilityFollowersOnly, strava.VisibilityOnlyMe}
+
+// withVisibility restricts f to activities visible at one of levels,
+// e.g. only those everyone can see for a published site. No levels leaves
+// f as it is.
+func (f *activityFilter) withVisibility(levels []string) (*activityFilter, error) {
+	if len(levels) == 0 {
+		return f, nil
+	}
+	if f == nil {
+		f = &activityFilter{seen: make(map[string]bool)}
+	}
+	f.visibility = make(map[string]bool, len(levels))
+	for _, level := range levels {
+		if !containsFold(visibilityLevels, level) {
+			return nil, fmt.Errorf("unknown visibility %q, expected %s", level, strings.Join(visibilityLevels, ", "))
+		}
+		f.visibility[strings.ToLower(level)] = true
+	}
+	return f, nil
+}
+
+// filterActivities returns the activities matching f, failing on fields
+// that no activity has
+func filterActivities(f *activityFilter, activities []strava.Activity) ([]strava.Activity, error) {
+	if f == nil {
+		return activities, nil
+	}
+	selected := make([]strava.Activity, 0)
+	for _, a := range activities {
+		ok, err := f.match(a)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, a)
+		}
+	}
+	if len(activities) == 0 {
+		return selected, nil
+	}
+	return selected, f.checkFields()
+}
+
+// checkFields reports a field the filter names that none of the activities
+// matched so far has, since it is most likely a typo
+func (f *activityFilter) checkFields() error {
+	if f == nil {
+		return nil
+	}
+	for name := range f.fields {
+		if !f.seen[name] {
+			return fmt.Errorf("filter %q: no activity has a field named %q", f.source, name)
+		}
+	}
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOp
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+	// pos is the byte offset of the token in the expression
+	pos    int
+	number float64
+}
+
+func (t filterToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// filterOps are the operators and punctuation, longest first
+var filterOps = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", "[", "]", ",", "."}
+
+func lexFilter(source string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(source) && rune(source[end]) != c {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, fmt.Errorf("filter: unterminated string at column %d", i+1)
+			}
+			text := source[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(source[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("filter: invalid string at column %d", i+1)
+				}
+				text = unquoted
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: text, pos: i})
+			i = end + 1
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(source) && (unicode.IsDigit(rune(source[end])) || source[end] == '.' || source[end] == '_') {
+				end++
+			}
+			n, err := strconv.ParseFloat(strings.ReplaceAll(source[i:end], "_", ""), 64)
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid number %q at column %d", source[i:end], i+1)
+			}
+			tokens = append(tokens, filterToken{kind: tokenNumber, text: source[i:end], pos: i, number: n})
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(source) && (unicode.IsLetter(rune(source[end])) || unicode.IsDigit(rune(source[end])) || source[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, filterToken{kind: tokenIdent, text: source[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range filterOps {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("filter: unexpected %q at column %d", c, i+1)
+			}
+			tokens = append(tokens, filterToken{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, filterToken{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// filterParser is a recursive descent parser. From loosest to tightest the
+// precedence is ||, &&, comparisons and in, + and -, * and /, unary ! and
+// -, then method calls.
+type filterParser struct {
+	source string
+	tokens []filterToken
+	next   int
+	fields map[string]bool
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.next]
+}
+
+func (p *filterParser) take() filterToken {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the operators ops
+func (p *filterParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp && t.kind != tokenIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.next++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *filterParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		t := p.peek()
+		return p.errorf(t, "expected %q, got %s", op, t)
+	}
+	return nil
+}
+
+func (p *filterParser) errorf(t filterToken, format string, args ...interface{}) error {
+	return fmt.Errorf("filter: %s at column %d\n  %s\n  %s^", fmt.Sprintf(format, args...), t.pos+1, p.source, strings.Repeat(" ", t.pos))
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	for err == nil {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		var right filterNode
+		right, err = p.parseAnd()
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+	return nil, err
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseComparison()
+	for err == nil {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		var right filterNode
+		right, err = p.parseComparison()
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+	return nil, err
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *filterParser) parseAdditive() (filterNode, error) {
+	left, err := p.parseMultiplicative()
+	for err == nil {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		var right filterNode
+		right, err = p.parseMultiplicative()
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return nil, err
+}
+
+func (p *filterParser) parseMultiplicative() (filterNode, error) {
+	left, err := p.parseUnary()
+	for err == nil {
+		op, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		var right filterNode
+		right, err = p.parseUnary()
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return nil, err
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *filterParser) parsePostfix() (filterNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("."); !ok {
+			return node, nil
+		}
+		name := p.take()
+		if name.kind != tokenIdent {
+			return nil, p.errorf(name, "expected a method name, got %s", name)
+		}
+		if _, ok := filterMethods[name.text]; !ok {
+			return nil, p.errorf(name, "unknown method %q, expected one of %s", name.text, methodNames())
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		args := make([]filterNode, 0)
+		if _, ok := p.accept(")"); !ok {
+			for {
+				arg, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if _, ok := p.accept(","); !ok {
+					break
+				}
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+		node = methodNode{name: name.text, receiver: node, args: args}
+	}
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	t := p.take()
+	switch t.kind {
+	case tokenNumber:
+		return literalNode{t.number}, nil
+	case tokenString:
+		return literalNode{t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		case "null":
+			return literalNode{nil}, nil
+		}
+		p.fields[t.text] = true
+		return fieldNode(t.text), nil
+	case tokenOp:
+		switch t.text {
+		case "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			items := make([]filterNode, 0)
+			if _, ok := p.accept("]"); ok {
+				return listNode(items), nil
+			}
+			for {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if _, ok := p.accept(","); !ok {
+					break
+				}
+			}
+			return listNode(items), p.expect("]")
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
+
+type literalNode struct{ value filterValue }
+
+func (n literalNode) eval(map[string]interface{}) (filterValue, error) { return n.value, nil }
+
+type fieldNode string
+
+func (n fieldNode) eval(fields map[string]interface{}) (filterValue, error) {
+	v := fields[string(n)]
+	if items, ok := v.([]interface{}); ok {
+		return items, nil
+	}
+	switch v.(type) {
+	case nil, float64, string, bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("field %q is an object and cannot be compared", string(n))
+}
+
+type listNode []filterNode
+
+func (n listNode) eval(fields map[string]interface{}) (filterValue, error) {
+	items := make([]interface{}, len(n))
+	for i, item := range n {
+		v, err := item.eval(fields)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right filterNode
+}
+
+func (n logicalNode) eval(fields map[string]interface{}) (filterValue, error) {
+	left, err := evalBool(n.left, fields, n.op)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" && !left || n.op == "||" && left {
+		return left, nil
+	}
+	return evalBool(n.right, fields, n.op)
+}
+
+func evalBool(n filterNode, fields map[string]interface{}, op string) (bool, error) {
+	v, err := n.eval(fields)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok && v != nil {
+		return false, fmt.Errorf("%s needs true or false, got %s", op, describe(v))
+	}
+	return b, nil
+}
+
+type unaryNode struct {
+	op      string
+	operand filterNode
+}
+
+func (n unaryNode) eval(fields map[string]interface{}) (filterValue, error) {
+	v, err := n.operand.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case nil:
+		if n.op == "!" {
+			return true, nil
+		}
+	case bool:
+		if n.op == "!" {
+			return !x, nil
+		}
+	case float64:
+		if n.op == "-" {
+			return -x, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to %s", n.op, describe(v))
+}
+
+type binaryNode struct {
+	op          string
+	left, right filterNode
+}
+
+func (n binaryNode) eval(fields map[string]interface{}) (filterValue, error) {
+	left, err := n.left.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		items, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("in needs a list on the right, got %s", describe(right))
+		}
+		for _, item := range items {
+			if equal(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// missing fields never satisfy an ordering
+	if left == nil || right == nil {
+		if n.op == "<" || n.op == "<=" || n.op == ">" || n.op == ">=" {
+			return false, nil
+		}
+		return nil, nil
+	}
+
+	if ls, ok := left.(string); ok {
+		rs, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s with %s using %s", describe(left), describe(right), n.op)
+		}
+		switch n.op {
+		case "+":
+			return ls + rs, nil
+		case "<":
+			return ls < rs, nil
+		case "<=":
+			return ls <= rs, nil
+		case ">":
+			return ls > rs, nil
+		case ">=":
+			return ls >= rs, nil
+		}
+		return nil, fmt.Errorf("cannot apply %s to strings", n.op)
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", n.op, describe(left), describe(right))
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+func equal(a, b filterValue) bool {
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case float64:
+		y, ok := b.(float64)
+		return ok && math.Abs(x-y) < 1e-9
+	}
+	return a == b
+}
+
+type methodNode struct {
+	name     string
+	receiver filterNode
+	args     []filterNode
+}
+
+// filterMethod is a method callable on a value, by the number of arguments
+// it takes
+type filterMethod struct {
+	args int
+	call func(receiver filterValue, args []filterValue) (filterValue, error)
+}
+
+// filterMethods are the methods available in expressions. The date methods
+// read RFC 3339 timestamps such as start_date_local, without converting
+// time zones.
+var filterMethods = map[string]filterMethod{
+	"year":    timeMethod(func(t time.Time) float64 { return float64(t.Year()) }),
+	"month":   timeMethod(func(t time.Time) float64 { return float64(t.Month()) }),
+	"day":     timeMethod(func(t time.Time) float64 { return float64(t.Day()) }),
+	"hour":    timeMethod(func(t time.Time) float64 { return float64(t.Hour()) }),
+	"weekday": timeMethod(func(t time.Time) float64 { return float64(t.Weekday()) }),
+	"lower": {0, func(r filterValue, _ []filterValue) (filterValue, error) {
+		s, err := stringArg(r, "lower")
+		return strings.ToLower(s), err
+	}},
+	"contains":   stringMethod(strings.Contains),
+	"startsWith": stringMethod(strings.HasPrefix),
+	"endsWith":   stringMethod(strings.HasSuffix),
+	"matches": {1, func(r filterValue, args []filterValue) (filterValue, error) {
+		s, err := stringArg(r, "matches")
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := stringArg(args[0], "matches argument")
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("matches: %w", err)
+		}
+		return re.MatchString(s), nil
+	}},
+	"size": {0, func(r filterValue, _ []filterValue) (filterValue, error) {
+		switch x := r.(type) {
+		case string:
+			return float64(len(x)), nil
+		case []interface{}:
+			return float64(len(x)), nil
+		}
+		return nil, fmt.Errorf("size needs a string or list, got %s", describe(r))
+	}},
+}
+
+func timeMethod(part func(time.Time) float64) filterMethod {
+	return filterMethod{0, func(r filterValue, _ []filterValue) (filterValue, error) {
+		s, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("date methods need a timestamp, got %s", describe(r))
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a timestamp", s)
+		}
+		return part(t), nil
+	}}
+}
+
+func stringMethod(test func(s, substr string) bool) filterMethod {
+	return filterMethod{1, func(r filterValue, args []filterValue) (filterValue, error) {
+		s, err := stringArg(r, "string methods")
+		if err != nil {
+			return nil, err
+		}
+		arg, err := stringArg(args[0], "string method argument")
+		if err != nil {
+			return nil, err
+		}
+		return test(s, arg), nil
+	}}
+}
+
+func stringArg(v filterValue, what string) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s need a string, got %s", what, describe(v))
+	}
+	return s, nil
+}
+
+func methodNames() string {
+	names := make([]string, 0, len(filterMethods))
+	for name := range filterMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (n methodNode) eval(fields map[string]interface{}) (filterValue, error) {
+	method := filterMethods[n.name]
+	if len(n.args) != method.args {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", n.name, method.args, len(n.args))
+	}
+	receiver, err := n.receiver.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+	if receiver == nil {
+		return nil, nil
+	}
+	args := make([]filterValue, len(n.args))
+	for i, arg := range n.args {
+		if args[i], err = arg.eval(fields); err != nil {
+			return nil, err
+		}
+	}
+	return method.call(receiver, args)
+}
+
+// describe names the type of a value for error messages
+func describe(v filterValue) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("boolean %v", x)
+	case float64:
+		return fmt.Sprintf("number %v", x)
+	case string:
+		return fmt.Sprintf("string %q", x)
+	case []interface{}:
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/brandtkeller/strava-api/pkg/strava/fit"
+	"github.com/spf13/cobra"
+)
+
+func newFitCmd() *cobra.Command {
+	var streams bool
+	var gpx string
+
+	cmd := &cobra.Command{
+		Use:   "fit <file.fit>",
+		Short: "Decode a FIT file into the activity and stream models used for API data",
+		Long: `Decodes a FIT file, such as one unpacked by import archive --extract, and
+prints the activity as JSON without calling the API. Gzipped files are
+accepted as is.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer f.Close()
+
+			file, err := fit.Decode(f)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			activity, err := file.Activity()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			s := file.Streams()
+
+			if gpx != "" {
+				var buf bytes.Buffer
+				if err := writeActivityGPX(&buf, strava.DetailedActivity{Activity: activity}, s); err != nil {
+					logger.Fatal(err)
+				}
+				if err := writeFile(gpx, buf.Bytes()); err != nil {
+					logger.Fatal(err)
+				}
+			}
+
+			if !streams {
+				printJSON(logger, activity)
+				return
+			}
+			printJSON(logger, struct {
+				Activity strava.Activity `json:"activity"`
+				Streams  strava.Streams  `json:"streams"`
+			}{activity, s})
+		},
+	}
+
+	cmd.Flags().BoolVar(&streams, "streams", false, "include the decoded streams")
+	cmd.Flags().StringVar(&gpx, "gpx", "", "also write the GPS track to this GPX file")
+
+	return cmd
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// parseFormatTemplate compiles a --format-template. Besides the text/template
+// builtins, templates can call distance and elevation with meters, duration
+// with seconds, pace with seconds and meters, date with a Go layout and an
+// RFC 3339 timestamp, and json with any value.
+func parseFormatTemplate(text string, output outputSettings) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"distance": func(meters float64) string {
+			d, unit := output.convert(meters)
+			return fmt.Sprintf("%.2f %s", d, unit)
+		},
+		"elevation": func(meters float64) string {
+			e, unit := output.convertElevation(meters)
+			return fmt.Sprintf("%.0f %s", e, unit)
+		},
+		"duration": formatDuration,
+		"pace": func(seconds int, meters float64) string {
+			d, unit := output.convert(meters)
+			return formatPace(seconds, d, unit)
+		},
+		"date": func(layout, timestamp string) (string, error) {
+			t, err := time.Parse(time.RFC3339, timestamp)
+			if err != nil {
+				return "", err
+			}
+			return t.Format(layout), nil
+		},
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}
+	t, err := template.New("format").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("--format-template: %w", err)
+	}
+	return t, nil
+}
+
+// writeTemplate executes t on data and writes the result as one line
+func writeTemplate(w io.Writer, t *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return fmt.Errorf("--format-template: %w", err)
+	}
+	_, err := fmt.Fprintln(w, strings.TrimSuffix(buf.String(), "\n"))
+	return err
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// gradeWindow is the distance in meters a grade is measured over, long
+	// enough to smooth out GPS altitude noise
+	gradeWindow = 20.0
+	// maxGrade clamps grades to the range the cost model was fitted on
+	maxGrade = 0.45
+	// maxStreamActivities bounds the stream requests made by one report
+	maxStreamActivities = 100
+)
+
+// gapStreams are the streams needed for grade adjusted pace
+var gapStreams = []string{strava.StreamDistance, strava.StreamAltitude, strava.StreamMoving}
+
+// runCost is the energy cost of running at a grade relative to the flat,
+// from Minetti et al. (2002)
+func runCost(grade float64) float64 {
+	g := math.Max(-maxGrade, math.Min(maxGrade, grade))
+	cost := 155.4*math.Pow(g, 5) - 30.4*math.Pow(g, 4) - 43.3*math.Pow(g, 3) + 46.3*g*g + 19.5*g + 3.6
+	return cost / 3.6
+}
+
+// gradeAdjustment returns how much longer the samples from start to end
+// would have been on the flat for the same effort, or false when the
+// streams have no altitude. end is inclusive; pass -1 for the whole
+// activity. Samples where the athlete stopped are ignored.
+func gradeAdjustment(streams strava.Streams, start, end int) (float64, bool) {
+	if streams.Distance == nil || streams.Altitude == nil {
+		return 0, false
+	}
+	dist, alt := streams.Distance.Data, streams.Altitude.Data
+	n := len(dist)
+	if len(alt) < n {
+		n = len(alt)
+	}
+	if end < 0 || end >= n {
+		end = n - 1
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	var flat, adjusted float64
+	back := start
+	for i := start + 1; i <= end; i++ {
+		d := dist[i] - dist[i-1]
+		if d <= 0 {
+			continue
+		}
+		if streams.Moving != nil && i < len(streams.Moving.Data) && !streams.Moving.Data[i] {
+			continue
+		}
+
+		// Measure the grade over the last gradeWindow meters
+		for back < i-1 && dist[i]-dist[back+1] >= gradeWindow {
+			back++
+		}
+		grade := 0.0
+		if span := dist[i] - dist[back]; span > 0 {
+			grade = (alt[i] - alt[back]) / span
+		}
+
+		flat += d
+		adjusted += d * runCost(grade)
+	}
+	if flat == 0 {
+		return 0, false
+	}
+	return adjusted / flat, true
+}
+
+func isRun(activityType string) bool {
+	return containsFold(runTypes, activityType)
+}
+
+func newGAPReportCmd() *cobra.Command {
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "gap",
+		Short: "Compare runs by pace and grade adjusted pace",
+		Long: `Shows the pace and grade adjusted pace (GAP) of the cached runs selected by
+--sport, --gear, --after, --before, --match, and --filter. GAP is the pace the same effort would give on
+flat ground, so hilly outdoor runs compare fairly with treadmill sessions.
+Runs without GPS are treated as flat and need no request; other runs cost
+one streams request the first time, after which their streams come from
+the cache.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if activities, err = filterActivities(expr, activities); err != nil {
+				logger.Fatal(err)
+			}
+			stored, err := cache.streamsStored()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			runs := make([]strava.Activity, 0)
+			outdoor := 0
+			for _, a := range activities {
+				if !isRun(a.Type) {
+					continue
+				}
+				runs = append(runs, a)
+				if a.Map != nil && a.Map.SummaryPolyline != "" && !stored[a.Id] {
+					outdoor++
+				}
+			}
+			if outdoor > maxStreamActivities {
+				logger.Fatalf("%d outdoor runs in range need streams, narrow the selection to at most %d or run hydrate first\n", outdoor, maxStreamActivities)
+			}
+
+			var client strava.ClientInterface
+			if outdoor > 0 {
+				c := newClient(ctx, logger, config)
+				authenticate(ctx, logger, c)
+				client = c
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "DATE\tNAME\tDISTANCE\tPACE\tGAP")
+			for _, a := range runs {
+				adjustment := 1.0
+				if a.Map != nil && a.Map.SummaryPolyline != "" {
+					streams, err := storedStreams(ctx, client, cache, config.Settings.Streams, int64(a.Id))
+					if err != nil {
+						logger.Fatalf("activity %d: %v\n", a.Id, err)
+					}
+					if adj, ok := gradeAdjustment(streams, 0, -1); ok {
+						adjustment = adj
+					}
+				}
+
+				distance, unit := config.Settings.Output.convert(a.Distance)
+				fmt.Fprintf(w, "%s\t%s\t%.2f %s\t%s\t%s\n", a.StartDate, a.Name, distance, unit,
+					formatPace(a.MovingTime, distance, unit), formatPace(a.MovingTime, distance*adjustment, unit))
+			}
+			w.Flush()
+		},
+	}
+
+	selection = addSelectionFlags(cmd)
+
+	return cmd
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONLineString `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONLineString struct {
+	Type string `json:"type"`
+	// Coordinates are [longitude, latitude], the reverse of Strava's order
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	Id          int     `json:"id"`
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	StartDate   string  `json:"start_date"`
+	Distance    float64 `json:"distance"`
+	MovingTime  int     `json:"moving_time"`
+	ElapsedTime int     `json:"elapsed_time"`
+}
+
+func newExportGeoJSONCmd() *cobra.Command {
+	var out string
+	var visibility []string
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "geojson",
+		Short: "Export cached activity tracks as a GeoJSON FeatureCollection",
+		Long: `Writes one LineString feature per cached activity, decoded from its
+summary polyline, for geojson.io, QGIS, or Leaflet. Activities without a
+GPS track, such as treadmill sessions, are left out.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			expr, err := selection.filter()
+			if err == nil {
+				expr, err = expr.withVisibility(visibility)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			var w io.Writer = os.Stdout
+			if out != "-" {
+				f, err := os.Create(out)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			bw := bufio.NewWriter(w)
+			count, err := exportGeoJSON(cache, bw, expr)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if err := bw.Flush(); err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Printf("Exported %d activity tracks as geojson\n", count)
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", "-", "output file, - for stdout")
+	selection = addSelectionFlags(cmd)
+	selection.aliasSports(cmd, "type")
+	cmd.Flags().StringSliceVar(&visibility, "visibility", nil, "only include activities with these visibilities: everyone, followers_only, only_me (default all)")
+
+	return cmd
+}
+
+// exportGeoJSON streams the FeatureCollection one feature at a time
+func exportGeoJSON(cache *activityCache, w io.Writer, filter *activityFilter) (int, error) {
+	if _, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := cache.eachActivity(func(a strava.Activity) error {
+		if ok, err := filter.match(a); err != nil || !ok {
+			return err
+		}
+		feature, ok, err := activityFeature(a)
+		if err != nil || !ok {
+			return err
+		}
+
+		data, err := json.Marshal(feature)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := filter.checkFields(); err != nil {
+		return count, err
+	}
+
+	_, err = io.WriteString(w, "\n]}\n")
+	return count, err
+}
+
+// activityFeature builds the feature for an activity, reporting false when
+// it has no track
+func activityFeature(a strava.Activity) (geoJSONFeature, bool, error) {
+	points, err := a.Map.Points()
+	if err != nil {
+		return geoJSONFeature{}, false, err
+	}
+	if len(points) < 2 {
+		return geoJSONFeature{}, false, nil
+	}
+
+	coordinates := make([][2]float64, 0, len(points))
+	for _, p := range points {
+		coordinates = append(coordinates, [2]float64{p[1], p[0]})
+	}
+	return geoJSONFeature{
+		Type:     "Feature",
+		Geometry: geoJSONLineString{Type: "LineString", Coordinates: coordinates},
+		Properties: geoJSONProperties{
+			Id:          a.Id,
+			Name:        a.Name,
+			Type:        a.Type,
+			StartDate:   a.StartDate,
+			Distance:    a.Distance,
+			MovingTime:  a.MovingTime,
+			ElapsedTime: a.ElapsedTime,
+		},
+	}, true, nil
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// writeGithubOutput appends the run results to the file referenced by
+// $GITHUB_OUTPUT so later workflow steps can read them as step outputs, and
+// emits a notice annotation summarizing the run, with a warning before it
+// when the totals are partial.
+func writeGithubOutput(totalMiles float64, activityCount int, streak int, partial bool) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return errors.New("--github-output requires the GITHUB_OUTPUT environment variable")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "total_miles=%.2f\nactivity_count=%d\nstreak=%d\npartial=%t\n", totalMiles, activityCount, streak, partial); err != nil {
+		return err
+	}
+
+	if partial {
+		fmt.Println("::warning title=Desk Treadmill::The sync failed partway, these totals leave out activities not fetched yet")
+	}
+	fmt.Printf("::notice title=Desk Treadmill::%d activities, %.2f miles, %d day streak\n", activityCount, totalMiles, streak)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// Goal metrics
+const (
+	goalCount      = "count"
+	goalDistance   = "distance"
+	goalMovingTime = "moving_time"
+	goalKilojoules = "kj"
+	goalCalories   = "kcal"
+	goalElevation  = "elevation"
+	goalEverests   = "everests"
+)
+
+// goalSettings is a target for one week, month, or year, such as burning
+// 3000 kcal a week or climbing 100,000 ft a year. Distance and elevation
+// targets are in the configured output units (miles and feet, or km and
+// meters) and moving time targets in hours.
+type goalSettings struct {
+	Label  string  `mapstructure:"label"`
+	Metric string  `mapstructure:"metric"`
+	Period string  `mapstructure:"period"`
+	Target float64 `mapstructure:"target"`
+	// Types limits the goal to some activity types, all types when empty
+	Types []string `mapstructure:"types"`
+}
+
+func (g goalSettings) validate() error {
+	switch g.Metric {
+	case goalCount, goalDistance, goalMovingTime, goalKilojoules, goalCalories, goalElevation, goalEverests:
+	default:
+		return fmt.Errorf("goal %q: metric must be count, distance, moving_time, kj, kcal, elevation, or everests, got %q", g.Label, g.Metric)
+	}
+	if !validPeriod(g.Period) {
+		return fmt.Errorf("goal %q: period must be week, month, or year, got %q", g.Label, g.Period)
+	}
+	if g.Target <= 0 {
+		return fmt.Errorf("goal %q: target must be positive", g.Label)
+	}
+	return nil
+}
+
+// goalProgress is how far the current period has come towards a goal
+type goalProgress struct {
+	Label  string  `json:"label"`
+	Metric string  `json:"metric"`
+	Period string  `json:"period"`
+	Value  float64 `json:"value"`
+	Target float64 `json:"target"`
+	Unit   string  `json:"unit"`
+}
+
+// Percent is the share of the target reached, which may pass 100
+func (p goalProgress) Percent() float64 {
+	return p.Value / p.Target * 100
+}
+
+func (p goalProgress) String() string {
+	return fmt.Sprintf("%s: %.1f/%.0f %s this %s (%.0f%%)", p.Label, p.Value, p.Target, p.Unit, p.Period, p.Percent())
+}
+
+// trackGoals measures each goal over the week, month, or year containing now
+func trackGoals(goals []goalSettings, activities []strava.Activity, calories map[int]float64, output outputSettings, now time.Time) []goalProgress {
+	progress := make([]goalProgress, 0, len(goals))
+	for _, g := range goals {
+		selected := make([]strava.Activity, 0)
+		for _, a := range activities {
+			if len(g.Types) == 0 || containsFold(g.Types, a.Type) {
+				selected = append(selected, a)
+			}
+		}
+
+		var current periodTotals
+		start := periodStart(now.Local(), g.Period)
+		for _, t := range aggregate(selected, g.Period, calories) {
+			if t.Start.Equal(start) {
+				current = t
+			}
+		}
+
+		p := goalProgress{Label: g.Label, Metric: g.Metric, Period: g.Period, Target: g.Target}
+		switch g.Metric {
+		case goalCount:
+			p.Value, p.Unit = float64(current.Count), "activities"
+		case goalDistance:
+			p.Value, p.Unit = output.convert(current.Distance)
+		case goalMovingTime:
+			p.Value, p.Unit = float64(current.MovingTime)/3600, "hours"
+		case goalKilojoules:
+			p.Value, p.Unit = current.Kilojoules, "kJ"
+		case goalCalories:
+			p.Value, p.Unit = current.Calories, "kcal"
+		case goalElevation:
+			p.Value, p.Unit = output.convertElevation(current.ElevationGain)
+		case goalEverests:
+			p.Value, p.Unit = current.Everests(), "Everests"
+		}
+		if p.Label == "" {
+			p.Label = g.Metric + " per " + g.Period
+		}
+		progress = append(progress, p)
+	}
+	return progress
+}
+
+// goalSummary renders goal progress for chat notifications
+func goalSummary(progress []goalProgress) string {
+	s := ""
+	for _, p := range progress {
+		s += "\nGoal " + p.String()
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// Strava only exports activities as GPX from the website, so backups
+// rebuild the track from the latlng, altitude, and time streams.
+
+type gpxFile struct {
+	XMLName xml.Name `xml:"gpx"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Name    string   `xml:"metadata>name"`
+	Time    string   `xml:"metadata>time,omitempty"`
+	Track   gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name   string     `xml:"name"`
+	Type   string     `xml:"type,omitempty"`
+	Points []gpxPoint `xml:"trkseg>trkpt"`
+}
+
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele,omitempty"`
+	Time string   `xml:"time,omitempty"`
+}
+
+// errNoTrack is returned for activities without GPS data
+var errNoTrack = errors.New("activity has no GPS track")
+
+// writeActivityGPX writes the activity's GPS track as GPX 1.1
+func writeActivityGPX(w io.Writer, activity strava.DetailedActivity, streams strava.Streams) error {
+	if streams.LatLng == nil || len(streams.LatLng.Data) == 0 {
+		return errNoTrack
+	}
+
+	start, _ := time.Parse(time.RFC3339, activity.StartDate)
+
+	track := gpxTrack{Name: activity.Name, Type: activity.Type}
+	for i, ll := range streams.LatLng.Data {
+		if len(ll) != 2 {
+			continue
+		}
+		point := gpxPoint{Lat: ll[0], Lon: ll[1]}
+		if streams.Altitude != nil && i < len(streams.Altitude.Data) {
+			ele := streams.Altitude.Data[i]
+			point.Ele = &ele
+		}
+		if streams.Time != nil && i < len(streams.Time.Data) && !start.IsZero() {
+			point.Time = start.Add(time.Duration(streams.Time.Data[i]) * time.Second).UTC().Format(time.RFC3339)
+		}
+		track.Points = append(track.Points, point)
+	}
+
+	file := gpxFile{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: "strava-api",
+		Name:    activity.Name,
+		Time:    activity.StartDate,
+		Track:   track,
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(file); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/brandtkeller/strava-api/pkg/stravapb"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer answers the StravaService RPCs from the activity cache
+type grpcServer struct {
+	stravapb.UnimplementedStravaServiceServer
+
+	logger *log.Logger
+	config envVars
+	client strava.ClientInterface
+	cache  *activityCache
+
+	// syncMu keeps syncs from overlapping
+	syncMu sync.Mutex
+}
+
+// selectActivities returns the cached activities within the date range,
+// of the given types, and matching the filter expression
+func (s *grpcServer) selectActivities(after, before string, types []string, filter string) ([]strava.Activity, error) {
+	from, to, err := parseDateRange(after, before)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	expr, err := parseFilter(filter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	activities, err := s.cache.activities()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	selected := make([]strava.Activity, 0, len(activities))
+	for _, a := range activities {
+		if inDateRange(a, from, to) && (len(types) == 0 || containsFold(types, a.Type)) {
+			selected = append(selected, a)
+		}
+	}
+	if selected, err = filterActivities(expr, selected); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return selected, nil
+}
+
+func (s *grpcServer) ListActivities(ctx context.Context, req *stravapb.ListActivitiesRequest) (*stravapb.ListActivitiesResponse, error) {
+	activities, err := s.selectActivities(req.After, req.Before, req.Types, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(activities, func(i, j int) bool { return activities[i].StartDate > activities[j].StartDate })
+	if req.Limit > 0 && len(activities) > int(req.Limit) {
+		activities = activities[:req.Limit]
+	}
+	return &stravapb.ListActivitiesResponse{Activities: activityMessages(activities)}, nil
+}
+
+func (s *grpcServer) GetSummary(ctx context.Context, req *stravapb.GetSummaryRequest) (*stravapb.GetSummaryResponse, error) {
+	if req.Period != "" && !validPeriod(req.Period) {
+		return nil, status.Errorf(codes.InvalidArgument, "period must be week, month, or year, got %q", req.Period)
+	}
+	activities, err := s.cache.activities()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	calories, err := s.cache.calories()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	now := time.Now()
+	sum, err := summarize(ctx, activities, aggregations(s.config.Settings.Rules, s.config.Settings.Metrics), now)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &stravapb.GetSummaryResponse{
+		Count:         int32(sum.Count),
+		Distance:      sum.Distance,
+		ElevationGain: sum.Elevation,
+		Streak:        int32(sum.Streak),
+	}
+	for _, g := range trackGoals(s.config.Settings.Goals, activities, calories, s.config.Settings.Output, now) {
+		res.Goals = append(res.Goals, &stravapb.GoalProgress{
+			Label:   g.Label,
+			Metric:  g.Metric,
+			Period:  g.Period,
+			Value:   g.Value,
+			Target:  g.Target,
+			Unit:    g.Unit,
+			Percent: g.Percent(),
+		})
+	}
+
+	if req.Period != "" {
+		selected, err := s.selectActivities(req.After, req.Before, req.Types, req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range aggregate(selected, req.Period, calories) {
+			res.Totals = append(res.Totals, &stravapb.PeriodTotals{
+				Start:         t.Start.Format(time.DateOnly),
+				Count:         int32(t.Count),
+				Distance:      t.Distance,
+				MovingTime:    int32(t.MovingTime),
+				ElapsedTime:   int32(t.ElapsedTime),
+				ElevationGain: t.ElevationGain,
+				Kilojoules:    t.Kilojoules,
+				Calories:      t.Calories,
+			})
+		}
+	}
+	return res, nil
+}
+
+func (s *grpcServer) Sync(ctx context.Context, req *stravapb.SyncRequest) (*stravapb.SyncResponse, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	added, _, err := syncActivities(ctx, s.logger, s.client, s.cache, s.config.Settings.Fetch)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, status.Errorf(codes.Unavailable, "syncing activities: %v", err)
+	}
+	activities, err := s.cache.activities()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Printf("Sync over gRPC added %d activities\n", len(added))
+	return &stravapb.SyncResponse{Added: activityMessages(added), Total: int32(len(activities))}, nil
+}
+
+func activityMessages(activities []strava.Activity) []*stravapb.Activity {
+	messages := make([]*stravapb.Activity, len(activities))
+	for i, a := range activities {
+		messages[i] = &stravapb.Activity{
+			Id:                 int64(a.Id),
+			Name:               a.Name,
+			Type:               a.Type,
+			StartDate:          a.StartDate,
+			StartDateLocal:     a.StartDateLocal,
+			Distance:           a.Distance,
+			MovingTime:         int32(a.MovingTime),
+			ElapsedTime:        int32(a.ElapsedTime),
+			TotalElevationGain: a.TotalElevationGain,
+			Kilojoules:         a.Kilojoules,
+			Commute:            a.Commute,
+			Trainer:            a.Trainer,
+			KudosCount:         int32(a.KudosCount),
+			CommentCount:       int32(a.CommentCount),
+		}
+	}
+	return messages
+}
+
+func newServeGRPCCmd() *cobra.Command {
+	var listen, healthListen string
+	var health healthSettings
+
+	cmd := &cobra.Command{
+		Use:   "grpc",
+		Short: "Serve the activity cache, summary, and sync over gRPC",
+		Long: `Listens on --listen for the strava.v1.StravaService defined in
+proto/strava/v1/strava.proto: ListActivities and GetSummary answer from the
+cache, and Sync fetches new activities from Strava. Server reflection is
+enabled, so tools such as grpcurl can call it without the .proto file.
+
+With --health-listen, /healthz and /readyz are served over HTTP on that
+address for container orchestrators: /healthz fails when no valid access
+token can be obtained, and /readyz also when no sync has finished within
+--max-sync-age or Strava cannot be reached.
+
+There is no authentication, so keep the default loopback address or expose
+it only on a trusted network.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			lis, err := net.Listen("tcp", listen)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			srv := grpc.NewServer()
+			stravapb.RegisterStravaServiceServer(srv, &grpcServer{logger: logger, config: config, client: client, cache: cache})
+			reflection.Register(srv)
+
+			if healthListen != "" {
+				mux := http.NewServeMux()
+				(&healthChecker{client: client, cache: cache, settings: health, logger: logger}).register(mux)
+				hs := &http.Server{Addr: healthListen, Handler: mux}
+				go func() {
+					logger.Printf("Serving health checks on %s\n", healthListen)
+					if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+						logger.Fatal(err)
+					}
+				}()
+				defer hs.Close()
+			}
+
+			go func() {
+				<-ctx.Done()
+				srv.GracefulStop()
+			}()
+			logger.Printf("Serving gRPC on %s\n", lis.Addr())
+			if err := srv.Serve(lis); err != nil {
+				logger.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:50051", "address to listen on")
+	cmd.Flags().StringVar(&healthListen, "health-listen", "", "serve /healthz and /readyz over HTTP on this address, e.g. :8081")
+	addHealthFlags(cmd, &health)
+
+	return cmd
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// lastSyncKey holds the time the last sync finished
+const lastSyncKey = "last_sync"
+
+// healthSettings are the flags shared by the commands that serve
+type healthSettings struct {
+	// MaxSyncAge fails readiness when the last sync finished longer ago,
+	// zero only requiring that one has
+	MaxSyncAge time.Duration
+	// ProbeInterval is how long a Strava reachability check is reused, as
+	// each costs a request against the rate limits
+	ProbeInterval time.Duration
+}
+
+func addHealthFlags(cmd *cobra.Command, s *healthSettings) {
+	cmd.Flags().DurationVar(&s.MaxSyncAge, "max-sync-age", 0, "report not ready when the last sync finished longer ago than this, e.g. 2h")
+	cmd.Flags().DurationVar(&s.ProbeInterval, "health-probe-interval", 15*time.Minute, "how often /readyz may call Strava to check it is reachable")
+}
+
+// healthCheck is one entry of a health response
+type healthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	// At is the token expiry, the last sync, or the last Strava check
+	At string `json:"at,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// healthChecker answers /healthz, which fails when no usable access token
+// can be obtained so the orchestrator restarts the pod, and /readyz, which
+// also needs a sync to have finished and Strava to be reachable
+type healthChecker struct {
+	client   strava.ClientInterface
+	cache    *activityCache
+	settings healthSettings
+	logger   *log.Logger
+
+	mu       sync.Mutex
+	probed   time.Time
+	probeErr error
+}
+
+// register adds the endpoints to mux
+func (h *healthChecker) register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h.respond(w, map[string]healthCheck{"token": h.token(r.Context())})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]healthCheck{"token": h.token(r.Context()), "last_sync": h.lastSync(time.Now())}
+		checks["strava"] = h.reachable(r.Context())
+		h.respond(w, checks)
+	})
+}
+
+func (h *healthChecker) respond(w http.ResponseWriter, checks map[string]healthCheck) {
+	res := healthResponse{Status: "ok", Checks: checks}
+	code := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			res.Status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+// token checks the access token, reloading it from the token store and
+// refreshing it once it has expired, as another process may have rotated
+// it. A token Strava rejected at the last probe counts as invalid too.
+func (h *healthChecker) token(ctx context.Context) healthCheck {
+	h.mu.Lock()
+	probeErr := h.probeErr
+	h.mu.Unlock()
+	var apiErr *strava.APIError
+	if errors.As(probeErr, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		return healthCheck{Error: probeErr.Error()}
+	}
+
+	if h.client.Token().Expired() {
+		if err := h.client.Authenticate(ctx); err != nil {
+			h.logger.Printf("Health check: %v\n", err)
+			return healthCheck{Error: err.Error()}
+		}
+	}
+	expires := time.Unix(h.client.Token().ExpiresAt, 0).UTC()
+	return healthCheck{OK: true, At: expires.Format(time.RFC3339)}
+}
+
+func (h *healthChecker) lastSync(now time.Time) healthCheck {
+	value, err := h.cache.state(lastSyncKey)
+	if err != nil {
+		return healthCheck{Error: err.Error()}
+	}
+	if value == "" {
+		return healthCheck{Error: "no sync has finished yet"}
+	}
+	check := healthCheck{OK: true, At: value}
+	at, err := time.Parse(time.RFC3339, value)
+	if err == nil && h.settings.MaxSyncAge > 0 && now.Sub(at) > h.settings.MaxSyncAge {
+		check.OK, check.Error = false, "last sync is older than "+h.settings.MaxSyncAge.String()
+	}
+	return check
+}
+
+// reachable probes Strava at most once per ProbeInterval. Without an
+// access token the probe could only fail, so it is skipped.
+func (h *healthChecker) reachable(ctx context.Context) healthCheck {
+	if h.client.Token().AccessToken == "" {
+		return healthCheck{Error: "skipped without an access token"}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// A failure is retried sooner, so a blip does not last a whole interval
+	interval := h.settings.ProbeInterval
+	if h.probeErr != nil {
+		interval = min(interval, time.Minute)
+	}
+	if h.probed.IsZero() || time.Since(h.probed) >= interval {
+		h.probeErr = h.client.Probe(ctx)
+		h.probed = time.Now()
+	}
+	check := healthCheck{OK: h.probeErr == nil, At: h.probed.UTC().Format(time.RFC3339)}
+	if h.probeErr != nil {
+		check.Error = h.probeErr.Error()
+	}
+	return check
+}
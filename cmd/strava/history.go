@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// syncRun is the record kept of every sync, finished or not
+type syncRun struct {
+	// Kind is full, resumed, or incremental
+	Kind     string
+	Started  time.Time
+	Finished time.Time
+	// Pages counts the pages stored in the cache
+	Pages    int
+	Added    int
+	Updated  int
+	APICalls int
+	// Error is empty for a sync that completed
+	Error string
+}
+
+func (c *activityCache) recordRun(run syncRun) error {
+	_, err := c.db.Exec(`INSERT INTO sync_runs (kind, started_at, finished_at, pages, added, updated, api_calls, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.Kind, run.Started.UTC().Format(time.RFC3339Nano), run.Finished.UTC().Format(time.RFC3339Nano),
+		run.Pages, run.Added, run.Updated, run.APICalls, run.Error)
+	return err
+}
+
+// recentRuns returns up to limit runs, newest first, only those that
+// failed when failed is set
+func (c *activityCache) recentRuns(limit int, failed bool) ([]syncRun, error) {
+	rows, err := c.db.Query(`SELECT kind, started_at, finished_at, pages, added, updated, api_calls, error
+		FROM sync_runs WHERE error != '' OR NOT ? ORDER BY id DESC LIMIT ?`, failed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]syncRun, 0)
+	for rows.Next() {
+		var run syncRun
+		var started, finished string
+		if err := rows.Scan(&run.Kind, &started, &finished, &run.Pages, &run.Added, &run.Updated, &run.APICalls, &run.Error); err != nil {
+			return nil, err
+		}
+		run.Started, _ = time.Parse(time.RFC3339Nano, started)
+		run.Finished, _ = time.Parse(time.RFC3339Nano, finished)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func newHistoryCmd() *cobra.Command {
+	var limit int
+	var failed bool
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List recent syncs with what each one fetched",
+		Long: `Lists the most recent syncs run against the cache, newest first, by any
+command: when each started and how long it took, the pages stored, the
+activities added and updated, the API calls made, and the error that
+stopped it. A run whose totals look wrong can be traced back to the sync
+that failed or fetched less than usual.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			runs, err := cache.recentRuns(limit, failed)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "STARTED\tKIND\tDURATION\tPAGES\tADDED\tUPDATED\tCALLS\tERROR")
+			for _, run := range runs {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", run.Started.Local().Format("2006-01-02 15:04:05"), run.Kind,
+					run.Finished.Sub(run.Started).Round(100*time.Millisecond), run.Pages, run.Added, run.Updated, run.APICalls, run.Error)
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "number of runs to show")
+	cmd.Flags().BoolVar(&failed, "failed", false, "only show runs that ended with an error")
+
+	return cmd
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// The kinds of hydration: details fetches the detail endpoint for calories
+// and best efforts, streams fetches the streams for peaks, power curves,
+// splits, and training load
+const (
+	hydrateDetails = "details"
+	hydrateStreams = "streams"
+)
+
+var hydrationKinds = []string{hydrateDetails, hydrateStreams}
+
+// maxHydrationAttempts is how often an item failing with something other
+// than the rate limit is retried before it is left out
+const maxHydrationAttempts = 3
+
+// hydrationItem is one queued fetch for a cached activity
+type hydrationItem struct {
+	Kind     string
+	Attempts int
+	Activity strava.Activity
+}
+
+// hydrationProgress counts the queue items of one kind
+type hydrationProgress struct {
+	Kind    string
+	Done    int
+	Pending int
+	Failed  int
+}
+
+// enqueueHydration queues the cached activities that have not been
+// hydrated for kind yet, skipping those already done by other commands,
+// and returns how many were added
+func (c *activityCache) enqueueHydration(kind string, now time.Time) (int, error) {
+	var done string
+	switch kind {
+	case hydrateDetails:
+		done = `id IN (SELECT activity_id FROM best_effort_checks)`
+	case hydrateStreams:
+		done = `id IN (SELECT activity_id FROM activity_streams)`
+	default:
+		return 0, fmt.Errorf("unknown hydration kind %q, use %s or %s", kind, hydrateDetails, hydrateStreams)
+	}
+	res, err := c.db.Exec(`INSERT OR IGNORE INTO hydration_queue (activity_id, kind, queued_at)
+		SELECT id, ?, ? FROM activities
+		WHERE id NOT IN (SELECT activity_id FROM deleted_activities) AND NOT (`+done+`)`,
+		kind, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// pendingHydration returns the queued items not done yet and not given up
+// on, newest activity first
+func (c *activityCache) pendingHydration(kinds []string) ([]hydrationItem, error) {
+	rows, err := c.db.Query(`SELECT q.kind, q.attempts, a.raw FROM hydration_queue q JOIN activities a ON a.id = q.activity_id
+		WHERE q.done_at IS NULL AND q.attempts < ? AND a.id NOT IN (SELECT activity_id FROM deleted_activities)
+		ORDER BY a.start_date DESC, q.kind`, maxHydrationAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wanted := make(map[string]bool)
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+	items := make([]hydrationItem, 0)
+	for rows.Next() {
+		var item hydrationItem
+		var raw string
+		if err := rows.Scan(&item.Kind, &item.Attempts, &raw); err != nil {
+			return nil, err
+		}
+		if !wanted[item.Kind] {
+			continue
+		}
+		if err := json.Unmarshal([]byte(raw), &item.Activity); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// finishHydration marks an item done
+func (c *activityCache) finishHydration(item hydrationItem, now time.Time) error {
+	_, err := c.db.Exec(`UPDATE hydration_queue SET done_at = ?, error = '' WHERE activity_id = ? AND kind = ?`,
+		now.UTC().Format(time.RFC3339), item.Activity.Id, item.Kind)
+	return err
+}
+
+// failHydration records a failed attempt at an item
+func (c *activityCache) failHydration(item hydrationItem, cause error) error {
+	_, err := c.db.Exec(`UPDATE hydration_queue SET attempts = attempts + 1, error = ? WHERE activity_id = ? AND kind = ?`,
+		cause.Error(), item.Activity.Id, item.Kind)
+	return err
+}
+
+// hydrationStatus counts the queue by kind
+func (c *activityCache) hydrationStatus() ([]hydrationProgress, error) {
+	rows, err := c.db.Query(`SELECT kind,
+			SUM(done_at IS NOT NULL),
+			SUM(done_at IS NULL AND attempts < ?),
+			SUM(done_at IS NULL AND attempts >= ?)
+		FROM hydration_queue GROUP BY kind ORDER BY kind`, maxHydrationAttempts, maxHydrationAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := make([]hydrationProgress, 0)
+	for rows.Next() {
+		var p hydrationProgress
+		if err := rows.Scan(&p.Kind, &p.Done, &p.Pending, &p.Failed); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+// retryHydration gives the items given up on another round of attempts
+func (c *activityCache) retryHydration() (int, error) {
+	res, err := c.db.Exec(`UPDATE hydration_queue SET attempts = 0 WHERE done_at IS NULL AND attempts >= ?`, maxHydrationAttempts)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// hydrate fetches and stores what item needs
+func hydrate(ctx context.Context, client strava.ClientInterface, cache *activityCache, settings settings, item hydrationItem) error {
+	a := item.Activity
+	switch item.Kind {
+	case hydrateDetails:
+		activity, err := client.GetActivity(ctx, int64(a.Id), false)
+		if err != nil {
+			return err
+		}
+		if _, err := cache.recordBestEfforts(activity); err != nil {
+			return err
+		}
+		if err := cache.setDevice(activity); err != nil {
+			return err
+		}
+		return cache.setCalories(activity)
+	case hydrateStreams:
+		streams, err := storedStreams(ctx, client, cache, settings.Streams, int64(a.Id))
+		if err != nil {
+			return err
+		}
+		if err := cache.setStreamPeaks(a.Id, a.StartDate, streams); err != nil {
+			return err
+		}
+		if streams.Watts != nil {
+			if err := cache.setPowerCurve(a.Id, a.StartDate, computePowerCurve(streams)); err != nil {
+				return err
+			}
+		}
+		if streams.Distance != nil {
+			s := activitySplits{
+				Metric:   computeSplits(streams, 1000),
+				Standard: computeSplits(streams, 1609.344),
+			}
+			if err := cache.setSplits(a.Id, s); err != nil {
+				return err
+			}
+		}
+		if training := settings.Training; training.FTP > 0 || training.MaxHR > 0 {
+			score, method := trainingLoad(training, a, streams)
+			return cache.setTrainingLoad(a.Id, method, score)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown hydration kind %q", item.Kind)
+}
+
+// quotaExhausted reports whether err means no more requests can be made
+// today, so the queue should stop rather than count it against the item
+func quotaExhausted(err error) bool {
+	var apiErr *strava.APIError
+	return errors.Is(err, strava.ErrDeferred) || errors.Is(err, strava.ErrRateLimited) ||
+		(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests)
+}
+
+func newHydrateCmd() *cobra.Command {
+	var kinds []string
+	var limit int
+	var status, retry bool
+
+	cmd := &cobra.Command{
+		Use:   "hydrate",
+		Short: "Work through fetching details and streams for every cached activity",
+		Long: `Queues every cached activity whose details or streams have not been
+fetched yet and works through the queue, newest first, as backfill
+priority requests. Details give calories and best efforts; streams give
+power and heart rate peaks, power curves, splits, and training load.
+
+Each finished item is recorded as it goes, so a run stopped by the daily
+rate limit, --limit, or Ctrl-C resumes where it left off: schedule it
+every day until it reports nothing left. Items that keep failing are left
+out after three attempts; --retry queues them again.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			now := time.Now()
+			for _, kind := range kinds {
+				added, err := cache.enqueueHydration(kind, now)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				if added > 0 {
+					logger.Printf("Queued %d activities for %s\n", added, kind)
+				}
+			}
+			if retry {
+				n, err := cache.retryHydration()
+				if err != nil {
+					logger.Fatal(err)
+				}
+				logger.Printf("Retrying %d failed items\n", n)
+			}
+			if status {
+				printHydrationStatus(cache, logger)
+				return
+			}
+
+			items, err := cache.pendingHydration(kinds)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if len(items) == 0 {
+				logger.Println("Nothing left to hydrate")
+				return
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			bulk := strava.WithPriority(ctx, strava.PriorityBulk)
+			done, failed := 0, 0
+			for i, item := range items {
+				if limit > 0 && i >= limit {
+					break
+				}
+				err := hydrate(bulk, client, cache, config.Settings, item)
+				if ctx.Err() != nil {
+					logger.Println("Interrupted")
+					break
+				}
+				if quotaExhausted(err) {
+					logger.Printf("Stopping: %v\n", err)
+					break
+				}
+				if err != nil {
+					logger.Printf("%s of activity %d: %v\n", item.Kind, item.Activity.Id, err)
+					if err := cache.failHydration(item, err); err != nil {
+						logger.Fatal(err)
+					}
+					failed++
+					continue
+				}
+				if err := cache.finishHydration(item, time.Now()); err != nil {
+					logger.Fatal(err)
+				}
+				done++
+			}
+
+			left := len(items) - done - failed
+			logger.Printf("Hydrated %d items, %d failed, %d left\n", done, failed, left)
+			if left > 0 {
+				logger.Println("Rerun to continue")
+			}
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&kinds, "kinds", hydrationKinds, "what to fetch: details, streams")
+	cmd.Flags().IntVar(&limit, "limit", 0, "maximum items to fetch this run, 0 for as many as the rate limit allows")
+	cmd.Flags().BoolVar(&status, "status", false, "show the queue's progress without fetching")
+	cmd.Flags().BoolVar(&retry, "retry", false, "queue the items left out after repeated failures again")
+
+	return cmd
+}
+
+func printHydrationStatus(cache *activityCache, logger *log.Logger) {
+	progress, err := cache.hydrationStatus()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tDONE\tPENDING\tFAILED")
+	for _, p := range progress {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", p.Kind, p.Done, p.Pending, p.Failed)
+	}
+	w.Flush()
+}
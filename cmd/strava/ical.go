@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// icalTimestamp is the UTC date-time format of RFC 5545
+const icalTimestamp = "20060102T150405Z"
+
+// icalLineLength is the longest content line RFC 5545 allows, in octets
+const icalLineLength = 75
+
+func newExportICalCmd() *cobra.Command {
+	var out, serve string
+	var visibility []string
+	var selection *activitySelection
+	var health healthSettings
+
+	cmd := &cobra.Command{
+		Use:   "ical",
+		Short: "Export cached activities as an iCalendar (.ics) feed",
+		Long: `Writes one calendar event per cached activity, spanning its elapsed time,
+with the distance, moving time, and pace in the description and a link to
+the activity on Strava.
+
+With --serve the feed is served over HTTP at /activities.ics instead, read
+from the cache on every request, so a calendar app can subscribe to it
+while a scheduled sync keeps the cache up to date. /healthz and /readyz are
+served alongside for container orchestrators: /healthz fails when no valid
+access token can be obtained, and /readyz also when no sync has finished
+within --max-sync-age or Strava cannot be reached.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			expr, err := selection.filter()
+			if err == nil {
+				expr, err = expr.withVisibility(visibility)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			if serve != "" {
+				health := &healthChecker{client: newClient(cmd.Context(), logger, config), cache: cache, settings: health, logger: logger}
+				if err := serveICal(cmd.Context(), logger, serve, cache, health, config.Settings.Output, expr); err != nil {
+					logger.Fatal(err)
+				}
+				return
+			}
+
+			var w io.Writer = os.Stdout
+			if out != "-" {
+				f, err := os.Create(out)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			bw := bufio.NewWriter(w)
+			count, err := exportICal(cache, bw, config.Settings.Output, expr, time.Now())
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if err := bw.Flush(); err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Printf("Exported %d activities as ical\n", count)
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", "-", "output file, - for stdout")
+	selection = addSelectionFlags(cmd)
+	selection.aliasSports(cmd, "type")
+	cmd.Flags().StringSliceVar(&visibility, "visibility", []string{strava.VisibilityEveryone}, "only include activities with these visibilities: everyone, followers_only, only_me")
+	cmd.Flags().StringVar(&serve, "serve", "", "serve the feed over HTTP on this address, e.g. :8080")
+	addHealthFlags(cmd, &health)
+
+	return cmd
+}
+
+// serveICal serves the feed until ctx is cancelled
+func serveICal(ctx context.Context, logger *log.Logger, addr string, cache *activityCache, health *healthChecker, output outputSettings, filter *activityFilter) error {
+	mux := http.NewServeMux()
+	health.register(mux)
+	mux.HandleFunc("/activities.ics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		count, err := exportICal(cache, bw, output, filter, time.Now())
+		if err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			logger.Printf("Serving calendar: %v\n", err)
+			return
+		}
+		logger.Printf("Served %d activities to %s\n", count, r.RemoteAddr)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	logger.Printf("Serving the activity calendar at http://%s/activities.ics\n", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// exportICal streams a VCALENDAR with one VEVENT per activity
+func exportICal(cache *activityCache, w io.Writer, output outputSettings, filter *activityFilter, now time.Time) (int, error) {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//brandtkeller//strava-api//EN",
+		"CALSCALE:GREGORIAN", "X-WR-CALNAME:Strava activities"}
+	if err := writeICalLines(w, lines...); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	stamp := now.UTC().Format(icalTimestamp)
+	err := cache.eachActivity(func(a strava.Activity) error {
+		if ok, err := filter.match(a); err != nil || !ok {
+			return err
+		}
+		start, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			return nil
+		}
+
+		distance, unit := output.convert(a.Distance)
+		description := fmt.Sprintf("%s\n%.2f %s in %s (%s)", a.Type, distance, unit, formatDuration(a.MovingTime), formatPace(a.MovingTime, distance, unit))
+		if a.TotalElevationGain > 0 {
+			elevation, elevationUnit := output.convertElevation(a.TotalElevationGain)
+			description += fmt.Sprintf("\n%.0f %s climbing", elevation, elevationUnit)
+		}
+		if a.Description != "" {
+			description += "\n\n" + a.Description
+		}
+		url := fmt.Sprintf("https://www.strava.com/activities/%d", a.Id)
+
+		err = writeICalLines(w,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:activity-%d@strava.com", a.Id),
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+start.UTC().Format(icalTimestamp),
+			"DTEND:"+start.Add(time.Duration(a.ElapsedTime)*time.Second).UTC().Format(icalTimestamp),
+			"SUMMARY:"+icalEscape(a.Name),
+			"DESCRIPTION:"+icalEscape(description+"\n\n"+url),
+			"URL:"+url,
+			"END:VEVENT",
+		)
+		if err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := filter.checkFields(); err != nil {
+		return count, err
+	}
+
+	return count, writeICalLines(w, "END:VCALENDAR")
+}
+
+// icalEscape escapes a TEXT value
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICalLines writes content lines terminated by CRLF, folding those
+// longer than icalLineLength octets without splitting a UTF-8 sequence
+func writeICalLines(w io.Writer, lines ...string) error {
+	for _, line := range lines {
+		var b strings.Builder
+		width := 0
+		for _, r := range line {
+			size := len(string(r))
+			if width+size > icalLineLength {
+				b.WriteString("\r\n ")
+				width = 1
+			}
+			b.WriteRune(r)
+			width += size
+		}
+		b.WriteString("\r\n")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
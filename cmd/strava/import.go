@@ -0,0 +1,521 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// archiveDateLayouts are the activity date formats seen in activities.csv,
+// which follow the account's language setting. Dates are always UTC.
+var archiveDateLayouts = []string{
+	"Jan 2, 2006, 3:04:05 PM",
+	"2 Jan 2006, 15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// csvDateLayouts are the local start times accepted by import csv. Rows
+// with only a date start at noon.
+var csvDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+func newImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Seed the local cache from files exported outside the API",
+	}
+	cmd.AddCommand(newImportArchiveCmd())
+	cmd.AddCommand(newImportCSVCmd())
+	return cmd
+}
+
+func newImportArchiveCmd() *cobra.Command {
+	var extract string
+	var overwrite bool
+
+	cmd := &cobra.Command{
+		Use:   "archive <export.zip>",
+		Short: "Import the bulk export archive Strava emails from account settings",
+		Long: `Reads activities.csv from a Strava bulk export and adds every activity
+to the local cache, so summaries and exports cover history the API can no
+longer return. Activities already cached from the API are left alone unless
+--overwrite is set. --extract also unpacks the GPX, TCX, and FIT files,
+decompressing any that are gzipped.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			archive, err := zip.OpenReader(args[0])
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer archive.Close()
+
+			activities, err := readArchiveActivities(&archive.Reader)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			imported := activities
+			if !overwrite {
+				imported, err = cache.missingActivities(activities)
+				if err != nil {
+					logger.Fatal(err)
+				}
+			}
+			if err := cache.upsertActivities(imported); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Imported %d of %d archived activities\n", len(imported), len(activities))
+
+			if extract != "" {
+				count, err := extractArchiveFiles(&archive.Reader, extract)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				logger.Printf("Extracted %d activity files to %s\n", count, extract)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&extract, "extract", "", "directory to unpack the activity files into")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "replace cached activities with the archived copy")
+
+	return cmd
+}
+
+// readArchiveActivities parses activities.csv from the archive
+func readArchiveActivities(archive *zip.Reader) ([]strava.Activity, error) {
+	var file *zip.File
+	for _, f := range archive.File {
+		if path.Base(f.Name) == "activities.csv" {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		return nil, errors.New("archive has no activities.csv, is it a Strava bulk export?")
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	r := csv.NewReader(rc)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("activities.csv: %w", err)
+	}
+	columns := archiveColumns(header)
+	for _, required := range []string{"Activity ID", "Activity Date", "Activity Type"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("activities.csv: missing %q column", required)
+		}
+	}
+
+	activities := make([]strava.Activity, 0)
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("activities.csv: %w", err)
+		}
+
+		a, err := archiveActivity(columns, record)
+		if err != nil {
+			return nil, fmt.Errorf("activities.csv line %d: %w", line, err)
+		}
+		activities = append(activities, a)
+	}
+	return activities, nil
+}
+
+// archiveColumns maps column names to their index. Newer exports repeat
+// Distance and Elapsed Time: the first copy is in display units, the later
+// one in meters and seconds, so the last occurrence wins. A lone Distance
+// column is in kilometers and is marked with a "km" suffix.
+func archiveColumns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	distances := 0
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		columns[name] = i
+		if name == "Distance" {
+			distances++
+		}
+	}
+	if distances == 1 {
+		columns["Distance km"] = columns["Distance"]
+		delete(columns, "Distance")
+	}
+	return columns
+}
+
+func archiveActivity(columns map[string]int, record []string) (strava.Activity, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	number := func(name string) float64 {
+		v, _ := strconv.ParseFloat(strings.ReplaceAll(field(name), ",", ""), 64)
+		return v
+	}
+
+	id, err := strconv.Atoi(field("Activity ID"))
+	if err != nil {
+		return strava.Activity{}, fmt.Errorf("invalid activity id: %w", err)
+	}
+	start, err := parseArchiveDate(field("Activity Date"))
+	if err != nil {
+		return strava.Activity{}, err
+	}
+
+	a := strava.Activity{
+		Id:          id,
+		Name:        field("Activity Name"),
+		Description: field("Activity Description"),
+		Type:        strings.ReplaceAll(field("Activity Type"), " ", ""),
+		StartDate:   start.Format(time.RFC3339),
+		Distance:    number("Distance"),
+		ElapsedTime: int(number("Elapsed Time")),
+		MovingTime:  int(number("Moving Time")),
+	}
+	if _, ok := columns["Distance km"]; ok {
+		a.Distance = number("Distance km") * 1000
+	}
+	if a.MovingTime == 0 {
+		a.MovingTime = a.ElapsedTime
+	}
+	return a, nil
+}
+
+func parseArchiveDate(value string) (time.Time, error) {
+	for _, layout := range archiveDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised activity date %q", value)
+}
+
+// extractArchiveFiles unpacks the activity files into dir, skipping files
+// already extracted
+func extractArchiveFiles(archive *zip.Reader, dir string) (int, error) {
+	count := 0
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || path.Dir(f.Name) != "activities" && !strings.HasSuffix(path.Dir(f.Name), "/activities") {
+			continue
+		}
+
+		name := path.Base(f.Name)
+		gzipped := strings.HasSuffix(name, ".gz")
+		name = strings.TrimSuffix(name, ".gz")
+		dest := filepath.Join(dir, name)
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+
+		if err := extractArchiveFile(f, dest, gzipped); err != nil {
+			return count, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func extractArchiveFile(f *zip.File, dest string, gzipped bool) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if gzipped {
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return writeFile(dest, data)
+}
+
+func newImportCSVCmd() *cobra.Command {
+	var trainer bool
+
+	cmd := &cobra.Command{
+		Use:   "csv <file.csv>",
+		Short: "Create manual activities on Strava from a spreadsheet export",
+		Long: `Reads a CSV file with a header row naming the columns date, name, type,
+distance, and duration, in any order, and creates a manual activity on
+Strava for each row. Dates are local, YYYY-MM-DD with an optional HH:MM time;
+distances are in the configured output units; durations are h:mm:ss, mm:ss,
+a number of minutes, or a duration such as 1h35m. Rows without a name or type
+use desk.name and desk.type from the settings file.
+
+Every row is checked before anything is created. Rows that look already
+logged, with a cached activity or an earlier row of the same sport type on
+the same day and a distance within 10%, are skipped, so sync first and rerun freely after a
+failure. Creating activities needs the activity:write scope.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				logger.Fatal(err)
+			}
+			rows, err := readActivityCSV(f, config.Settings)
+			f.Close()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			cached, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			pending := make([]strava.CreatableActivity, 0, len(rows))
+			for _, row := range rows {
+				if dup, ok := loggedActivity(cached, row); ok {
+					if dup.Id == 0 {
+						logger.Printf("Skipping %s %q, repeated in the file\n", row.StartDateLocal, row.Name)
+					} else {
+						logger.Printf("Skipping %s %q, already logged as %d\n", row.StartDateLocal, row.Name, dup.Id)
+					}
+					continue
+				}
+				row.Trainer = trainer
+				pending = append(pending, row)
+				// Later rows are checked against this one too, so a row
+				// repeated in the file is created once
+				cached = append(cached, strava.Activity{
+					Name:           row.Name,
+					SportType:      row.SportType,
+					StartDateLocal: row.StartDateLocal,
+					Distance:       row.Distance,
+				})
+			}
+			if len(pending) == 0 {
+				logger.Printf("All %d rows are already logged\n", len(rows))
+				return
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			created, err := createActivities(ctx, logger, client, cache, pending)
+			if err != nil {
+				logger.Fatalf("Created %d of %d activities: %v\n", created, len(pending), err)
+			}
+			if viper.GetBool("dry-run") {
+				logger.Printf("Would create %d activities, skipped %d already logged\n", created, len(rows)-len(pending))
+			} else {
+				logger.Printf("Created %d activities, skipped %d already logged\n", created, len(rows)-len(pending))
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&trainer, "trainer", false, "mark every activity as a trainer or treadmill session")
+
+	return cmd
+}
+
+// readActivityCSV parses the rows of an import csv file into activities to
+// create
+func readActivityCSV(r io.Reader, s settings) ([]strava.CreatableActivity, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"date", "duration"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+
+	rows := make([]strava.CreatableActivity, 0)
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		start, err := parseCSVDate(field("date"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		elapsed, err := parseCSVDuration(field("duration"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		var distance float64
+		if v := field("distance"); v != "" {
+			if distance, err = strconv.ParseFloat(strings.ReplaceAll(v, ",", ""), 64); err != nil || distance < 0 {
+				return nil, fmt.Errorf("line %d: invalid distance %q", line, v)
+			}
+		}
+
+		row := strava.CreatableActivity{
+			Name:           field("name"),
+			SportType:      strings.ReplaceAll(field("type"), " ", ""),
+			StartDateLocal: start.Format("2006-01-02T15:04:05"),
+			ElapsedTime:    int(elapsed.Seconds()),
+			Distance:       s.Output.toMeters(distance),
+		}
+		if row.Name == "" {
+			row.Name = s.Desk.Name
+		}
+		if row.SportType == "" {
+			row.SportType = s.Desk.Type
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseCSVDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t.Add(12 * time.Hour), nil
+	}
+	for _, layout := range csvDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD [HH:MM]", value)
+}
+
+// parseCSVDuration reads h:mm:ss, mm:ss, minutes, or a Go duration
+func parseCSVDuration(value string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration %q, expected h:mm:ss, mm:ss, or minutes", value)
+	var d time.Duration
+	switch parts := strings.Split(value, ":"); {
+	case len(parts) == 2 || len(parts) == 3:
+		for _, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return 0, invalid
+			}
+			d = d*60 + time.Duration(n)*time.Second
+		}
+	default:
+		if minutes, err := strconv.ParseFloat(value, 64); err == nil {
+			d = time.Duration(minutes * float64(time.Minute))
+		} else if d, err = time.ParseDuration(value); err != nil {
+			return 0, invalid
+		}
+	}
+	if d <= 0 {
+		return 0, invalid
+	}
+	return d, nil
+}
+
+// loggedActivity finds a cached activity of the same sport type on the same
+// local day whose distance is within duplicateDistance of the row's.
+// Activities cached without a sport type are compared by their type.
+func loggedActivity(cached []strava.Activity, row strava.CreatableActivity) (strava.Activity, bool) {
+	day := row.StartDateLocal[:len(time.DateOnly)]
+	for _, a := range cached {
+		local := a.StartDateLocal
+		if local == "" {
+			start, err := time.Parse(time.RFC3339, a.StartDate)
+			if err != nil {
+				continue
+			}
+			local = start.Local().Format(time.RFC3339)
+		}
+		sport := a.SportType
+		if sport == "" {
+			sport = a.Type
+		}
+		if strings.HasPrefix(local, day) && strings.EqualFold(sport, row.SportType) && similarDistance(a.Distance, row.Distance) {
+			return a, true
+		}
+	}
+	return strava.Activity{}, false
+}
+
+// createActivities creates the activities in order, returning how many
+// were created before any failure
+func createActivities(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, rows []strava.CreatableActivity) (int, error) {
+	for i, row := range rows {
+		created, err := createActivity(ctx, client, cache, row)
+		if err != nil {
+			return i, err
+		}
+		if viper.GetBool("dry-run") {
+			logger.Printf("Would create %s %q\n", row.StartDateLocal, created.Name)
+		} else {
+			logger.Printf("Created %d %s %q\n", created.Id, row.StartDateLocal, created.Name)
+		}
+	}
+	return len(rows), nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// unknownDevice groups activities whose details were never fetched
+const unknownDevice = "unknown"
+
+// setDevice stores the name of the device that recorded a detailed
+// activity, which the list endpoint does not return
+func (c *activityCache) setDevice(activity strava.DetailedActivity) error {
+	if activity.DeviceName == "" {
+		return nil
+	}
+	_, err := c.db.Exec(`INSERT INTO activity_devices (activity_id, device_name) VALUES (?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET device_name = excluded.device_name`, activity.Id, activity.DeviceName)
+	return err
+}
+
+// devices returns every stored device name by activity id
+func (c *activityCache) devices() (map[int]string, error) {
+	rows, err := c.db.Query(`SELECT activity_id, device_name FROM activity_devices`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		devices[id] = name
+	}
+	return devices, rows.Err()
+}
+
+// environmentTotals splits the totals of one period into the activities
+// recorded indoors and outdoors
+type environmentTotals struct {
+	Start   time.Time    `json:"start"`
+	Indoor  periodTotals `json:"indoor"`
+	Outdoor periodTotals `json:"outdoor"`
+}
+
+// IndoorShare is the fraction of the moving time spent indoors
+func (t environmentTotals) IndoorShare() float64 {
+	total := t.Indoor.MovingTime + t.Outdoor.MovingTime
+	if total == 0 {
+		return 0
+	}
+	return float64(t.Indoor.MovingTime) / float64(total)
+}
+
+// aggregateEnvironments totals the indoor and outdoor activities by
+// period, oldest first
+func aggregateEnvironments(activities []strava.Activity, period string) []environmentTotals {
+	byStart := make(map[time.Time]*environmentTotals)
+	at := func(start time.Time) *environmentTotals {
+		t, ok := byStart[start]
+		if !ok {
+			t = &environmentTotals{Start: start, Indoor: periodTotals{Start: start}, Outdoor: periodTotals{Start: start}}
+			byStart[start] = t
+		}
+		return t
+	}
+	var indoor, outdoor []strava.Activity
+	for _, a := range activities {
+		if a.Indoor() {
+			indoor = append(indoor, a)
+		} else {
+			outdoor = append(outdoor, a)
+		}
+	}
+	for _, t := range aggregate(indoor, period, nil) {
+		at(t.Start).Indoor = t
+	}
+	for _, t := range aggregate(outdoor, period, nil) {
+		at(t.Start).Outdoor = t
+	}
+
+	totals := make([]environmentTotals, 0, len(byStart))
+	for _, t := range byStart {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Start.Before(totals[j].Start) })
+	return totals
+}
+
+// deviceTotals sums the activities recorded with one device
+type deviceTotals struct {
+	Device     string  `json:"device"`
+	Count      int     `json:"count"`
+	Indoor     int     `json:"indoor"`
+	Distance   float64 `json:"distance"`
+	MovingTime int     `json:"moving_time"`
+}
+
+// aggregateDevices totals activities by the device that recorded them,
+// most used first
+func aggregateDevices(activities []strava.Activity, devices map[int]string) []deviceTotals {
+	byDevice := make(map[string]*deviceTotals)
+	for _, a := range activities {
+		name, ok := devices[a.Id]
+		if !ok {
+			name = unknownDevice
+		}
+		t, ok := byDevice[name]
+		if !ok {
+			t = &deviceTotals{Device: name}
+			byDevice[name] = t
+		}
+		t.Count++
+		if a.Indoor() {
+			t.Indoor++
+		}
+		t.Distance += a.Distance
+		t.MovingTime += a.MovingTime
+	}
+
+	totals := make([]deviceTotals, 0, len(byDevice))
+	for _, t := range byDevice {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Count != totals[j].Count {
+			return totals[i].Count > totals[j].Count
+		}
+		return totals[i].Device < totals[j].Device
+	})
+	return totals
+}
+
+func newIndoorReportCmd() *cobra.Command {
+	var period, format string
+	var byDevice bool
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "indoor",
+		Short: "Split activity volume into indoor and outdoor by week, month, or year",
+		Long: `Totals the cached activities selected by --sport, --gear, --workout,
+--after, --before, --match, and --filter by --period, indoor and outdoor
+apart, with the share of the moving time spent indoors. Activities marked
+as on a trainer and virtual sports, such as a VirtualRide from Zwift,
+count as indoor. Their distance is not covered on the road, so keep them
+out of the other reports with --outdoor, or look at them alone with
+--indoor.
+
+--by-device totals the activities by the device or app that recorded
+them instead. Strava only returns the device with an activity's details,
+so it is known for activities fetched by hydrate, fetch.track_prs, or prs
+running backfill; the rest are counted as unknown.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			if !validPeriod(period) {
+				logger.Fatalf("unknown period %q, expected week, month, or year\n", period)
+			}
+			if format != "table" && format != "json" {
+				logger.Fatalf("unknown format %q, expected table or json\n", format)
+			}
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			selected, err := filterActivities(expr, activities)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			output := config.Settings.Output
+
+			if byDevice {
+				devices, err := cache.devices()
+				if err != nil {
+					logger.Fatal(err)
+				}
+				totals := aggregateDevices(selected, devices)
+				if format == "json" {
+					printJSON(logger, totals)
+					return
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "DEVICE\tCOUNT\tINDOOR\tDISTANCE\tMOVING")
+				for _, t := range totals {
+					distance, unit := output.convert(t.Distance)
+					fmt.Fprintf(w, "%s\t%d\t%d\t%.2f %s\t%s\n", t.Device, t.Count, t.Indoor, distance, unit, formatDuration(t.MovingTime))
+				}
+				w.Flush()
+				return
+			}
+
+			totals := aggregateEnvironments(selected, period)
+			if format == "json" {
+				printJSON(logger, totals)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "PERIOD\tOUTDOOR\tDISTANCE\tMOVING\tINDOOR\tDISTANCE\tMOVING\tINDOOR %")
+			for _, t := range totals {
+				outdoor, unit := output.convert(t.Outdoor.Distance)
+				indoor, _ := output.convert(t.Indoor.Distance)
+				fmt.Fprintf(w, "%s\t%d\t%.2f %s\t%s\t%d\t%.2f %s\t%s\t%.0f%%\n", t.Start.Format(time.DateOnly),
+					t.Outdoor.Count, outdoor, unit, formatDuration(t.Outdoor.MovingTime),
+					t.Indoor.Count, indoor, unit, formatDuration(t.Indoor.MovingTime), t.IndoorShare()*100)
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&period, "period", periodMonth, "aggregate by week, month, or year")
+	cmd.Flags().BoolVar(&byDevice, "by-device", false, "total by recording device instead of by period")
+	selection = addSelectionFlags(cmd)
+	// Both sides are shown
+	cmd.Flags().MarkHidden("indoor")
+	cmd.Flags().MarkHidden("outdoor")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+
+	return cmd
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/brandtkeller/strava-api/pkg/strava/keyring"
+	"github.com/spf13/cobra"
+)
+
+func newKeyringCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keyring",
+		Short: "Manage secrets stored in the OS keyring",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "import",
+		Short: "Move the client secret and refresh token from strava.env into the OS keyring",
+		Long: `Copies STRAVA_CLIENT_SECRET and STRAVA_REFRESH_TOKEN into the OS keyring.
+Once imported, set STRAVA_TOKEN_STORE=keyring and remove both values from strava.env.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+			store := keyring.New(config.StravaKeyringAccount)
+
+			if config.StravaClientSecret != "" {
+				if err := store.SetClientSecret(config.StravaClientSecret); err != nil {
+					logger.Fatal(err)
+				}
+				logger.Println("Client secret saved to keyring")
+			}
+			if config.StravaRefreshToken != "" {
+				if err := store.Save(cmd.Context(), strava.Token{RefreshToken: config.StravaRefreshToken}); err != nil {
+					logger.Fatal(err)
+				}
+				logger.Println("Refresh token saved to keyring")
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete",
+		Short: "Remove the stored client secret and token from the OS keyring",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+			if err := keyring.New(config.StravaKeyringAccount).Delete(); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Println("Keyring entries deleted")
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// The club feed has no ids or dates, so each activity is keyed by its
+// contents and dated by when it was first seen. Syncing the feed at least
+// daily keeps the dates close to when activities were uploaded.
+
+// clubActivityKey identifies a club feed activity by its contents
+func clubActivityKey(a strava.ClubActivity) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%.1f|%d|%d",
+		a.Athlete.Firstname, a.Athlete.Lastname, a.Name, a.Type, a.Distance, a.MovingTime, a.ElapsedTime)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// recordClubActivities stores feed activities not seen before, dated now
+func (c *activityCache) recordClubActivities(clubID int64, activities []strava.ClubActivity, now time.Time) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO club_activities (club_id, key, first_seen, athlete, raw) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	seen := now.UTC().Format(time.RFC3339)
+	for _, a := range activities {
+		raw, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		athlete := strings.TrimSpace(a.Athlete.Firstname + " " + a.Athlete.Lastname)
+		if _, err := stmt.Exec(clubID, clubActivityKey(a), seen, athlete, string(raw)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// clubActivitiesSeen returns the feed activities first seen in [from, to)
+func (c *activityCache) clubActivitiesSeen(clubID int64, from, to time.Time) ([]strava.ClubActivity, error) {
+	rows, err := c.db.Query(`SELECT raw FROM club_activities WHERE club_id = ? AND first_seen >= ? AND first_seen < ?`,
+		clubID, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := make([]strava.ClubActivity, 0)
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var a strava.ClubActivity
+		if err := json.Unmarshal([]byte(raw), &a); err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
+
+// leaderboardEntry is one member's totals for the period
+type leaderboardEntry struct {
+	Athlete    string
+	Activities int
+	Distance   float64
+	MovingTime int
+	Elevation  float64
+}
+
+// buildLeaderboard totals activities per athlete, ranked by distance
+func buildLeaderboard(activities []strava.ClubActivity) []leaderboardEntry {
+	byAthlete := make(map[string]*leaderboardEntry)
+	for _, a := range activities {
+		name := strings.TrimSpace(a.Athlete.Firstname + " " + a.Athlete.Lastname)
+		e, ok := byAthlete[name]
+		if !ok {
+			e = &leaderboardEntry{Athlete: name}
+			byAthlete[name] = e
+		}
+		e.Activities++
+		e.Distance += a.Distance
+		e.MovingTime += a.MovingTime
+		e.Elevation += a.TotalElevationGain
+	}
+
+	entries := make([]leaderboardEntry, 0, len(byAthlete))
+	for _, e := range byAthlete {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Distance != entries[j].Distance {
+			return entries[i].Distance > entries[j].Distance
+		}
+		return entries[i].Athlete < entries[j].Athlete
+	})
+	return entries
+}
+
+// weekStart returns midnight on the Monday of t's week
+func weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func newLeaderboardCmd() *cobra.Command {
+	var clubID int64
+	var lastWeek, markdown bool
+	var sport string
+
+	cmd := &cobra.Command{
+		Use:   "leaderboard",
+		Short: "Rank club members by distance for the week",
+		Long: `Reads the club's activity feed, remembers which activities are new, and ranks
+members by the distance of the activities first seen this week, Monday to
+Sunday. The feed has no activity dates, so run this (or club activities)
+at least daily to date activities accurately. --week reports the previous
+full week, for posting on Mondays.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			now := time.Now()
+			feed := listClubActivities(ctx, logger, client, clubID, 1000)
+			if err := cache.recordClubActivities(clubID, feed, now); err != nil {
+				logger.Fatal(err)
+			}
+
+			from := weekStart(now)
+			if lastWeek {
+				from = from.AddDate(0, 0, -7)
+			}
+			to := from.AddDate(0, 0, 7)
+
+			activities, err := cache.clubActivitiesSeen(clubID, from, to)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if sport != "" {
+				filtered := activities[:0]
+				for _, a := range activities {
+					if strings.EqualFold(a.Type, sport) || strings.EqualFold(a.SportType, sport) {
+						filtered = append(filtered, a)
+					}
+				}
+				activities = filtered
+			}
+
+			entries := buildLeaderboard(activities)
+			output := config.Settings.Output
+			title := output.printer().Sprintf("Week of %s", output.formatDate(from))
+			if markdown {
+				writeLeaderboardMarkdown(os.Stdout, title, output, entries)
+				return
+			}
+			writeLeaderboardTable(os.Stdout, title, output, entries)
+		},
+	}
+
+	cmd.Flags().Int64Var(&clubID, "club", 0, "club id")
+	cmd.Flags().BoolVar(&lastWeek, "week", false, "report the previous full week instead of the current one")
+	cmd.Flags().BoolVar(&markdown, "markdown", false, "print a Markdown post instead of a table")
+	cmd.Flags().StringVar(&sport, "type", "", "only count activities of this type, e.g. Run")
+	cmd.RegisterFlagCompletionFunc("type", completeSport)
+	cmd.MarkFlagRequired("club")
+
+	return cmd
+}
+
+func writeLeaderboardTable(out io.Writer, title string, output outputSettings, entries []leaderboardEntry) {
+	p := output.printer()
+	fmt.Fprintln(out, title)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, output.header("Rank", "Athlete", "Activities", "Distance", "Moving time", "Elevation"))
+	for i, e := range entries {
+		distance, unit := output.convert(e.Distance)
+		p.Fprintf(w, "%d\t%s\t%d\t%.2f %s\t%s\t%.0f m\n", i+1, e.Athlete, e.Activities, distance, unit, formatDuration(e.MovingTime), e.Elevation)
+	}
+	w.Flush()
+}
+
+func writeLeaderboardMarkdown(out io.Writer, title string, output outputSettings, entries []leaderboardEntry) {
+	p := output.printer()
+	fmt.Fprintf(out, "## %s\n\n", title)
+	if len(entries) == 0 {
+		fmt.Fprintln(out, p.Sprintf("No activities this week."))
+		return
+	}
+
+	var total float64
+	for _, e := range entries {
+		total += e.Distance
+	}
+	distance, unit := output.convert(total)
+	fmt.Fprintf(out, "%s\n\n", p.Sprintf("%d members covered %.1f %s together.", len(entries), distance, unit))
+
+	fmt.Fprintf(out, "| %s |\n", strings.Join(translate(p, "Rank", "Athlete", "Activities", "Distance", "Moving time", "Elevation"), " | "))
+	fmt.Fprintln(out, "|-----:|---------|-----------:|---------:|------------:|----------:|")
+	for i, e := range entries {
+		distance, unit := output.convert(e.Distance)
+		p.Fprintf(out, "| %d | %s | %d | %.2f %s | %s | %.0f m |\n", i+1, e.Athlete, e.Activities, distance, unit, formatDuration(e.MovingTime), e.Elevation)
+	}
+}
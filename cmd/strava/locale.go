@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// languages are the languages reports can be printed in, English first as
+// the fallback
+var languages = []language.Tag{language.English, language.German, language.French, language.Spanish}
+
+var languageMatcher = language.NewMatcher(languages)
+
+// reportTranslations maps the English report labels and sentences to their
+// German, French, and Spanish translations, in that order
+var reportTranslations = map[string][3]string{
+	"Rank":        {"Rang", "Rang", "Puesto"},
+	"Athlete":     {"Athlet", "Athlète", "Atleta"},
+	"Activities":  {"Aktivitäten", "Activités", "Actividades"},
+	"Distance":    {"Distanz", "Distance", "Distancia"},
+	"Moving time": {"Bewegungszeit", "Temps en mouvement", "Tiempo en movimiento"},
+	"Elevation":   {"Höhenmeter", "Dénivelé", "Desnivel"},
+	"Period":      {"Zeitraum", "Période", "Periodo"},
+	"Count":       {"Anzahl", "Nombre", "Cantidad"},
+	"Moving":      {"Bewegung", "Mouvement", "Movimiento"},
+	"Elapsed":     {"Gesamt", "Écoulé", "Transcurrido"},
+	"Stopped":     {"Pause", "Arrêt", "Parado"},
+	"Climbing":    {"Anstieg", "Montée", "Ascenso"},
+	"Workout":     {"Training", "Séance", "Entrenamiento"},
+	"Date":        {"Datum", "Date", "Fecha"},
+	"Name":        {"Name", "Nom", "Nombre"},
+	"Type":        {"Typ", "Type", "Tipo"},
+	"Stopped %%":  {"Pause %%", "Arrêt %%", "Parado %%"},
+	"Week of %s":  {"Woche vom %s", "Semaine du %s", "Semana del %s"},
+	"%d members covered %.1f %s together.": {
+		"%d Mitglieder legten zusammen %.1f %s zurück.",
+		"%d membres ont parcouru %.1f %s ensemble.",
+		"%d miembros recorrieron %.1f %s juntos.",
+	},
+	"No activities this week.": {"Keine Aktivitäten diese Woche.", "Aucune activité cette semaine.", "Ninguna actividad esta semana."},
+}
+
+// monthNames are the German, French, and Spanish month names for dates
+var monthNames = map[language.Tag][12]string{
+	language.German:  {"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+	language.French:  {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	language.Spanish: {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+}
+
+var reportCatalog = newReportCatalog()
+
+func newReportCatalog() catalog.Catalog {
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+	for english, translated := range reportTranslations {
+		for i, tag := range languages[1:] {
+			b.SetString(tag, english, translated[i])
+		}
+	}
+	return b
+}
+
+// parseLanguage checks that a language tag such as de or fr-CH is one
+// reports can be printed in
+func parseLanguage(name string) (language.Tag, error) {
+	tag, err := language.Parse(name)
+	if err != nil {
+		return language.Und, fmt.Errorf("output.language: %w", err)
+	}
+	if _, _, confidence := languageMatcher.Match(tag); confidence == language.No {
+		return language.Und, fmt.Errorf("output.language must be one of en, de, fr, or es, got %q", name)
+	}
+	return tag, nil
+}
+
+// locale returns the configured language, or English
+func (o outputSettings) locale() language.Tag {
+	tag, err := parseLanguage(o.Language)
+	if err != nil {
+		return language.English
+	}
+	return tag
+}
+
+// printer translates report labels and formats numbers with the decimal
+// and grouping separators of the configured language
+func (o outputSettings) printer() *message.Printer {
+	return message.NewPrinter(o.locale(), message.Catalog(reportCatalog))
+}
+
+// translate looks up each label in p's language
+func translate(p *message.Printer, labels ...string) []string {
+	translated := make([]string, len(labels))
+	for i, label := range labels {
+		translated[i] = p.Sprintf(label)
+	}
+	return translated
+}
+
+// header translates labels into a tab-separated table header
+func (o outputSettings) header(labels ...string) string {
+	return strings.ToUpper(strings.Join(translate(o.printer(), labels...), "\t"))
+}
+
+// formatDate writes a day the way the configured language does, e.g.
+// Jan 2, 2006 or 2. Januar 2006
+func (o outputSettings) formatDate(t time.Time) string {
+	_, index, _ := languageMatcher.Match(o.locale())
+	tag := languages[index]
+	month := monthNames[tag][t.Month()-1]
+	switch tag {
+	case language.German:
+		return fmt.Sprintf("%d. %s %d", t.Day(), month, t.Year())
+	case language.French:
+		return fmt.Sprintf("%d %s %d", t.Day(), month, t.Year())
+	case language.Spanish:
+		return fmt.Sprintf("%d de %s de %d", t.Day(), month, t.Year())
+	}
+	return t.Format("Jan 2, 2006")
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/brandtkeller/strava-api/pkg/strava/keyring"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type envVars struct {
+	StravaClientId       string `mapstructure:"STRAVA_CLIENT_ID"`
+	StravaClientSecret   string `mapstructure:"STRAVA_CLIENT_SECRET"`
+	StravaRefreshToken   string `mapstructure:"STRAVA_REFRESH_TOKEN"`
+	StravaTokenScope     string `mapstructure:"STRAVA_TOKEN_SCOPE"`
+	StravaCachePath      string `mapstructure:"STRAVA_CACHE_PATH"`
+	StravaBaseURL        string `mapstructure:"STRAVA_BASE_URL"`
+	StravaTokenStore     string `mapstructure:"STRAVA_TOKEN_STORE"`
+	StravaTokenFile      string `mapstructure:"STRAVA_TOKEN_FILE"`
+	StravaKeyringAccount string `mapstructure:"STRAVA_KEYRING_ACCOUNT"`
+	StravaAwsSecretId    string `mapstructure:"STRAVA_AWS_SECRET_ID"`
+	StravaAwsSsmPath     string `mapstructure:"STRAVA_AWS_SSM_PATH"`
+	StravaVaultMount     string `mapstructure:"STRAVA_VAULT_MOUNT"`
+	StravaVaultPath      string `mapstructure:"STRAVA_VAULT_PATH"`
+	StravaVaultKvVersion string `mapstructure:"STRAVA_VAULT_KV_VERSION"`
+	VaultAddr            string `mapstructure:"VAULT_ADDR"`
+	VaultNamespace       string `mapstructure:"VAULT_NAMESPACE"`
+	VaultToken           string `mapstructure:"VAULT_TOKEN"`
+	VaultRoleId          string `mapstructure:"VAULT_ROLE_ID"`
+	VaultSecretId        string `mapstructure:"VAULT_SECRET_ID"`
+
+	StravaWebhookVerifyToken    string `mapstructure:"STRAVA_WEBHOOK_VERIFY_TOKEN"`
+	StravaWebhookSubscriptionId string `mapstructure:"STRAVA_WEBHOOK_SUBSCRIPTION_ID"`
+
+	SheetsCredentialsFile string `mapstructure:"SHEETS_CREDENTIALS_FILE"`
+	SheetsSpreadsheetId   string `mapstructure:"SHEETS_SPREADSHEET_ID"`
+	SheetsTab             string `mapstructure:"SHEETS_TAB"`
+	SheetsSummaryTab      string `mapstructure:"SHEETS_SUMMARY_TAB"`
+	SheetsColumns         string `mapstructure:"SHEETS_COLUMNS"`
+
+	// Settings is the structured configuration from strava.yaml
+	Settings settings `mapstructure:"-"`
+}
+
+// summary holds the totals of the activities matched by the rules
+type summary struct {
+	Count    int
+	Distance float64
+	Miles    float64
+	Streak   int
+	// Elevation is the climbing of the matched activities in meters
+	Elevation float64
+	// PRs are the segment records set by activities new in this run
+	PRs []personalRecord
+	// BestEfforts are the running distance records set in this run
+	BestEfforts []bestEffortRecord
+	// Goals is the progress towards each configured goal this period
+	Goals []goalProgress
+	// Changes are the cached activities edited or deleted on Strava, as
+	// found by this run's sync
+	Changes []activityChange
+	// Partial is set when the sync failed partway, so the totals leave out
+	// the activities it had not fetched yet
+	Partial bool
+
+	// Latest is the newest synced activity, matched by the rules or not
+	Latest *strava.Activity
+	// Today totals every activity started today
+	Today periodTotals
+	// Metrics holds the results of every aggregation by name, the
+	// built-in matched and streak ones included
+	Metrics map[string]map[string]float64
+}
+
+type historicalData struct {
+}
+
+func (hd *historicalData) GetData() (int, error) {
+	return 0, nil
+}
+
+func (hd *historicalData) StoreData(year int, month time.Month, distance float64) error {
+	return nil
+}
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:     "strava-api",
+		Short:   "Summarize desk treadmill activities from Strava",
+		Version: version,
+		Run: func(cmd *cobra.Command, args []string) {
+			run(cmd.Context())
+		},
+	}
+
+	rootCmd.Flags().Bool("github-output", false, "write results to $GITHUB_OUTPUT and emit workflow annotations")
+	viper.BindPFlag("github-output", rootCmd.Flags().Lookup("github-output"))
+	rootCmd.Flags().String("format-template", "", "Go template executed on the summary and printed to stdout")
+	viper.BindPFlag("format-template", rootCmd.Flags().Lookup("format-template"))
+	rootCmd.Flags().Bool("strict", false, "exit when a page of the sync fails instead of summarizing the activities fetched so far")
+	viper.BindPFlag("strict", rootCmd.Flags().Lookup("strict"))
+
+	rootCmd.PersistentFlags().String("config", "", "settings file (default strava.yaml, strava.yml, or strava.toml)")
+	rootCmd.PersistentFlags().String("profile", "", "settings profile to use")
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+
+	rootCmd.PersistentFlags().Bool("debug-http", false, "dump sanitized API requests and responses with timings to stderr")
+	rootCmd.PersistentFlags().Int("debug-http-body", 512, "bytes of each body to include in --debug-http output, -1 for all")
+	rootCmd.PersistentFlags().Bool("log-payload-sizes", false, "log the compressed and decompressed size of every API response")
+	viper.BindPFlag("debug-http", rootCmd.PersistentFlags().Lookup("debug-http"))
+	viper.BindPFlag("debug-http-body", rootCmd.PersistentFlags().Lookup("debug-http-body"))
+	viper.BindPFlag("log-payload-sizes", rootCmd.PersistentFlags().Lookup("log-payload-sizes"))
+	rootCmd.PersistentFlags().Duration("timeout", 0, "give up on the whole command after this long, e.g. 10m (default no limit)")
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	rootCmd.PersistentFlags().Bool("error-json", false, "report a fatal error as JSON on stderr, with its category and exit code")
+	viper.BindPFlag("error-json", rootCmd.PersistentFlags().Lookup("error-json"))
+	rootCmd.PersistentFlags().Bool("include-deleted", false, "include activities deleted or made private on Strava in reports and exports")
+	viper.BindPFlag("include-deleted", rootCmd.PersistentFlags().Lookup("include-deleted"))
+	rootCmd.PersistentFlags().Bool("dry-run", false, "print the API calls that would create, update, or delete activities instead of making them, leaving the cache untouched")
+	viper.BindPFlag("dry-run", rootCmd.PersistentFlags().Lookup("dry-run"))
+	rootCmd.PersistentFlags().Bool("force", false, "sync even when the planned requests exceed what is left of the daily rate limit")
+	viper.BindPFlag("force", rootCmd.PersistentFlags().Lookup("force"))
+
+	rootCmd.AddCommand(newSheetsCmd())
+	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(newKeyringCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newSegmentsCmd())
+	rootCmd.AddCommand(newPRsCmd())
+	rootCmd.AddCommand(newReportCmd())
+	rootCmd.AddCommand(newClubCmd())
+	rootCmd.AddCommand(newRoutesCmd())
+	rootCmd.AddCommand(newPhotosCmd())
+	rootCmd.AddCommand(newBackupCmd())
+	rootCmd.AddCommand(newImportCmd())
+	rootCmd.AddCommand(newFitCmd())
+	rootCmd.AddCommand(newThumbnailsCmd())
+	rootCmd.AddCommand(newFitnessCmd())
+	rootCmd.AddCommand(newThresholdsCmd())
+	rootCmd.AddCommand(newPowerCurveCmd())
+	rootCmd.AddCommand(newHydrateCmd())
+	rootCmd.AddCommand(newTagCmd())
+	rootCmd.AddCommand(newRetitleCmd())
+	rootCmd.AddCommand(newWeatherCmd())
+	rootCmd.AddCommand(newDedupeCmd())
+	rootCmd.AddCommand(newActivitiesCmd())
+	rootCmd.AddCommand(newLogCmd())
+	rootCmd.AddCommand(newBrowseCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newQuotaCmd())
+	rootCmd.AddCommand(newSyncCmd())
+	rootCmd.AddCommand(newHistoryCmd())
+	rootCmd.AddCommand(newChangelogCmd())
+	rootCmd.AddCommand(newManCmd())
+	rootCmd.AddCommand(newSelfUpdateCmd())
+	rootCmd.AddCommand(newAthleteCmd())
+	rootCmd.AddCommand(newQueryCmd())
+	rootCmd.AddCommand(newWatchCmd())
+	rootCmd.AddCommand(newSplitsCmd())
+	rootCmd.AddCommand(newRacesCmd())
+
+	// Cancel in-flight work on Ctrl-C so fetch progress can be saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Every request derives from the command's context, so a deadline on
+	// it bounds the whole run
+	cancelTimeout := context.CancelFunc(func() {})
+	defer func() { cancelTimeout() }()
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		cmd.SetContext(startTracing(cmd))
+		if timeout := viper.GetDuration("timeout"); timeout > 0 {
+			var ctx context.Context
+			ctx, cancelTimeout = context.WithTimeout(cmd.Context(), timeout)
+			cmd.SetContext(ctx)
+		}
+	}
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		stop()
+		stopTracing(exitUsage)
+		os.Exit(exitUsage)
+	}
+	stopTracing(0)
+}
+
+func run(ctx context.Context) {
+
+	// setup logging
+	logger := log.Default()
+
+	config := loadConfig(ctx, logger)
+
+	var summaryTemplate *template.Template
+	if text := viper.GetString("format-template"); text != "" {
+		var err error
+		if summaryTemplate, err = parseFormatTemplate(text, config.Settings.Output); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	// Create Strava Client
+	client := newClient(ctx, logger, config)
+
+	authenticate(ctx, logger, client)
+
+	cache, err := openCache(config.StravaCachePath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer cache.Close()
+
+	activities, added, changes, syncErr := getActivities(ctx, logger, client, cache, config.Settings.Fetch)
+	// A page that failed after others were stored leaves a usable cache, so
+	// the run goes on with what it has unless asked not to
+	partial := errors.As(syncErr, new(partialSyncError)) && ctx.Err() == nil && !viper.GetBool("strict")
+	if syncErr != nil && !partial {
+		fatal(logger, syncErr)
+	}
+	if partial {
+		logger.Printf("Warning: %v\n", syncErr)
+		logger.Println("Warning: the totals are partial, they leave out the activities not fetched yet")
+	}
+
+	sum, err := processSync(ctx, logger, client, cache, config.Settings, activities, added, changes)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	sum.Partial = partial
+
+	// Log number of matched activities
+	logger.Printf("Matched Activities: %d\n", sum.Count)
+	// Log distance after converting meters to the configured units
+	logger.Printf("Total Distance: %s\n", config.Settings.Output.formatDistance(sum.Distance))
+	elevation, elevationUnit := config.Settings.Output.convertElevation(sum.Elevation)
+	logger.Printf("Total Elevation Gain: %.0f %s\n", elevation, elevationUnit)
+	// Log number of consecutive days with a matched activity
+	logger.Printf("Current Streak: %d days\n", sum.Streak)
+	for _, goal := range sum.Goals {
+		logger.Printf("Goal %s\n", goal)
+	}
+	for _, m := range config.Settings.Metrics {
+		keys := make([]string, 0, len(sum.Metrics[m.Name]))
+		for key := range sum.Metrics[m.Name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			logger.Printf("Metric %s.%s: %g\n", m.Name, key, sum.Metrics[m.Name][key])
+		}
+	}
+	if summaryTemplate != nil {
+		if err := writeTemplate(os.Stdout, summaryTemplate, sum); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if viper.GetBool("github-output") || config.Settings.Output.GithubOutput {
+		if err := writeGithubOutput(sum.Miles, sum.Count, sum.Streak, sum.Partial); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if err := notify(ctx, config.Settings.Notifications, sum, config.Settings.Output); err != nil {
+		logger.Fatal(err)
+	}
+	logger.Printf("API calls this run: %d\n", apiUsage.count())
+	// The summary is out, but schedulers should still know to rerun
+	if partial {
+		fatal(logger, syncErr)
+	}
+}
+
+// processSync runs the steps that follow a sync on the activities it
+// added, such as tagging and PR tracking, and summarizes all activities
+func processSync(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, s settings, activities, added []strava.Activity, changes []activityChange) (summary, error) {
+	if len(s.Tags) > 0 {
+		autoTag(ctx, logger, client, cache, s.Tags, added)
+	}
+
+	sum, err := summarize(ctx, activities, aggregations(s.Rules, s.Metrics), time.Now())
+	if err != nil {
+		return sum, err
+	}
+	sum.Changes = changes
+
+	if s.Fetch.TrackPRs {
+		sum.PRs, sum.BestEfforts = trackPRs(ctx, logger, client, cache, added)
+	}
+
+	if s.Weather.Enabled {
+		syncWeather(ctx, logger, s.Weather, s.HTTP, cache, added)
+	}
+
+	if s.Titles.enabled() {
+		enrichTitles(ctx, logger, client, cache, s.Titles, s.Output, added)
+	}
+
+	if len(s.Goals) > 0 {
+		calories, err := cache.calories()
+		if err != nil {
+			return sum, err
+		}
+		sum.Goals = trackGoals(s.Goals, activities, calories, s.Output, time.Now())
+	}
+	return sum, nil
+}
+
+// loadConfig reads the settings file and the env file of the active
+// profile, with environment variables taking precedence over values in the
+// files. The env file is optional when everything is provided through the
+// environment or a remote backend.
+func loadConfig(ctx context.Context, logger *log.Logger) envVars {
+	var config envVars
+
+	s, err := loadSettings(viper.GetString("config"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if profile := viper.GetString("profile"); profile != "" {
+		s.Profile = profile
+		if _, ok := s.Profiles[profile]; !ok {
+			logger.Fatalf("profile %q is not defined\n", profile)
+		}
+	}
+	profile := s.activeProfile()
+
+	envFile := profile.EnvFile
+	if envFile == "" {
+		envFile = "strava.env"
+	}
+
+	// Load environment configuration - IE secret tokens
+	viper.SetConfigFile(envFile)
+	viper.SetConfigType("env")
+
+	viper.AutomaticEnv()
+	bindEnvKeys(config)
+
+	// Profile values act as defaults beneath the env file and environment
+	viper.SetDefault("STRAVA_CACHE_PATH", profile.CachePath)
+	viper.SetDefault("STRAVA_TOKEN_STORE", profile.TokenStore)
+
+	err = viper.ReadInConfig()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Fatal(err)
+	}
+
+	if err := viper.Unmarshal(&config); err != nil {
+		logger.Fatal(err)
+	}
+
+	// Pull secrets from Vault or AWS when configured
+	if err := loadRemoteValues(ctx, config); err != nil {
+		logger.Fatal(err)
+	}
+	if err := viper.Unmarshal(&config); err != nil {
+		logger.Fatal(err)
+	}
+
+	s.Fetch.Force = viper.GetBool("force")
+	config.Settings = s
+	return config
+}
+
+// bindEnvKeys registers every mapstructure key of config with viper so that
+// environment variables are picked up by Unmarshal even when the key is
+// missing from the env file
+func bindEnvKeys(config interface{}) {
+	t := reflect.TypeOf(config)
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("mapstructure"); key != "" && key != "-" {
+			viper.BindEnv(key)
+		}
+	}
+}
+
+// newClient returns a Strava client configured with the application credentials
+func newClient(ctx context.Context, logger *log.Logger, config envVars) *strava.Client {
+	store, err := newTokenStore(ctx, config)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// The client secret may live in the keyring instead of the env file
+	if ks, ok := store.(*keyring.Store); ok && config.StravaClientSecret == "" {
+		config.StravaClientSecret, err = ks.ClientSecret()
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	// Backfills yield to interactive requests and leave them a share of
+	// the quota, including what other processes sharing the cache use
+	scheduler := strava.NewScheduler(strava.NewRateLimiter(), config.Settings.HTTP.BulkReserve)
+	apiUsage.schedule(scheduler)
+
+	return newStoreClient(logger, config, store, scheduler)
+}
+
+// newStoreClient returns a client saving its tokens to store and
+// scheduling requests with scheduler, which clients of several athletes
+// share since the rate limits belong to the application
+func newStoreClient(logger *log.Logger, config envVars, store strava.TokenStore, scheduler *strava.Scheduler) *strava.Client {
+	transport, err := newTransport(config.Settings.HTTP)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	var rt http.RoundTripper = transport
+	if tracer != nil {
+		rt = tracingTransport{base: transport}
+	}
+	httpClient := &http.Client{Transport: rt}
+	if viper.GetBool("debug-http") {
+		httpClient.Transport = strava.NewDebugTransport(rt, os.Stderr, viper.GetInt("debug-http-body"))
+	}
+
+	opts := []strava.Option{
+		strava.WithHTTPClient(httpClient),
+		strava.WithRateLimiter(scheduler),
+		strava.WithLogger(logger),
+		strava.WithCredentials(config.StravaClientId, config.StravaClientSecret),
+		strava.WithToken(strava.Token{RefreshToken: config.StravaRefreshToken}),
+		strava.WithTokenStore(store),
+		strava.WithResponseCache(config.Settings.Fetch.CacheDir, config.Settings.Fetch.CacheTTL),
+		strava.WithTimeouts(strava.Timeouts(config.Settings.HTTP.Timeouts)),
+		strava.WithCircuitBreaker(config.Settings.HTTP.BreakerThreshold, config.Settings.HTTP.BreakerCooldown),
+		strava.WithRetries(config.Settings.HTTP.Retries, config.Settings.HTTP.RetryBackoff),
+		strava.WithPayloadLogging(viper.GetBool("log-payload-sizes")),
+		strava.WithResponseHook(apiUsage.observe),
+	}
+	if viper.GetBool("dry-run") {
+		opts = append(opts, strava.WithDryRun(os.Stderr))
+	}
+	// Calls the token was not authorized for fail before they are sent
+	if config.StravaTokenScope != "" {
+		opts = append(opts, strava.WithScopes(strava.ParseScopes(config.StravaTokenScope)...))
+	}
+	// Point at another API root, e.g. the strava-mock server
+	if config.StravaBaseURL != "" {
+		opts = append(opts, strava.WithBaseURL(config.StravaBaseURL))
+	}
+
+	return strava.New(opts...)
+}
+
+// newTokenStore selects where refreshed tokens are persisted. The default
+// writes them back to the env file the configuration was read from.
+func newTokenStore(ctx context.Context, config envVars) (strava.TokenStore, error) {
+	// Rotated tokens go back to the remote backend the configuration came from
+	if config.StravaTokenStore == "" && remoteConfigured(config) {
+		config.StravaTokenStore = "remote"
+	}
+
+	switch config.StravaTokenStore {
+	case "", "env":
+		path := viper.ConfigFileUsed()
+		if path == "" {
+			path = "strava.env"
+		}
+		return strava.NewEnvFileTokenStore(path), nil
+	case "file":
+		path := config.StravaTokenFile
+		if path == "" {
+			path = "strava-token.json"
+		}
+		return strava.NewFileTokenStore(path), nil
+	case "memory":
+		return strava.NewMemoryTokenStore(), nil
+	case "keyring":
+		return keyring.New(config.StravaKeyringAccount), nil
+	case "remote", "aws", "vault":
+		store, err := newRemoteStore(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		if store == nil {
+			return nil, errors.New("STRAVA_TOKEN_STORE=remote requires STRAVA_VAULT_PATH, STRAVA_AWS_SECRET_ID, or STRAVA_AWS_SSM_PATH")
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown STRAVA_TOKEN_STORE %q, expected env, file, memory, keyring, or remote", config.StravaTokenStore)
+}
+
+// authenticate loads the stored token and refreshes it when it has expired
+func authenticate(ctx context.Context, logger *log.Logger, client strava.ClientInterface) {
+	if err := client.Authenticate(ctx); err != nil {
+		fatal(logger, authError{err})
+	}
+}
+
+// getActivities syncs every activity for the authenticated athlete into the
+// cache and returns the cached set. Activities new to the cache are also
+// returned on their own, as are the edits and deletions found on Strava.
+// A sync that fails partway saves its progress so the next run resumes
+// from there; the cached activities are still returned along with the
+// error, so the caller may go on with them.
+func getActivities(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, fetch fetchSettings) (activities, added []strava.Activity, changes []activityChange, err error) {
+	logger.Println("Authenticated - Preparing to get activities by page of 200")
+
+	added, changes, syncErr := syncActivities(ctx, logger, client, cache, fetch)
+	if syncErr != nil {
+		syncErr = syncFailure(ctx, syncErr)
+	}
+
+	activities, err = cache.activities()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Log total number of activities
+	logger.Printf("Total Number of activities: %d\n", len(activities))
+
+	return activities, added, changes, syncErr
+}
+
+// summarize runs the aggregations over the activities, filling the matched
+// totals and streak from the built-in ones, and adds the newest activity
+// and today's totals
+func summarize(ctx context.Context, activities []strava.Activity, aggs []aggregation, now time.Time) (summary, error) {
+	sum := summary{Metrics: make(map[string]map[string]float64, len(aggs))}
+	for _, agg := range aggs {
+		metrics, err := agg.Aggregate(ctx, activities, now)
+		if err != nil {
+			return sum, err
+		}
+		sum.Metrics[agg.Name()] = metrics
+	}
+	sum.Count = int(sum.Metrics["matched"]["count"])
+	sum.Distance = sum.Metrics["matched"]["distance"]
+	sum.Elevation = sum.Metrics["matched"]["elevation"]
+	sum.Miles = sum.Distance * 0.000621371
+	sum.Streak = int(sum.Metrics["streak"]["days"])
+
+	today := now.Local()
+	sum.Today.Start = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	for i, activity := range activities {
+		if sum.Latest == nil || activity.StartDate > sum.Latest.StartDate {
+			sum.Latest = &activities[i]
+		}
+		timestamp, err := time.Parse(time.RFC3339, activity.StartDate)
+		if err != nil || timestamp.Local().Format(time.DateOnly) != today.Format(time.DateOnly) {
+			continue
+		}
+		sum.Today.Count++
+		sum.Today.Distance += activity.Distance
+		sum.Today.MovingTime += activity.MovingTime
+		sum.Today.ElapsedTime += activity.ElapsedTime
+		sum.Today.ElevationGain += activity.TotalElevationGain
+	}
+
+	return sum, nil
+}
+
+// currentStreak returns the number of consecutive days, ending today or
+// yesterday, that contain at least one of the given activity times.
+func currentStreak(days []time.Time, now time.Time) int {
+	seen := make(map[string]bool)
+	for _, day := range days {
+		seen[day.Format(time.DateOnly)] = true
+	}
+
+	// An activity may not have been recorded yet today - start from yesterday
+	day := now
+	if !seen[day.Format(time.DateOnly)] {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	streak := 0
+	for seen[day.Format(time.DateOnly)] {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
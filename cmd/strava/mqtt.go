@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/mqtt"
+)
+
+const (
+	defaultMQTTTopic       = "strava"
+	defaultDiscoveryPrefix = "homeassistant"
+)
+
+// mqttMessage is one retained JSON message for the broker
+type mqttMessage struct {
+	Topic   string
+	Payload interface{}
+}
+
+// haSensor is a Home Assistant MQTT discovery config for one sensor
+type haSensor struct {
+	Name                   string   `json:"name"`
+	UniqueID               string   `json:"unique_id"`
+	StateTopic             string   `json:"state_topic"`
+	ValueTemplate          string   `json:"value_template"`
+	Unit                   string   `json:"unit_of_measurement,omitempty"`
+	DeviceClass            string   `json:"device_class,omitempty"`
+	StateClass             string   `json:"state_class,omitempty"`
+	Icon                   string   `json:"icon,omitempty"`
+	JSONAttributesTopic    string   `json:"json_attributes_topic,omitempty"`
+	JSONAttributesTemplate string   `json:"json_attributes_template,omitempty"`
+	Device                 haDevice `json:"device"`
+}
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+// mqttSink sends the latest activity, today's totals, and goal progress
+// to an MQTT broker as retained JSON, with Home Assistant discovery configs
+// so the sensors show up without any YAML
+type mqttSink struct {
+	settings sinkSettings
+	output   outputSettings
+}
+
+func (m mqttSink) Send(ctx context.Context, sum summary) error {
+	client, err := mqtt.Dial(ctx, m.settings.URL, "strava-api")
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, m := range mqttMessages(m.settings, sum, m.output) {
+		payload, err := json.Marshal(m.Payload)
+		if err != nil {
+			return err
+		}
+		if err := client.Publish(m.Topic, payload, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mqttMessages lays out the discovery configs followed by the state topics
+// under the sink's base topic:
+//
+//	<topic>/latest   the newest activity
+//	<topic>/today    today's count, distance, moving time, climbing, and streak
+//	<topic>/goals    progress towards each goal, keyed by its slugged label
+func mqttMessages(sink sinkSettings, sum summary, output outputSettings) []mqttMessage {
+	base, prefix := sink.Topic, sink.DiscoveryPrefix
+	if base == "" {
+		base = defaultMQTTTopic
+	}
+	if prefix == "" {
+		prefix = defaultDiscoveryPrefix
+	}
+	latestTopic, todayTopic, goalsTopic := path.Join(base, "latest"), path.Join(base, "today"), path.Join(base, "goals")
+	_, unit := output.convert(0)
+	_, elevationUnit := output.convertElevation(0)
+
+	device := haDevice{Identifiers: []string{"strava-api_" + base}, Name: "Strava", Manufacturer: "strava-api"}
+	sensor := func(id, name, topic, value string) haSensor {
+		objectID := strings.ReplaceAll(base, "/", "_") + "_" + id
+		return haSensor{Name: name, UniqueID: objectID, StateTopic: topic, ValueTemplate: value, Device: device}
+	}
+
+	latest := sensor("latest_activity", "Latest activity", latestTopic, "{{ value_json.name }}")
+	latest.Icon = "mdi:run"
+	latest.JSONAttributesTopic = latestTopic
+	distance := sensor("today_distance", "Distance today", todayTopic, "{{ value_json.distance }}")
+	distance.Unit, distance.DeviceClass, distance.StateClass = unit, "distance", "measurement"
+	moving := sensor("today_moving_time", "Moving time today", todayTopic, "{{ value_json.moving_time }}")
+	moving.Unit, moving.DeviceClass, moving.StateClass = "min", "duration", "measurement"
+	count := sensor("today_activities", "Activities today", todayTopic, "{{ value_json.count }}")
+	count.Icon, count.StateClass = "mdi:counter", "measurement"
+	climbing := sensor("today_elevation", "Climbing today", todayTopic, "{{ value_json.elevation }}")
+	climbing.Unit, climbing.DeviceClass, climbing.StateClass = elevationUnit, "distance", "measurement"
+	streak := sensor("streak", "Streak", todayTopic, "{{ value_json.streak }}")
+	streak.Unit, streak.Icon = "days", "mdi:fire"
+	sensors := []haSensor{latest, distance, moving, count, climbing, streak}
+
+	goals := make(map[string]interface{}, len(sum.Goals))
+	for _, g := range sum.Goals {
+		key := strings.ReplaceAll(slug(g.Label), "-", "_")
+		goals[key] = map[string]interface{}{
+			"label":   g.Label,
+			"period":  g.Period,
+			"value":   round1(g.Value),
+			"target":  g.Target,
+			"unit":    g.Unit,
+			"percent": round1(g.Percent()),
+		}
+		goal := sensor("goal_"+key, "Goal "+g.Label, goalsTopic, fmt.Sprintf("{{ value_json['%s'].percent }}", key))
+		goal.Unit, goal.Icon, goal.StateClass = "%", "mdi:flag-checkered", "measurement"
+		goal.JSONAttributesTopic = goalsTopic
+		goal.JSONAttributesTemplate = fmt.Sprintf("{{ value_json['%s'] | tojson }}", key)
+		sensors = append(sensors, goal)
+	}
+
+	messages := make([]mqttMessage, 0, len(sensors)+3)
+	for _, s := range sensors {
+		messages = append(messages, mqttMessage{Topic: path.Join(prefix, "sensor", s.UniqueID, "config"), Payload: s})
+	}
+
+	if a := sum.Latest; a != nil {
+		d, _ := output.convert(a.Distance)
+		e, _ := output.convertElevation(a.TotalElevationGain)
+		messages = append(messages, mqttMessage{Topic: latestTopic, Payload: map[string]interface{}{
+			"id":          a.Id,
+			"name":        a.Name,
+			"type":        a.Type,
+			"start_date":  a.StartDate,
+			"distance":    round1(d),
+			"moving_time": a.MovingTime / 60,
+			"elevation":   round1(e),
+			"unit":        unit,
+			"url":         fmt.Sprintf("https://www.strava.com/activities/%d", a.Id),
+		}})
+	}
+	d, _ := output.convert(sum.Today.Distance)
+	e, _ := output.convertElevation(sum.Today.ElevationGain)
+	messages = append(messages,
+		mqttMessage{Topic: todayTopic, Payload: map[string]interface{}{
+			"date":        sum.Today.Start.Format(time.DateOnly),
+			"count":       sum.Today.Count,
+			"distance":    round1(d),
+			"moving_time": sum.Today.MovingTime / 60,
+			"elevation":   round1(e),
+			"streak":      sum.Streak,
+		}},
+		mqttMessage{Topic: goalsTopic, Payload: goals},
+	)
+	return messages
+}
+
+// round1 keeps one decimal so sensor states stay readable
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/otlp"
+)
+
+// notify sends the run summary to every configured sink
+func notify(ctx context.Context, sinks []sinkSettings, sum summary, output outputSettings) error {
+	for _, settings := range sinks {
+		ctx, span := tracer.Start(ctx, "notify "+settings.Type, otlp.String("strava.notification.type", settings.Type))
+		s, err := newSink(settings, output)
+		if err == nil {
+			err = s.Send(ctx, sum)
+		}
+		span.RecordError(err)
+		span.End()
+		if err != nil {
+			return fmt.Errorf("%s notification: %w", settings.Type, err)
+		}
+	}
+	return nil
+}
+
+// newSink returns the built-in sink or plugin a notification is set up for
+func newSink(settings sinkSettings, output outputSettings) (sink, error) {
+	switch settings.Type {
+	case "webhook":
+		return webhookSink{url: settings.URL}, nil
+	case "slack":
+		return slackSink{url: settings.URL}, nil
+	case "mqtt":
+		return mqttSink{settings: settings, output: output}, nil
+	case "plugin":
+		return pluginSink{command: settings.Command}, nil
+	}
+	return nil, fmt.Errorf("unknown notification type %q", settings.Type)
+}
+
+// webhookSink POSTs the summary as JSON
+type webhookSink struct {
+	url string
+}
+
+func (w webhookSink) Send(ctx context.Context, sum summary) error {
+	return postJSON(ctx, w.url, webhookPayload(sum))
+}
+
+// webhookPayload is the summary as sent to webhooks and plugin sinks
+func webhookPayload(sum summary) map[string]interface{} {
+	return map[string]interface{}{
+		"activity_count":   sum.Count,
+		"total_miles":      sum.Miles,
+		"total_elevation":  sum.Elevation,
+		"streak":           sum.Streak,
+		"new_prs":          sum.PRs,
+		"new_best_efforts": sum.BestEfforts,
+		"goals":            sum.Goals,
+		"metrics":          sum.Metrics,
+		"changes":          sum.Changes,
+		"partial":          sum.Partial,
+	}
+}
+
+// slackSink posts a one line summary to a Slack incoming webhook
+type slackSink struct {
+	url string
+}
+
+func (s slackSink) Send(ctx context.Context, sum summary) error {
+	return postJSON(ctx, s.url, map[string]string{
+		"text": partialSummary(sum.Partial) + fmt.Sprintf("%d activities, %.2f miles, %d day streak", sum.Count, sum.Miles, sum.Streak) + prSummary(sum.PRs) + bestEffortSummary(sum.BestEfforts) + goalSummary(sum.Goals) + changeSummary(sum.Changes),
+	})
+}
+
+// partialSummary prefixes the Slack text of a run whose sync failed partway
+func partialSummary(partial bool) string {
+	if partial {
+		return "Partial: the sync failed partway. "
+	}
+	return ""
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", url, res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A minimal Apache Parquet writer: flat schemas of required and optional
+// columns, PLAIN encoding, no compression. That is all the activity export needs and keeps
+// the format readable by pandas, DuckDB, and Spark without a heavy dependency.
+
+// Parquet physical types
+const (
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+)
+
+// Parquet converted types for byte array columns
+const (
+	parquetUTF8 int32 = 0
+	parquetJSON int32 = 19
+)
+
+var parquetMagic = []byte("PAR1")
+
+type parquetColumn struct {
+	Name string
+	Type int32
+	// Converted is the logical annotation for byte array columns
+	Converted int32
+	// Optional columns accept nil, stored as NULL
+	Optional bool
+}
+
+type parquetChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+type parquetRowGroup struct {
+	chunks  []parquetChunk
+	numRows int64
+}
+
+// parquetWriter buffers rows column by column and writes a row group each
+// time Flush is called. Memory use is bounded by the rows buffered since the
+// last flush.
+type parquetWriter struct {
+	w       io.Writer
+	offset  int64
+	columns []parquetColumn
+	buffers []bytes.Buffer
+	// defined holds, for optional columns, whether each buffered row has
+	// a value
+	defined   [][]bool
+	rows      int64
+	rowGroups []parquetRowGroup
+}
+
+func newParquetWriter(w io.Writer, columns []parquetColumn) (*parquetWriter, error) {
+	pw := &parquetWriter{
+		w:       w,
+		columns: columns,
+		buffers: make([]bytes.Buffer, len(columns)),
+		defined: make([][]bool, len(columns)),
+	}
+	if err := pw.write(parquetMagic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WriteRow appends one value per column. Values must be int64, float64, or
+// string to match the column types, or nil for optional columns.
+func (pw *parquetWriter) WriteRow(values ...interface{}) error {
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("parquet row has %d values, schema has %d columns", len(values), len(pw.columns))
+	}
+
+	for i, value := range values {
+		if value == nil && !pw.columns[i].Optional {
+			return fmt.Errorf("parquet column %s is required, got nil", pw.columns[i].Name)
+		}
+		if pw.columns[i].Optional {
+			pw.defined[i] = append(pw.defined[i], value != nil)
+			if value == nil {
+				continue
+			}
+		}
+		buf := &pw.buffers[i]
+		switch pw.columns[i].Type {
+		case parquetInt64:
+			v, ok := value.(int64)
+			if !ok {
+				return fmt.Errorf("parquet column %s expects int64, got %T", pw.columns[i].Name, value)
+			}
+			binary.Write(buf, binary.LittleEndian, v)
+		case parquetDouble:
+			v, ok := value.(float64)
+			if !ok {
+				return fmt.Errorf("parquet column %s expects float64, got %T", pw.columns[i].Name, value)
+			}
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(v))
+		case parquetByteArray:
+			v, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("parquet column %s expects string, got %T", pw.columns[i].Name, value)
+			}
+			binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+			buf.WriteString(v)
+		default:
+			return fmt.Errorf("parquet column %s has unsupported type %d", pw.columns[i].Name, pw.columns[i].Type)
+		}
+	}
+
+	pw.rows++
+	return nil
+}
+
+// Flush writes the buffered rows as a row group with one data page per column
+func (pw *parquetWriter) Flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{numRows: pw.rows}
+	for i, column := range pw.columns {
+		data := pw.buffers[i].Bytes()
+		if column.Optional {
+			// Definition levels precede the values: 1 for a value, 0 for NULL
+			levels := definitionLevels(pw.defined[i])
+			page := make([]byte, 4, 4+len(levels)+len(data))
+			binary.LittleEndian.PutUint32(page, uint32(len(levels)))
+			data = append(append(page, levels...), data...)
+		}
+
+		var header thriftEncoder
+		header.beginStruct()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.beginStructField(5)
+		header.i32(1, int32(pw.rows))
+		header.i32(2, 0) // PLAIN
+		header.i32(3, 3) // RLE definition levels (only written for optional columns)
+		header.i32(4, 3) // RLE repetition levels (unused for required columns)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetChunk{
+			offset:    pw.offset,
+			size:      int64(header.buf.Len() + len(data)),
+			numValues: pw.rows,
+		}
+		if err := pw.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(data); err != nil {
+			return err
+		}
+
+		group.chunks = append(group.chunks, chunk)
+		pw.buffers[i].Reset()
+		pw.defined[i] = pw.defined[i][:0]
+	}
+
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.rows = 0
+	return nil
+}
+
+// Close flushes any buffered rows and writes the file footer. It does not
+// close the underlying writer.
+func (pw *parquetWriter) Close() error {
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+
+	var totalRows int64
+	for _, group := range pw.rowGroups {
+		totalRows += group.numRows
+	}
+
+	var meta thriftEncoder
+	meta.beginStruct()
+	meta.i32(1, 1) // version
+
+	meta.beginList(2, thriftStruct, len(pw.columns)+1)
+	meta.beginStruct()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.endStruct()
+	for _, column := range pw.columns {
+		meta.beginStruct()
+		meta.i32(1, column.Type)
+		if column.Optional {
+			meta.i32(3, 1) // OPTIONAL
+		} else {
+			meta.i32(3, 0) // REQUIRED
+		}
+		meta.binary(4, column.Name)
+		if column.Type == parquetByteArray {
+			meta.i32(6, column.Converted)
+		}
+		meta.endStruct()
+	}
+
+	meta.i64(3, totalRows)
+
+	meta.beginList(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		var groupSize int64
+		meta.beginStruct()
+		meta.beginList(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			groupSize += chunk.size
+			meta.beginStruct()
+			meta.i64(2, chunk.offset)
+			meta.beginStructField(3)
+			meta.i32(1, pw.columns[i].Type)
+			meta.beginList(2, thriftI32, 1)
+			meta.listI32(0) // PLAIN
+			meta.beginList(3, thriftBinary, 1)
+			meta.listBinary(pw.columns[i].Name)
+			meta.i32(4, 0) // UNCOMPRESSED
+			meta.i64(5, chunk.numValues)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, groupSize)
+		meta.i64(3, group.numRows)
+		meta.endStruct()
+	}
+
+	meta.binary(6, "github.com/brandtkeller/strava-api")
+	meta.endStruct()
+
+	if err := pw.write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(meta.buf.Len()))
+	if err := pw.write(length); err != nil {
+		return err
+	}
+	return pw.write(parquetMagic)
+}
+
+// definitionLevels encodes the definition levels of an optional column as
+// RLE runs of the RLE/bit-packing hybrid with a bit width of one
+func definitionLevels(defined []bool) []byte {
+	var e thriftEncoder
+	for start := 0; start < len(defined); {
+		end := start
+		for end < len(defined) && defined[end] == defined[start] {
+			end++
+		}
+		e.uvarint(uint64(end-start) << 1)
+		if defined[start] {
+			e.buf.WriteByte(1)
+		} else {
+			e.buf.WriteByte(0)
+		}
+		start = end
+	}
+	return e.buf.Bytes()
+}
+
+func (pw *parquetWriter) write(p []byte) error {
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	return err
+}
+
+// Thrift compact protocol type ids
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftEncoder writes the subset of the Thrift compact protocol used by
+// Parquet metadata
+type thriftEncoder struct {
+	buf bytes.Buffer
+	// last holds the previous field id for each open struct
+	last []int16
+}
+
+func (e *thriftEncoder) beginStruct() {
+	e.last = append(e.last, 0)
+}
+
+func (e *thriftEncoder) beginStructField(id int16) {
+	e.field(id, thriftStruct)
+	e.beginStruct()
+}
+
+func (e *thriftEncoder) endStruct() {
+	if len(e.last) == 0 {
+		panic(errors.New("thrift: endStruct without beginStruct"))
+	}
+	e.buf.WriteByte(0)
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *thriftEncoder) i32(id int16, v int32) {
+	e.field(id, thriftI32)
+	e.varint(int64(v))
+}
+
+func (e *thriftEncoder) i64(id int16, v int64) {
+	e.field(id, thriftI64)
+	e.varint(v)
+}
+
+func (e *thriftEncoder) binary(id int16, v string) {
+	e.field(id, thriftBinary)
+	e.listBinary(v)
+}
+
+func (e *thriftEncoder) beginList(id int16, elem byte, size int) {
+	e.field(id, thriftList)
+	if size < 15 {
+		e.buf.WriteByte(byte(size)<<4 | elem)
+	} else {
+		e.buf.WriteByte(0xf0 | elem)
+		e.uvarint(uint64(size))
+	}
+}
+
+func (e *thriftEncoder) listI32(v int32) {
+	e.varint(int64(v))
+}
+
+func (e *thriftEncoder) listBinary(v string) {
+	e.uvarint(uint64(len(v)))
+	e.buf.WriteString(v)
+}
+
+func (e *thriftEncoder) field(id int16, typ byte) {
+	top := len(e.last) - 1
+	delta := id - e.last[top]
+	if delta > 0 && delta <= 15 {
+		e.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		e.buf.WriteByte(typ)
+		e.varint(int64(id))
+	}
+	e.last[top] = id
+}
+
+// varint writes a zigzag encoded signed integer
+func (e *thriftEncoder) varint(v int64) {
+	e.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (e *thriftEncoder) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	e.buf.Write(tmp[:n])
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// thriftDecoder reads the Thrift compact protocol generically, so the test
+// checks the bytes against the Parquet format rather than the writer's
+// own idea of it
+type thriftDecoder struct {
+	r *bytes.Reader
+}
+
+func (d *thriftDecoder) uvarint() uint64 {
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (d *thriftDecoder) varint() int64 {
+	u := d.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *thriftDecoder) byte() byte {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// value reads a value of compact type typ. Structs become maps by field id.
+func (d *thriftDecoder) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3:
+		return int8(d.byte())
+	case 4, 5, 6:
+		return d.varint()
+	case 7:
+		var v float64
+		binary.Read(d.r, binary.LittleEndian, &v)
+		return v
+	case 8:
+		b := make([]byte, d.uvarint())
+		d.r.Read(b)
+		return string(b)
+	case 9, 10:
+		header := d.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(d.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = d.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return d.structure()
+	}
+	panic(fmt.Sprintf("thrift type %d", typ))
+}
+
+func (d *thriftDecoder) structure() map[int64]interface{} {
+	fields := make(map[int64]interface{})
+	var last int64
+	for {
+		header := d.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int64(header>>4)
+		if header>>4 == 0 {
+			id = d.varint()
+		}
+		fields[id] = d.value(header & 0x0f)
+		last = id
+	}
+}
+
+// readParquet decodes a file written by parquetWriter, returning the
+// footer and every row, NULLs as nil
+func readParquet(t *testing.T, file []byte) (map[int64]interface{}, [][]interface{}) {
+	t.Helper()
+	if !bytes.HasPrefix(file, parquetMagic) || !bytes.HasSuffix(file, parquetMagic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	length := binary.LittleEndian.Uint32(file[len(file)-8:])
+	footer := file[len(file)-8-int(length) : len(file)-8]
+	meta := (&thriftDecoder{bytes.NewReader(footer)}).structure()
+
+	schema := meta[2].([]interface{})
+	columns := schema[1:]
+	rows := make([][]interface{}, 0)
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int64]interface{})
+		numRows := int(group[3].(int64))
+		groupRows := make([][]interface{}, numRows)
+		for i := range groupRows {
+			groupRows[i] = make([]interface{}, len(columns))
+		}
+		for c, ch := range group[1].([]interface{}) {
+			column := columns[c].(map[int64]interface{})
+			chunkMeta := ch.(map[int64]interface{})[3].(map[int64]interface{})
+			offset := chunkMeta[9].(int64)
+
+			r := bytes.NewReader(file[offset:])
+			header := (&thriftDecoder{r}).structure()
+			if header[1].(int64) != 0 {
+				t.Fatalf("column %v: page type %v, want DATA_PAGE", column[4], header[1])
+			}
+			page := make([]byte, header[3].(int64))
+			r.Read(page)
+			if n := header[5].(map[int64]interface{})[1].(int64); int(n) != numRows {
+				t.Fatalf("column %v: page has %d values, row group %d rows", column[4], n, numRows)
+			}
+
+			defined := make([]bool, numRows)
+			for i := range defined {
+				defined[i] = true
+			}
+			if column[3].(int64) == 1 {
+				n := binary.LittleEndian.Uint32(page)
+				levels := bytes.NewReader(page[4 : 4+n])
+				for i := 0; i < numRows; {
+					run, _ := binary.ReadUvarint(levels)
+					if run&1 != 0 {
+						t.Fatalf("column %v: bit-packed definition levels not expected", column[4])
+					}
+					level, _ := levels.ReadByte()
+					for j := 0; j < int(run>>1); j++ {
+						defined[i] = level == 1
+						i++
+					}
+				}
+				page = page[4+n:]
+			}
+
+			values := bytes.NewReader(page)
+			for i := range groupRows {
+				if !defined[i] {
+					continue
+				}
+				switch column[1].(int64) {
+				case int64(parquetInt64):
+					var v int64
+					binary.Read(values, binary.LittleEndian, &v)
+					groupRows[i][c] = v
+				case int64(parquetDouble):
+					var v uint64
+					binary.Read(values, binary.LittleEndian, &v)
+					groupRows[i][c] = math.Float64frombits(v)
+				case int64(parquetByteArray):
+					var n uint32
+					binary.Read(values, binary.LittleEndian, &n)
+					b := make([]byte, n)
+					values.Read(b)
+					groupRows[i][c] = string(b)
+				}
+			}
+		}
+		rows = append(rows, groupRows...)
+	}
+	return meta, rows
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	columns := []parquetColumn{
+		{Name: "id", Type: parquetInt64},
+		{Name: "name", Type: parquetByteArray, Converted: parquetUTF8},
+		{Name: "description", Type: parquetByteArray, Converted: parquetUTF8, Optional: true},
+		{Name: "distance", Type: parquetDouble},
+		{Name: "raw", Type: parquetByteArray, Converted: parquetJSON},
+	}
+	rows := [][]interface{}{
+		{int64(1), "Morning Run", "Easy", 5012.5, `{"id":1}`},
+		{int64(2), "Lunch Ride", nil, 40123.0, `{"id":2}`},
+		{int64(3), "Évening Swim", nil, 1500.0, `{"id":3}`},
+		{int64(4), "Long Run", "Hilly", 21097.5, `{"id":4}`},
+		{int64(-5), "", nil, 0.0, `{}`},
+	}
+
+	var file bytes.Buffer
+	pw, err := newParquetWriter(&file, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		if err := pw.WriteRow(row...); err != nil {
+			t.Fatal(err)
+		}
+		// Row groups of two, three, and nothing left for Close
+		if i == 1 || i == 4 {
+			if err := pw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, got := readParquet(t, file.Bytes())
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("rows read back:\n%v\nwant:\n%v", got, rows)
+	}
+
+	if meta[1] != int64(1) || meta[3] != int64(len(rows)) || meta[6] != "github.com/brandtkeller/strava-api" {
+		t.Errorf("footer version %v, num_rows %v, created_by %v", meta[1], meta[3], meta[6])
+	}
+	groups := meta[4].([]interface{})
+	if len(groups) != 2 {
+		t.Fatalf("%d row groups, want 2", len(groups))
+	}
+	offset := int64(len(parquetMagic))
+	for g, want := range []int64{2, 3} {
+		group := groups[g].(map[int64]interface{})
+		if group[3] != want {
+			t.Errorf("row group %d has %v rows, want %d", g, group[3], want)
+		}
+		// Chunks are laid out back to back
+		var size int64
+		for c, ch := range group[1].([]interface{}) {
+			chunk := ch.(map[int64]interface{})[3].(map[int64]interface{})
+			if chunk[9] != offset || chunk[5] != want || chunk[3].([]interface{})[0] != columns[c].Name {
+				t.Errorf("row group %d column %d: offset %v, values %v, path %v", g, c, chunk[9], chunk[5], chunk[3])
+			}
+			offset += chunk[7].(int64)
+			size += chunk[7].(int64)
+		}
+		if group[2] != size {
+			t.Errorf("row group %d total size %v, want %d", g, group[2], size)
+		}
+	}
+
+	schema := meta[2].([]interface{})
+	if root := schema[0].(map[int64]interface{}); root[4] != "schema" || root[5] != int64(len(columns)) {
+		t.Errorf("schema root = %v", root)
+	}
+	for i, column := range columns {
+		element := schema[i+1].(map[int64]interface{})
+		repetition := int64(0)
+		if column.Optional {
+			repetition = 1
+		}
+		if element[4] != column.Name || element[1] != int64(column.Type) || element[3] != repetition {
+			t.Errorf("schema element %d = %v", i, element)
+		}
+		if column.Type == parquetByteArray && element[6] != int64(column.Converted) {
+			t.Errorf("column %s converted type %v, want %d", column.Name, element[6], column.Converted)
+		}
+	}
+}
+
+func TestParquetRequiredColumnRejectsNil(t *testing.T) {
+	pw, err := newParquetWriter(&bytes.Buffer{}, []parquetColumn{{Name: "id", Type: parquetInt64}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteRow(nil); err == nil {
+		t.Error("WriteRow(nil) into a required column succeeded")
+	}
+}
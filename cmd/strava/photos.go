@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// defaultPhotoSize is the longest side, in pixels, of downloaded photos
+const defaultPhotoSize = 2048
+
+func newPhotosCmd() *cobra.Command {
+	var size int
+	var download string
+
+	cmd := &cobra.Command{
+		Use:   "photos <activity id...>",
+		Short: "List activity photo URLs and optionally download the images",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ids := make([]int64, 0, len(args))
+			for _, arg := range args {
+				id, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil {
+					logger.Fatalf("invalid activity id %q\n", arg)
+				}
+				ids = append(ids, id)
+			}
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			transport, err := newTransport(config.Settings.HTTP)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			httpClient := &http.Client{Transport: transport}
+
+			for _, id := range ids {
+				photos, err := client.ListActivityPhotos(ctx, id, size)
+				if err != nil {
+					logger.Fatalf("activity %d: %v\n", id, err)
+				}
+				for _, p := range photos {
+					fmt.Printf("%d\t%s\t%s\n", id, p.UniqueId, photoURL(p))
+				}
+
+				if download != "" {
+					saved, err := downloadPhotos(ctx, httpClient, photos, filepath.Join(download, strconv.FormatInt(id, 10)))
+					if err != nil {
+						logger.Fatalf("activity %d: %v\n", id, err)
+					}
+					logger.Printf("Activity %d: %d photos downloaded\n", id, saved)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().IntVar(&size, "size", defaultPhotoSize, "longest side of the photos in pixels")
+	cmd.Flags().StringVar(&download, "download", "", "directory to save the images to, one folder per activity")
+
+	return cmd
+}
+
+// photoURL returns the address of the largest size Strava returned
+func photoURL(p strava.Photo) string {
+	best, bestSize := "", -1
+	for size, u := range p.URLs {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			n = 0
+		}
+		if n > bestSize {
+			best, bestSize = u, n
+		}
+	}
+	return best
+}
+
+// downloadPhotos saves each photo to dir as <unique id>.<ext>. Photos
+// already on disk are skipped, so repeated runs only fetch new ones. It
+// returns the number of images downloaded.
+func downloadPhotos(ctx context.Context, httpClient *http.Client, photos []strava.Photo, dir string) (int, error) {
+	saved := 0
+	for _, p := range photos {
+		u := photoURL(p)
+		if u == "" || p.UniqueId == "" {
+			continue
+		}
+
+		ext := ".jpg"
+		if parsed, err := url.Parse(u); err == nil && path.Ext(parsed.Path) != "" {
+			ext = path.Ext(parsed.Path)
+		}
+		dest := filepath.Join(dir, p.UniqueId+ext)
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return saved, err
+		}
+		if err := downloadFile(ctx, httpClient, u, dest); err != nil {
+			return saved, fmt.Errorf("photo %s: %w", p.UniqueId, err)
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+// downloadFile fetches u into dest, leaving nothing behind on failure
+func downloadFile(ctx context.Context, httpClient *http.Client, u, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, res.Status)
+	}
+
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, res.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// athleteIDKey caches the authenticated athlete's id, which the stats
+// endpoint needs, so planning costs one request rather than two
+const athleteIDKey = "athlete_id"
+
+// planPageTime is the rough time to fetch one page of MaxPerPage activities
+const planPageTime = 2 * time.Second
+
+// syncPlan estimates the requests a sync needs against what is left of the
+// rate limits
+type syncPlan struct {
+	// Activities is the estimated number still to fetch
+	Activities int
+	Pages      int
+	Usage      strava.RateLimitUsage
+	// Tracked is false when the rate limiter does not report its usage
+	Tracked bool
+}
+
+// planSync estimates the size of a sync from the athlete's all-time stats,
+// or from the cache when it holds more, since the stats only count rides,
+// runs, and swims. When resuming from after, or starting there in an
+// incremental sync, activities already stored up to that point are left out.
+func planSync(ctx context.Context, client strava.ClientInterface, cache *activityCache, after int64, resuming bool) (syncPlan, error) {
+	id, err := athleteID(ctx, client, cache)
+	if err != nil {
+		return syncPlan{}, err
+	}
+	stats, err := client.GetAthleteStats(ctx, id)
+	if err != nil {
+		return syncPlan{}, err
+	}
+
+	var cached, stored int
+	cutoff := time.Unix(after, 0).UTC().Format(time.RFC3339)
+	err = cache.eachActivity(func(a strava.Activity) error {
+		cached++
+		if resuming && a.StartDate <= cutoff {
+			stored++
+		}
+		return nil
+	})
+	if err != nil {
+		return syncPlan{}, err
+	}
+
+	total := stats.AllRideTotals.Count + stats.AllRunTotals.Count + stats.AllSwimTotals.Count
+	plan := syncPlan{Activities: max(total, cached) - stored}
+	// The last page is the first one that comes back short, even if empty
+	plan.Pages = plan.Activities/strava.MaxPerPage + 1
+	plan.Usage, plan.Tracked = client.RateLimit()
+	return plan, nil
+}
+
+// athleteID returns the authenticated athlete's id from the cache, asking
+// Strava the first time
+func athleteID(ctx context.Context, client strava.ClientInterface, cache *activityCache) (int64, error) {
+	value, err := cache.state(athleteIDKey)
+	if err != nil {
+		return 0, err
+	}
+	if value != "" {
+		return strconv.ParseInt(value, 10, 64)
+	}
+
+	athlete, err := client.GetAthlete(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return athlete.Id, cache.setState(athleteIDKey, strconv.FormatInt(athlete.Id, 10))
+}
+
+// eta is how long the plan should take, including waits for the
+// fifteen-minute window to reset once it runs out
+func (p syncPlan) eta(now time.Time) time.Duration {
+	left := p.Usage.ShortRemaining()
+	if !p.Tracked || p.Pages <= left || p.Usage.ShortLimit < 1 {
+		return time.Duration(p.Pages) * planPageTime
+	}
+	rest := p.Pages - left
+	windows := (rest - 1) / p.Usage.ShortLimit
+	wait := p.Usage.ShortReset.Sub(now) + time.Duration(windows)*15*time.Minute
+	return wait + time.Duration(rest-windows*p.Usage.ShortLimit)*planPageTime
+}
+
+// budgetError is a sync plan that needs more requests than are left today
+type budgetError struct {
+	plan syncPlan
+}
+
+func (e budgetError) Error() string {
+	u := e.plan.Usage
+	return fmt.Sprintf("sync needs about %d requests but only %d of the daily %d are left until %s; pass --force to start anyway and resume tomorrow",
+		e.plan.Pages, u.DailyRemaining(), u.DailyLimit, u.DailyReset.Local().Format("15:04 MST"))
+}
+
+// check fails when the plan needs more requests than are left today
+func (p syncPlan) check() error {
+	if !p.Tracked || p.Pages <= p.Usage.DailyRemaining() {
+		return nil
+	}
+	return budgetError{p}
+}
+
+func (p syncPlan) describe(now time.Time) string {
+	pages := "pages"
+	if p.Pages == 1 {
+		pages = "page"
+	}
+	text := fmt.Sprintf("Sync plan: about %d activities in %d %s", p.Activities, p.Pages, pages)
+	if p.Tracked {
+		text += fmt.Sprintf(", %d of %d requests left today and %d of %d in this 15-minute window",
+			p.Usage.DailyRemaining(), p.Usage.DailyLimit, p.Usage.ShortRemaining(), p.Usage.ShortLimit)
+	}
+	return text + ", ETA " + p.eta(now).Round(time.Second).String()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// pluginTimeout bounds each call to a plugin process
+const pluginTimeout = time.Minute
+
+// aggregation computes named metrics from the synced activities. The
+// built-in totals and streak implement it as well as metrics plugins, and
+// every result is reported in summary.Metrics under the aggregation's name.
+type aggregation interface {
+	Name() string
+	Aggregate(ctx context.Context, activities []strava.Activity, now time.Time) (map[string]float64, error)
+}
+
+// sink receives the run summary once a run has finished
+type sink interface {
+	Send(ctx context.Context, sum summary) error
+}
+
+// metricSettings adds a metrics plugin to the summary
+type metricSettings struct {
+	Name string `mapstructure:"name"`
+	// Command is the program and its arguments
+	Command []string `mapstructure:"command"`
+}
+
+// matchedTotals counts the activities matching any rule, with their
+// distance and climbing in meters
+type matchedTotals struct {
+	rules []matchRule
+}
+
+func (matchedTotals) Name() string { return "matched" }
+
+func (t matchedTotals) Aggregate(ctx context.Context, activities []strava.Activity, now time.Time) (map[string]float64, error) {
+	metrics := map[string]float64{"count": 0, "distance": 0, "elevation": 0}
+	for _, a := range activities {
+		matched, err := matchesAnyRule(t.rules, a)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			metrics["count"]++
+			metrics["distance"] += a.Distance
+			metrics["elevation"] += a.TotalElevationGain
+		}
+	}
+	return metrics, nil
+}
+
+// streakAggregation is the number of consecutive days, ending today or
+// yesterday, with an activity matching any rule
+type streakAggregation struct {
+	rules []matchRule
+}
+
+func (streakAggregation) Name() string { return "streak" }
+
+func (s streakAggregation) Aggregate(ctx context.Context, activities []strava.Activity, now time.Time) (map[string]float64, error) {
+	days := make([]time.Time, 0)
+	for _, a := range activities {
+		matched, err := matchesAnyRule(s.rules, a)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			start, _ := time.Parse(time.RFC3339, a.StartDate)
+			days = append(days, start.Local())
+		}
+	}
+	return map[string]float64{"days": float64(currentStreak(days, now))}, nil
+}
+
+func matchesAnyRule(rules []matchRule, a strava.Activity) (bool, error) {
+	start, err := time.Parse(time.RFC3339, a.StartDate)
+	if err != nil {
+		return false, fmt.Errorf("error parsing date: %s", a.StartDate)
+	}
+	for _, rule := range rules {
+		if rule.matches(a, start) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pluginAggregation runs a program that reads
+//
+//	{"kind": "aggregate", "now": "<RFC 3339>", "activities": [<raw activities>]}
+//
+// on stdin and writes {"metrics": {"<name>": <number>, ...}} to stdout
+type pluginAggregation struct {
+	name    string
+	command []string
+}
+
+func (p pluginAggregation) Name() string { return p.name }
+
+func (p pluginAggregation) Aggregate(ctx context.Context, activities []strava.Activity, now time.Time) (map[string]float64, error) {
+	raw := make([]json.RawMessage, len(activities))
+	for i, a := range activities {
+		raw[i] = a.Raw
+		if len(raw[i]) == 0 {
+			// Activities built locally rather than read from the API
+			data, err := json.Marshal(a)
+			if err != nil {
+				return nil, err
+			}
+			raw[i] = data
+		}
+	}
+
+	var res struct {
+		Metrics map[string]float64 `json:"metrics"`
+	}
+	req := map[string]interface{}{"kind": "aggregate", "now": now.Format(time.RFC3339), "activities": raw}
+	if err := callPlugin(ctx, p.command, req, &res); err != nil {
+		return nil, fmt.Errorf("metrics plugin %q: %w", p.name, err)
+	}
+	return res.Metrics, nil
+}
+
+// pluginSink runs a program that reads {"kind": "notify", "summary": {...}}
+// on stdin, with the same summary as the webhook payload
+type pluginSink struct {
+	command []string
+}
+
+func (p pluginSink) Send(ctx context.Context, sum summary) error {
+	return callPlugin(ctx, p.command, map[string]interface{}{"kind": "notify", "summary": webhookPayload(sum)}, nil)
+}
+
+// callPlugin runs command with req as JSON on stdin and decodes its stdout
+// into res unless res is nil. A plugin fails by exiting non-zero or by
+// printing {"error": "..."}; anything it writes to stderr is passed through.
+func callPlugin(ctx context.Context, command []string, req, res interface{}) error {
+	if len(command) == 0 {
+		return errors.New("no command configured")
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%s: %w", strings.Join(command, " "), err)
+	}
+
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil
+	}
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(out, &failure); err != nil {
+		return fmt.Errorf("%s: invalid JSON output: %w", strings.Join(command, " "), err)
+	}
+	if failure.Error != "" {
+		return errors.New(failure.Error)
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(out, res)
+}
+
+// aggregations lists the built-in aggregations followed by the metrics
+// plugins in the settings file
+func aggregations(rules []matchRule, metrics []metricSettings) []aggregation {
+	aggs := []aggregation{matchedTotals{rules}, streakAggregation{rules}}
+	for _, m := range metrics {
+		aggs = append(aggs, pluginAggregation{name: m.Name, command: m.Command})
+	}
+	return aggs
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// powerDurations are the mean-maximal power durations, in seconds
+var powerDurations = []int{5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// powerCurve is the best average power for each duration in seconds.
+// Durations longer than the activity are missing.
+type powerCurve map[int]float64
+
+func computePowerCurve(streams strava.Streams) powerCurve {
+	curve := make(powerCurve)
+	if streams.Time == nil || streams.Watts == nil {
+		return curve
+	}
+	for _, d := range powerDurations {
+		if best, ok := bestAverage(streams.Time.Data, streams.Watts.Data, d); ok {
+			curve[d] = best
+		}
+	}
+	return curve
+}
+
+// merge raises each duration of c to the best of c and other
+func (c powerCurve) merge(other powerCurve) {
+	for d, w := range other {
+		if w > c[d] {
+			c[d] = w
+		}
+	}
+}
+
+func (c *activityCache) setPowerCurve(id int, startDate string, curve powerCurve) error {
+	data, err := json.Marshal(curve)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec(`INSERT INTO power_curves (activity_id, start_date, curve) VALUES (?, ?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET start_date = excluded.start_date, curve = excluded.curve`, id, startDate, string(data))
+	return err
+}
+
+// powerCurves returns the stored curve of every analysed activity
+func (c *activityCache) powerCurves() (map[int]powerCurve, error) {
+	rows, err := c.db.Query(`SELECT activity_id, curve FROM power_curves`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	curves := make(map[int]powerCurve)
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, err
+		}
+		var curve powerCurve
+		if err := json.Unmarshal([]byte(raw), &curve); err != nil {
+			return nil, err
+		}
+		curves[id] = curve
+	}
+	return curves, rows.Err()
+}
+
+// formatSeconds renders a duration label such as 5s, 2m, or 1h
+func formatSeconds(seconds int) string {
+	switch {
+	case seconds >= 3600 && seconds%3600 == 0:
+		return fmt.Sprintf("%dh", seconds/3600)
+	case seconds >= 60 && seconds%60 == 0:
+		return fmt.Sprintf("%dm", seconds/60)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+func newPowerCurveCmd() *cobra.Command {
+	var windows []int
+	var format string
+	var chart bool
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "powercurve [activity id]",
+		Short: "Show mean-maximal power from 5 seconds to an hour",
+		Long: `With an activity id, shows the best average power the activity held for
+each duration from 5 seconds to an hour. Without one, shows your best over
+rolling windows of days (--windows 42,90,365) across every cached activity
+with a power meter.
+
+Curves are computed from the watts stream, fetched once per activity and
+stored in the cache; at most --limit new activities are fetched per run.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			switch format {
+			case "table", "csv", "json":
+			default:
+				logger.Fatalf("unknown format %q, expected table, csv, or json\n", format)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			columns := make([]string, 0)
+			curves := make(map[string]powerCurve)
+
+			if len(args) == 1 {
+				id, err := strconv.ParseInt(args[0], 10, 64)
+				if err != nil {
+					logger.Fatalf("invalid activity id %q\n", args[0])
+				}
+				streams, err := storedStreams(ctx, client, cache, config.Settings.Streams, id)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				if streams.Watts == nil {
+					logger.Fatalf("activity %d has no power data\n", id)
+				}
+				columns = append(columns, "watts")
+				curves["watts"] = computePowerCurve(streams)
+			} else {
+				sort.Ints(windows)
+				if len(windows) == 0 || windows[0] < 1 {
+					logger.Fatal("--windows must list positive numbers of days")
+				}
+				now := time.Now().UTC()
+				from := now.AddDate(0, 0, -windows[len(windows)-1])
+
+				activities, err := cache.activities()
+				if err != nil {
+					logger.Fatal(err)
+				}
+				stored, err := cache.powerCurves()
+				if err != nil {
+					logger.Fatal(err)
+				}
+
+				// Fetch the newest rides without a stored curve first
+				rides := make([]strava.Activity, 0)
+				for _, a := range activities {
+					if a.DeviceWatts && inDateRange(a, from, time.Time{}) {
+						rides = append(rides, a)
+					}
+				}
+				sort.Slice(rides, func(i, j int) bool { return rides[i].StartDate > rides[j].StartDate })
+				requests := 0
+				for _, a := range rides {
+					if _, ok := stored[a.Id]; ok {
+						continue
+					}
+					if requests >= limit {
+						logger.Printf("Fetched %d power streams, rerun to fetch the remaining ones\n", requests)
+						break
+					}
+					streams, err := storedStreams(strava.WithPriority(ctx, strava.PriorityBulk), client, cache, config.Settings.Streams, int64(a.Id))
+					if errors.Is(err, strava.ErrDeferred) {
+						logger.Printf("Fetched %d power streams, %v; rerun to fetch the remaining ones\n", requests, err)
+						break
+					}
+					if err != nil {
+						logger.Fatalf("activity %d: %v\n", a.Id, err)
+					}
+					requests++
+					curve := computePowerCurve(streams)
+					if err := cache.setPowerCurve(a.Id, a.StartDate, curve); err != nil {
+						logger.Fatal(err)
+					}
+					stored[a.Id] = curve
+				}
+
+				for _, days := range windows {
+					name := fmt.Sprintf("%dd", days)
+					columns = append(columns, name)
+					curve := make(powerCurve)
+					start := now.AddDate(0, 0, -days)
+					for _, a := range rides {
+						if inDateRange(a, start, time.Time{}) {
+							curve.merge(stored[a.Id])
+						}
+					}
+					curves[name] = curve
+				}
+			}
+
+			switch format {
+			case "csv":
+				err = writePowerCurveCSV(columns, curves)
+			case "json":
+				printJSON(logger, powerCurveRows(columns, curves))
+			default:
+				writePowerCurveTable(columns, curves, chart)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().IntSliceVar(&windows, "windows", []int{42, 90, 365}, "rolling windows in days")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, csv, or json")
+	cmd.Flags().BoolVar(&chart, "chart", false, "draw the first column as a bar chart")
+	cmd.Flags().IntVar(&limit, "limit", defaultScoreLimit, "maximum streams requests")
+
+	return cmd
+}
+
+func writePowerCurveTable(columns []string, curves map[string]powerCurve, chart bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DURATION\t"+strings.ToUpper(strings.Join(columns, "\t")))
+
+	// Bars are scaled to the peak of the first column
+	peak := 0.0
+	for _, v := range curves[columns[0]] {
+		if v > peak {
+			peak = v
+		}
+	}
+
+	for _, d := range powerDurations {
+		fmt.Fprint(w, formatSeconds(d))
+		for _, c := range columns {
+			fmt.Fprintf(w, "\t%s", optional(curves[c][d]))
+		}
+		if chart && peak > 0 {
+			fmt.Fprintf(w, "\t%s", strings.Repeat("#", int(curves[columns[0]][d]/peak*40+0.5)))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+func writePowerCurveCSV(columns []string, curves map[string]powerCurve) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(append([]string{"duration_s", "duration"}, columns...)); err != nil {
+		return err
+	}
+	for _, d := range powerDurations {
+		record := []string{strconv.Itoa(d), formatSeconds(d)}
+		for _, c := range columns {
+			v := ""
+			if watts, ok := curves[c][d]; ok {
+				v = strconv.FormatFloat(watts, 'f', 0, 64)
+			}
+			record = append(record, v)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+type powerCurveRow struct {
+	Seconds  int                `json:"duration_s"`
+	Duration string             `json:"duration"`
+	Watts    map[string]float64 `json:"watts"`
+}
+
+// powerCurveRows lays the curves out one duration per row, for JSON
+func powerCurveRows(columns []string, curves map[string]powerCurve) []powerCurveRow {
+	rows := make([]powerCurveRow, 0, len(powerDurations))
+	for _, d := range powerDurations {
+		r := powerCurveRow{Seconds: d, Duration: formatSeconds(d), Watts: make(map[string]float64)}
+		for _, c := range columns {
+			if watts, ok := curves[c][d]; ok {
+				r.Watts[c] = watts
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
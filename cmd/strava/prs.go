@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// maxPRChecks bounds the detail requests made for PRs in a single run, so
+// the first sync of a long history does not use up the rate limit
+const maxPRChecks = 50
+
+// personalRecord is a segment effort that beat every earlier effort on
+// the segment
+type personalRecord struct {
+	SegmentId   int64  `json:"segment_id"`
+	SegmentName string `json:"segment_name"`
+	ActivityId  int64  `json:"activity_id"`
+	EffortId    int64  `json:"effort_id"`
+	ElapsedTime int    `json:"elapsed_time"`
+	StartDate   string `json:"start_date"`
+	// Previous is the elapsed time of the record this one replaced, 0 for
+	// the first effort on a segment
+	Previous int `json:"previous,omitempty"`
+}
+
+// trackPRs fetches the segment and best efforts of new activities and
+// records the segment efforts Strava ranked as PRs along with new running
+// distance records. Failures are logged rather than fatal because PRs are
+// a bonus on top of the summary.
+func trackPRs(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, added []strava.Activity) ([]personalRecord, []bestEffortRecord) {
+	if len(added) > maxPRChecks {
+		logger.Printf("Skipping PR check for %d new activities, run `prs refresh` and `prs running backfill` to catch up\n", len(added))
+		return nil, nil
+	}
+
+	records := make([]personalRecord, 0)
+	bests := make([]bestEffortRecord, 0)
+	for _, a := range added {
+		activity, err := client.GetActivity(ctx, int64(a.Id), false)
+		if err != nil {
+			logger.Printf("PR check for activity %d: %v\n", a.Id, err)
+			continue
+		}
+		if err := cache.setCalories(activity); err != nil {
+			logger.Printf("PR check for activity %d: %v\n", a.Id, err)
+		}
+		if err := cache.setDevice(activity); err != nil {
+			logger.Printf("PR check for activity %d: %v\n", a.Id, err)
+		}
+
+		for _, effort := range activity.SegmentEfforts {
+			if !effort.IsPR() {
+				continue
+			}
+			pr, isNew, err := cache.recordPR(effort)
+			if err != nil {
+				logger.Printf("PR check for activity %d: %v\n", a.Id, err)
+				continue
+			}
+			if isNew {
+				logger.Printf("New PR on %s: %s\n", pr.SegmentName, formatDuration(pr.ElapsedTime))
+				records = append(records, pr)
+			}
+		}
+
+		newBests, err := cache.recordBestEfforts(activity)
+		if err != nil {
+			logger.Printf("PR check for activity %d: %v\n", a.Id, err)
+			continue
+		}
+		for _, best := range newBests {
+			logger.Printf("New %s PR: %s\n", best.Name, formatDuration(best.ElapsedTime))
+		}
+		bests = append(bests, newBests...)
+	}
+	return records, bests
+}
+
+// recordPR saves effort when it is faster than the best recorded on its
+// segment, reporting whether it was a new record
+func (c *activityCache) recordPR(effort strava.SegmentEffort) (personalRecord, bool, error) {
+	pr := personalRecord{
+		SegmentId:   effort.Segment.Id,
+		SegmentName: effort.Segment.Name,
+		ActivityId:  effort.Activity.Id,
+		EffortId:    effort.Id,
+		ElapsedTime: effort.ElapsedTime,
+		StartDate:   effort.StartDate,
+	}
+	if pr.SegmentName == "" {
+		pr.SegmentName = effort.Name
+	}
+
+	var best sql.NullInt64
+	err := c.db.QueryRow(`SELECT MIN(elapsed_time) FROM segment_prs WHERE segment_id = ? AND start_date < ?`,
+		pr.SegmentId, pr.StartDate).Scan(&best)
+	if err != nil {
+		return pr, false, err
+	}
+	if best.Valid {
+		if int64(pr.ElapsedTime) >= best.Int64 {
+			return pr, false, nil
+		}
+		pr.Previous = int(best.Int64)
+	}
+
+	res, err := c.db.Exec(`INSERT OR IGNORE INTO segment_prs (effort_id, segment_id, segment_name, activity_id, elapsed_time, start_date)
+		VALUES (?, ?, ?, ?, ?, ?)`, pr.EffortId, pr.SegmentId, pr.SegmentName, pr.ActivityId, pr.ElapsedTime, pr.StartDate)
+	if err != nil {
+		return pr, false, err
+	}
+	n, err := res.RowsAffected()
+	return pr, n > 0, err
+}
+
+// personalRecords returns the PR history of a segment oldest first, or the
+// current PR of every segment when segmentID is 0
+func (c *activityCache) personalRecords(segmentID int64) ([]personalRecord, error) {
+	query := `SELECT p.segment_id, p.segment_name, p.activity_id, p.effort_id, p.elapsed_time, p.start_date
+		FROM segment_prs p
+		WHERE p.elapsed_time = (SELECT MIN(elapsed_time) FROM segment_prs WHERE segment_id = p.segment_id)
+		ORDER BY p.segment_name`
+	args := []interface{}{}
+	if segmentID != 0 {
+		query = `SELECT segment_id, segment_name, activity_id, effort_id, elapsed_time, start_date
+			FROM segment_prs WHERE segment_id = ? ORDER BY start_date`
+		args = append(args, segmentID)
+	}
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]personalRecord, 0)
+	for rows.Next() {
+		var pr personalRecord
+		if err := rows.Scan(&pr.SegmentId, &pr.SegmentName, &pr.ActivityId, &pr.EffortId, &pr.ElapsedTime, &pr.StartDate); err != nil {
+			return nil, err
+		}
+		records = append(records, pr)
+	}
+	return records, rows.Err()
+}
+
+func newPRsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prs",
+		Short: "Show segment personal records tracked in the local cache",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			records, err := cache.personalRecords(0)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			printPRs(records)
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "history <segment id>",
+		Short: "Show how the PR on a segment improved over time",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				logger.Fatalf("invalid segment id %q\n", args[0])
+			}
+
+			config := loadConfig(cmd.Context(), logger)
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			records, err := cache.personalRecords(id)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			printPRs(records)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "refresh",
+		Short: "Rebuild PR history from your efforts on every starred segment",
+		Long: `Lists your efforts on each starred segment and records every effort that
+beat the ones before it. Listing efforts needs a Strava subscription.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			added := 0
+			for page := 1; ; page++ {
+				segments, err := client.ListStarredSegments(ctx, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+				if err != nil {
+					logger.Fatal(err)
+				}
+
+				for _, s := range segments {
+					efforts, err := client.ListSegmentEfforts(ctx, s.Id, strava.SegmentEffortsOptions{PerPage: strava.MaxPerPage})
+					if err != nil {
+						logger.Fatalf("segment %d: %v\n", s.Id, err)
+					}
+
+					// Walk oldest first so each effort is compared with the ones before it
+					sort.Slice(efforts, func(i, j int) bool { return efforts[i].StartDate < efforts[j].StartDate })
+					for _, e := range efforts {
+						if e.Segment.Name == "" {
+							e.Segment = s
+						}
+						_, isNew, err := cache.recordPR(e)
+						if err != nil {
+							logger.Fatal(err)
+						}
+						if isNew {
+							added++
+						}
+					}
+				}
+
+				if len(segments) < strava.MaxPerPage {
+					break
+				}
+			}
+			logger.Printf("Recorded %d PRs\n", added)
+		},
+	})
+
+	cmd.AddCommand(newRunningPRsCmd())
+
+	return cmd
+}
+
+func printPRs(records []personalRecord) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEGMENT\tNAME\tTIME\tDATE\tACTIVITY")
+	for _, pr := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\n", pr.SegmentId, pr.SegmentName, formatDuration(pr.ElapsedTime), pr.StartDate, pr.ActivityId)
+	}
+	w.Flush()
+}
+
+// prSummary renders new PRs for chat notifications
+func prSummary(records []personalRecord) string {
+	s := ""
+	for _, pr := range records {
+		s += fmt.Sprintf("\nNew PR on %s: %s", pr.SegmentName, formatDuration(pr.ElapsedTime))
+		if pr.Previous > 0 {
+			s += fmt.Sprintf(" (was %s)", formatDuration(pr.Previous))
+		}
+	}
+	return s
+}
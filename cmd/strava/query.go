@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// queryFields are the activity API fields the activities view adds as
+// columns next to those of the cache table, read from the raw payload
+var queryFields = []string{
+	"start_date_local", "sport_type", "total_elevation_gain", "average_speed", "max_speed",
+	"average_heartrate", "max_heartrate", "average_watts", "kilojoules", "gear_id",
+	"commute", "trainer", "kudos_count",
+}
+
+// openQueryCache opens the cache at path read-only on a single connection,
+// with a temporary activities view that shadows the table for unqualified
+// names. The view adds queryFields and leaves out deleted activities unless
+// --include-deleted is set; main.activities is the table itself.
+func openQueryCache(ctx context.Context, path string) (*sql.Conn, func(), error) {
+	if path == "" {
+		path = defaultCachePath
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("no cache at %s, run sync first: %w", path, err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro")
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	closeAll := func() {
+		conn.Close()
+		db.Close()
+	}
+
+	columns := make([]string, len(queryFields))
+	for i, field := range queryFields {
+		columns[i] = fmt.Sprintf("json_extract(a.raw, '$.%s') AS %s", field, field)
+	}
+	where := "WHERE a.id NOT IN (SELECT activity_id FROM main.deleted_activities)"
+	if viper.GetBool("include-deleted") {
+		where = ""
+	}
+	view := `CREATE TEMP VIEW activities AS SELECT a.*, ` + strings.Join(columns, ", ") + ` FROM main.activities a ` + where
+	if _, err := conn.ExecContext(ctx, view); err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	// Temporary objects are still writable on a read-only database
+	if _, err := conn.ExecContext(ctx, `PRAGMA query_only = ON`); err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	return conn, closeAll, nil
+}
+
+// queryResult is the rows of a query with their values as text, NULL as
+// an empty string
+type queryResult struct {
+	columns []string
+	rows    [][]string
+	values  []map[string]interface{}
+}
+
+func runQuery(ctx context.Context, conn *sql.Conn, query string) (queryResult, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return queryResult{}, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return queryResult{}, err
+	}
+	res := queryResult{columns: columns, rows: make([][]string, 0), values: make([]map[string]interface{}, 0)}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		scan := make([]interface{}, len(columns))
+		for i := range values {
+			scan[i] = &values[i]
+		}
+		if err := rows.Scan(scan...); err != nil {
+			return queryResult{}, err
+		}
+		row := make([]string, len(columns))
+		object := make(map[string]interface{}, len(columns))
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			row[i] = formatQueryValue(v)
+			object[columns[i]] = v
+		}
+		res.rows = append(res.rows, row)
+		res.values = append(res.values, object)
+	}
+	return res, rows.Err()
+}
+
+func formatQueryValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (r queryResult) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(r.columns, "\t"))
+	for _, row := range r.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (r queryResult) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(r.columns)
+	cw.WriteAll(r.rows)
+	return cw.Error()
+}
+
+func newQueryCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "query <sql>",
+		Short: "Run read-only SQL against the cache",
+		Long: `Runs a SQL query against the SQLite cache and prints the rows, for questions
+no built-in report answers:
+
+  strava-api query "SELECT strftime('%Y-%m', start_date_local) m, sum(distance) FROM activities GROUP BY m"
+
+The cache is opened read-only, so statements that would change it fail.
+activities is a view over the cached activities that adds columns for
+common API fields, such as start_date_local, sport_type,
+total_elevation_gain, average_heartrate, average_watts, and gear_id, and
+leaves out deleted activities unless --include-deleted is set. Any other
+field is in the raw JSON, e.g. json_extract(raw, '$.max_watts'), and the
+table itself is main.activities. Distances are in meters and times in
+seconds, as Strava returns them. The other tables, such as best_efforts,
+segment_prs, and stream_samples, are listed in the README.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			if format != "table" && format != "csv" && format != "json" {
+				logger.Fatalf("--format must be table, csv, or json, got %q", format)
+			}
+
+			conn, closeConn, err := openQueryCache(ctx, config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer closeConn()
+
+			res, err := runQuery(ctx, conn, args[0])
+			if errors.Is(err, context.Canceled) {
+				logger.Fatal("query interrupted")
+			}
+			if err != nil {
+				logger.Fatalf("query: %v", err)
+			}
+			switch format {
+			case "csv":
+				err = res.writeCSV(os.Stdout)
+			case "json":
+				printJSON(logger, res.values)
+			default:
+				err = res.writeTable(os.Stdout)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, csv, or json")
+	return cmd
+}
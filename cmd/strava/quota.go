@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// State keys for API call accounting. Daily counts are keyed by UTC date,
+// the day Strava's daily limit resets on.
+const (
+	apiCallsDayPrefix = "api_calls:"
+	apiCallsTotalKey  = "api_calls_total"
+	rateLimitKey      = "rate_limit_usage"
+)
+
+// rateLimitSnapshot is the last usage Strava reported to any run sharing
+// the cache, which includes calls made by other clients of the application
+type rateLimitSnapshot struct {
+	ShortUsed  int       `json:"short_used"`
+	ShortLimit int       `json:"short_limit"`
+	DailyUsed  int       `json:"daily_used"`
+	DailyLimit int       `json:"daily_limit"`
+	SeenAt     time.Time `json:"seen_at"`
+}
+
+// current drops usage counted in windows that have reset since the
+// snapshot was taken
+func (s rateLimitSnapshot) current(now time.Time) strava.RateLimitUsage {
+	u := strava.RateLimitUsage{
+		ShortUsed:  s.ShortUsed,
+		ShortLimit: s.ShortLimit,
+		ShortReset: s.SeenAt.Truncate(15 * time.Minute).Add(15 * time.Minute),
+		DailyUsed:  s.DailyUsed,
+		DailyLimit: s.DailyLimit,
+	}
+	y, m, d := s.SeenAt.UTC().Date()
+	u.DailyReset = time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	if !now.Before(u.ShortReset) {
+		u.ShortUsed = 0
+		u.ShortReset = now.Truncate(15 * time.Minute).Add(15 * time.Minute)
+	}
+	if !now.Before(u.DailyReset) {
+		u.DailyUsed = 0
+		y, m, d = now.UTC().Date()
+		u.DailyReset = time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	}
+	return u
+}
+
+// usageTracker counts the API calls made by this process. The client and
+// the cache are created separately by each command, so calls are held
+// until a cache is open and then recorded there as they happen.
+type usageTracker struct {
+	mu       sync.Mutex
+	calls    int
+	pending  int
+	snapshot rateLimitSnapshot
+	cache    *activityCache
+	// limiter is seeded with the usage other runs saw, until this one
+	// gets its own from Strava
+	limiter interface{ Update(http.Header) }
+}
+
+// apiUsage observes every response of the client made by newClient
+var apiUsage = &usageTracker{}
+
+func (t *usageTracker) observe(res *http.Response) {
+	// Token refreshes are not counted against the rate limits
+	if res.Request != nil && strings.HasSuffix(res.Request.URL.Path, "/oauth/token") {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+	t.pending++
+	if u, ok := strava.ParseRateLimit(res.Header); ok {
+		t.snapshot = rateLimitSnapshot{ShortUsed: u.ShortUsed, ShortLimit: u.ShortLimit, DailyUsed: u.DailyUsed, DailyLimit: u.DailyLimit, SeenAt: time.Now()}
+	}
+	t.flush()
+}
+
+// count is the number of API calls made by this process
+func (t *usageTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.calls
+}
+
+func (t *usageTracker) attach(cache *activityCache) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cache = cache
+	t.flush()
+	t.seed()
+}
+
+func (t *usageTracker) schedule(limiter interface{ Update(http.Header) }) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limiter = limiter
+	t.seed()
+}
+
+// seed passes the usage last recorded in the cache to the limiter, so a
+// backfill starting right after another command knows how much quota is
+// left before its first response. t.mu must be held.
+func (t *usageTracker) seed() {
+	if t.cache == nil || t.limiter == nil || !t.snapshot.SeenAt.IsZero() {
+		return
+	}
+	raw, err := t.cache.state(rateLimitKey)
+	if err != nil || raw == "" {
+		return
+	}
+	var snapshot rateLimitSnapshot
+	if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+		return
+	}
+	u := snapshot.current(time.Now())
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", fmt.Sprintf("%d,%d", u.ShortLimit, u.DailyLimit))
+	h.Set("X-RateLimit-Usage", fmt.Sprintf("%d,%d", u.ShortUsed, u.DailyUsed))
+	t.limiter.Update(h)
+}
+
+func (t *usageTracker) detach(cache *activityCache) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cache == cache {
+		t.cache = nil
+	}
+}
+
+// flush records pending calls in the cache. Failures keep them pending for
+// the next response, since the counts are only informational. t.mu must be
+// held.
+func (t *usageTracker) flush() {
+	if t.cache == nil || t.pending == 0 {
+		return
+	}
+	day := time.Now().UTC().Format(time.DateOnly)
+	if err := t.cache.addState(apiCallsDayPrefix+day, t.pending); err != nil {
+		return
+	}
+	if err := t.cache.addState(apiCallsTotalKey, t.pending); err != nil {
+		return
+	}
+	t.pending = 0
+	if !t.snapshot.SeenAt.IsZero() {
+		if raw, err := json.Marshal(t.snapshot); err == nil {
+			t.cache.setState(rateLimitKey, string(raw))
+		}
+	}
+}
+
+func newQuotaCmd() *cobra.Command {
+	var refresh bool
+
+	cmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Show API usage against the 15-minute and daily rate limits",
+		Long: `Shows the rate limit usage Strava last reported to any command sharing
+the cache, which counts every client of the application, such as a webhook
+handler and a cron sync, next to the calls made from this cache today and in
+total. Windows that have reset since are shown as unused. --refresh makes one
+request to get the current figures first.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			if refresh {
+				client := newClient(ctx, logger, config)
+				authenticate(ctx, logger, client)
+				if err := client.Probe(ctx); err != nil {
+					logger.Fatal(err)
+				}
+			}
+
+			now := time.Now()
+			today, err := stateInt(cache, apiCallsDayPrefix+now.UTC().Format(time.DateOnly))
+			if err != nil {
+				logger.Fatal(err)
+			}
+			total, err := stateInt(cache, apiCallsTotalKey)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			raw, err := cache.state(rateLimitKey)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			if raw == "" {
+				fmt.Println("Strava has not reported rate limit usage yet; run a sync or pass --refresh")
+			} else {
+				var snapshot rateLimitSnapshot
+				if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+					logger.Fatal(err)
+				}
+				u := snapshot.current(now)
+
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "WINDOW\tUSED\tLIMIT\tLEFT\tRESETS")
+				fmt.Fprintf(w, "15-minute\t%d\t%d\t%d\t%s\n", u.ShortUsed, u.ShortLimit, u.ShortRemaining(), u.ShortReset.Local().Format("15:04"))
+				fmt.Fprintf(w, "daily\t%d\t%d\t%d\t%s\n", u.DailyUsed, u.DailyLimit, u.DailyRemaining(), u.DailyReset.Local().Format("Jan 2 15:04"))
+				w.Flush()
+				fmt.Printf("\nReported by Strava %s ago\n", now.Sub(snapshot.SeenAt).Round(time.Second))
+			}
+			fmt.Printf("Calls from this cache: %d today (UTC), %d in total\n", today, total)
+		},
+	}
+
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "make one API call to get the current usage")
+
+	return cmd
+}
+
+// stateInt reads a counter stored with addState, zero when unset
+func stateInt(cache *activityCache, key string) (int, error) {
+	value, err := cache.state(key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// raceBucketTolerance is how far, as a fraction, a race's recorded
+// distance may be from a standard distance to be compared at it. GPS
+// usually reads a few percent long over a measured course.
+const raceBucketTolerance = 0.05
+
+// raceBucket is a standard race distance
+type raceBucket struct {
+	Name     string
+	Distance float64
+}
+
+var raceBuckets = []raceBucket{
+	{"5k", 5000},
+	{"10k", 10000},
+	{"half", 21097.5},
+	{"marathon", 42195},
+}
+
+// bucketFor returns the standard distance within raceBucketTolerance of
+// meters, or false
+func bucketFor(meters float64) (raceBucket, bool) {
+	for _, b := range raceBuckets {
+		if math.Abs(meters-b.Distance) <= b.Distance*raceBucketTolerance {
+			return b, true
+		}
+	}
+	return raceBucket{}, false
+}
+
+// raceResult is an activity marked as a race. StandardTime is the finish
+// time scaled to the distance of the bucket, so races over slightly long
+// or short courses compare fairly.
+type raceResult struct {
+	ActivityId   int     `json:"activity_id"`
+	Name         string  `json:"name"`
+	Type         string  `json:"type"`
+	StartDate    string  `json:"start_date"`
+	Distance     float64 `json:"distance"`
+	ElapsedTime  int     `json:"elapsed_time"`
+	Bucket       string  `json:"bucket,omitempty"`
+	StandardTime int     `json:"standard_time,omitempty"`
+	// PR is set for a race faster at its bucket than every earlier one.
+	// Behind is how much slower than the best earlier one it was otherwise.
+	PR     bool `json:"pr,omitempty"`
+	Behind int  `json:"behind,omitempty"`
+}
+
+// raceResults returns the activities marked as races, oldest first, the
+// runs at a standard distance compared with the earlier ones there. The
+// finish time is the elapsed time, as on a race clock.
+func raceResults(activities []strava.Activity) []raceResult {
+	races := make([]strava.Activity, 0)
+	for _, a := range activities {
+		if a.Workout() == strava.WorkoutRace {
+			races = append(races, a)
+		}
+	}
+	sort.SliceStable(races, func(i, j int) bool { return races[i].StartDate < races[j].StartDate })
+
+	best := make(map[string]int)
+	results := make([]raceResult, 0, len(races))
+	for _, a := range races {
+		r := raceResult{ActivityId: a.Id, Name: a.Name, Type: a.Type, StartDate: a.StartDate, Distance: a.Distance, ElapsedTime: a.ElapsedTime}
+		if b, ok := bucketFor(a.Distance); ok && a.ElapsedTime > 0 && containsFold(runTypes, a.Type) {
+			r.Bucket = b.Name
+			r.StandardTime = int(float64(a.ElapsedTime)*b.Distance/a.Distance + 0.5)
+			if previous, ok := best[b.Name]; !ok || r.StandardTime < previous {
+				r.PR = true
+				best[b.Name] = r.StandardTime
+			} else {
+				r.Behind = r.StandardTime - previous
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func writeRaces(w io.Writer, output outputSettings, results []raceResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tNAME\tDISTANCE\tTIME\tPACE\tBUCKET\tSTANDARD\tVS BEST")
+	for _, r := range results {
+		distance, unit := output.convert(r.Distance)
+		bucket, standard, versus := "-", "-", "-"
+		if r.Bucket != "" {
+			bucket, standard = r.Bucket, formatDuration(r.StandardTime)
+			versus = "PR"
+			if !r.PR {
+				versus = "+" + formatDuration(r.Behind)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.2f %s\t%s\t%s\t%s\t%s\t%s\n", r.StartDate, r.Name, distance, unit, formatDuration(r.ElapsedTime),
+			formatPace(r.ElapsedTime, distance, unit), bucket, standard, versus)
+	}
+	return tw.Flush()
+}
+
+// raceRecords returns the fastest race at each standard distance, in the
+// order of raceBuckets
+func raceRecords(results []raceResult) []raceResult {
+	best := make(map[string]raceResult)
+	for _, r := range results {
+		if r.PR {
+			best[r.Bucket] = r
+		}
+	}
+	records := make([]raceResult, 0, len(best))
+	for _, b := range raceBuckets {
+		if r, ok := best[b.Name]; ok {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+func newRacesCmd() *cobra.Command {
+	var format string
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "races",
+		Short: "List the activities marked as races with their finish times and PRs",
+		Long: `Lists the cached activities marked as races on Strava, or by a workout
+autotag rule, selected by --sport, --gear, --after, --before, --match, and
+--filter, oldest first, with the finish time and pace. The finish time is
+the elapsed time, as on a race clock.
+
+Runs within 5% of 5k, 10k, a half marathon, or a marathon are compared
+at that distance: the standard time scales the finish time to exactly the
+distance, so a course that measured long on GPS is not held against a
+race, and each is marked as a PR or shown behind the best earlier race.
+The fastest race at each distance follows the table.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+			if format != "table" && format != "json" {
+				logger.Fatalf("unknown format %q, expected table or json\n", format)
+			}
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			activities, err := cache.activities()
+			cache.Close()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			selected, err := filterActivities(expr, activities)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			results := raceResults(selected)
+			if format == "json" {
+				printJSON(logger, results)
+				return
+			}
+			if len(results) == 0 {
+				logger.Println("No races among the selected activities; mark activities as races on Strava or with a workout autotag rule")
+				return
+			}
+			output := config.Settings.Output
+			if err := writeRaces(os.Stdout, output, results); err != nil {
+				logger.Fatal(err)
+			}
+			for _, r := range raceRecords(results) {
+				fmt.Printf("Best %s: %s (%s, %s)\n", r.Bucket, formatDuration(r.StandardTime), r.Name, r.StartDate)
+			}
+		},
+	}
+
+	selection = addSelectionFlags(cmd)
+	// Races are the one workout type listed
+	cmd.Flags().MarkHidden("workout")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+
+	return cmd
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/brandtkeller/strava-api/pkg/strava/awsstore"
+	"github.com/brandtkeller/strava-api/pkg/strava/vaultstore"
+	"github.com/spf13/viper"
+)
+
+// remoteStore is a secret backend that holds the STRAVA_* configuration and
+// receives rotated tokens
+type remoteStore interface {
+	strava.TokenStore
+	Values(ctx context.Context) (map[string]string, error)
+}
+
+// remoteConfigured reports whether a remote secret backend is configured
+func remoteConfigured(config envVars) bool {
+	return config.StravaAwsSecretId != "" || config.StravaAwsSsmPath != "" || config.StravaVaultPath != ""
+}
+
+// newRemoteStore returns the Vault, Secrets Manager, or Parameter Store
+// backend selected by the configuration, or nil when none is configured
+func newRemoteStore(ctx context.Context, config envVars) (remoteStore, error) {
+	if config.StravaVaultPath != "" {
+		kvVersion, _ := strconv.Atoi(config.StravaVaultKvVersion)
+		transport, err := newTransport(config.Settings.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		return &vaultstore.Store{
+			Address:    config.VaultAddr,
+			Namespace:  config.VaultNamespace,
+			Mount:      config.StravaVaultMount,
+			Path:       config.StravaVaultPath,
+			KVVersion:  kvVersion,
+			Token:      config.VaultToken,
+			RoleID:     config.VaultRoleId,
+			SecretID:   config.VaultSecretId,
+			HTTPClient: &http.Client{Transport: transport},
+		}, nil
+	}
+
+	if config.StravaAwsSecretId == "" && config.StravaAwsSsmPath == "" {
+		return nil, nil
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.StravaAwsSecretId != "" {
+		return awsstore.NewSecretsManagerStore(cfg, config.StravaAwsSecretId), nil
+	}
+	return awsstore.NewParameterStore(cfg, config.StravaAwsSsmPath), nil
+}
+
+// loadRemoteValues overlays STRAVA_* values from the configured remote
+// backend on top of the env file. Real environment variables still take
+// precedence.
+func loadRemoteValues(ctx context.Context, config envVars) error {
+	store, err := newRemoteStore(ctx, config)
+	if err != nil || store == nil {
+		return err
+	}
+
+	values, err := store.Values(ctx)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		if _, ok := os.LookupEnv(key); !ok {
+			viper.Set(key, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+func newReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Print reports about your activities",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "activity <id>",
+		Short: "Show an activity with its laps, splits, and segment efforts",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				logger.Fatalf("invalid activity id %q\n", args[0])
+			}
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			activity, err := client.GetActivity(ctx, id, false)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			laps, err := client.ListActivityLaps(ctx, id)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			// Grade adjusted pace only applies to runs recorded with GPS
+			var streams strava.Streams
+			if isRun(activity.Type) && activity.Map != nil && activity.Map.SummaryPolyline != "" {
+				streams, err = client.GetActivityStreams(ctx, id, gapStreams...)
+				if err != nil {
+					logger.Fatal(err)
+				}
+			}
+
+			printActivityReport(config.Settings.Output, activity, laps, streams)
+		},
+	})
+
+	cmd.AddCommand(newZonesReportCmd())
+	cmd.AddCommand(newSocialReportCmd())
+	cmd.AddCommand(newGAPReportCmd())
+	cmd.AddCommand(newTotalsReportCmd())
+	cmd.AddCommand(newStoppedReportCmd())
+	cmd.AddCommand(newIndoorReportCmd())
+	cmd.AddCommand(newChartsReportCmd())
+	cmd.AddCommand(newSiteReportCmd())
+
+	return cmd
+}
+
+// maxZoneActivities bounds the zone requests made by one report
+const maxZoneActivities = 100
+
+func newZonesReportCmd() *cobra.Command {
+	var athlete bool
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "zones [activity id]",
+		Short: "Show time in heart rate and power zones for an activity or a date range",
+		Long: `With an activity id, shows that activity's time in each zone. Otherwise the
+zones of the cached activities selected by --sport, --gear, --after,
+--before, --match, and --filter are added up. Zone data needs a Strava
+subscription.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			if athlete {
+				client := newClient(ctx, logger, config)
+				authenticate(ctx, logger, client)
+
+				zones, err := client.GetAthleteZones(ctx)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "TYPE\tZONE\tRANGE")
+				if zones.HeartRate != nil {
+					for i, z := range zones.HeartRate.Zones {
+						fmt.Fprintf(w, "heartrate\tZ%d\t%s\n", i+1, zoneRange(z.Min, z.Max))
+					}
+				}
+				if zones.Power != nil {
+					for i, z := range zones.Power.Zones {
+						fmt.Fprintf(w, "power\tZ%d\t%s\n", i+1, zoneRange(z.Min, z.Max))
+					}
+				}
+				w.Flush()
+				return
+			}
+
+			ids := make([]int64, 0)
+			if len(args) == 1 {
+				id, err := strconv.ParseInt(args[0], 10, 64)
+				if err != nil {
+					logger.Fatalf("invalid activity id %q\n", args[0])
+				}
+				ids = append(ids, id)
+			} else {
+				expr, err := selection.filter()
+				if err != nil {
+					logger.Fatal(err)
+				}
+
+				cache, err := openCache(config.StravaCachePath)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				activities, err := cache.activities()
+				cache.Close()
+				if err != nil {
+					logger.Fatal(err)
+				}
+				if activities, err = filterActivities(expr, activities); err != nil {
+					logger.Fatal(err)
+				}
+				for _, a := range activities {
+					ids = append(ids, int64(a.Id))
+				}
+				if len(ids) > maxZoneActivities {
+					logger.Fatalf("%d activities selected, narrow the selection to at most %d\n", len(ids), maxZoneActivities)
+				}
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			totals := make(map[string][]strava.TimedZoneRange)
+			for _, id := range ids {
+				zones, err := client.GetActivityZones(ctx, id)
+				if err != nil {
+					logger.Fatalf("activity %d: %v\n", id, err)
+				}
+				for _, zone := range zones {
+					totals[zone.Type] = addZoneTimes(totals[zone.Type], zone.DistributionBuckets)
+				}
+			}
+
+			fmt.Printf("Time in zones across %d activities\n", len(ids))
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "TYPE\tZONE\tRANGE\tTIME\tSHARE")
+			for _, zoneType := range []string{"heartrate", "power"} {
+				buckets := totals[zoneType]
+				total := 0
+				for _, b := range buckets {
+					total += b.Time
+				}
+				for i, b := range buckets {
+					share := 0.0
+					if total > 0 {
+						share = float64(b.Time) / float64(total) * 100
+					}
+					fmt.Fprintf(w, "%s\tZ%d\t%s\t%s\t%.1f%%\n", zoneType, i+1, zoneRange(b.Min, b.Max), formatDuration(b.Time), share)
+				}
+			}
+			w.Flush()
+		},
+	}
+
+	selection = addSelectionFlags(cmd)
+	cmd.Flags().BoolVar(&athlete, "athlete", false, "show your configured zone boundaries instead")
+
+	return cmd
+}
+
+// addZoneTimes adds the time of each bucket to the matching zone of totals
+func addZoneTimes(totals, buckets []strava.TimedZoneRange) []strava.TimedZoneRange {
+	for i, b := range buckets {
+		if i < len(totals) {
+			totals[i].Time += b.Time
+			continue
+		}
+		totals = append(totals, b)
+	}
+	return totals
+}
+
+func zoneRange(min, max int) string {
+	if max < 0 {
+		return fmt.Sprintf("%d+", min)
+	}
+	return fmt.Sprintf("%d-%d", min, max)
+}
+
+// parseDateRange reads optional YYYY-MM-DD bounds. The returned end is
+// exclusive, the start of the day after before.
+func parseDateRange(after, before string) (from, to time.Time, err error) {
+	if after != "" {
+		if from, err = time.Parse(time.DateOnly, after); err != nil {
+			return from, to, fmt.Errorf("--after must be YYYY-MM-DD: %w", err)
+		}
+	}
+	if before != "" {
+		if to, err = time.Parse(time.DateOnly, before); err != nil {
+			return from, to, fmt.Errorf("--before must be YYYY-MM-DD: %w", err)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// inDateRange reports whether a started within [from, to), where zero
+// bounds are open
+func inDateRange(a strava.Activity, from, to time.Time) bool {
+	start, err := time.Parse(time.RFC3339, a.StartDate)
+	if err != nil {
+		return false
+	}
+	return (from.IsZero() || !start.Before(from)) && (to.IsZero() || start.Before(to))
+}
+
+// printActivityReport prints the activity summary, laps, and efforts.
+// Grade adjusted pace is shown when streams carries distance and altitude.
+func printActivityReport(output outputSettings, activity strava.DetailedActivity, laps []strava.Lap, streams strava.Streams) {
+	fmt.Printf("%s (%s) %s\n", activity.Name, activity.Type, activity.StartDate)
+	fmt.Printf("Distance: %s\n", output.formatDistance(activity.Distance))
+	fmt.Printf("Moving Time: %s  Elapsed Time: %s  Stopped Time: %s\n", formatDuration(activity.MovingTime),
+		formatDuration(activity.ElapsedTime), formatDuration(stoppedTime(activity.Activity)))
+
+	adjustment, hasGAP := gradeAdjustment(streams, 0, -1)
+	if hasGAP {
+		distance, unit := output.convert(activity.Distance)
+		fmt.Printf("Pace: %s  Grade Adjusted Pace: %s\n", formatPace(activity.MovingTime, distance, unit),
+			formatPace(activity.MovingTime, distance*adjustment, unit))
+	}
+
+	if len(laps) > 0 {
+		fmt.Println("\nLaps")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		header := "LAP\tNAME\tDISTANCE\tTIME\tPACE"
+		if hasGAP {
+			header += "\tGAP"
+		}
+		fmt.Fprintln(w, header+"\tAVG HR\tMAX HR\tAVG WATTS")
+		for _, lap := range laps {
+			distance, unit := output.convert(lap.Distance)
+			fmt.Fprintf(w, "%d\t%s\t%.2f %s\t%s\t%s\t", lap.LapIndex, lap.Name, distance, unit,
+				formatDuration(lap.MovingTime), formatPace(lap.MovingTime, distance, unit))
+			if hasGAP {
+				gap := "-"
+				if adjustment, ok := gradeAdjustment(streams, lap.StartIndex, lap.EndIndex); ok {
+					gap = formatPace(lap.MovingTime, distance*adjustment, unit)
+				}
+				fmt.Fprintf(w, "%s\t", gap)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", optional(lap.AverageHeartrate), optional(lap.MaxHeartrate), optional(lap.AverageWatts))
+		}
+		w.Flush()
+	}
+
+	if splits := detailSplits(output, activity); len(splits) > 0 {
+		fmt.Println("\nSplits")
+		writeSplits(os.Stdout, output, splits)
+		if halves := describeSplitHalves(output, splits); halves != "" {
+			fmt.Println(halves)
+		}
+	}
+
+	if len(activity.SegmentEfforts) > 0 {
+		fmt.Println("\nSegment efforts")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SEGMENT\tNAME\tTIME\tPR")
+		for _, e := range activity.SegmentEfforts {
+			pr := ""
+			if e.IsPR() {
+				pr = "PR"
+			} else if e.PRRank != nil {
+				pr = fmt.Sprintf("#%d", *e.PRRank)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.Segment.Id, e.Name, formatDuration(e.ElapsedTime), pr)
+		}
+		w.Flush()
+	}
+}
+
+// formatPace renders time per unit of distance as m:ss/unit
+func formatPace(seconds int, distance float64, unit string) string {
+	if distance <= 0 {
+		return "-"
+	}
+	return formatDuration(int(float64(seconds)/distance+0.5)) + "/" + unit
+}
+
+// optional renders a metric, or "-" when the device did not record it
+func optional(v float64) string {
+	if v == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+func newSocialReportCmd() *cobra.Command {
+	var top int
+	var people bool
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "social",
+		Short: "Show which activities got the most kudos and comments over a period",
+		Long: `Ranks the cached activities selected by --sport, --gear, --after, --before,
+--match, and --filter by kudos and comments. Counts are as of the last sync. With --people, the kudoers and
+commenters of those activities are fetched and the most frequent are listed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			activities, err := cache.activities()
+			cache.Close()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			selected, err := filterActivities(expr, activities)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			kudos, comments := 0, 0
+			for _, a := range selected {
+				kudos += a.KudosCount
+				comments += a.CommentCount
+			}
+			fmt.Printf("%d activities, %d kudos, %d comments\n", len(selected), kudos, comments)
+
+			sort.SliceStable(selected, func(i, j int) bool {
+				return selected[i].KudosCount+selected[i].CommentCount > selected[j].KudosCount+selected[j].CommentCount
+			})
+			if len(selected) > top {
+				selected = selected[:top]
+			}
+
+			fmt.Println("\nMost popular activities")
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tDATE\tNAME\tKUDOS\tCOMMENTS")
+			for _, a := range selected {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\n", a.Id, a.StartDate, a.Name, a.KudosCount, a.CommentCount)
+			}
+			w.Flush()
+
+			if !people {
+				return
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			counts := make(map[string]int)
+			for _, a := range selected {
+				if a.KudosCount > 0 {
+					athletes, err := listAllKudoers(ctx, client, int64(a.Id))
+					if err != nil {
+						logger.Fatalf("activity %d: %v\n", a.Id, err)
+					}
+					for _, athlete := range athletes {
+						counts[athleteName(athlete)]++
+					}
+				}
+				if a.CommentCount > 0 {
+					comments, err := listAllComments(ctx, client, int64(a.Id))
+					if err != nil {
+						logger.Fatalf("activity %d: %v\n", a.Id, err)
+					}
+					for _, c := range comments {
+						counts[athleteName(c.Athlete)]++
+					}
+				}
+			}
+
+			names := make([]string, 0, len(counts))
+			for name := range counts {
+				names = append(names, name)
+			}
+			sort.Slice(names, func(i, j int) bool {
+				if counts[names[i]] != counts[names[j]] {
+					return counts[names[i]] > counts[names[j]]
+				}
+				return names[i] < names[j]
+			})
+			if len(names) > top {
+				names = names[:top]
+			}
+
+			fmt.Println("\nBiggest fans")
+			w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ATHLETE\tKUDOS AND COMMENTS")
+			for _, name := range names {
+				fmt.Fprintf(w, "%s\t%d\n", name, counts[name])
+			}
+			w.Flush()
+		},
+	}
+
+	selection = addSelectionFlags(cmd)
+	cmd.Flags().IntVar(&top, "top", 10, "number of activities and athletes to list")
+	cmd.Flags().BoolVar(&people, "people", false, "also rank the athletes who gave the top activities kudos and comments")
+
+	return cmd
+}
+
+// listAllKudoers pages through every kudoer of an activity
+func listAllKudoers(ctx context.Context, client strava.ClientInterface, id int64) ([]strava.SummaryAthlete, error) {
+	all := make([]strava.SummaryAthlete, 0)
+	for page := 1; ; page++ {
+		athletes, err := client.ListActivityKudoers(ctx, id, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+		if err != nil {
+			return all, err
+		}
+		all = append(all, athletes...)
+		if len(athletes) < strava.MaxPerPage {
+			return all, nil
+		}
+	}
+}
+
+// listAllComments pages through every comment on an activity
+func listAllComments(ctx context.Context, client strava.ClientInterface, id int64) ([]strava.Comment, error) {
+	all := make([]strava.Comment, 0)
+	for page := 1; ; page++ {
+		comments, err := client.ListActivityComments(ctx, id, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+		if err != nil {
+			return all, err
+		}
+		all = append(all, comments...)
+		if len(comments) < strava.MaxPerPage {
+			return all, nil
+		}
+	}
+}
+
+func athleteName(a strava.SummaryAthlete) string {
+	return strings.TrimSpace(a.Firstname + " " + a.Lastname)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+func newRoutesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "routes",
+		Short: "List and back up your saved routes",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List your saved routes",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tTYPE\tDISTANCE\tELEVATION GAIN")
+			for _, r := range listAllRoutes(ctx, logger, client) {
+				distance, unit := config.Settings.Output.convert(r.Distance)
+				fmt.Fprintf(w, "%d\t%s\t%s\t%.2f %s\t%.0f m\n", r.Id, r.Name, routeType(r.Type), distance, unit, r.ElevationGain)
+			}
+			w.Flush()
+		},
+	})
+
+	var format, dir string
+	export := &cobra.Command{
+		Use:   "export [route id...]",
+		Short: "Save routes as GPX or TCX files",
+		Long:  "Downloads the given routes, or every saved route when no ids are given, into --dir.",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+
+			formats := []string{format}
+			switch format {
+			case "gpx", "tcx":
+			case "both":
+				formats = []string{"gpx", "tcx"}
+			default:
+				logger.Fatalf("--format must be gpx, tcx, or both, got %q\n", format)
+			}
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			routes := make([]strava.Route, 0)
+			if len(args) == 0 {
+				routes = listAllRoutes(ctx, logger, client)
+			}
+			for _, arg := range args {
+				id, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil {
+					logger.Fatalf("invalid route id %q\n", arg)
+				}
+				route, err := client.GetRoute(ctx, id)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				routes = append(routes, route)
+			}
+
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				logger.Fatal(err)
+			}
+			for _, r := range routes {
+				for _, f := range formats {
+					file, err := client.ExportRoute(ctx, r.Id, f)
+					if err != nil {
+						logger.Fatalf("route %d: %v\n", r.Id, err)
+					}
+					path := filepath.Join(dir, routeFileName(r, f))
+					if err := os.WriteFile(path, file, 0o644); err != nil {
+						logger.Fatal(err)
+					}
+					logger.Printf("Saved %s\n", path)
+				}
+			}
+		},
+	}
+	export.Flags().StringVar(&format, "format", "gpx", "gpx, tcx, or both")
+	export.Flags().StringVar(&dir, "dir", "routes", "directory to write the files to")
+	cmd.AddCommand(export)
+
+	return cmd
+}
+
+// listAllRoutes pages through every route of the authenticated athlete
+func listAllRoutes(ctx context.Context, logger *log.Logger, client strava.ClientInterface) []strava.Route {
+	athlete, err := client.GetAthlete(ctx)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	all := make([]strava.Route, 0)
+	for page := 1; ; page++ {
+		routes, err := client.ListAthleteRoutes(ctx, athlete.Id, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		all = append(all, routes...)
+		if len(routes) < strava.MaxPerPage {
+			return all
+		}
+	}
+}
+
+func routeType(t int) string {
+	switch t {
+	case strava.RouteTypeRide:
+		return "Ride"
+	case strava.RouteTypeRun:
+		return "Run"
+	}
+	return "-"
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// routeFileName names an export after the route id and a slug of its name
+func routeFileName(r strava.Route, format string) string {
+	slug := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(r.Name), "-"), "-")
+	if slug == "" {
+		return fmt.Sprintf("%d.%s", r.Id, format)
+	}
+	return fmt.Sprintf("%d-%s.%s", r.Id, slug, format)
+}
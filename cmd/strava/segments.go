@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+func newSegmentsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "segments",
+		Short: "Look up, list, and explore Strava segments",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "starred",
+		Short: "List starred segments with your current PRs and save them to the local cache",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			segments := make([]strava.Segment, 0)
+			for page := 1; ; page++ {
+				batch, err := client.ListStarredSegments(ctx, strava.PageOptions{Page: page, PerPage: strava.MaxPerPage})
+				if err != nil {
+					logger.Fatal(err)
+				}
+				segments = append(segments, batch...)
+				if len(batch) < strava.MaxPerPage {
+					break
+				}
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			if err := cache.upsertSegments(segments); err != nil {
+				logger.Fatal(err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tTYPE\tDISTANCE\tGRADE\tPR\tPR DATE\tEFFORTS")
+			for _, s := range segments {
+				pr, prDate, efforts := "-", "-", 0
+				if s.AthletePREffort != nil {
+					pr = formatDuration(s.AthletePREffort.PRElapsedTime)
+					prDate = s.AthletePREffort.PRDate
+					efforts = s.AthletePREffort.EffortCount
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.1f%%\t%s\t%s\t%d\n", s.Id, s.Name, s.ActivityType,
+					config.Settings.Output.formatDistance(s.Distance), s.AverageGrade, pr, prDate, efforts)
+			}
+			w.Flush()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <id>",
+		Short: "Print a segment as JSON",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				logger.Fatalf("invalid segment id %q\n", args[0])
+			}
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			segment, err := client.GetSegment(ctx, id)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			printJSON(logger, segment)
+		},
+	})
+
+	var bounds, activityType string
+	explore := &cobra.Command{
+		Use:   "explore",
+		Short: "Find popular segments within a bounding box",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			b, err := parseBounds(bounds)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			segments, err := client.ExploreSegments(ctx, b, strava.ExploreOptions{ActivityType: activityType})
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tDISTANCE\tGRADE\tCLIMB")
+			for _, s := range segments {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%.1f%%\t%s\n", s.Id, s.Name,
+					config.Settings.Output.formatDistance(s.Distance), s.AverageGrade, s.ClimbCategoryDesc)
+			}
+			w.Flush()
+		},
+	}
+	explore.Flags().StringVar(&bounds, "bounds", "", "south west and north east corners as sw_lat,sw_lng,ne_lat,ne_lng")
+	explore.Flags().StringVar(&activityType, "type", "", "running or riding (default both)")
+	explore.RegisterFlagCompletionFunc("type", cobra.FixedCompletions([]string{"running", "riding"}, cobra.ShellCompDirectiveNoFileComp))
+	explore.MarkFlagRequired("bounds")
+	cmd.AddCommand(explore)
+
+	return cmd
+}
+
+// parseBounds reads "sw_lat,sw_lng,ne_lat,ne_lng"
+func parseBounds(s string) (strava.Bounds, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return strava.Bounds{}, fmt.Errorf("bounds %q must be sw_lat,sw_lng,ne_lat,ne_lng", s)
+	}
+
+	coords := make([]float64, 4)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return strava.Bounds{}, fmt.Errorf("bounds %q: %v", s, err)
+		}
+		coords[i] = v
+	}
+	return strava.Bounds{SouthWest: strava.LatLng{coords[0], coords[1]}, NorthEast: strava.LatLng{coords[2], coords[3]}}, nil
+}
+
+// formatDuration renders seconds as h:mm:ss, or m:ss under an hour
+func formatDuration(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(logger *log.Logger, v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logger.Fatal(err)
+	}
+}
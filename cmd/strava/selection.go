@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// noGear is the --gear value matching activities recorded without gear
+const noGear = "none"
+
+// activitySelection holds the flags every command reporting on or
+// exporting cached activities selects them with, so they all agree on
+// what --sport or --after means
+type activitySelection struct {
+	sports     []string
+	gear       []string
+	workouts   []string
+	indoor     bool
+	outdoor    bool
+	after      string
+	before     string
+	match      string
+	expression string
+}
+
+// addSelectionFlags registers --sport, --gear, --workout, --indoor,
+// --outdoor, --after, --before, --match, and --filter on cmd
+func addSelectionFlags(cmd *cobra.Command) *activitySelection {
+	s := &activitySelection{}
+	flags := cmd.Flags()
+	flags.StringSliceVar(&s.sports, "sport", nil, "only include these sport types, e.g. Run,VirtualRide")
+	flags.StringSliceVar(&s.gear, "gear", nil, "only include activities with this gear id, e.g. b1234567, or none")
+	flags.StringSliceVar(&s.workouts, "workout", nil, "only include these workout types: race, long_run, workout, or default")
+	flags.BoolVar(&s.indoor, "indoor", false, "only include trainer and virtual activities")
+	flags.BoolVar(&s.outdoor, "outdoor", false, "leave out trainer and virtual activities")
+	flags.StringVar(&s.after, "after", "", "first day to include, YYYY-MM-DD")
+	flags.StringVar(&s.before, "before", "", "last day to include, YYYY-MM-DD")
+	flags.StringVar(&s.match, "match", "", "only include activities whose name matches this regular expression")
+	flags.StringVar(&s.expression, "filter", "", "only include activities matching this expression")
+	cmd.RegisterFlagCompletionFunc("sport", completeSports)
+	return s
+}
+
+// aliasSports keeps an older name of --sport working on cmd
+func (s *activitySelection) aliasSports(cmd *cobra.Command, name string) {
+	cmd.Flags().StringSliceVar(&s.sports, name, nil, "same as --sport")
+	cmd.Flags().MarkDeprecated(name, "use --sport instead")
+	cmd.RegisterFlagCompletionFunc(name, completeSports)
+}
+
+// filter compiles the flags into one filter, nil when none are set
+func (s *activitySelection) filter() (*activityFilter, error) {
+	f, err := parseFilter(s.expression)
+	if err != nil {
+		return nil, err
+	}
+	require := func(check func(strava.Activity) bool) {
+		if f == nil {
+			f = &activityFilter{seen: make(map[string]bool)}
+		}
+		f.require = append(f.require, check)
+	}
+
+	if len(s.sports) > 0 {
+		sports := s.sports
+		require(func(a strava.Activity) bool {
+			return containsFold(sports, a.SportType) || containsFold(sports, a.Type)
+		})
+	}
+	if len(s.gear) > 0 {
+		gear := s.gear
+		require(func(a strava.Activity) bool {
+			if a.GearId == "" {
+				return containsFold(gear, noGear)
+			}
+			return containsFold(gear, a.GearId)
+		})
+	}
+	if len(s.workouts) > 0 {
+		for _, w := range s.workouts {
+			if !validWorkout(w) {
+				return nil, fmt.Errorf("--workout: unknown workout type %q, expected race, long_run, workout, or default", w)
+			}
+		}
+		workouts := s.workouts
+		require(func(a strava.Activity) bool { return containsFold(workouts, a.Workout()) })
+	}
+	if s.indoor && s.outdoor {
+		return nil, errors.New("--indoor and --outdoor cannot be used together")
+	}
+	if s.indoor || s.outdoor {
+		indoor := s.indoor
+		require(func(a strava.Activity) bool { return a.Indoor() == indoor })
+	}
+	if s.after != "" || s.before != "" {
+		from, to, err := parseDateRange(s.after, s.before)
+		if err != nil {
+			return nil, err
+		}
+		require(func(a strava.Activity) bool { return inDateRange(a, from, to) })
+	}
+	if s.match != "" {
+		pattern, err := regexp.Compile("(?i)" + s.match)
+		if err != nil {
+			return nil, fmt.Errorf("--match: %w", err)
+		}
+		require(func(a strava.Activity) bool { return pattern.MatchString(a.Name) })
+	}
+	return f, nil
+}
+
+func validWorkout(workout string) bool {
+	switch workout {
+	case strava.WorkoutDefault, strava.WorkoutRace, strava.WorkoutLongRun, strava.WorkoutWorkout:
+		return true
+	}
+	return false
+}
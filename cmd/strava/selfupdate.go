@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/mod/semver"
+)
+
+// version is the release this binary was built from, set with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// releasePublicKey is the base64 Ed25519 key release checksums are signed
+// with, set at build time like version. update.public_key overrides it.
+var releasePublicKey = ""
+
+// Each release carries a binary per platform, a sha256sum checksums file
+// listing them, and a detached Ed25519 signature of that file
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+)
+
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+// githubAsset is a release file. URL is its API endpoint, which unlike the
+// browser download URL accepts a token, so assets of private repositories
+// can be downloaded.
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// releaseAssetName is the name of the binary for this platform, e.g.
+// strava-api_linux_arm64
+func releaseAssetName() string {
+	name := fmt.Sprintf("strava-api_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// asset returns the API URL of the named asset
+func (r githubRelease) asset(name string) (string, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, nil
+		}
+	}
+	return "", fmt.Errorf("release %s has no %s", r.TagName, name)
+}
+
+// fetchRelease returns the latest release of repo, or the one tagged tag
+func fetchRelease(ctx context.Context, httpClient *http.Client, apiURL, repo, tag string) (githubRelease, error) {
+	u := strings.TrimSuffix(apiURL, "/") + "/repos/" + repo + "/releases/latest"
+	if tag != "" {
+		u = strings.TrimSuffix(apiURL, "/") + "/repos/" + repo + "/releases/tags/" + tag
+	}
+	body, err := fetchReleaseFile(ctx, httpClient, u, "application/vnd.github+json")
+	if err != nil {
+		return githubRelease{}, err
+	}
+	var release githubRelease
+	if err := json.Unmarshal(body, &release); err != nil {
+		return githubRelease{}, fmt.Errorf("%s: %w", u, err)
+	}
+	return release, nil
+}
+
+// fetchReleaseFile downloads u from the GitHub API as accept, sending
+// $GITHUB_TOKEN when set so private repositories and the higher API rate
+// limit work. Assets are fetched as application/octet-stream, which GitHub
+// answers with a redirect to the file; the client does not forward the token
+// to the other host.
+func fetchReleaseFile(ctx context.Context, httpClient *http.Client, u, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", u, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// verifyRelease checks the signature of the checksums file with publicKey
+// and that it lists binary under name
+func verifyRelease(publicKey string, checksums, signature []byte, name string, binary []byte) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("update.public_key must be a base64 Ed25519 public key")
+	}
+	// Accept the raw signature or its base64 encoding
+	if len(signature) != ed25519.SignatureSize {
+		if signature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err != nil {
+			return fmt.Errorf("%s: %w", signatureAsset, err)
+		}
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, signature) {
+		return fmt.Errorf("%s is not signed by update.public_key", checksumsAsset)
+	}
+
+	sum := sha256.Sum256(binary)
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		if fields[0] != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("%s does not match its checksum", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+}
+
+// replaceExecutable swaps the running binary for binary, keeping its mode.
+// The new file is written next to the old one and renamed over it, so an
+// interrupted update leaves the old binary in place.
+func replaceExecutable(binary []byte) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(exe), ".strava-api-update-*")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if _, err := f.Write(binary); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	// Windows cannot replace a running binary, but can rename it
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		os.Rename(old, exe)
+		return "", err
+	}
+	os.Remove(old)
+	return exe, nil
+}
+
+func newSelfUpdateCmd() *cobra.Command {
+	var check bool
+	var tag string
+
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Replace this binary with the latest signed GitHub release",
+		Long: `Checks the GitHub releases of update.repo for a newer version than this
+one and installs it in place of the running binary. The release's
+checksums.txt must carry a valid Ed25519 signature, checksums.txt.sig, by
+update.public_key, and list the downloaded binary with its SHA-256, so an
+update never installs a file that was not signed for the release.
+
+Run it from cron or a systemd timer to keep unattended installs current.
+--check only reports whether an update is available, exiting 0 either
+way, and --tag installs a given release, also to go back to an older one.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+
+			s, err := loadSettings(viper.GetString("config"))
+			if err != nil {
+				logger.Fatal(err)
+			}
+			transport, err := newTransport(s.HTTP)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			httpClient := &http.Client{Transport: transport, Timeout: 5 * time.Minute}
+
+			release, err := fetchRelease(ctx, httpClient, s.Update.APIURL, s.Update.Repo, tag)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if tag == "" && semver.Compare(release.TagName, version) <= 0 {
+				logger.Printf("strava-api %s is up to date\n", version)
+				return
+			}
+			if check {
+				logger.Printf("strava-api %s is available, this is %s\n", release.TagName, version)
+				return
+			}
+
+			publicKey := s.Update.PublicKey
+			if publicKey == "" {
+				publicKey = releasePublicKey
+			}
+			if publicKey == "" {
+				logger.Fatal("self-update needs update.public_key to verify releases")
+			}
+
+			name := releaseAssetName()
+			files := make(map[string][]byte)
+			for _, asset := range []string{name, checksumsAsset, signatureAsset} {
+				u, err := release.asset(asset)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				if files[asset], err = fetchReleaseFile(ctx, httpClient, u, "application/octet-stream"); err != nil {
+					logger.Fatal(err)
+				}
+			}
+			if err := verifyRelease(publicKey, files[checksumsAsset], files[signatureAsset], name, files[name]); err != nil {
+				logger.Fatal(err)
+			}
+
+			exe, err := replaceExecutable(files[name])
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Updated %s from %s to %s\n", exe, version, release.TagName)
+		},
+	}
+
+	cmd.Flags().BoolVar(&check, "check", false, "only report whether a newer release is available")
+	cmd.Flags().StringVar(&tag, "tag", "", "install this release instead of the latest, e.g. v1.4.0")
+
+	return cmd
+}
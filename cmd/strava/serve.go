@@ -0,0 +1,15 @@
+package main
+
+import "github.com/spf13/cobra"
+
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a long-lived server for other programs to query",
+	}
+
+	cmd.AddCommand(newServeGRPCCmd())
+	cmd.AddCommand(newServeWebhookCmd())
+
+	return cmd
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/viper"
+)
+
+// settingsFiles are searched in order when --config is not given
+var settingsFiles = []string{"strava.yaml", "strava.yml", "strava.toml"}
+
+// settings is the structured configuration read from strava.yaml or
+// strava.toml. Secrets stay in the env file selected by the active profile.
+// Any value can be overridden with an environment variable named after its
+// path, for example STRAVA_OUTPUT_UNITS for output.units.
+type settings struct {
+	Profile       string                     `mapstructure:"profile"`
+	Profiles      map[string]profileSettings `mapstructure:"profiles"`
+	Rules         []matchRule                `mapstructure:"rules"`
+	Notifications []sinkSettings             `mapstructure:"notifications"`
+	Output        outputSettings             `mapstructure:"output"`
+	Fetch         fetchSettings              `mapstructure:"fetch"`
+	HTTP          httpSettings               `mapstructure:"http"`
+	Training      trainingSettings           `mapstructure:"training"`
+	// Goals are weekly or monthly targets reported with the summary
+	Goals []goalSettings `mapstructure:"goals"`
+	// Tags mark new activities as commutes or trainer rides during sync
+	Tags []tagRule `mapstructure:"autotag"`
+	// Titles rewrites default names of new activities during sync
+	Titles titleSettings `mapstructure:"titles"`
+	// Weather stores the conditions of new activities during sync
+	Weather weatherSettings `mapstructure:"weather"`
+	// Metrics are plugins computing extra values for the summary
+	Metrics []metricSettings `mapstructure:"metrics"`
+	// Desk names the activities logged by log desk
+	Desk deskSettings `mapstructure:"desk"`
+	// Streams sets the resolution of the streams stored in the cache
+	Streams streamSettings `mapstructure:"streams"`
+	// Update is where self-update looks for releases
+	Update updateSettings `mapstructure:"update"`
+}
+
+// profileSettings lets several athletes or setups share one settings file
+type profileSettings struct {
+	EnvFile    string `mapstructure:"env_file"`
+	CachePath  string `mapstructure:"cache_path"`
+	TokenStore string `mapstructure:"token_store"`
+}
+
+// matchRule selects the activities counted in the summary. An activity is
+// counted when it matches any rule; every field set on a rule must match.
+type matchRule struct {
+	Label string   `mapstructure:"label"`
+	Name  string   `mapstructure:"name"`
+	Types []string `mapstructure:"types"`
+	// After is a YYYY-MM-DD date; YAML and TOML may already decode it as a time
+	After interface{} `mapstructure:"after"`
+
+	namePattern *regexp.Regexp
+	after       time.Time
+}
+
+// sinkSettings configures a notification target for the run summary
+type sinkSettings struct {
+	Type string `mapstructure:"type"`
+	URL  string `mapstructure:"url"`
+
+	// Topic is the MQTT base topic, strava by default
+	Topic string `mapstructure:"topic"`
+	// DiscoveryPrefix is where Home Assistant looks for MQTT discovery
+	// configs, homeassistant by default
+	DiscoveryPrefix string `mapstructure:"discovery_prefix"`
+
+	// Command runs a plugin sink, the program followed by its arguments
+	Command []string `mapstructure:"command"`
+}
+
+type outputSettings struct {
+	// Units is miles (default) or km
+	Units string `mapstructure:"units"`
+	// Language is the language of report labels, numbers, and dates: en
+	// (default), de, fr, or es
+	Language     string `mapstructure:"language"`
+	GithubOutput bool   `mapstructure:"github_output"`
+}
+
+type fetchSettings struct {
+	// Prefetch is the number of activity pages requested concurrently
+	Prefetch int `mapstructure:"prefetch"`
+	// CacheDir enables the on-disk HTTP response cache
+	CacheDir string `mapstructure:"cache_dir"`
+	// CacheTTL is how long a cached response is served without revalidating
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// TrackPRs checks new activities for segment PRs, one request each
+	TrackPRs bool `mapstructure:"track_prs"`
+	// Force starts a sync whose plan exceeds the daily rate limit. It is
+	// set by --force rather than the settings file.
+	Force bool `mapstructure:"-"`
+	// Incremental starts from the newest cached activity instead of
+	// fetching everything again. It is set by sync --once.
+	Incremental bool `mapstructure:"-"`
+}
+
+// trainingSettings are the physiological inputs to the training load model
+type trainingSettings struct {
+	// FTP is functional threshold power in watts, for power based TSS
+	FTP       int `mapstructure:"ftp"`
+	MaxHR     int `mapstructure:"max_hr"`
+	RestingHR int `mapstructure:"resting_hr"`
+	// Sex selects the TRIMP weighting, male (default) or female
+	Sex string `mapstructure:"sex"`
+}
+
+// httpSettings configures the transport used for API calls
+type httpSettings struct {
+	// Proxy overrides HTTPS_PROXY / HTTP_PROXY from the environment
+	Proxy string `mapstructure:"proxy"`
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile        string `mapstructure:"ca_file"`
+	TLSMinVersion string `mapstructure:"tls_min_version"`
+	// Timeouts per operation class; unset classes use the client defaults
+	// and negative ones have no timeout
+	Timeouts timeoutSettings `mapstructure:"timeouts"`
+	// BreakerThreshold consecutive failures open the circuit breaker, 0 disables it
+	BreakerThreshold int           `mapstructure:"breaker_threshold"`
+	BreakerCooldown  time.Duration `mapstructure:"breaker_cooldown"`
+	// Retries of a request failing with a 5xx, 0 disables them
+	Retries      int           `mapstructure:"retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	// BulkReserve is the share of each rate limit window backfills leave
+	// to interactive commands and syncs
+	BulkReserve float64 `mapstructure:"bulk_reserve"`
+}
+
+// streamSettings trade detail for size in the streams that are stored.
+// Resolution and SeriesType are passed to Strava, which samples the streams
+// down on its side; Downsample then keeps one sample per that much time.
+type streamSettings struct {
+	// Resolution is low, medium, or high; unset keeps every sample
+	Resolution string `mapstructure:"resolution"`
+	// SeriesType is time or distance, what a resolution samples along
+	SeriesType string        `mapstructure:"series_type"`
+	Downsample time.Duration `mapstructure:"downsample"`
+}
+
+// options are the stream request options to send to Strava
+func (s streamSettings) options() strava.StreamOptions {
+	return strava.StreamOptions{Resolution: s.Resolution, SeriesType: s.SeriesType}
+}
+
+// reduce downsamples fetched streams
+func (s streamSettings) reduce(streams strava.Streams) strava.Streams {
+	return streams.Downsample(int(s.Downsample / time.Second))
+}
+
+// updateSettings name the GitHub repository self-update installs releases
+// from and the key their checksums are signed with
+type updateSettings struct {
+	// Repo is owner/name on GitHub
+	Repo string `mapstructure:"repo"`
+	// APIURL is the GitHub API, to use GitHub Enterprise or a mirror
+	APIURL string `mapstructure:"api_url"`
+	// PublicKey is the base64 Ed25519 public key of the release signer
+	PublicKey string `mapstructure:"public_key"`
+}
+
+type timeoutSettings struct {
+	List    time.Duration `mapstructure:"list"`
+	Detail  time.Duration `mapstructure:"detail"`
+	Streams time.Duration `mapstructure:"streams"`
+	Upload  time.Duration `mapstructure:"upload"`
+}
+
+// defaultRules reproduces the original behaviour of counting desk treadmill
+// sessions by name
+var defaultRules = []matchRule{{Label: "Desk Treadmill", Name: "^desk treadmill$"}}
+
+// loadSettings reads the settings file named by path, or the first of
+// settingsFiles that exists. A missing file yields the defaults.
+func loadSettings(path string) (settings, error) {
+	sv := viper.New()
+	sv.SetEnvPrefix("STRAVA")
+	sv.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	sv.AutomaticEnv()
+	sv.SetDefault("profile", "default")
+	sv.SetDefault("output.units", "miles")
+	sv.SetDefault("output.language", "en")
+	sv.SetDefault("output.github_output", false)
+	sv.SetDefault("fetch.prefetch", 1)
+	sv.SetDefault("http.breaker_threshold", strava.DefaultBreakerThreshold)
+	sv.SetDefault("http.breaker_cooldown", strava.DefaultBreakerCooldown)
+	sv.SetDefault("http.retries", strava.DefaultMaxRetries)
+	sv.SetDefault("http.retry_backoff", strava.DefaultRetryBackoff)
+	sv.SetDefault("http.bulk_reserve", strava.DefaultBulkReserve)
+	sv.SetDefault("update.repo", "brandtkeller/strava-api")
+	sv.SetDefault("update.api_url", "https://api.github.com")
+	sv.SetDefault("desk.name", "Desk Treadmill")
+	sv.SetDefault("desk.type", "Walk")
+
+	if path == "" {
+		for _, candidate := range settingsFiles {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	if path != "" {
+		sv.SetConfigFile(path)
+		if err := sv.ReadInConfig(); err != nil {
+			return settings{}, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	var s settings
+	if err := sv.Unmarshal(&s); err != nil {
+		return settings{}, err
+	}
+
+	if len(s.Rules) == 0 {
+		s.Rules = defaultRules
+	}
+	for i := range s.Rules {
+		if err := s.Rules[i].compile(); err != nil {
+			return settings{}, err
+		}
+	}
+
+	switch s.Output.Units {
+	case "miles", "km":
+	default:
+		return settings{}, fmt.Errorf("output.units must be miles or km, got %q", s.Output.Units)
+	}
+	if _, err := parseLanguage(s.Output.Language); err != nil {
+		return settings{}, err
+	}
+
+	if owner, name, ok := strings.Cut(s.Update.Repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return settings{}, fmt.Errorf("update.repo must be owner/name, got %q", s.Update.Repo)
+	}
+
+	if s.HTTP.BulkReserve < 0 || s.HTTP.BulkReserve >= 1 {
+		return settings{}, fmt.Errorf("http.bulk_reserve must be at least 0 and below 1, got %g", s.HTTP.BulkReserve)
+	}
+
+	switch s.Streams.Resolution {
+	case "", strava.ResolutionLow, strava.ResolutionMedium, strava.ResolutionHigh:
+	default:
+		return settings{}, fmt.Errorf("streams.resolution must be low, medium, or high, got %q", s.Streams.Resolution)
+	}
+	switch s.Streams.SeriesType {
+	case "", strava.SeriesTypeTime, strava.SeriesTypeDistance:
+	default:
+		return settings{}, fmt.Errorf("streams.series_type must be time or distance, got %q", s.Streams.SeriesType)
+	}
+	if s.Streams.Downsample < 0 || (s.Streams.Downsample > 0 && s.Streams.Downsample%time.Second != 0) {
+		return settings{}, fmt.Errorf("streams.downsample must be a whole number of seconds, got %s", s.Streams.Downsample)
+	}
+
+	switch s.Training.Sex {
+	case "", "male", "female":
+	default:
+		return settings{}, fmt.Errorf("training.sex must be male or female, got %q", s.Training.Sex)
+	}
+	if s.Training.MaxHR > 0 && s.Training.RestingHR >= s.Training.MaxHR {
+		return settings{}, errors.New("training.resting_hr must be below training.max_hr")
+	}
+
+	for i := range s.Tags {
+		if err := s.Tags[i].compile(); err != nil {
+			return settings{}, err
+		}
+	}
+
+	if err := s.Titles.compile(); err != nil {
+		return settings{}, err
+	}
+
+	for _, g := range s.Goals {
+		if err := g.validate(); err != nil {
+			return settings{}, err
+		}
+	}
+
+	seen := map[string]bool{"matched": true, "streak": true}
+	for _, m := range s.Metrics {
+		if m.Name == "" || len(m.Command) == 0 {
+			return settings{}, errors.New("metrics plugins need a name and a command")
+		}
+		if seen[m.Name] {
+			return settings{}, fmt.Errorf("metrics plugin name %q is already taken", m.Name)
+		}
+		seen[m.Name] = true
+	}
+
+	if s.Fetch.Prefetch < 1 || s.Fetch.Prefetch > 10 {
+		return settings{}, fmt.Errorf("fetch.prefetch must be between 1 and 10, got %d", s.Fetch.Prefetch)
+	}
+
+	if _, ok := s.Profiles[s.Profile]; !ok && s.Profile != "default" {
+		return settings{}, fmt.Errorf("profile %q is not defined", s.Profile)
+	}
+
+	return s, nil
+}
+
+// activeProfile returns the selected profile, which may be empty
+func (s settings) activeProfile() profileSettings {
+	return s.Profiles[s.Profile]
+}
+
+func (r *matchRule) compile() error {
+	if r.Name != "" {
+		pattern, err := regexp.Compile("(?i)" + r.Name)
+		if err != nil {
+			return fmt.Errorf("rule %q: invalid name pattern: %w", r.Label, err)
+		}
+		r.namePattern = pattern
+	}
+	switch after := r.After.(type) {
+	case nil:
+	case time.Time:
+		r.after = after
+	case string:
+		parsed, err := time.Parse(time.DateOnly, after)
+		if err != nil {
+			return fmt.Errorf("rule %q: after must be YYYY-MM-DD: %w", r.Label, err)
+		}
+		r.after = parsed
+	default:
+		return fmt.Errorf("rule %q: after must be YYYY-MM-DD, got %v", r.Label, after)
+	}
+	if r.namePattern == nil && len(r.Types) == 0 && r.after.IsZero() {
+		return errors.New("rules must set at least one of name, types, or after")
+	}
+	return nil
+}
+
+// matches reports whether a started at start satisfies the rule
+func (r matchRule) matches(a strava.Activity, start time.Time) bool {
+	if r.namePattern != nil && !r.namePattern.MatchString(strings.TrimSpace(a.Name)) {
+		return false
+	}
+	if len(r.Types) > 0 {
+		found := false
+		for _, t := range r.Types {
+			if strings.EqualFold(t, a.Type) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !r.after.IsZero() && start.Before(r.after) {
+		return false
+	}
+	return true
+}
+
+// formatDistance renders meters in the configured units
+func (o outputSettings) formatDistance(meters float64) string {
+	if o.Units == "km" {
+		return fmt.Sprintf("%f Kilometers", meters/1000)
+	}
+	return fmt.Sprintf("%f Miles", meters*0.000621371)
+}
+
+// convert returns meters in the configured units along with the short unit
+// name, for tables
+func (o outputSettings) convert(meters float64) (float64, string) {
+	if o.Units == "km" {
+		return meters / 1000, "km"
+	}
+	return meters * 0.000621371, "mi"
+}
+
+// toMeters converts a distance in the configured units to meters
+func (o outputSettings) toMeters(distance float64) float64 {
+	if o.Units == "km" {
+		return distance * 1000
+	}
+	return distance / 0.000621371
+}
+
+// convertElevation returns meters of climbing in feet when the configured
+// units are miles, along with the short unit name
+func (o outputSettings) convertElevation(meters float64) (float64, string) {
+	if o.Units == "km" {
+		return meters, "m"
+	}
+	return meters * 3.28084, "ft"
+}
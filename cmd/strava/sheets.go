@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	sheetsApiUrl = "https://sheets.googleapis.com/v4/spreadsheets"
+	sheetsScope  = "https://www.googleapis.com/auth/spreadsheets"
+)
+
+// defaultSheetsColumns is used when SHEETS_COLUMNS is not configured
+var defaultSheetsColumns = []string{"id", "start_date", "name", "type", "distance_miles", "moving_time", "elapsed_time"}
+
+// serviceAccountKey is the subset of a Google service account JSON key needed
+// to request an access token
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenUri    string `json:"token_uri"`
+}
+
+type sheetsClient struct {
+	client        *http.Client
+	spreadsheetId string
+	accessToken   string
+}
+
+type valueRange struct {
+	Range  string          `json:"range,omitempty"`
+	Values [][]interface{} `json:"values"`
+}
+
+func newSheetsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sheets",
+		Short: "Upsert activities (and an optional summary tab) into a Google Sheet",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			if config.SheetsSpreadsheetId == "" || config.SheetsCredentialsFile == "" {
+				logger.Fatal("SHEETS_SPREADSHEET_ID and SHEETS_CREDENTIALS_FILE must be set")
+			}
+			tab := config.SheetsTab
+			if tab == "" {
+				tab = "Activities"
+			}
+			columns := defaultSheetsColumns
+			if config.SheetsColumns != "" {
+				columns = strings.Split(config.SheetsColumns, ",")
+				for i, column := range columns {
+					columns[i] = strings.TrimSpace(column)
+				}
+			}
+
+			ctx := cmd.Context()
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, _, _, err := getActivities(ctx, logger, client, cache, config.Settings.Fetch)
+			if err != nil {
+				fatal(logger, err)
+			}
+
+			transport, err := newTransport(config.Settings.HTTP)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			sc, err := newSheetsClient(ctx, &http.Client{Transport: transport}, config.SheetsCredentialsFile, config.SheetsSpreadsheetId)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			added, updated, err := sc.upsertActivities(ctx, tab, columns, activities)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Sheet %q: %d activities added, %d updated\n", tab, added, updated)
+
+			if config.SheetsSummaryTab != "" {
+				sum, err := summarize(ctx, activities, aggregations(config.Settings.Rules, config.Settings.Metrics), time.Now())
+				if err != nil {
+					logger.Fatal(err)
+				}
+				if err := sc.writeSummary(ctx, config.SheetsSummaryTab, sum); err != nil {
+					logger.Fatal(err)
+				}
+				logger.Printf("Sheet %q: summary updated\n", config.SheetsSummaryTab)
+			}
+		},
+	}
+}
+
+// newSheetsClient authenticates as the service account in credentialsFile
+// using the JWT bearer grant.
+func newSheetsClient(ctx context.Context, client *http.Client, credentialsFile string, spreadsheetId string) (*sheetsClient, error) {
+	raw, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("parsing service account key: %w", err)
+	}
+	if key.TokenUri == "" {
+		key.TokenUri = "https://oauth2.googleapis.com/token"
+	}
+
+	assertion, err := signServiceAccountJWT(key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, key.TokenUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("service account token request failed: %s: %s", res.Status, body)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+
+	return &sheetsClient{client: client, spreadsheetId: spreadsheetId, accessToken: token.AccessToken}, nil
+}
+
+// signServiceAccountJWT builds the RS256 signed assertion for the token request
+func signServiceAccountJWT(key serviceAccountKey, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", errors.New("service account private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("parsing service account private key: %w", err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("service account private key is not an RSA key")
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   key.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   key.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// upsertActivities writes one row per activity to tab, updating the row in
+// place when the activity ID is already present so repeated runs are
+// idempotent. A header row is written when the tab is empty.
+func (sc *sheetsClient) upsertActivities(ctx context.Context, tab string, columns []string, activities []strava.Activity) (int, int, error) {
+	idColumn := -1
+	for i, column := range columns {
+		if column == "id" {
+			idColumn = i
+		}
+	}
+	if idColumn == -1 {
+		return 0, 0, errors.New("sheet columns must include id")
+	}
+
+	existing, err := sc.getValues(ctx, quoteTab(tab))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	appends := make([][]interface{}, 0)
+	if len(existing) == 0 {
+		header := make([]interface{}, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		appends = append(appends, header)
+	}
+
+	// Map activity ID to its 1-based row number in the sheet
+	rows := make(map[string]int)
+	for i, row := range existing {
+		if idColumn < len(row) {
+			rows[row[idColumn]] = i + 1
+		}
+	}
+
+	updates := make([]valueRange, 0)
+	for _, a := range activities {
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			value, err := activityField(a, column)
+			if err != nil {
+				return 0, 0, err
+			}
+			row[i] = value
+		}
+
+		if n, ok := rows[strconv.Itoa(a.Id)]; ok {
+			updates = append(updates, valueRange{
+				Range:  fmt.Sprintf("%s!A%d", quoteTab(tab), n),
+				Values: [][]interface{}{row},
+			})
+		} else {
+			appends = append(appends, row)
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := sc.batchUpdate(ctx, updates); err != nil {
+			return 0, 0, err
+		}
+	}
+	added := 0
+	if len(appends) > 0 {
+		if err := sc.appendValues(ctx, quoteTab(tab), appends); err != nil {
+			return 0, 0, err
+		}
+		added = len(appends)
+		if len(existing) == 0 {
+			added-- // header row
+		}
+	}
+
+	return added, len(updates), nil
+}
+
+// writeSummary overwrites the top of tab with the run totals
+func (sc *sheetsClient) writeSummary(ctx context.Context, tab string, sum summary) error {
+	return sc.batchUpdate(ctx, []valueRange{{
+		Range: quoteTab(tab) + "!A1",
+		Values: [][]interface{}{
+			{"activity_count", sum.Count},
+			{"total_miles", fmt.Sprintf("%.2f", sum.Miles)},
+			{"streak", sum.Streak},
+			{"updated", time.Now().Format(time.RFC3339)},
+		},
+	}})
+}
+
+func (sc *sheetsClient) getValues(ctx context.Context, rng string) ([][]string, error) {
+	var result struct {
+		Values [][]string `json:"values"`
+	}
+	err := sc.do(ctx, "GET", sheetsApiUrl+"/"+sc.spreadsheetId+"/values/"+url.PathEscape(rng), nil, &result)
+	return result.Values, err
+}
+
+func (sc *sheetsClient) appendValues(ctx context.Context, rng string, rows [][]interface{}) error {
+	endpoint := sheetsApiUrl + "/" + sc.spreadsheetId + "/values/" + url.PathEscape(rng) + ":append?valueInputOption=RAW&insertDataOption=INSERT_ROWS"
+	return sc.do(ctx, "POST", endpoint, valueRange{Values: rows}, nil)
+}
+
+func (sc *sheetsClient) batchUpdate(ctx context.Context, data []valueRange) error {
+	payload := map[string]interface{}{
+		"valueInputOption": "RAW",
+		"data":             data,
+	}
+	return sc.do(ctx, "POST", sheetsApiUrl+"/"+sc.spreadsheetId+"/values:batchUpdate", payload, nil)
+}
+
+func (sc *sheetsClient) do(ctx context.Context, method string, endpoint string, payload interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+sc.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := sc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("sheets api %s %s: %s: %s", method, endpoint, res.Status, body)
+	}
+	if out != nil {
+		return json.Unmarshal(body, out)
+	}
+	return nil
+}
+
+// activityField maps a configured column name to the activity value
+func activityField(a strava.Activity, column string) (interface{}, error) {
+	switch strings.TrimSpace(column) {
+	case "id":
+		return strconv.Itoa(a.Id), nil
+	case "name":
+		return a.Name, nil
+	case "description":
+		return a.Description, nil
+	case "type":
+		return a.Type, nil
+	case "start_date":
+		return a.StartDate, nil
+	case "distance":
+		return a.Distance, nil
+	case "distance_miles":
+		return fmt.Sprintf("%.2f", a.Distance*0.000621371), nil
+	case "moving_time":
+		return a.MovingTime, nil
+	case "elapsed_time":
+		return a.ElapsedTime, nil
+	}
+	return nil, fmt.Errorf("unknown sheet column %q", column)
+}
+
+func quoteTab(tab string) string {
+	return "'" + strings.ReplaceAll(tab, "'", "''") + "'"
+}
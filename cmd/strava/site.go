@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/charts"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// siteMapSize is the width and height of the route map on activity pages
+const siteMapSize = 480
+
+// split is one kilometre or mile of an activity; the last is usually shorter
+type split struct {
+	Distance            float64 `json:"distance"`
+	ElapsedTime         int     `json:"elapsed_time"`
+	MovingTime          int     `json:"moving_time"`
+	ElevationDifference float64 `json:"elevation_difference"`
+}
+
+// activitySplits holds an activity's splits by kilometre and by mile, so
+// changing output.units needs no new streams request
+type activitySplits struct {
+	Metric   []split `json:"metric"`
+	Standard []split `json:"standard"`
+}
+
+// forUnits returns the splits matching the output units
+func (s activitySplits) forUnits(output outputSettings) []split {
+	if output.Units == "km" {
+		return s.Metric
+	}
+	return s.Standard
+}
+
+// computeSplits cuts an activity into pieces of length meters, ending each
+// at the first sample past the boundary. Moving time counts the samples
+// flagged as moving; without that stream it equals the elapsed time.
+func computeSplits(streams strava.Streams, length float64) []split {
+	if streams.Time == nil || streams.Distance == nil {
+		return nil
+	}
+	times, distances := streams.Time.Data, streams.Distance.Data
+	n := min(len(times), len(distances))
+	if n < 2 {
+		return nil
+	}
+	altitude := func(i int) float64 {
+		if streams.Altitude == nil || i >= len(streams.Altitude.Data) {
+			return 0
+		}
+		return streams.Altitude.Data[i]
+	}
+
+	splits := make([]split, 0)
+	start := 0
+	current := split{}
+	for i := 1; i < n; i++ {
+		dt := times[i] - times[i-1]
+		current.ElapsedTime += dt
+		if streams.Moving == nil || (i < len(streams.Moving.Data) && streams.Moving.Data[i]) {
+			current.MovingTime += dt
+		}
+		if distances[i]-distances[start] >= length || i == n-1 {
+			current.Distance = distances[i] - distances[start]
+			current.ElevationDifference = altitude(i) - altitude(start)
+			if current.Distance > 0 {
+				splits = append(splits, current)
+			}
+			start, current = i, split{}
+		}
+	}
+	return splits
+}
+
+func (c *activityCache) setSplits(id int, splits activitySplits) error {
+	data, err := json.Marshal(splits)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec(`INSERT INTO activity_splits (activity_id, splits) VALUES (?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET splits = excluded.splits`, id, string(data))
+	return err
+}
+
+// splits returns the stored splits of every analysed activity
+func (c *activityCache) splits() (map[int]activitySplits, error) {
+	rows, err := c.db.Query(`SELECT activity_id, splits FROM activity_splits`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	splits := make(map[int]activitySplits)
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, err
+		}
+		var s activitySplits
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			return nil, err
+		}
+		splits[id] = s
+	}
+	return splits, rows.Err()
+}
+
+// siteActivity is an activity formatted for the site's pages
+type siteActivity struct {
+	Id         int
+	Name       string
+	Type       string
+	Date       string
+	Distance   string
+	MovingTime string
+	// Speed is the pace for runs and the average speed otherwise
+	Speed       string
+	Climbing    string
+	Description string
+	Map         string
+	Splits      []siteSplit
+}
+
+type siteSplit struct {
+	Number    int
+	Distance  string
+	Time      string
+	Speed     string
+	Elevation string
+}
+
+type siteYear struct {
+	Year       int
+	Count      int
+	Distance   string
+	MovingTime string
+	Climbing   string
+	Activities []siteActivity
+}
+
+// speed renders the pace of runs and the average speed of everything else
+func speed(activityType string, seconds int, meters float64, output outputSettings) string {
+	distance, unit := output.convert(meters)
+	if containsFold(runTypes, activityType) {
+		return formatPace(seconds, distance, unit)
+	}
+	if seconds <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f %s/h", distance/(float64(seconds)/3600), unit)
+}
+
+func newSiteActivity(a strava.Activity, output outputSettings) siteActivity {
+	distance, unit := output.convert(a.Distance)
+	climbing, elevationUnit := output.convertElevation(a.TotalElevationGain)
+	date := ""
+	if start, err := time.Parse(time.RFC3339, a.StartDateLocal); err == nil {
+		date = start.Format("Mon Jan 2, 2006 15:04")
+	} else if start, err := time.Parse(time.RFC3339, a.StartDate); err == nil {
+		date = start.Local().Format("Mon Jan 2, 2006 15:04")
+	}
+	return siteActivity{
+		Id:          a.Id,
+		Name:        a.Name,
+		Type:        a.Type,
+		Date:        date,
+		Distance:    fmt.Sprintf("%.2f %s", distance, unit),
+		MovingTime:  formatDuration(a.MovingTime),
+		Speed:       speed(a.Type, a.MovingTime, a.Distance, output),
+		Climbing:    fmt.Sprintf("%.0f %s", climbing, elevationUnit),
+		Description: a.Description,
+	}
+}
+
+// monthlyDistanceChart sums a year's distance per month
+func monthlyDistanceChart(year int, activities []strava.Activity, output outputSettings) charts.Chart {
+	_, unit := output.convert(0)
+	values := make([]float64, 12)
+	for _, t := range aggregate(activities, periodMonth, nil) {
+		if t.Start.Year() == year {
+			values[t.Start.Month()-1], _ = output.convert(t.Distance)
+		}
+	}
+	chart := charts.Chart{Title: fmt.Sprintf("Distance per month, %d", year), Unit: unit}
+	for m := time.January; m <= time.December; m++ {
+		chart.Labels = append(chart.Labels, m.String()[:3])
+	}
+	chart.Series = []charts.Series{{Name: "Distance", Values: values, Bars: true}}
+	return chart
+}
+
+// buildSite writes the index, one page per year, and one page per activity
+// to out, returning the number of pages written. Links are relative so the
+// site works from any path, such as a GitHub Pages project site.
+func buildSite(out, title string, activities []strava.Activity, splits map[int]activitySplits, output outputSettings, now time.Time) (int, error) {
+	sort.Slice(activities, func(i, j int) bool { return activities[i].StartDate > activities[j].StartDate })
+
+	byYear := make(map[int][]strava.Activity)
+	for _, a := range activities {
+		start, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			continue
+		}
+		byYear[start.Local().Year()] = append(byYear[start.Local().Year()], a)
+	}
+
+	if err := writeFile(filepath.Join(out, "style.css"), []byte(siteStyle)); err != nil {
+		return 0, err
+	}
+	if err := writeFile(filepath.Join(out, "charts", "weekly-distance.svg"), weeklyDistanceChart(activities, output, 12, now).SVG()); err != nil {
+		return 0, err
+	}
+
+	pages := 0
+	render := func(path string, page *template.Template, data interface{}) error {
+		var buf bytes.Buffer
+		if err := page.Execute(&buf, data); err != nil {
+			return err
+		}
+		pages++
+		return writeFile(filepath.Join(out, path), buf.Bytes())
+	}
+
+	years := make([]siteYear, 0, len(byYear))
+	for _, t := range aggregate(activities, periodYear, nil) {
+		year := t.Start.Year()
+		distance, unit := output.convert(t.Distance)
+		climbing, elevationUnit := output.convertElevation(t.ElevationGain)
+		summary := siteYear{
+			Year:       year,
+			Count:      t.Count,
+			Distance:   fmt.Sprintf("%.0f %s", distance, unit),
+			MovingTime: fmt.Sprintf("%.0f h", float64(t.MovingTime)/3600),
+			Climbing:   fmt.Sprintf("%.0f %s", climbing, elevationUnit),
+		}
+
+		for _, a := range byYear[year] {
+			page := newSiteActivity(a, output)
+			if points, err := a.Map.Points(); err == nil && len(points) >= 2 {
+				data, err := renderThumbnailSVG(points, siteMapSize)
+				if err != nil {
+					return pages, err
+				}
+				page.Map = strconv.Itoa(a.Id) + ".svg"
+				if err := writeFile(filepath.Join(out, "activities", page.Map), data); err != nil {
+					return pages, err
+				}
+			}
+			for i, s := range splits[a.Id].forUnits(output) {
+				distance, unit := output.convert(s.Distance)
+				elevation, elevationUnit := output.convertElevation(s.ElevationDifference)
+				page.Splits = append(page.Splits, siteSplit{
+					Number:    i + 1,
+					Distance:  fmt.Sprintf("%.2f %s", distance, unit),
+					Time:      formatDuration(s.MovingTime),
+					Speed:     speed(a.Type, s.MovingTime, s.Distance, output),
+					Elevation: fmt.Sprintf("%+.0f %s", elevation, elevationUnit),
+				})
+			}
+			if err := render(filepath.Join("activities", strconv.Itoa(a.Id)+".html"), siteActivityPage, struct {
+				Title    string
+				Year     int
+				Activity siteActivity
+			}{title, year, page}); err != nil {
+				return pages, err
+			}
+			summary.Activities = append(summary.Activities, page)
+		}
+
+		chart := monthlyDistanceChart(year, byYear[year], output)
+		if err := writeFile(filepath.Join(out, "charts", fmt.Sprintf("%d.svg", year)), chart.SVG()); err != nil {
+			return pages, err
+		}
+		if err := render(fmt.Sprintf("%d.html", year), siteYearPage, struct {
+			Title string
+			siteYear
+		}{title, summary}); err != nil {
+			return pages, err
+		}
+		years = append(years, summary)
+	}
+
+	// Newest year first on the index
+	for i, j := 0, len(years)-1; i < j; i, j = i+1, j-1 {
+		years[i], years[j] = years[j], years[i]
+	}
+	err := render("index.html", siteIndexPage, struct {
+		Title   string
+		Years   []siteYear
+		Updated string
+	}{title, years, now.Format("January 2, 2006")})
+	return pages, err
+}
+
+func newSiteReportCmd() *cobra.Command {
+	var out, title string
+	var visibility []string
+	var limit int
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "site",
+		Short: "Generate a static website of the cached activities",
+		Long: `Writes a small static training log to --out: an index of yearly summaries,
+a page per year listing its activities, and a page per activity with its
+route map and splits. Publish the directory with GitHub Pages or any static
+host.
+
+Maps are drawn from the cached summary polylines. Splits come from the time
+and distance streams, fetched once per activity and stored in the cache; at
+most --limit new activities are fetched per run, newest first. With
+--limit 0 the site is built from the cache alone, without signing in.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			expr, err := selection.filter()
+			if err == nil {
+				expr, err = expr.withVisibility(visibility)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if activities, err = filterActivities(expr, activities); err != nil {
+				logger.Fatal(err)
+			}
+			stored, err := cache.splits()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			pending := make([]strava.Activity, 0)
+			for _, a := range activities {
+				if _, ok := stored[a.Id]; !ok && a.Distance > 0 {
+					pending = append(pending, a)
+				}
+			}
+			if limit > 0 && len(pending) > 0 {
+				client := newClient(ctx, logger, config)
+				authenticate(ctx, logger, client)
+
+				sort.Slice(pending, func(i, j int) bool { return pending[i].StartDate > pending[j].StartDate })
+				bulk := strava.WithPriority(ctx, strava.PriorityBulk)
+				for i, a := range pending {
+					if i >= limit {
+						logger.Printf("Fetched splits for %d activities, rerun to fetch the remaining %d\n", limit, len(pending)-limit)
+						break
+					}
+					// Manual activities have no streams and get no splits
+					streams, err := storedStreams(bulk, client, cache, config.Settings.Streams, int64(a.Id))
+					if errors.Is(err, strava.ErrDeferred) {
+						logger.Printf("Fetched splits for %d activities, %v; rerun to fetch the remaining %d\n", i, err, len(pending)-i)
+						break
+					}
+					if err != nil {
+						logger.Fatalf("activity %d: %v\n", a.Id, err)
+					}
+					s := activitySplits{
+						Metric:   computeSplits(streams, 1000),
+						Standard: computeSplits(streams, 1609.344),
+					}
+					if err := cache.setSplits(a.Id, s); err != nil {
+						logger.Fatal(err)
+					}
+					stored[a.Id] = s
+				}
+			}
+
+			pages, err := buildSite(out, title, activities, stored, config.Settings.Output, time.Now())
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Wrote %d pages to %s\n", pages, out)
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "public", "directory to write the site to")
+	cmd.Flags().StringVar(&title, "title", "Training log", "site title")
+	selection = addSelectionFlags(cmd)
+	cmd.Flags().StringSliceVar(&visibility, "visibility", []string{strava.VisibilityEveryone}, "only include activities with these visibilities: everyone, followers_only, only_me")
+	cmd.Flags().IntVar(&limit, "limit", defaultScoreLimit, "maximum streams requests for splits")
+
+	return cmd
+}
+
+var siteIndexPage = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<img class="chart" src="charts/weekly-distance.svg" alt="Weekly distance">
+<table>
+<tr><th>Year</th><th>Activities</th><th>Distance</th><th>Moving time</th><th>Climbing</th></tr>
+{{- range .Years}}
+<tr><td><a href="{{.Year}}.html">{{.Year}}</a></td><td>{{.Count}}</td><td>{{.Distance}}</td><td>{{.MovingTime}}</td><td>{{.Climbing}}</td></tr>
+{{- end}}
+</table>
+<footer>Updated {{.Updated}}</footer>
+</body>
+</html>
+`))
+
+var siteYearPage = template.Must(template.New("year").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Year}} - {{.Title}}</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<nav><a href="index.html">{{.Title}}</a></nav>
+<h1>{{.Year}}</h1>
+<p>{{.Count}} activities, {{.Distance}}, {{.MovingTime}} moving, {{.Climbing}} climbed</p>
+<img class="chart" src="charts/{{.Year}}.svg" alt="Distance per month">
+<table>
+<tr><th></th><th>Date</th><th>Activity</th><th>Type</th><th>Distance</th><th>Time</th><th>Pace or speed</th></tr>
+{{- range .Activities}}
+<tr><td>{{if .Map}}<img class="thumb" src="activities/{{.Map}}" alt="">{{end}}</td><td>{{.Date}}</td><td><a href="activities/{{.Id}}.html">{{.Name}}</a></td><td>{{.Type}}</td><td>{{.Distance}}</td><td>{{.MovingTime}}</td><td>{{.Speed}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+var siteActivityPage = template.Must(template.New("activity").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Activity.Name}} - {{.Title}}</title>
+<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+{{- with .Activity}}
+<nav><a href="../index.html">{{$.Title}}</a> / <a href="../{{$.Year}}.html">{{$.Year}}</a></nav>
+<h1>{{.Name}}</h1>
+<p>{{.Type}} on {{.Date}}</p>
+{{- if .Description}}
+<p>{{.Description}}</p>
+{{- end}}
+<table>
+<tr><th>Distance</th><th>Moving time</th><th>Pace or speed</th><th>Climbing</th></tr>
+<tr><td>{{.Distance}}</td><td>{{.MovingTime}}</td><td>{{.Speed}}</td><td>{{.Climbing}}</td></tr>
+</table>
+{{- if .Map}}
+<img class="map" src="{{.Map}}" alt="Route map">
+{{- end}}
+{{- if .Splits}}
+<h2>Splits</h2>
+<table>
+<tr><th>Split</th><th>Distance</th><th>Time</th><th>Pace or speed</th><th>Elevation</th></tr>
+{{- range .Splits}}
+<tr><td>{{.Number}}</td><td>{{.Distance}}</td><td>{{.Time}}</td><td>{{.Speed}}</td><td>{{.Elevation}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<p><a href="https://www.strava.com/activities/{{.Id}}">View on Strava</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+const siteStyle = `body { font-family: sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #222; }
+a { color: #fc4c02; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 0.3em 0.8em; text-align: left; border-bottom: 1px solid #e0e0e0; }
+img.chart { max-width: 100%; }
+img.thumb { width: 48px; height: 48px; }
+img.map { width: 100%; max-width: 480px; border: 1px solid #e0e0e0; }
+nav, footer { color: #777; margin: 1em 0; }
+`
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// detailSplits returns the splits Strava computed for the activity in the
+// output units
+func detailSplits(output outputSettings, activity strava.DetailedActivity) []strava.Split {
+	if output.Units == "km" {
+		return activity.SplitsMetric
+	}
+	return activity.SplitsStandard
+}
+
+// writeSplits writes a table of the splits with the pace of each, the
+// grade adjusted pace when Strava reported one
+func writeSplits(w io.Writer, output outputSettings, splits []strava.Split) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPLIT\tDISTANCE\tTIME\tPACE\tGAP\tELEVATION\tAVG HR")
+	for _, s := range splits {
+		distance, unit := output.convert(s.Distance)
+		elevation, elevationUnit := output.convertElevation(s.ElevationDifference)
+		gap := "-"
+		if s.AverageGradeAdjustedSpeed > 0 && s.AverageSpeed > 0 {
+			gap = formatPace(s.MovingTime, distance*s.AverageGradeAdjustedSpeed/s.AverageSpeed, unit)
+		}
+		fmt.Fprintf(tw, "%d\t%.2f %s\t%s\t%s\t%s\t%+.0f %s\t%s\n", s.Split, distance, unit, formatDuration(s.MovingTime),
+			formatPace(s.MovingTime, distance, unit), gap, elevation, elevationUnit, optional(s.AverageHeartrate))
+	}
+	return tw.Flush()
+}
+
+// splitHalves returns the pace, in seconds per meter, of the first and
+// second half of the splits by count, the middle one going to the second
+// half. ok is false with fewer than two splits.
+func splitHalves(splits []strava.Split) (first, second float64, ok bool) {
+	if len(splits) < 2 {
+		return 0, 0, false
+	}
+	pace := func(splits []strava.Split) float64 {
+		var seconds, meters float64
+		for _, s := range splits {
+			seconds += float64(s.MovingTime)
+			meters += s.Distance
+		}
+		if meters == 0 {
+			return 0
+		}
+		return seconds / meters
+	}
+	half := len(splits) / 2
+	first, second = pace(splits[:half]), pace(splits[half:])
+	return first, second, first > 0 && second > 0
+}
+
+// describeSplitHalves says whether the second half was run faster than
+// the first, by how much per unit of distance
+func describeSplitHalves(output outputSettings, splits []strava.Split) string {
+	first, second, ok := splitHalves(splits)
+	if !ok {
+		return ""
+	}
+	perUnit, unit := output.convert(1)
+	diff := int((first - second) / perUnit)
+	switch {
+	case diff > 0:
+		return fmt.Sprintf("Negative split: second half %s/%s faster", formatDuration(diff), unit)
+	case diff < 0:
+		return fmt.Sprintf("Positive split: second half %s/%s slower", formatDuration(-diff), unit)
+	}
+	return "Even split"
+}
+
+func newSplitsCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "splits <activity-id>",
+		Short: "Show the kilometre or mile splits of an activity",
+		Long: `Prints the splits Strava computed for an activity, by kilometre when
+output.units is km and by mile otherwise, with the pace, grade adjusted
+pace, climbing, and heart rate of each, then whether the second half was
+faster than the first. --format json prints both sets of splits as Strava
+returns them, distances in meters and times in seconds.
+
+Strava only splits runs and walks recorded with GPS.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				logger.Fatalf("invalid activity id %q\n", args[0])
+			}
+			if format != "table" && format != "json" {
+				logger.Fatalf("unknown format %q, expected table or json\n", format)
+			}
+
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			activity, err := client.GetActivity(ctx, id, false)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if format == "json" {
+				printJSON(logger, map[string][]strava.Split{"metric": activity.SplitsMetric, "standard": activity.SplitsStandard})
+				return
+			}
+
+			splits := detailSplits(config.Settings.Output, activity)
+			if len(splits) == 0 {
+				logger.Fatalf("activity %d has no splits; Strava only splits runs and walks recorded with GPS\n", id)
+			}
+			fmt.Printf("%s (%s) %s\n", activity.Name, activity.Type, activity.StartDate)
+			if err := writeSplits(os.Stdout, config.Settings.Output, splits); err != nil {
+				logger.Fatal(err)
+			}
+			if halves := describeSplitHalves(config.Settings.Output, splits); halves != "" {
+				fmt.Println(halves)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+	return cmd
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// streamColumns are the stream_samples columns, in the order scanned
+const streamColumns = `time, distance, lat, lng, altitude, velocity_smooth, heartrate, cadence, watts, temp, moving, grade_smooth`
+
+// storedStreams returns the streams of an activity from the cache. The
+// first time, every stream is fetched at the resolution in settings and
+// stored, so each activity costs one request whatever is asked of it
+// later. Activities without streams, such as manual ones, are stored as
+// having none.
+func storedStreams(ctx context.Context, client strava.ClientInterface, cache *activityCache, settings streamSettings, id int64) (strava.Streams, error) {
+	streams, ok, err := cache.streams(id)
+	if err != nil || ok {
+		return streams, err
+	}
+
+	streams, err = client.GetActivityStreamsWithOptions(ctx, id, settings.options())
+	var apiErr *strava.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		streams, err = strava.Streams{}, nil
+	}
+	if err != nil {
+		return strava.Streams{}, err
+	}
+	streams = settings.reduce(streams)
+	return streams, cache.setStreams(id, settings.Resolution, streams, time.Now())
+}
+
+// setStreams replaces the stored samples of an activity
+func (c *activityCache) setStreams(id int64, resolution string, streams strava.Streams, now time.Time) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM stream_samples WHERE activity_id = ?`, id); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO stream_samples (activity_id, idx, ` + streamColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	keys, samples := streamKeys(streams)
+	for i := 0; i < samples; i++ {
+		var lat, lng interface{}
+		if streams.LatLng != nil && i < len(streams.LatLng.Data) && len(streams.LatLng.Data[i]) == 2 {
+			lat, lng = streams.LatLng.Data[i][0], streams.LatLng.Data[i][1]
+		}
+		_, err := stmt.Exec(id, i,
+			intSample(streams.Time, i), floatSample(streams.Distance, i), lat, lng,
+			floatSample(streams.Altitude, i), floatSample(streams.VelocitySmooth, i),
+			intSample(streams.Heartrate, i), intSample(streams.Cadence, i), intSample(streams.Watts, i), intSample(streams.Temp, i),
+			boolSample(streams.Moving, i), floatSample(streams.GradeSmooth, i))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO activity_streams (activity_id, keys, resolution, samples, fetched_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET keys = excluded.keys, resolution = excluded.resolution, samples = excluded.samples, fetched_at = excluded.fetched_at`,
+		id, strings.Join(keys, ","), resolution, samples, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// streams loads the stored streams of an activity, reporting whether they
+// were stored
+func (c *activityCache) streams(id int64) (strava.Streams, bool, error) {
+	var keyList string
+	err := c.db.QueryRow(`SELECT keys FROM activity_streams WHERE activity_id = ?`, id).Scan(&keyList)
+	if errors.Is(err, sql.ErrNoRows) {
+		return strava.Streams{}, false, nil
+	}
+	if err != nil {
+		return strava.Streams{}, false, err
+	}
+	keys := make(map[string]bool)
+	for _, key := range strings.Split(keyList, ",") {
+		keys[key] = true
+	}
+
+	streams := strava.Streams{}
+	if keys[strava.StreamTime] {
+		streams.Time = &strava.IntStream{}
+	}
+	if keys[strava.StreamDistance] {
+		streams.Distance = &strava.FloatStream{}
+	}
+	if keys[strava.StreamLatLng] {
+		streams.LatLng = &strava.LatLngStream{}
+	}
+	if keys[strava.StreamAltitude] {
+		streams.Altitude = &strava.FloatStream{}
+	}
+	if keys[strava.StreamVelocitySmooth] {
+		streams.VelocitySmooth = &strava.FloatStream{}
+	}
+	if keys[strava.StreamHeartrate] {
+		streams.Heartrate = &strava.IntStream{}
+	}
+	if keys[strava.StreamCadence] {
+		streams.Cadence = &strava.IntStream{}
+	}
+	if keys[strava.StreamWatts] {
+		streams.Watts = &strava.IntStream{}
+	}
+	if keys[strava.StreamTemp] {
+		streams.Temp = &strava.IntStream{}
+	}
+	if keys[strava.StreamMoving] {
+		streams.Moving = &strava.BoolStream{}
+	}
+	if keys[strava.StreamGradeSmooth] {
+		streams.GradeSmooth = &strava.FloatStream{}
+	}
+
+	rows, err := c.db.Query(`SELECT `+streamColumns+` FROM stream_samples WHERE activity_id = ? ORDER BY idx`, id)
+	if err != nil {
+		return strava.Streams{}, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t, hr, cadence, watts, temp sql.NullInt64
+		var distance, lat, lng, altitude, velocity, grade sql.NullFloat64
+		var moving sql.NullBool
+		if err := rows.Scan(&t, &distance, &lat, &lng, &altitude, &velocity, &hr, &cadence, &watts, &temp, &moving, &grade); err != nil {
+			return strava.Streams{}, false, err
+		}
+		appendInt(streams.Time, t)
+		appendFloat(streams.Distance, distance)
+		if streams.LatLng != nil && lat.Valid && lng.Valid {
+			streams.LatLng.Data = append(streams.LatLng.Data, strava.LatLng{lat.Float64, lng.Float64})
+		}
+		appendFloat(streams.Altitude, altitude)
+		appendFloat(streams.VelocitySmooth, velocity)
+		appendInt(streams.Heartrate, hr)
+		appendInt(streams.Cadence, cadence)
+		appendInt(streams.Watts, watts)
+		appendInt(streams.Temp, temp)
+		if streams.Moving != nil && moving.Valid {
+			streams.Moving.Data = append(streams.Moving.Data, moving.Bool)
+		}
+		appendFloat(streams.GradeSmooth, grade)
+	}
+	return streams, true, rows.Err()
+}
+
+// clearStreams removes the stored streams of an activity, so they are
+// fetched again
+func (c *activityCache) clearStreams(id int64) error {
+	for _, table := range []string{"stream_samples", "activity_streams"} {
+		if _, err := c.db.Exec(`DELETE FROM `+table+` WHERE activity_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamsStored returns the ids of the activities with stored streams
+func (c *activityCache) streamsStored() (map[int]bool, error) {
+	rows, err := c.db.Query(`SELECT activity_id FROM activity_streams`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		stored[id] = true
+	}
+	return stored, rows.Err()
+}
+
+// streamKeys lists the streams present and the number of samples of the
+// longest
+func streamKeys(s strava.Streams) ([]string, int) {
+	keys := make([]string, 0)
+	samples := 0
+	add := func(key string, present bool, n int) {
+		if present {
+			keys = append(keys, key)
+			samples = max(samples, n)
+		}
+	}
+	add(strava.StreamTime, s.Time != nil, intLen(s.Time))
+	add(strava.StreamDistance, s.Distance != nil, floatLen(s.Distance))
+	if s.LatLng != nil {
+		add(strava.StreamLatLng, true, len(s.LatLng.Data))
+	}
+	add(strava.StreamAltitude, s.Altitude != nil, floatLen(s.Altitude))
+	add(strava.StreamVelocitySmooth, s.VelocitySmooth != nil, floatLen(s.VelocitySmooth))
+	add(strava.StreamHeartrate, s.Heartrate != nil, intLen(s.Heartrate))
+	add(strava.StreamCadence, s.Cadence != nil, intLen(s.Cadence))
+	add(strava.StreamWatts, s.Watts != nil, intLen(s.Watts))
+	add(strava.StreamTemp, s.Temp != nil, intLen(s.Temp))
+	if s.Moving != nil {
+		add(strava.StreamMoving, true, len(s.Moving.Data))
+	}
+	add(strava.StreamGradeSmooth, s.GradeSmooth != nil, floatLen(s.GradeSmooth))
+	return keys, samples
+}
+
+func intLen(s *strava.IntStream) int {
+	if s == nil {
+		return 0
+	}
+	return len(s.Data)
+}
+
+func floatLen(s *strava.FloatStream) int {
+	if s == nil {
+		return 0
+	}
+	return len(s.Data)
+}
+
+// intSample is sample i of s, or NULL past its end
+func intSample(s *strava.IntStream, i int) interface{} {
+	if s == nil || i >= len(s.Data) {
+		return nil
+	}
+	return s.Data[i]
+}
+
+func floatSample(s *strava.FloatStream, i int) interface{} {
+	if s == nil || i >= len(s.Data) {
+		return nil
+	}
+	return s.Data[i]
+}
+
+func boolSample(s *strava.BoolStream, i int) interface{} {
+	if s == nil || i >= len(s.Data) {
+		return nil
+	}
+	return s.Data[i]
+}
+
+func appendInt(s *strava.IntStream, v sql.NullInt64) {
+	if s != nil && v.Valid {
+		s.Data = append(s.Data, int(v.Int64))
+	}
+}
+
+func appendFloat(s *strava.FloatStream, v sql.NullFloat64) {
+	if s != nil && v.Valid {
+		s.Data = append(s.Data, v.Float64)
+	}
+}
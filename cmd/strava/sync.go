@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/otlp"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// syncResumeKey holds the start time of the newest activity stored by a
+// sync that has not finished yet
+const syncResumeKey = "resume_after"
+
+// errSyncRunning is returned by lockFile while another sync holds the lock
+var errSyncRunning = errors.New("another sync is running")
+
+// syncActivities fetches activities oldest first into the cache, one page
+// of MaxPerPage at a time. After each page the start time of its last
+// activity is saved, so a run cut short by an error or Ctrl-C resumes from
+// that point instead of from page 1. A completed sync clears the mark and
+// the next run fetches everything again to pick up edits, unless
+// fetch.Incremental starts it after the newest cached activity. The activities
+// that were not cached before are returned, even when err is not nil, along
+// with the cached ones that were edited on Strava. A full sync also marks
+// the ones Strava no longer returns as deleted.
+func syncActivities(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, fetch fetchSettings) (added []strava.Activity, changes []activityChange, err error) {
+	ctx, span := tracer.Start(ctx, "sync")
+	run := syncRun{Kind: "full", Started: time.Now()}
+	changes = make([]activityChange, 0)
+	calls := apiUsage.count()
+	saved := 0
+	defer func() {
+		span.SetAttributes(otlp.Int("strava.sync.pages_saved", saved), otlp.Int("strava.sync.added", len(added)))
+		span.RecordError(err)
+		span.End()
+
+		run.Finished, run.Pages, run.Added, run.APICalls = time.Now(), saved, len(added), apiUsage.count()-calls
+		for _, change := range changes {
+			if change.Kind == "updated" {
+				run.Updated++
+			}
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		if recordErr := cache.recordRun(run); recordErr != nil {
+			logger.Printf("Recording the sync run: %v\n", recordErr)
+		}
+	}()
+
+	// Strava only returns activities oldest first when after is set
+	var after int64 = 1
+
+	resume, err := cache.state(syncResumeKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resume != "" {
+		after, err = strconv.ParseInt(resume, 10, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Printf("Resuming interrupted fetch from %s\n", time.Unix(after, 0).UTC().Format(time.RFC3339))
+		run.Kind = "resumed"
+	} else if fetch.Incremental {
+		newest, err := cache.newestStart()
+		if err != nil {
+			return nil, nil, err
+		}
+		if start, err := time.Parse(time.RFC3339, newest); err == nil {
+			after = start.Unix() - 1
+			run.Kind = "incremental"
+			logger.Printf("Fetching activities started after %s\n", newest)
+		}
+	}
+
+	span.SetAttributes(otlp.Int("strava.sync.after", int(after)), otlp.Bool("strava.sync.resumed", resume != ""))
+
+	// The plan is advisory, so a failure to make one does not stop the sync
+	plan, err := planSync(ctx, client, cache, after, after > 1)
+	if err != nil {
+		logger.Printf("Skipping the sync plan: %v\n", err)
+	} else {
+		logger.Println(plan.describe(time.Now()))
+		span.SetAttributes(otlp.Int("strava.sync.planned_pages", plan.Pages))
+		if err := plan.check(); err != nil && !fetch.Force {
+			return nil, nil, err
+		}
+	}
+
+	// Only a sync from the first activity sees every one that still exists
+	var seen map[int]bool
+	if run.Kind == "full" {
+		seen = make(map[int]bool)
+	}
+
+	opts := strava.ListActivitiesOptions{PerPage: strava.MaxPerPage, After: after, Prefetch: fetch.Prefetch}
+	err = client.ListActivitiesPages(ctx, opts, func(page []strava.Activity) (err error) {
+		_, span := tracer.Start(ctx, "sync.store_page", otlp.Int("strava.page", saved+1), otlp.Int("strava.page.activities", len(page)))
+		defer func() {
+			span.RecordError(err)
+			span.End()
+		}()
+
+		missing, err := cache.missingActivities(page)
+		if err != nil {
+			return err
+		}
+		changed, err := cache.activityChanges(page)
+		if err != nil {
+			return err
+		}
+		if err := cache.recordChanges(run.Started, changed); err != nil {
+			return err
+		}
+		for _, change := range changed {
+			logger.Printf("Changed on Strava: %s\n", change)
+		}
+		added = append(added, missing...)
+		changes = append(changes, changed...)
+		span.SetAttributes(otlp.Int("strava.page.added", len(missing)), otlp.Int("strava.page.updated", len(changed)))
+		if err := cache.upsertActivities(page); err != nil {
+			return err
+		}
+		if seen != nil {
+			for _, a := range page {
+				seen[a.Id] = true
+			}
+		}
+
+		last, err := time.Parse(time.RFC3339, page[len(page)-1].StartDate)
+		if err != nil {
+			return err
+		}
+
+		// Step back a second so activities sharing the boundary start time
+		// are fetched again rather than skipped
+		if err := cache.setState(syncResumeKey, strconv.FormatInt(last.Unix()-1, 10)); err != nil {
+			return err
+		}
+		saved++
+		return nil
+	})
+	if err != nil && saved > 0 {
+		return added, changes, partialSyncError{err: err, pages: saved}
+	}
+	if err != nil {
+		return added, changes, err
+	}
+
+	if seen != nil {
+		deleted, err := markUnseenDeleted(logger, cache, seen)
+		if err != nil {
+			return added, changes, err
+		}
+		if err := cache.recordChanges(run.Started, deleted); err != nil {
+			return added, changes, err
+		}
+		for _, change := range deleted {
+			logger.Printf("Changed on Strava: %s\n", change)
+		}
+		changes = append(changes, deleted...)
+	}
+
+	if err := cache.setState(syncResumeKey, ""); err != nil {
+		return added, changes, err
+	}
+	return added, changes, cache.setState(lastSyncKey, time.Now().UTC().Format(time.RFC3339))
+}
+
+// syncFailure explains an error from syncActivities that came from the
+// command's context being done rather than from the sync itself
+func syncFailure(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("timed out after %s: %w", viper.GetDuration("timeout"), err)
+	case ctx.Err() != nil:
+		err = fmt.Errorf("interrupted: %w", err)
+	}
+	return fmt.Errorf("error retrieving activities: %w", err)
+}
+
+// syncReport is the JSON summary sync --once prints to stdout
+type syncReport struct {
+	// Skipped is set when another sync held the lock
+	Skipped bool `json:"skipped,omitempty"`
+	// Partial is set when the sync failed after storing some pages
+	Partial  bool             `json:"partial,omitempty"`
+	Count    int              `json:"count"`
+	Added    []syncedActivity `json:"added"`
+	Changes  []activityChange `json:"changes"`
+	APICalls int              `json:"api_calls"`
+	Seconds  float64          `json:"duration_seconds"`
+}
+
+type syncedActivity struct {
+	Id         int     `json:"id"`
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	StartDate  string  `json:"start_date"`
+	Distance   float64 `json:"distance"`
+	MovingTime int     `json:"moving_time"`
+}
+
+func newSyncCmd() *cobra.Command {
+	var once bool
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Fetch activities into the cache without summarizing them",
+		Long: `Syncs every activity into the cache, resuming an interrupted fetch first.
+Only one sync runs at a time per cache: a run that finds the lock file next
+to the cache held exits straight away.
+
+--once is meant for cron and Kubernetes CronJobs. It fetches only the
+activities started after the newest cached one and prints a JSON summary of
+the new activities to stdout, so rerunning it is cheap and safe. Failures
+exit with the codes described in the README, and a run skipped because
+another holds the lock exits 0 with "skipped": true. A sync that fails
+after storing some pages still prints its summary, with "partial": true,
+before exiting with the partial sync code.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			config.Settings.Fetch.Incremental = once
+
+			report, err := syncOnce(strava.WithPriority(ctx, strava.PrioritySync), logger, config, newClient(ctx, logger, config))
+			if err != nil && !report.Partial {
+				fatal(logger, err)
+			}
+			if report.Skipped {
+				logger.Println("Another sync is running, skipping this one")
+			} else {
+				logger.Printf("Added %d activities with %d API calls\n", report.Count, report.APICalls)
+			}
+			if once {
+				if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+					logger.Fatal(err)
+				}
+			}
+			if err != nil {
+				fatal(logger, err)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&once, "once", false, "sync only new activities and print a JSON summary, for scheduled jobs")
+	return cmd
+}
+
+// syncOnce runs one sync with client under the cache's lock file,
+// releasing it and closing the cache before returning so the caller may
+// exit on an error. A sync that fails partway returns its report of what
+// it stored, marked partial, with the error.
+func syncOnce(ctx context.Context, logger *log.Logger, config envVars, client *strava.Client) (syncReport, error) {
+	started, calls := time.Now(), apiUsage.count()
+	path := config.StravaCachePath
+	if path == "" {
+		path = defaultCachePath
+	}
+	unlock, err := lockFile(path + ".lock")
+	if errors.Is(err, errSyncRunning) {
+		return syncReport{Skipped: true, Added: []syncedActivity{}, Changes: []activityChange{}}, nil
+	}
+	if err != nil {
+		return syncReport{}, err
+	}
+	defer unlock()
+
+	if err := client.Authenticate(ctx); err != nil {
+		return syncReport{}, authError{err}
+	}
+
+	cache, err := openCache(path)
+	if err != nil {
+		return syncReport{}, err
+	}
+	defer cache.Close()
+
+	added, changes, err := syncActivities(ctx, logger, client, cache, config.Settings.Fetch)
+	partial := errors.As(err, new(partialSyncError))
+	if err != nil && !partial {
+		return syncReport{}, syncFailure(ctx, err)
+	}
+
+	report := syncReport{Partial: partial, Count: len(added), Added: make([]syncedActivity, len(added)), Changes: changes, APICalls: apiUsage.count() - calls}
+	for i, a := range added {
+		report.Added[i] = syncedActivity{Id: a.Id, Name: a.Name, Type: a.Type, StartDate: a.StartDate, Distance: a.Distance, MovingTime: a.MovingTime}
+	}
+	report.Seconds = time.Since(started).Round(time.Millisecond).Seconds()
+	if partial {
+		return report, syncFailure(ctx, err)
+	}
+	return report, nil
+}
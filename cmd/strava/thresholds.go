@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// thresholdDuration is the test length the estimates are based on
+	thresholdDuration = 20 * 60
+	// thresholdFactor scales a 20 minute best to a one hour threshold
+	thresholdFactor = 0.95
+)
+
+// bestAverage returns the highest mean of data over any window of the
+// given seconds, or false when the activity is shorter than that
+func bestAverage(times, data []int, seconds int) (float64, bool) {
+	n := len(times)
+	if len(data) < n {
+		n = len(data)
+	}
+
+	// Allow a few seconds of slack for recording gaps on long windows
+	slack := min(5, seconds/100)
+
+	best, found := 0.0, false
+	sum, start := 0, 0
+	for i := 0; i < n; i++ {
+		sum += data[i]
+		for times[i]-times[start] > seconds {
+			sum -= data[start]
+			start++
+		}
+		if times[i]-times[start] < seconds-slack {
+			continue
+		}
+		if avg := float64(sum) / float64(i-start+1); avg > best {
+			best, found = avg, true
+		}
+	}
+	return best, found
+}
+
+// setStreamPeaks stores the best 20 minute power and heart rate of an
+// activity; missing streams are stored as NULL
+func (c *activityCache) setStreamPeaks(id int, startDate string, streams strava.Streams) error {
+	var watts, hr sql.NullFloat64
+	if streams.Time != nil && streams.Watts != nil {
+		watts.Float64, watts.Valid = bestAverage(streams.Time.Data, streams.Watts.Data, thresholdDuration)
+	}
+	if streams.Time != nil && streams.Heartrate != nil {
+		hr.Float64, hr.Valid = bestAverage(streams.Time.Data, streams.Heartrate.Data, thresholdDuration)
+	}
+
+	_, err := c.db.Exec(`INSERT INTO stream_peaks (activity_id, start_date, watts_20m, heartrate_20m) VALUES (?, ?, ?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET start_date = excluded.start_date, watts_20m = excluded.watts_20m, heartrate_20m = excluded.heartrate_20m`,
+		id, startDate, watts, hr)
+	return err
+}
+
+// streamPeak is the best 20 minute power and heart rate of an activity,
+// zero when not recorded
+type streamPeak struct {
+	StartDate string
+	Watts     float64
+	Heartrate float64
+}
+
+func (c *activityCache) streamPeaks() (map[int]streamPeak, error) {
+	rows, err := c.db.Query(`SELECT activity_id, start_date, watts_20m, heartrate_20m FROM stream_peaks`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	peaks := make(map[int]streamPeak)
+	for rows.Next() {
+		var id int
+		var p streamPeak
+		var watts, hr sql.NullFloat64
+		if err := rows.Scan(&id, &p.StartDate, &watts, &hr); err != nil {
+			return nil, err
+		}
+		p.Watts, p.Heartrate = watts.Float64, hr.Float64
+		peaks[id] = p
+	}
+	return peaks, rows.Err()
+}
+
+// thresholdWeek holds the estimates as of the end of a week
+type thresholdWeek struct {
+	Start time.Time
+	// BestWatts and BestHR are the week's own 20 minute bests
+	BestWatts, BestHR float64
+	// FTP and LTHR are estimated from the best efforts within the window
+	FTP, LTHR float64
+}
+
+// thresholdHistory estimates FTP and threshold HR for each of the last
+// weeks, using the best 20 minute efforts over a trailing window of weeks
+func thresholdHistory(peaks map[int]streamPeak, now time.Time, weeks, window int) []thresholdWeek {
+	first := thresholdStart(now, weeks, window)
+
+	// Bests per week, oldest first
+	bests := make([]thresholdWeek, weeks+window-1)
+	for i := range bests {
+		bests[i].Start = first.AddDate(0, 0, 7*i)
+	}
+	for _, p := range peaks {
+		start, err := time.Parse(time.RFC3339, p.StartDate)
+		if err != nil || start.Before(first) {
+			continue
+		}
+		i := int(start.Sub(first).Hours() / (24 * 7))
+		if i >= len(bests) {
+			continue
+		}
+		if p.Watts > bests[i].BestWatts {
+			bests[i].BestWatts = p.Watts
+		}
+		if p.Heartrate > bests[i].BestHR {
+			bests[i].BestHR = p.Heartrate
+		}
+	}
+
+	history := make([]thresholdWeek, 0, weeks)
+	for i := window - 1; i < len(bests); i++ {
+		week := bests[i]
+		for _, b := range bests[i-window+1 : i+1] {
+			if b.BestWatts*thresholdFactor > week.FTP {
+				week.FTP = b.BestWatts * thresholdFactor
+			}
+			if b.BestHR*thresholdFactor > week.LTHR {
+				week.LTHR = b.BestHR * thresholdFactor
+			}
+		}
+		history = append(history, week)
+	}
+	return history
+}
+
+// thresholdStart is the first day of the oldest week a history of weeks
+// with the given window draws on
+func thresholdStart(now time.Time, weeks, window int) time.Time {
+	return weekStart(now).AddDate(0, 0, -7*(weeks+window-2))
+}
+
+func newThresholdsCmd() *cobra.Command {
+	var weeks, window, limit int
+
+	cmd := &cobra.Command{
+		Use:   "thresholds",
+		Short: "Estimate FTP and threshold heart rate from recent best efforts",
+		Long: `Estimates functional threshold power as 95% of the best 20 minute average
+power, and threshold heart rate as 95% of the best 20 minute average heart
+rate, over a trailing window of weeks. The table shows how the estimates
+moved week by week; use them for training.ftp in the settings file.
+
+Best efforts come from the activity streams, which are fetched once and
+stored in the cache alongside the fitness scores.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			now := time.Now().UTC()
+			if weeks < 1 || window < 1 {
+				logger.Fatal("--weeks and --window must be at least 1")
+			}
+			from := thresholdStart(now, weeks, window)
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			recent := make([]strava.Activity, 0)
+			for _, a := range activities {
+				if inDateRange(a, from, time.Time{}) {
+					recent = append(recent, a)
+				}
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			if _, err := scoreActivities(ctx, logger, client, cache, config.Settings.Training, config.Settings.Streams, recent, limit); err != nil {
+				logger.Fatal(err)
+			}
+
+			peaks, err := cache.streamPeaks()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			history := thresholdHistory(peaks, now, weeks, window)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "WEEK\tBEST 20MIN W\tFTP\tCHANGE\tBEST 20MIN HR\tLTHR\tCHANGE")
+			var prev thresholdWeek
+			for i, week := range history {
+				ftpChange, hrChange := "", ""
+				if i > 0 {
+					ftpChange = thresholdChange(week.FTP, prev.FTP)
+					hrChange = thresholdChange(week.LTHR, prev.LTHR)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", week.Start.Format(time.DateOnly),
+					optional(week.BestWatts), optional(week.FTP), ftpChange,
+					optional(week.BestHR), optional(week.LTHR), hrChange)
+				prev = week
+			}
+			w.Flush()
+
+			if prev.FTP > 0 && config.Settings.Training.FTP > 0 && int(prev.FTP+0.5) != config.Settings.Training.FTP {
+				fmt.Printf("\nEstimated FTP %.0f W differs from training.ftp %d W\n", prev.FTP, config.Settings.Training.FTP)
+			}
+		},
+	}
+
+	cmd.Flags().IntVar(&weeks, "weeks", 12, "number of weeks to show")
+	cmd.Flags().IntVar(&window, "window", 6, "weeks of best efforts each estimate draws on")
+	cmd.Flags().IntVar(&limit, "limit", defaultScoreLimit, "maximum streams requests")
+
+	return cmd
+}
+
+// thresholdChange renders the week on week change of an estimate
+func thresholdChange(current, previous float64) string {
+	if current == 0 || previous == 0 || int(current+0.5) == int(previous+0.5) {
+		return ""
+	}
+	return fmt.Sprintf("%+.0f", current-previous)
+}
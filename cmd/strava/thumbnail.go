@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultThumbnailSize = 128
+	// thumbnailPadding is the margin left around the route, in pixels
+	thumbnailPadding = 4
+)
+
+// thumbnailColor is Strava orange
+var thumbnailColor = color.RGBA{R: 0xfc, G: 0x4c, B: 0x02, A: 0xff}
+
+func newThumbnailsCmd() *cobra.Command {
+	var dir, format string
+	var size int
+	var refresh bool
+
+	cmd := &cobra.Command{
+		Use:   "thumbnails",
+		Short: "Draw a small route image for every cached activity with a GPS track",
+		Long: `Renders each cached activity's summary polyline as <dir>/<id>.svg or .png,
+for embedding in Markdown reports and web pages. Existing images are kept
+unless --refresh is set, so re-runs only draw new activities.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			if size < 16 {
+				logger.Fatal("--size must be at least 16 pixels")
+			}
+
+			var render func([]strava.LatLng, int) ([]byte, error)
+			switch format {
+			case "svg":
+				render = renderThumbnailSVG
+			case "png":
+				render = renderThumbnailPNG
+			default:
+				logger.Fatalf("unknown thumbnail format %q, expected svg or png\n", format)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			drawn := 0
+			err = cache.eachActivity(func(a strava.Activity) error {
+				path := filepath.Join(dir, strconv.Itoa(a.Id)+"."+format)
+				if _, err := os.Stat(path); err == nil && !refresh {
+					return nil
+				}
+
+				points, err := a.Map.Points()
+				if err != nil {
+					return fmt.Errorf("activity %d: %w", a.Id, err)
+				}
+				if len(points) < 2 {
+					return nil
+				}
+
+				data, err := render(points, size)
+				if err != nil {
+					return err
+				}
+				drawn++
+				return writeFile(path, data)
+			})
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Drew %d thumbnails in %s\n", drawn, dir)
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "thumbnails", "directory to write the images to")
+	cmd.Flags().StringVar(&format, "format", "svg", "image format: svg or png")
+	cmd.Flags().IntVar(&size, "size", defaultThumbnailSize, "width and height in pixels")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "redraw images that already exist")
+
+	return cmd
+}
+
+// projectRoute maps points onto a size x size square, keeping the aspect
+// ratio. Longitude is scaled by the cosine of the latitude so routes far
+// from the equator are not stretched.
+func projectRoute(points []strava.LatLng, size int) [][2]float64 {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+
+	scale := math.Cos(points[0][0] * math.Pi / 180)
+	xy := make([][2]float64, len(points))
+	for i, p := range points {
+		x, y := p[1]*scale, -p[0]
+		xy[i] = [2]float64{x, y}
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	inner := float64(size - 2*thumbnailPadding)
+	span := math.Max(maxX-minX, maxY-minY)
+	if span == 0 {
+		span = 1
+	}
+	offsetX := (inner - (maxX-minX)/span*inner) / 2
+	offsetY := (inner - (maxY-minY)/span*inner) / 2
+
+	for i := range xy {
+		xy[i][0] = thumbnailPadding + offsetX + (xy[i][0]-minX)/span*inner
+		xy[i][1] = thumbnailPadding + offsetY + (xy[i][1]-minY)/span*inner
+	}
+	return xy
+}
+
+func renderThumbnailSVG(points []strava.LatLng, size int) ([]byte, error) {
+	var path strings.Builder
+	for i, p := range projectRoute(points, size) {
+		if i == 0 {
+			fmt.Fprintf(&path, "M%.1f %.1f", p[0], p[1])
+			continue
+		}
+		fmt.Fprintf(&path, "L%.1f %.1f", p[0], p[1])
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, size, size, size, size)
+	fmt.Fprintf(&buf, `<path d="%s" fill="none" stroke="#fc4c02" stroke-width="2" stroke-linejoin="round" stroke-linecap="round"/>`, path.String())
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+func renderThumbnailPNG(points []strava.LatLng, size int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	xy := projectRoute(points, size)
+	for i := 1; i < len(xy); i++ {
+		drawLine(img, xy[i-1], xy[i])
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLine plots a segment about two pixels thick on a transparent background
+func drawLine(img *image.RGBA, from, to [2]float64) {
+	steps := int(math.Ceil(math.Max(math.Abs(to[0]-from[0]), math.Abs(to[1]-from[1]))))
+	if steps == 0 {
+		steps = 1
+	}
+	for s := 0; s <= steps; s++ {
+		t := float64(s) / float64(steps)
+		x := int(math.Round(from[0] + (to[0]-from[0])*t))
+		y := int(math.Round(from[1] + (to[1]-from[1])*t))
+		img.SetRGBA(x, y, thumbnailColor)
+		img.SetRGBA(x+1, y, thumbnailColor)
+		img.SetRGBA(x, y+1, thumbnailColor)
+	}
+}
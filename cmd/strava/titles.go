@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// defaultTitlePattern matches the names Strava gives new activities, such
+// as "Morning Run" or "Lunch Ride"
+const defaultTitlePattern = `^(Morning|Lunch|Afternoon|Evening|Night) [A-Za-z ]+$`
+
+// titleSettings rewrites default activity names, and optionally empty
+// descriptions, from Go templates executed on titleData
+type titleSettings struct {
+	// Match selects the names to rewrite, defaultTitlePattern when empty
+	Match       string `mapstructure:"match"`
+	Template    string `mapstructure:"template"`
+	Description string `mapstructure:"description"`
+
+	match       *regexp.Regexp
+	template    *template.Template
+	description *template.Template
+}
+
+func (t *titleSettings) compile() error {
+	if t.Template == "" && t.Description == "" {
+		return nil
+	}
+	pattern := t.Match
+	if pattern == "" {
+		pattern = defaultTitlePattern
+	}
+	var err error
+	if t.match, err = regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("titles.match: %w", err)
+	}
+	if t.Template != "" {
+		if t.template, err = template.New("title").Parse(t.Template); err != nil {
+			return fmt.Errorf("titles.template: %w", err)
+		}
+	}
+	if t.Description != "" {
+		if t.description, err = template.New("description").Parse(t.Description); err != nil {
+			return fmt.Errorf("titles.description: %w", err)
+		}
+	}
+	return nil
+}
+
+// enabled reports whether any template is configured
+func (t titleSettings) enabled() bool {
+	return t.template != nil || t.description != nil
+}
+
+// titleData is the activity as seen by the title templates, with
+// distances in the configured units
+type titleData struct {
+	Name     string
+	Type     string
+	Date     string
+	Time     string
+	Distance float64
+	Unit     string
+	Pace     string
+	Moving   string
+	Elapsed  string
+	// Elevation is in feet with miles and meters with km
+	Elevation     float64
+	ElevationUnit string
+	// PRs are the segments the activity set a record on, BestEfforts the
+	// running distances
+	PRs         []string
+	BestEfforts []string
+	// Weather is the stored weather at the start, such as "54°F, wind 8
+	// mph, overcast", empty unless weather enrichment is enabled
+	Weather string
+}
+
+func newTitleData(a strava.Activity, output outputSettings, prs, bests []string) titleData {
+	distance, unit := output.convert(a.Distance)
+	elevation, elevationUnit := output.convertElevation(a.TotalElevationGain)
+	d := titleData{
+		Name:          a.Name,
+		Type:          a.Type,
+		Distance:      distance,
+		Unit:          unit,
+		Pace:          formatPace(a.MovingTime, distance, unit),
+		Moving:        formatDuration(a.MovingTime),
+		Elapsed:       formatDuration(a.ElapsedTime),
+		Elevation:     elevation,
+		ElevationUnit: elevationUnit,
+		PRs:           prs,
+		BestEfforts:   bests,
+	}
+	if start, err := time.Parse(time.RFC3339, a.StartDateLocal); err == nil {
+		d.Date, d.Time = start.Format(time.DateOnly), start.Format("15:04")
+	}
+	return d
+}
+
+// activityRecords returns the segments an activity set a PR on and the
+// running distances where it set a best effort, as recorded in the cache
+func (c *activityCache) activityRecords(id int) (prs, bests []string, err error) {
+	rows, err := c.db.Query(`SELECT segment_name FROM segment_prs WHERE activity_id = ? ORDER BY start_date`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, nil, err
+		}
+		prs = append(prs, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = c.db.Query(`SELECT b.name FROM best_efforts b
+		WHERE b.activity_id = ? AND NOT EXISTS (
+			SELECT 1 FROM best_efforts e WHERE e.name = b.name AND e.start_date < b.start_date AND e.elapsed_time <= b.elapsed_time)
+		ORDER BY b.distance`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, nil, err
+		}
+		bests = append(bests, name)
+	}
+	return prs, bests, rows.Err()
+}
+
+// titleChange is a rename or new description for an activity
+type titleChange struct {
+	Activity strava.Activity
+	Update   strava.UpdatableActivity
+}
+
+func (c titleChange) String() string {
+	s := fmt.Sprintf("%d %s %q", c.Activity.Id, c.Activity.StartDate, c.Activity.Name)
+	if c.Update.Name != nil {
+		s += fmt.Sprintf(" -> %q", *c.Update.Name)
+	}
+	if c.Update.Description != nil {
+		s += fmt.Sprintf(" with description %q", *c.Update.Description)
+	}
+	return s
+}
+
+// planTitles renders new names for activities whose name matches the
+// titles pattern, and descriptions for those without one
+func planTitles(titles titleSettings, output outputSettings, cache *activityCache, activities []strava.Activity) ([]titleChange, error) {
+	changes := make([]titleChange, 0)
+	for _, a := range activities {
+		if !titles.match.MatchString(strings.TrimSpace(a.Name)) {
+			continue
+		}
+		prs, bests, err := cache.activityRecords(a.Id)
+		if err != nil {
+			return nil, err
+		}
+		data := newTitleData(a, output, prs, bests)
+		if w, ok, err := cache.activityWeather(a.Id); err != nil {
+			return nil, err
+		} else if ok {
+			data.Weather = w.format(output)
+		}
+
+		change := titleChange{Activity: a}
+		if titles.template != nil {
+			name, err := renderTitle(titles.template, data)
+			if err != nil {
+				return nil, err
+			}
+			if name != "" && name != a.Name {
+				change.Update.Name = &name
+			}
+		}
+		if titles.description != nil && a.Description == "" {
+			description, err := renderTitle(titles.description, data)
+			if err != nil {
+				return nil, err
+			}
+			if description != "" {
+				change.Update.Description = &description
+			}
+		}
+		if change.Update.Name != nil || change.Update.Description != nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func renderTitle(t *template.Template, data titleData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%s template: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// applyTitles sends each change to Strava and refreshes the cached activity
+func applyTitles(ctx context.Context, client strava.ClientInterface, cache *activityCache, changes []titleChange) error {
+	for _, change := range changes {
+		updated, err := client.UpdateActivity(ctx, int64(change.Activity.Id), change.Update)
+		if err != nil {
+			return fmt.Errorf("renaming activity %d: %w", change.Activity.Id, err)
+		}
+		if err := cache.upsertActivities([]strava.Activity{updated.Activity}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enrichTitles renames the activities new in this sync. It runs after PR
+// tracking so the templates see the records set. Failures are logged
+// rather than fatal.
+func enrichTitles(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, titles titleSettings, output outputSettings, added []strava.Activity) {
+	changes, err := planTitles(titles, output, cache, added)
+	if err == nil && len(changes) > maxSyncUpdates {
+		logger.Printf("Skipping renaming of %d activities, run `retitle` to catch up\n", len(changes))
+		return
+	}
+	if err == nil {
+		err = applyTitles(ctx, client, cache, changes)
+	}
+	if err != nil {
+		logger.Printf("Renaming activities: %v\n", err)
+		return
+	}
+	for _, change := range changes {
+		logger.Printf("Renamed %s\n", change)
+	}
+}
+
+func newRetitleCmd() *cobra.Command {
+	var after, before string
+
+	cmd := &cobra.Command{
+		Use:   "retitle",
+		Short: "Rewrite default activity names with the titles template",
+		Long: `Renames cached activities started within --after and --before whose name
+matches titles.match (Strava's "Morning Run" style names by default), using
+titles.template, and fills empty descriptions from titles.description.
+New activities are renamed during every sync; use this to preview the
+templates with --dry-run, which prints the updates instead of sending them,
+or to rename older activities.
+
+Updating activities needs a refresh token with the activity:write scope.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			titles := config.Settings.Titles
+			if !titles.enabled() {
+				logger.Fatal("Set titles.template or titles.description in the settings file")
+			}
+
+			from, to, err := parseDateRange(after, before)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			selected := make([]strava.Activity, 0)
+			for _, a := range activities {
+				if inDateRange(a, from, to) {
+					selected = append(selected, a)
+				}
+			}
+
+			changes, err := planTitles(titles, config.Settings.Output, cache, selected)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			for _, change := range changes {
+				fmt.Println(change)
+			}
+			if len(changes) == 0 {
+				logger.Printf("%d activities to rename\n", len(changes))
+				return
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			if err := applyTitles(ctx, client, cache, changes); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Renamed %d activities\n", len(changes))
+		},
+	}
+
+	cmd.Flags().StringVar(&after, "after", "", "first day to include, YYYY-MM-DD")
+	cmd.Flags().StringVar(&before, "before", "", "last day to include, YYYY-MM-DD")
+
+	return cmd
+}
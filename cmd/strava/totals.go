@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// Aggregation periods for totals and goals
+const (
+	periodWeek  = "week"
+	periodMonth = "month"
+	periodYear  = "year"
+)
+
+// everestHeight is the height of Mount Everest in meters, the climbing
+// needed for an Everesting
+const everestHeight = 8848.86
+
+func validPeriod(period string) bool {
+	return period == periodWeek || period == periodMonth || period == periodYear
+}
+
+// periodTotals sums the activities started within one week, month, or year
+type periodTotals struct {
+	Start      time.Time `json:"start"`
+	Count      int       `json:"count"`
+	Distance   float64   `json:"distance"`
+	MovingTime int       `json:"moving_time"`
+	Kilojoules float64   `json:"kilojoules"`
+	Calories   float64   `json:"calories"`
+	// ElapsedTime includes the time stopped with the recording running
+	ElapsedTime int `json:"elapsed_time"`
+	StoppedTime int `json:"stopped_time"`
+	// ElevationGain is the total climbing in meters
+	ElevationGain float64 `json:"elevation_gain"`
+	// Workout is the workout type the totals are for when aggregated by
+	// workout
+	Workout string `json:"workout,omitempty"`
+}
+
+// Everests is the climbing as a multiple of the height of Everest
+func (t periodTotals) Everests() float64 {
+	return t.ElevationGain / everestHeight
+}
+
+// periodStart returns the start of the week (Monday), month, or year
+// containing t
+func periodStart(t time.Time, period string) time.Time {
+	switch period {
+	case periodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case periodYear:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	return weekStart(t)
+}
+
+// activityCalories returns the kcal Strava estimated for an activity, from
+// the detail fetches stored in calories. Without one, rides with power
+// fall back to their kilojoules: at the roughly 24% efficiency of cycling
+// one kJ of work costs about one kcal.
+func activityCalories(a strava.Activity, calories map[int]float64) float64 {
+	if kcal, ok := calories[a.Id]; ok {
+		return kcal
+	}
+	return a.Kilojoules
+}
+
+// aggregate totals activities by week, month, or year in local time, oldest
+// period first
+func aggregate(activities []strava.Activity, period string, calories map[int]float64) []periodTotals {
+	byStart := make(map[time.Time]*periodTotals)
+	for _, a := range activities {
+		start, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			continue
+		}
+		key := periodStart(start.Local(), period)
+		t, ok := byStart[key]
+		if !ok {
+			t = &periodTotals{Start: key}
+			byStart[key] = t
+		}
+		t.Count++
+		t.Distance += a.Distance
+		t.MovingTime += a.MovingTime
+		t.ElapsedTime += a.ElapsedTime
+		t.StoppedTime += stoppedTime(a)
+		t.ElevationGain += a.TotalElevationGain
+		t.Kilojoules += a.Kilojoules
+		t.Calories += activityCalories(a, calories)
+	}
+
+	totals := make([]periodTotals, 0, len(byStart))
+	for _, t := range byStart {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Start.Before(totals[j].Start) })
+	return totals
+}
+
+// aggregateByWorkout totals activities by period and workout type, oldest
+// period first and workout types by name within one
+func aggregateByWorkout(activities []strava.Activity, period string, calories map[int]float64) []periodTotals {
+	byWorkout := make(map[string][]strava.Activity)
+	for _, a := range activities {
+		byWorkout[a.Workout()] = append(byWorkout[a.Workout()], a)
+	}
+	totals := make([]periodTotals, 0)
+	for workout, group := range byWorkout {
+		for _, t := range aggregate(group, period, calories) {
+			t.Workout = workout
+			totals = append(totals, t)
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if !totals[i].Start.Equal(totals[j].Start) {
+			return totals[i].Start.Before(totals[j].Start)
+		}
+		return totals[i].Workout < totals[j].Workout
+	})
+	return totals
+}
+
+// stoppedTime is the time an activity spent paused with the recording
+// running, such as waiting at traffic lights
+func stoppedTime(a strava.Activity) int {
+	if a.ElapsedTime <= a.MovingTime {
+		return 0
+	}
+	return a.ElapsedTime - a.MovingTime
+}
+
+// setCalories stores the calorie estimate of a detailed activity, which
+// the list endpoint does not return
+func (c *activityCache) setCalories(activity strava.DetailedActivity) error {
+	if activity.Calories <= 0 {
+		return nil
+	}
+	_, err := c.db.Exec(`INSERT INTO activity_calories (activity_id, calories) VALUES (?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET calories = excluded.calories`, activity.Id, activity.Calories)
+	return err
+}
+
+// calories returns every stored calorie estimate by activity id
+func (c *activityCache) calories() (map[int]float64, error) {
+	rows, err := c.db.Query(`SELECT activity_id, calories FROM activity_calories`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calories := make(map[int]float64)
+	for rows.Next() {
+		var id int
+		var kcal float64
+		if err := rows.Scan(&id, &kcal); err != nil {
+			return nil, err
+		}
+		calories[id] = kcal
+	}
+	return calories, rows.Err()
+}
+
+func newTotalsReportCmd() *cobra.Command {
+	var period, format, formatTemplate string
+	var byWorkout bool
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "totals",
+		Short: "Total activities, distance, time, climbing, and energy by week, month, or year",
+		Long: `Aggregates the cached activities selected by --sport, --gear, --workout,
+--after, --before, --match, and --filter by --period week (starting
+Monday), month, or year. --by-workout totals races, long runs, workouts,
+and the rest apart within each period.
+Stopped time is elapsed minus moving time, the time spent paused with the
+recording running. Climbing is also shown as a multiple of the height of
+Everest.
+
+Energy is shown as kilojoules of work, recorded by power meters, and
+kilocalories burned. Strava only returns calories with an activity's
+details, so they are known for activities fetched by fetch.track_prs or
+prs running backfill; other rides with power count their kilojoules as
+kilocalories, and the rest count nothing.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			if !validPeriod(period) {
+				logger.Fatalf("unknown period %q, expected week, month, or year\n", period)
+			}
+			if format != "table" && format != "json" {
+				logger.Fatalf("unknown format %q, expected table or json\n", format)
+			}
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			calories, err := cache.calories()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			selected, err := filterActivities(expr, activities)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			totals := aggregate(selected, period, calories)
+			if byWorkout {
+				totals = aggregateByWorkout(selected, period, calories)
+			}
+
+			if formatTemplate != "" {
+				tmpl, err := parseFormatTemplate(formatTemplate, config.Settings.Output)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				for _, t := range totals {
+					if err := writeTemplate(os.Stdout, tmpl, t); err != nil {
+						logger.Fatal(err)
+					}
+				}
+				return
+			}
+			if format == "json" {
+				printJSON(logger, totals)
+				return
+			}
+
+			output := config.Settings.Output
+			p := output.printer()
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			labels := []string{"Period", "Count", "Distance", "Moving", "Elapsed", "Stopped", "Climbing", "Everests", "kJ", "kcal"}
+			if byWorkout {
+				labels = append([]string{"Period", "Workout"}, labels[1:]...)
+			}
+			fmt.Fprintln(w, output.header(labels...))
+			for _, t := range totals {
+				distance, unit := output.convert(t.Distance)
+				elevation, elevationUnit := output.convertElevation(t.ElevationGain)
+				period := t.Start.Format(time.DateOnly)
+				if byWorkout {
+					period += "\t" + t.Workout
+				}
+				p.Fprintf(w, "%s\t%d\t%.2f %s\t%s\t%s\t%s\t%.0f %s\t%.2f\t%s\t%s\n", period, t.Count, distance, unit,
+					formatDuration(t.MovingTime), formatDuration(t.ElapsedTime), formatDuration(t.StoppedTime),
+					elevation, elevationUnit, t.Everests(), optional(t.Kilojoules), optional(t.Calories))
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&period, "period", periodWeek, "aggregate by week, month, or year")
+	cmd.Flags().BoolVar(&byWorkout, "by-workout", false, "total each workout type apart within a period")
+	selection = addSelectionFlags(cmd)
+	selection.aliasSports(cmd, "types")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+	cmd.Flags().StringVar(&formatTemplate, "format-template", "", "Go template executed per period instead of the table")
+
+	return cmd
+}
+
+func newStoppedReportCmd() *cobra.Command {
+	var formatTemplate string
+	var selection *activitySelection
+
+	cmd := &cobra.Command{
+		Use:   "stopped",
+		Short: "List activities by the time spent stopped with the recording running",
+		Long: `Lists the cached activities selected by --sport, --gear, --after, --before,
+--match, and --filter with their moving, elapsed, and stopped time,
+longest stopped first. Handy for seeing how much of a commute is spent at traffic
+lights.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			activities, err := cache.activities()
+			cache.Close()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			selected, err := filterActivities(expr, activities)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			sort.SliceStable(selected, func(i, j int) bool { return stoppedTime(selected[i]) > stoppedTime(selected[j]) })
+
+			if formatTemplate != "" {
+				tmpl, err := parseFormatTemplate(formatTemplate, config.Settings.Output)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				for _, a := range selected {
+					if err := writeTemplate(os.Stdout, tmpl, a); err != nil {
+						logger.Fatal(err)
+					}
+				}
+				return
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, config.Settings.Output.header("Date", "Name", "Moving", "Elapsed", "Stopped", "Stopped %%"))
+			for _, a := range selected {
+				share := "-"
+				if a.ElapsedTime > 0 {
+					share = fmt.Sprintf("%.0f%%", float64(stoppedTime(a))/float64(a.ElapsedTime)*100)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.StartDate, a.Name, formatDuration(a.MovingTime),
+					formatDuration(a.ElapsedTime), formatDuration(stoppedTime(a)), share)
+			}
+			w.Flush()
+		},
+	}
+
+	selection = addSelectionFlags(cmd)
+	selection.aliasSports(cmd, "types")
+	cmd.Flags().StringVar(&formatTemplate, "format-template", "", "Go template executed per activity instead of the table")
+
+	return cmd
+}
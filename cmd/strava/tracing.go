@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/otlp"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// tracer exports spans when OTEL_EXPORTER_OTLP_ENDPOINT is set. It is nil
+// otherwise, which records nothing.
+var tracer *otlp.Tracer
+
+// commandSpan is the root span of the running command
+var commandSpan *otlp.Span
+
+// startTracing starts the tracer and the command's root span, returning
+// the context carrying it
+func startTracing(cmd *cobra.Command) context.Context {
+	cfg, ok := otlp.ConfigFromEnv("strava-api")
+	if !ok {
+		return cmd.Context()
+	}
+	tracer = otlp.New(cfg)
+	ctx, span := tracer.Start(cmd.Context(), cmd.CommandPath())
+	commandSpan = span
+	return ctx
+}
+
+// stopTracing ends the root span with the command's exit code and exports
+// what is left, waiting a few seconds at most for the collector
+func stopTracing(code int) {
+	if tracer == nil {
+		return
+	}
+	commandSpan.SetAttributes(otlp.Int("process.exit.code", code))
+	commandSpan.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.Default().Printf("Exporting traces: %v\n", err)
+	}
+	tracer = nil
+}
+
+// tracingTransport records a client span for every API call, with the
+// page requested and the rate limit usage Strava reports
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+routeName(req.URL.Path),
+		otlp.String("http.request.method", req.Method),
+		otlp.String("url.path", req.URL.Path),
+		otlp.String("server.address", req.URL.Host))
+	span.SetKind(otlp.KindClient)
+	defer span.End()
+	if page, err := strconv.Atoi(req.URL.Query().Get("page")); err == nil {
+		span.SetAttributes(otlp.Int("strava.page", page))
+	}
+
+	res, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(otlp.Int("http.response.status_code", res.StatusCode))
+	if usage, ok := strava.ParseRateLimit(res.Header); ok {
+		span.SetAttributes(
+			otlp.Int("strava.rate_limit.short_used", usage.ShortUsed),
+			otlp.Int("strava.rate_limit.short_limit", usage.ShortLimit),
+			otlp.Int("strava.rate_limit.daily_used", usage.DailyUsed),
+			otlp.Int("strava.rate_limit.daily_limit", usage.DailyLimit))
+	}
+	if res.StatusCode >= 400 {
+		span.RecordError(errStatus(res.Status))
+	}
+	return res, nil
+}
+
+// errStatus is an HTTP error status as a span error
+type errStatus string
+
+func (e errStatus) Error() string { return string(e) }
+
+// routeName replaces the ids in an API path with {id}, so span names stay
+// few enough to group, e.g. /activities/{id}/streams
+func routeName(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if _, err := strconv.ParseInt(p, 10, 64); err == nil {
+			parts[i] = "{id}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// ctlDays and atlDays are the time constants of fitness (chronic
+	// training load) and fatigue (acute training load)
+	ctlDays = 42
+	atlDays = 7
+	// npWindow is the rolling average window of normalized power, in seconds
+	npWindow = 30
+	// maxSampleGap caps the time a single stream sample counts for, so
+	// pauses with the recording running do not add load
+	maxSampleGap = 30
+	// defaultScoreLimit bounds the stream requests of one fitness run
+	defaultScoreLimit = 100
+)
+
+// Load methods stored with each score
+const (
+	loadPower = "tss"
+	loadHR    = "trimp"
+	loadNone  = "none"
+)
+
+// trainingLoad scores an activity from its streams: power based TSS when
+// the activity has power and an FTP is configured, otherwise Banister's
+// heart rate TRIMP. It returns loadNone when neither applies.
+func trainingLoad(training trainingSettings, activity strava.Activity, streams strava.Streams) (float64, string) {
+	if training.FTP > 0 && streams.Watts != nil && streams.Time != nil {
+		np := normalizedPower(streams)
+		if np > 0 {
+			intensity := np / float64(training.FTP)
+			return float64(activity.MovingTime) * np * intensity / (float64(training.FTP) * 3600) * 100, loadPower
+		}
+	}
+	if training.MaxHR > 0 && streams.Heartrate != nil && streams.Time != nil {
+		return trimp(training, streams), loadHR
+	}
+	return 0, loadNone
+}
+
+// normalizedPower is the fourth root of the mean fourth power of the 30
+// second rolling average power
+func normalizedPower(streams strava.Streams) float64 {
+	times, watts := streams.Time.Data, streams.Watts.Data
+	n := len(times)
+	if len(watts) < n {
+		n = len(watts)
+	}
+
+	var sum, fourth float64
+	var count int
+	start := 0
+	for i := 0; i < n; i++ {
+		sum += float64(watts[i])
+		for times[i]-times[start] >= npWindow {
+			sum -= float64(watts[start])
+			start++
+		}
+		if times[i] < npWindow {
+			continue
+		}
+		avg := sum / float64(i-start+1)
+		fourth += math.Pow(avg, 4)
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return math.Pow(fourth/float64(count), 0.25)
+}
+
+// trimp is Banister's training impulse: minutes weighted by an
+// exponential of the heart rate reserve used
+func trimp(training trainingSettings, streams strava.Streams) float64 {
+	a, b := 0.64, 1.92
+	if training.Sex == "female" {
+		a, b = 0.86, 1.67
+	}
+	rest := float64(training.RestingHR)
+	reserve := float64(training.MaxHR) - rest
+
+	times, hr := streams.Time.Data, streams.Heartrate.Data
+	n := len(times)
+	if len(hr) < n {
+		n = len(hr)
+	}
+
+	total := 0.0
+	for i := 1; i < n; i++ {
+		if streams.Moving != nil && i < len(streams.Moving.Data) && !streams.Moving.Data[i] {
+			continue
+		}
+		dt := times[i] - times[i-1]
+		if dt <= 0 {
+			continue
+		}
+		if dt > maxSampleGap {
+			dt = maxSampleGap
+		}
+		ratio := math.Max(0, math.Min(1, (float64(hr[i])-rest)/reserve))
+		total += float64(dt) / 60 * ratio * a * math.Exp(b*ratio)
+	}
+	return total
+}
+
+// trainingLoads returns the stored score of every scored activity
+func (c *activityCache) trainingLoads() (map[int]float64, error) {
+	rows, err := c.db.Query(`SELECT activity_id, score FROM training_load`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loads := make(map[int]float64)
+	for rows.Next() {
+		var id int
+		var score float64
+		if err := rows.Scan(&id, &score); err != nil {
+			return nil, err
+		}
+		loads[id] = score
+	}
+	return loads, rows.Err()
+}
+
+func (c *activityCache) setTrainingLoad(id int, method string, score float64) error {
+	_, err := c.db.Exec(`INSERT INTO training_load (activity_id, method, score) VALUES (?, ?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET method = excluded.method, score = excluded.score`, id, method, score)
+	return err
+}
+
+func (c *activityCache) clearTrainingLoads() error {
+	_, err := c.db.Exec(`DELETE FROM training_load`)
+	return err
+}
+
+// fitnessDay is the training load model on one day
+type fitnessDay struct {
+	Date time.Time
+	Load float64
+	// CTL is fitness, ATL fatigue, and TSB form: yesterday's fitness minus
+	// yesterday's fatigue
+	CTL, ATL, TSB float64
+}
+
+// fitnessCurve rolls daily load up into CTL, ATL, and TSB from the first
+// activity through today
+func fitnessCurve(activities []strava.Activity, loads map[int]float64, today time.Time) []fitnessDay {
+	daily := make(map[string]float64)
+	var first time.Time
+	for _, a := range activities {
+		start, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			continue
+		}
+		day := start.Format(time.DateOnly)
+		daily[day] += loads[a.Id]
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+
+	days := make([]fitnessDay, 0)
+	var ctl, atl float64
+	end := today.Truncate(24 * time.Hour)
+	for d := first.Truncate(24 * time.Hour); !d.After(end); d = d.AddDate(0, 0, 1) {
+		load := daily[d.Format(time.DateOnly)]
+		tsb := ctl - atl
+		ctl += (load - ctl) / ctlDays
+		atl += (load - atl) / atlDays
+		days = append(days, fitnessDay{Date: d, Load: load, CTL: ctl, ATL: atl, TSB: tsb})
+	}
+	return days
+}
+
+// scoreActivities fetches streams for activities without a stored score
+// or stream peaks, newest first, up to limit requests, and stores both.
+// Activities without heart rate or power are scored as zero without a
+// request.
+func scoreActivities(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, training trainingSettings, streamSettings streamSettings, activities []strava.Activity, limit int) (int, error) {
+	loads, err := cache.trainingLoads()
+	if err != nil {
+		return 0, err
+	}
+	peaks, err := cache.streamPeaks()
+	if err != nil {
+		return 0, err
+	}
+
+	// Without training settings there is nothing to score yet, only peaks
+	needScore := training.FTP > 0 || training.MaxHR > 0
+
+	pending := make([]strava.Activity, 0)
+	for _, a := range activities {
+		_, scored := loads[a.Id]
+		_, peaked := peaks[a.Id]
+		if (needScore && !scored) || !peaked {
+			pending = append(pending, a)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].StartDate > pending[j].StartDate })
+
+	requests := 0
+	for _, a := range pending {
+		if !a.HasHeartrate && !a.DeviceWatts {
+			if needScore {
+				if err := cache.setTrainingLoad(a.Id, loadNone, 0); err != nil {
+					return requests, err
+				}
+			}
+			if err := cache.setStreamPeaks(a.Id, a.StartDate, strava.Streams{}); err != nil {
+				return requests, err
+			}
+			continue
+		}
+		if requests >= limit {
+			logger.Printf("Scored %d activities, rerun to score the remaining ones\n", requests)
+			break
+		}
+
+		streams, err := storedStreams(strava.WithPriority(ctx, strava.PriorityBulk), client, cache, streamSettings, int64(a.Id))
+		if errors.Is(err, strava.ErrDeferred) {
+			logger.Printf("Scored %d activities, %v; rerun to score the remaining ones\n", requests, err)
+			break
+		}
+		if err != nil {
+			return requests, fmt.Errorf("activity %d: %w", a.Id, err)
+		}
+		requests++
+
+		if needScore {
+			score, method := trainingLoad(training, a, streams)
+			if err := cache.setTrainingLoad(a.Id, method, score); err != nil {
+				return requests, err
+			}
+		}
+		if err := cache.setStreamPeaks(a.Id, a.StartDate, streams); err != nil {
+			return requests, err
+		}
+		if streams.Watts != nil {
+			if err := cache.setPowerCurve(a.Id, a.StartDate, computePowerCurve(streams)); err != nil {
+				return requests, err
+			}
+		}
+	}
+	return requests, nil
+}
+
+func newFitnessCmd() *cobra.Command {
+	var days, limit int
+	var rescore bool
+
+	cmd := &cobra.Command{
+		Use:   "fitness",
+		Short: "Tabulate training load, fitness (CTL), fatigue (ATL), and form (TSB)",
+		Long: `Scores every cached activity with power based TSS (when training.ftp is
+set and the activity has power) or heart rate TRIMP (training.max_hr and
+training.resting_hr), then rolls the daily load into a 42 day fitness and 7
+day fatigue average. Form is yesterday's fitness minus fatigue.
+
+Scores are kept in the cache, so only new activities cost a streams
+request; --rescore recomputes them after changing the training settings.
+TSS and TRIMP are on similar scales but not identical, so keep to one
+method where possible.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			training := config.Settings.Training
+			if training.FTP == 0 && training.MaxHR == 0 {
+				logger.Fatal("Set training.ftp or training.max_hr and training.resting_hr in the settings file")
+			}
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			if rescore {
+				if err := cache.clearTrainingLoads(); err != nil {
+					logger.Fatal(err)
+				}
+			}
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+			if _, err := scoreActivities(ctx, logger, client, cache, training, config.Settings.Streams, activities, limit); err != nil {
+				logger.Fatal(err)
+			}
+
+			loads, err := cache.trainingLoads()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			curve := fitnessCurve(activities, loads, time.Now().UTC())
+			if len(curve) > days {
+				curve = curve[len(curve)-days:]
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "DATE\tLOAD\tFITNESS\tFATIGUE\tFORM")
+			for _, d := range curve {
+				fmt.Fprintf(w, "%s\t%.0f\t%.1f\t%.1f\t%+.1f\n", d.Date.Format(time.DateOnly), d.Load, d.CTL, d.ATL, d.TSB)
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 42, "number of days to show")
+	cmd.Flags().IntVar(&limit, "limit", defaultScoreLimit, "maximum streams requests for scoring")
+	cmd.Flags().BoolVar(&rescore, "rescore", false, "recompute every stored score")
+
+	return cmd
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTransport builds the HTTP transport for API calls. Without settings it
+// behaves like http.DefaultTransport, including honouring HTTPS_PROXY.
+func newTransport(hs httpSettings) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if hs.Proxy != "" {
+		proxy, err := url.Parse(hs.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("http.proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if hs.CAFile == "" && hs.TLSMinVersion == "" {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if hs.TLSMinVersion != "" {
+		version, ok := tlsVersions[hs.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("http.tls_min_version must be one of 1.0, 1.1, 1.2, 1.3, got %q", hs.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if hs.CAFile != "" {
+		// Trust the system roots plus the bundle, e.g. a TLS-intercepting proxy CA
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(hs.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("http.ca_file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("http.ca_file: no PEM certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// watchPerPage is the size of the page a watch poll asks for. Anything on
+// it is new, so it only needs to be big enough to tell a quiet interval
+// from a busy one.
+const watchPerPage = 10
+
+// hasNewActivities asks for the first page of activities started after the
+// newest cached one and reports whether it holds any not cached yet
+func hasNewActivities(ctx context.Context, client strava.ClientInterface, cache *activityCache) (bool, error) {
+	newest, err := cache.newestStart()
+	if err != nil {
+		return false, err
+	}
+	start, err := time.Parse(time.RFC3339, newest)
+	if err != nil {
+		return false, err
+	}
+	page, err := client.ListActivities(ctx, strava.ListActivitiesOptions{Page: 1, PerPage: watchPerPage, After: start.Unix()})
+	if err != nil {
+		return false, err
+	}
+	missing, err := cache.missingActivities(page)
+	return len(missing) > 0, err
+}
+
+// watchSync fetches the new activities, runs the steps that follow a sync
+// on them, hydrates them for kinds, and sends the summary to the
+// notification sinks
+func watchSync(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, s settings, kinds []string) error {
+	fetch := s.Fetch
+	fetch.Incremental = true
+	added, changes, err := syncActivities(ctx, logger, client, cache, fetch)
+	partial := errors.As(err, new(partialSyncError)) && ctx.Err() == nil
+	if err != nil && !partial {
+		return syncFailure(ctx, err)
+	}
+	if partial {
+		logger.Printf("Warning: %v, going on with what was fetched\n", syncFailure(ctx, err))
+	}
+	for _, a := range added {
+		logger.Printf("New activity: %s (%s, %s)\n", a.Name, a.Type, s.Output.formatDistance(a.Distance))
+	}
+
+	activities, err := cache.activities()
+	if err != nil {
+		return err
+	}
+	sum, err := processSync(ctx, logger, client, cache, s, activities, added, changes)
+	if err != nil {
+		return err
+	}
+	sum.Partial = partial
+
+	hydrateAdded(ctx, logger, client, cache, s, kinds, added)
+
+	return notify(ctx, s.Notifications, sum, s.Output)
+}
+
+// hydrateAdded queues hydration for kinds and works through the items of
+// the added activities right away, leaving the rest of the queue to the
+// hydrate command
+func hydrateAdded(ctx context.Context, logger *log.Logger, client strava.ClientInterface, cache *activityCache, s settings, kinds []string, added []strava.Activity) {
+	if len(kinds) == 0 || len(added) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, kind := range kinds {
+		if _, err := cache.enqueueHydration(kind, now); err != nil {
+			logger.Printf("Queueing %s: %v\n", kind, err)
+			return
+		}
+	}
+	items, err := cache.pendingHydration(kinds)
+	if err != nil {
+		logger.Printf("Reading the hydration queue: %v\n", err)
+		return
+	}
+
+	isAdded := make(map[int]bool, len(added))
+	for _, a := range added {
+		isAdded[a.Id] = true
+	}
+	for _, item := range items {
+		if !isAdded[item.Activity.Id] {
+			continue
+		}
+		err := hydrate(ctx, client, cache, s, item)
+		if ctx.Err() != nil || quotaExhausted(err) {
+			logger.Printf("Leaving the rest to hydrate: %v\n", err)
+			return
+		}
+		if err != nil {
+			logger.Printf("%s of activity %d: %v\n", item.Kind, item.Activity.Id, err)
+			if err := cache.failHydration(item, err); err != nil {
+				logger.Println(err)
+			}
+			continue
+		}
+		if err := cache.finishHydration(item, time.Now()); err != nil {
+			logger.Println(err)
+		}
+	}
+}
+
+func newWatchCmd() *cobra.Command {
+	var interval time.Duration
+	var kinds []string
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Poll Strava for new activities and process them as they appear",
+		Long: `Checks for new activities every --interval, for when Strava cannot reach a
+webhook server. Each check asks for a single small page of activities
+started after the newest cached one, so a quiet interval costs one API
+call. When something new appears, an incremental sync stores it, the
+steps a run takes on new activities follow (tags, PRs, weather, titles,
+and goals), the new activities are hydrated for --hydrate, and the
+summary goes to the notification sinks.
+
+The cache must hold a sync already. Edits and deletions on Strava are not
+seen; run a full sync for those. Stop it with Ctrl-C.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			if interval < time.Minute {
+				logger.Fatalf("--interval must be at least 1m, got %s", interval)
+			}
+			for _, kind := range kinds {
+				if kind != hydrateDetails && kind != hydrateStreams {
+					logger.Fatalf("unknown hydration kind %q, use %s or %s", kind, hydrateDetails, hydrateStreams)
+				}
+			}
+
+			client := newClient(ctx, logger, config)
+			authenticate(ctx, logger, client)
+
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+			// Everything would be new to an empty cache, which is a job for
+			// sync rather than for a step per activity
+			if newest, err := cache.newestStart(); err != nil {
+				logger.Fatal(err)
+			} else if newest == "" {
+				logger.Fatal("the cache is empty, run sync first")
+			}
+
+			ctx = strava.WithPriority(ctx, strava.PrioritySync)
+			logger.Printf("Checking for new activities every %s\n", interval)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				found, err := hasNewActivities(ctx, client, cache)
+				switch {
+				case ctx.Err() != nil:
+				case err != nil:
+					logger.Printf("Checking for new activities: %v\n", err)
+				case found:
+					if err := watchSync(ctx, logger, client, cache, config.Settings, kinds); err != nil {
+						logger.Println(err)
+					}
+				}
+
+				select {
+				case <-ctx.Done():
+					logger.Println("Stopped watching")
+					return
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Minute, "how often to check for new activities")
+	cmd.Flags().StringSliceVar(&kinds, "hydrate", hydrationKinds, "what to fetch for new activities: details, streams, or none with --hydrate=")
+
+	return cmd
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	openMeteoArchiveURL  = "https://archive-api.open-meteo.com/v1/archive"
+	openMeteoForecastURL = "https://api.open-meteo.com/v1/forecast"
+	// openMeteoArchiveDelay is how long the archive lags behind; newer
+	// activities are looked up in the forecast API's recent past instead
+	openMeteoArchiveDelay = 5 * 24 * time.Hour
+	// defaultWeatherLimit bounds the lookups of one backfill run
+	defaultWeatherLimit = 100
+	// temperatureBand is the width of the bands in the weather report, in °C
+	temperatureBand = 5
+)
+
+// weatherSettings enables storing the weather of new activities
+type weatherSettings struct {
+	// Provider is open-meteo, the default when Enabled is set
+	Provider string `mapstructure:"provider"`
+	Enabled  bool   `mapstructure:"enabled"`
+	// URL replaces the provider's endpoints, for self-hosted instances
+	URL string `mapstructure:"url"`
+}
+
+// weather is the conditions at the start of an activity, in metric units
+type weather struct {
+	Temperature float64 `json:"temperature"`
+	WindSpeed   float64 `json:"wind_speed"`
+	Conditions  string  `json:"conditions"`
+}
+
+// format renders the weather in the configured units
+func (w weather) format(output outputSettings) string {
+	if output.Units == "km" {
+		return fmt.Sprintf("%.0f°C, wind %.0f km/h, %s", w.Temperature, w.WindSpeed, w.Conditions)
+	}
+	return fmt.Sprintf("%.0f°F, wind %.0f mph, %s", w.Temperature*9/5+32, w.WindSpeed*0.621371, w.Conditions)
+}
+
+// weatherProvider looks up historical weather at a place and time
+type weatherProvider interface {
+	Name() string
+	Weather(ctx context.Context, lat, lng float64, at time.Time) (weather, error)
+}
+
+// newWeatherProvider returns the configured provider
+func newWeatherProvider(ws weatherSettings, hs httpSettings) (weatherProvider, error) {
+	transport, err := newTransport(hs)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: transport, Timeout: 30 * time.Second}
+
+	switch ws.Provider {
+	case "", "open-meteo":
+		p := &openMeteo{client: client, archiveURL: openMeteoArchiveURL, forecastURL: openMeteoForecastURL}
+		if ws.URL != "" {
+			p.archiveURL, p.forecastURL = ws.URL, ws.URL
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown weather.provider %q, expected open-meteo", ws.Provider)
+}
+
+// openMeteo reads hourly weather from the free Open-Meteo APIs, which need
+// no API key
+type openMeteo struct {
+	client      *http.Client
+	archiveURL  string
+	forecastURL string
+}
+
+func (p *openMeteo) Name() string {
+	return "open-meteo"
+}
+
+func (p *openMeteo) Weather(ctx context.Context, lat, lng float64, at time.Time) (weather, error) {
+	at = at.UTC()
+	endpoint := p.archiveURL
+	if time.Since(at) < openMeteoArchiveDelay {
+		endpoint = p.forecastURL
+	}
+	day := at.Format(time.DateOnly)
+	q := url.Values{
+		"latitude":        {strconv.FormatFloat(lat, 'f', 4, 64)},
+		"longitude":       {strconv.FormatFloat(lng, 'f', 4, 64)},
+		"start_date":      {day},
+		"end_date":        {day},
+		"hourly":          {"temperature_2m,wind_speed_10m,weather_code"},
+		"timezone":        {"GMT"},
+		"wind_speed_unit": {"kmh"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return weather{}, err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return weather{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return weather{}, fmt.Errorf("open-meteo returned %s", res.Status)
+	}
+
+	var body struct {
+		Hourly struct {
+			Time        []string  `json:"time"`
+			Temperature []float64 `json:"temperature_2m"`
+			WindSpeed   []float64 `json:"wind_speed_10m"`
+			WeatherCode []int     `json:"weather_code"`
+		} `json:"hourly"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return weather{}, fmt.Errorf("decoding open-meteo response: %w", err)
+	}
+
+	h := body.Hourly
+	hour := at.Truncate(time.Hour).Format("2006-01-02T15:04")
+	for i, t := range h.Time {
+		if t != hour || i >= len(h.Temperature) || i >= len(h.WindSpeed) || i >= len(h.WeatherCode) {
+			continue
+		}
+		return weather{Temperature: h.Temperature[i], WindSpeed: h.WindSpeed[i], Conditions: weatherConditions(h.WeatherCode[i])}, nil
+	}
+	return weather{}, fmt.Errorf("open-meteo has no data for %s", hour)
+}
+
+// weatherConditions describes a WMO weather interpretation code
+func weatherConditions(code int) string {
+	switch {
+	case code == 0:
+		return "clear"
+	case code <= 2:
+		return "partly cloudy"
+	case code == 3:
+		return "overcast"
+	case code == 45 || code == 48:
+		return "fog"
+	case code >= 51 && code <= 57:
+		return "drizzle"
+	case code >= 61 && code <= 67, code >= 80 && code <= 82:
+		return "rain"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "snow"
+	case code >= 95:
+		return "thunderstorm"
+	}
+	return "unknown"
+}
+
+func (c *activityCache) setWeather(id int, provider string, w weather) error {
+	_, err := c.db.Exec(`INSERT INTO activity_weather (activity_id, provider, temperature, wind_speed, conditions) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET provider = excluded.provider, temperature = excluded.temperature,
+			wind_speed = excluded.wind_speed, conditions = excluded.conditions`, id, provider, w.Temperature, w.WindSpeed, w.Conditions)
+	return err
+}
+
+// activityWeather returns the stored weather of an activity, if any
+func (c *activityCache) activityWeather(id int) (weather, bool, error) {
+	var w weather
+	err := c.db.QueryRow(`SELECT temperature, wind_speed, conditions FROM activity_weather WHERE activity_id = ?`, id).
+		Scan(&w.Temperature, &w.WindSpeed, &w.Conditions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return w, false, nil
+	}
+	return w, err == nil, err
+}
+
+// weathers returns the stored weather of every enriched activity
+func (c *activityCache) weathers() (map[int]weather, error) {
+	rows, err := c.db.Query(`SELECT activity_id, temperature, wind_speed, conditions FROM activity_weather`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weathers := make(map[int]weather)
+	for rows.Next() {
+		var id int
+		var w weather
+		if err := rows.Scan(&id, &w.Temperature, &w.WindSpeed, &w.Conditions); err != nil {
+			return nil, err
+		}
+		weathers[id] = w
+	}
+	return weathers, rows.Err()
+}
+
+// syncWeather enriches the activities new in this sync. Failures are
+// logged rather than fatal, as the weather is a bonus on top of the summary.
+func syncWeather(ctx context.Context, logger *log.Logger, ws weatherSettings, hs httpSettings, cache *activityCache, added []strava.Activity) {
+	provider, err := newWeatherProvider(ws, hs)
+	if err == nil {
+		var n int
+		n, err = enrichWeather(ctx, provider, cache, added, defaultWeatherLimit)
+		if n > 0 {
+			logger.Printf("Stored the weather of %d activities\n", n)
+		}
+	}
+	if err != nil {
+		logger.Printf("Weather enrichment: %v\n", err)
+	}
+}
+
+// enrichWeather stores the weather of activities with a start position
+// and no stored weather, newest first, making at most limit lookups
+func enrichWeather(ctx context.Context, provider weatherProvider, cache *activityCache, activities []strava.Activity, limit int) (int, error) {
+	stored, err := cache.weathers()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := make([]strava.Activity, 0)
+	for _, a := range activities {
+		if _, ok := stored[a.Id]; !ok && len(a.StartLatlng) == 2 {
+			pending = append(pending, a)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].StartDate > pending[j].StartDate })
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+
+	for i, a := range pending {
+		start, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			return i, err
+		}
+		w, err := provider.Weather(ctx, a.StartLatlng[0], a.StartLatlng[1], start)
+		if err != nil {
+			return i, fmt.Errorf("weather for activity %d: %w", a.Id, err)
+		}
+		if err := cache.setWeather(a.Id, provider.Name(), w); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+func newWeatherCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "weather",
+		Short: "Store and report the weather of your activities",
+	}
+
+	var limit int
+	backfill := &cobra.Command{
+		Use:   "backfill",
+		Short: "Look up the weather of cached activities that have none stored",
+		Long: `Looks up the temperature, wind, and conditions at the start of every cached
+activity with a GPS position, newest first, from the weather.provider
+(Open-Meteo by default), and stores them in the cache. At most --limit
+activities are looked up per run; new activities are enriched during sync
+when weather.enabled is set.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+
+			provider, err := newWeatherProvider(config.Settings.Weather, config.Settings.HTTP)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			n, err := enrichWeather(ctx, provider, cache, activities, limit)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Printf("Stored the weather of %d activities\n", n)
+		},
+	}
+	backfill.Flags().IntVar(&limit, "limit", defaultWeatherLimit, "maximum lookups")
+	cmd.AddCommand(backfill)
+
+	var selection *activitySelection
+	report := &cobra.Command{
+		Use:   "report",
+		Short: "Compare pace across temperature bands",
+		Long: `Groups the cached activities with stored weather, selected by --sport,
+--gear, --after, --before, --match, and --filter, into 5°C temperature
+bands and shows the count, average pace, and average wind of each band.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			config := loadConfig(cmd.Context(), logger)
+
+			expr, err := selection.filter()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			cache, err := openCache(config.StravaCachePath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer cache.Close()
+
+			activities, err := cache.activities()
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if activities, err = filterActivities(expr, activities); err != nil {
+				logger.Fatal(err)
+			}
+			weathers, err := cache.weathers()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			type band struct {
+				count, moving  int
+				distance, wind float64
+			}
+			bands := make(map[int]*band)
+			for _, a := range activities {
+				w, ok := weathers[a.Id]
+				if !ok {
+					continue
+				}
+				lower := int(math.Floor(w.Temperature/temperatureBand)) * temperatureBand
+				b, ok := bands[lower]
+				if !ok {
+					b = &band{}
+					bands[lower] = b
+				}
+				b.count++
+				b.moving += a.MovingTime
+				b.distance += a.Distance
+				b.wind += w.WindSpeed
+			}
+			lowers := make([]int, 0, len(bands))
+			for lower := range bands {
+				lowers = append(lowers, lower)
+			}
+			sort.Ints(lowers)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "TEMPERATURE\tCOUNT\tPACE\tAVG WIND")
+			for _, lower := range lowers {
+				b := bands[lower]
+				distance, unit := config.Settings.Output.convert(b.distance)
+				temperature := fmt.Sprintf("%d to %d°C", lower, lower+temperatureBand)
+				wind := fmt.Sprintf("%.0f km/h", b.wind/float64(b.count))
+				if config.Settings.Output.Units != "km" {
+					temperature = fmt.Sprintf("%.0f to %.0f°F", float64(lower)*9/5+32, float64(lower+temperatureBand)*9/5+32)
+					wind = fmt.Sprintf("%.0f mph", b.wind/float64(b.count)*0.621371)
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", temperature, b.count, formatPace(b.moving, distance, unit), wind)
+			}
+			w.Flush()
+		},
+	}
+	selection = addSelectionFlags(report)
+	selection.aliasSports(report, "types")
+	cmd.AddCommand(report)
+
+	return cmd
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	"github.com/spf13/cobra"
+)
+
+// webhookServer routes push subscription events to the sync of the athlete
+// they belong to. Each connected athlete has a token and a cache of their
+// own in dir, named by athlete ID.
+type webhookServer struct {
+	logger    *log.Logger
+	config    envVars
+	dir       string
+	stores    strava.AthleteTokenStore
+	scheduler *strava.Scheduler
+	// subscription is the ID of the push subscription. The callback is
+	// unauthenticated, so events naming another one are dropped.
+	subscription int64
+
+	// client, publicURL, and scopes are set when /connect is served
+	client    *strava.Client
+	publicURL string
+	scopes    []string
+
+	mu sync.Mutex
+	// states are the unexpired state parameters of authorizations in
+	// progress, with when they were issued
+	states map[string]time.Time
+	// queue holds the athletes waiting for a sync in the order their
+	// events arrived, and full those whose sync must fetch every activity
+	queue []int64
+	full  map[int64]bool
+	wake  chan struct{}
+}
+
+func newWebhookServer(logger *log.Logger, config envVars, dir string, scheduler *strava.Scheduler) *webhookServer {
+	return &webhookServer{
+		logger:    logger,
+		config:    config,
+		dir:       dir,
+		stores:    strava.NewDirTokenStore(dir),
+		scheduler: scheduler,
+		full:      make(map[int64]bool),
+		wake:      make(chan struct{}, 1),
+		states:    make(map[string]time.Time),
+	}
+}
+
+func (s *webhookServer) handle(event strava.WebhookEvent) {
+	if event.SubscriptionID != s.subscription {
+		s.logger.Printf("Ignoring an event for subscription %d, expected %d\n", event.SubscriptionID, s.subscription)
+		return
+	}
+	switch {
+	case event.Deauthorized():
+		// Strava wants an answer within two seconds, and confirming the
+		// deauthorization takes API calls
+		go s.deauthorize(context.Background(), event.OwnerID)
+	case event.ObjectType == "activity":
+		// A new activity is found by an incremental sync, while edits and
+		// deletions need every activity compared with the cache
+		s.enqueue(event.OwnerID, event.AspectType != "create")
+	}
+}
+
+// deauthorizeTimeout bounds confirming a deauthorization with Strava
+const deauthorizeTimeout = time.Minute
+
+// deauthorize deletes the token of athlete once Strava confirms it was
+// revoked by rejecting it. Anyone can POST to the callback, so the event
+// alone is not trusted.
+func (s *webhookServer) deauthorize(ctx context.Context, athlete int64) {
+	ctx, cancel := context.WithTimeout(ctx, deauthorizeTimeout)
+	defer cancel()
+
+	store := s.stores.Athlete(athlete)
+	if _, err := store.Load(ctx); err != nil {
+		if !errors.Is(err, strava.ErrNoToken) {
+			s.logger.Printf("Loading the token of athlete %d: %v\n", athlete, err)
+		}
+		return
+	}
+
+	logger := log.New(s.logger.Writer(), "athlete "+strconv.FormatInt(athlete, 10)+": ", s.logger.Flags()|log.Lmsgprefix)
+	client := newStoreClient(logger, s.athleteConfig(athlete, false), store, s.scheduler)
+	ctx = strava.WithPriority(ctx, strava.PrioritySync)
+	err := client.Authenticate(ctx)
+	if err == nil {
+		err = client.Probe(ctx)
+	}
+	if !tokenRevoked(err) {
+		if err != nil {
+			logger.Printf("Ignoring a deauthorization that could not be confirmed: %v\n", err)
+		} else {
+			logger.Println("Ignoring a deauthorization, Strava still accepts the token")
+		}
+		return
+	}
+
+	if err := s.stores.Forget(ctx, athlete); err != nil {
+		logger.Printf("Forgetting the token: %v\n", err)
+		return
+	}
+	logger.Println("Deauthorized the application, token deleted")
+}
+
+// tokenRevoked reports whether err is Strava rejecting the athlete's token:
+// a 401 for the access token, other than for a missing scope, or a refresh
+// refused because the refresh token is no longer valid
+func tokenRevoked(err error) bool {
+	var apiErr *strava.APIError
+	var scopeErr *strava.ScopeError
+	if !errors.As(err, &apiErr) || errors.As(err, &scopeErr) {
+		return false
+	}
+	if apiErr.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	for _, fe := range apiErr.Errors {
+		if apiErr.StatusCode == http.StatusBadRequest && fe.Field == "refresh_token" && fe.Code == "invalid" {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue schedules a sync for athlete, merging it with one already
+// waiting so a burst of events costs a single sync
+func (s *webhookServer) enqueue(athlete int64, full bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, waiting := s.full[athlete]; !waiting {
+		s.queue = append(s.queue, athlete)
+	}
+	s.full[athlete] = s.full[athlete] || full
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *webhookServer) next() (int64, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return 0, false, false
+	}
+	athlete := s.queue[0]
+	s.queue = s.queue[1:]
+	full := s.full[athlete]
+	delete(s.full, athlete)
+	return athlete, full, true
+}
+
+// run syncs the queued athletes one at a time until ctx is done. Their
+// syncs share the application's rate limits, so running them in parallel
+// would only make them wait on each other.
+func (s *webhookServer) run(ctx context.Context) {
+	for {
+		athlete, full, ok := s.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+				continue
+			}
+		}
+		s.sync(ctx, athlete, full)
+	}
+}
+
+// sync runs the sync pipeline for one athlete with their own token and
+// cache
+func (s *webhookServer) sync(ctx context.Context, athlete int64, full bool) {
+	id := strconv.FormatInt(athlete, 10)
+	logger := log.New(s.logger.Writer(), "athlete "+id+": ", s.logger.Flags()|log.Lmsgprefix)
+
+	store := s.stores.Athlete(athlete)
+	if _, err := store.Load(ctx); err != nil {
+		if errors.Is(err, strava.ErrNoToken) {
+			logger.Println("Ignoring event, the athlete has not connected")
+		} else {
+			logger.Printf("Loading token: %v\n", err)
+		}
+		return
+	}
+
+	config := s.athleteConfig(athlete, full)
+	client := newStoreClient(logger, config, store, s.scheduler)
+	report, err := syncOnce(strava.WithPriority(ctx, strava.PrioritySync), logger, config, client)
+	switch {
+	case err != nil:
+		logger.Printf("Sync failed: %v\n", err)
+	case report.Skipped:
+		logger.Println("Another sync is running, skipping this one")
+	default:
+		logger.Printf("Added %d activities with %d API calls\n", report.Count, report.APICalls)
+	}
+}
+
+// athleteConfig returns the configuration of a sync of athlete: their
+// cache, and, when the HTTP response cache is enabled, a response cache of
+// their own under it. Cached responses are keyed by URL, and every athlete
+// lists their activities at the same URLs, so a shared one would serve one
+// athlete's activities to another.
+func (s *webhookServer) athleteConfig(athlete int64, full bool) envVars {
+	id := strconv.FormatInt(athlete, 10)
+	config := s.config
+	config.StravaCachePath = filepath.Join(s.dir, id+".db")
+	config.StravaRefreshToken = ""
+	config.StravaTokenScope = ""
+	config.Settings.Fetch.Incremental = !full
+	if config.Settings.Fetch.CacheDir != "" {
+		config.Settings.Fetch.CacheDir = filepath.Join(config.Settings.Fetch.CacheDir, id)
+	}
+	return config
+}
+
+// connectTimeout bounds how long an athlete may take on Strava's
+// authorize page
+const connectTimeout = 10 * time.Minute
+
+// connect sends the athlete to Strava's authorize page with a state
+// parameter the callback checks, so it only accepts authorizations started
+// here
+func (s *webhookServer) connect(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	now := time.Now()
+	for old, issued := range s.states {
+		if now.Sub(issued) > connectTimeout {
+			delete(s.states, old)
+		}
+	}
+	s.states[state] = now
+	s.mu.Unlock()
+
+	http.Redirect(w, r, s.client.AuthorizeURL(s.publicURL+"/connect/callback", state, s.scopes...), http.StatusFound)
+}
+
+// callback exchanges the code Strava redirects back with for the athlete's
+// token, stores it, and queues their first sync
+func (s *webhookServer) callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	issued, ok := s.states[q.Get("state")]
+	delete(s.states, q.Get("state"))
+	s.mu.Unlock()
+
+	granted := strava.ParseScopes(q.Get("scope"))
+	switch {
+	case !ok || time.Since(issued) > connectTimeout:
+		http.Error(w, "This link has expired, start again from /connect", http.StatusBadRequest)
+		return
+	case q.Get("error") != "":
+		http.Error(w, "Authorization was declined: "+q.Get("error"), http.StatusForbidden)
+		return
+	case !strava.HasScope(granted, strava.ScopeActivityRead):
+		http.Error(w, "Syncing needs activity:read, start again from /connect and leave it checked", http.StatusForbidden)
+		return
+	}
+
+	token, athlete, err := s.client.Exchange(r.Context(), q.Get("code"), q.Get("scope"))
+	if err != nil {
+		s.logger.Printf("Exchanging an authorization code: %v\n", err)
+		http.Error(w, "Strava did not accept the authorization, start again from /connect", http.StatusBadGateway)
+		return
+	}
+	if err := s.stores.Athlete(athlete.Id).Save(r.Context(), token); err != nil {
+		s.logger.Printf("Saving the token of athlete %d: %v\n", athlete.Id, err)
+		http.Error(w, "Saving the authorization failed", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Printf("Athlete %d connected with scopes %s\n", athlete.Id, strings.Join(granted, ", "))
+	s.enqueue(athlete.Id, true)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Connected %s %s. Your activities are being synced; you can close this page.\n", athlete.Firstname, athlete.Lastname)
+}
+
+func newServeWebhookCmd() *cobra.Command {
+	var listen, dir, publicURL, scopes string
+
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Sync the activities of connected athletes as Strava reports changes",
+		Long: `Serves the callback of a Strava push subscription on --listen at /webhook.
+The GET Strava sends to validate the subscription is answered when
+hub.verify_token matches STRAVA_WEBHOOK_VERIFY_TOKEN.
+
+Events for any subscription but STRAVA_WEBHOOK_SUBSCRIPTION_ID, the id
+Strava returned when the subscription was created, are dropped. The others
+are routed by owner_id to the athlete they belong to. Every athlete
+has a token, <id>.json, and a cache, <id>.db, in --athletes-dir, and with
+fetch.cache_dir set, HTTP responses are cached in <cache_dir>/<id>; events
+for athletes without a token are ignored. A new activity triggers an
+incremental sync of that athlete's cache, and an edit or deletion a full
+one, so the change is picked up. Events arriving while a sync is queued are
+merged into it, and syncs run one at a time since they share the
+application's rate limits. An athlete who deauthorizes the application has
+their token deleted once Strava rejects it, so a forged event cannot
+disconnect anyone; their cache is kept.
+
+With --public-url, the URL the server is reachable at, athletes connect
+themselves: /connect redirects to Strava's authorize page asking for
+--scopes, and /connect/callback exchanges the code Strava sends back for
+the athlete's token, stores it, and queues their first sync. The host of
+--public-url must be the Authorization Callback Domain of the application,
+and athletes must grant at least activity:read.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.Default()
+			ctx := cmd.Context()
+			config := loadConfig(ctx, logger)
+			if config.StravaWebhookVerifyToken == "" {
+				logger.Fatal("STRAVA_WEBHOOK_VERIFY_TOKEN must be set to the verify_token of the push subscription")
+			}
+			subscription, err := strconv.ParseInt(config.StravaWebhookSubscriptionId, 10, 64)
+			if err != nil {
+				logger.Fatal("STRAVA_WEBHOOK_SUBSCRIPTION_ID must be set to the id of the push subscription")
+			}
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				logger.Fatal(err)
+			}
+
+			scheduler := strava.NewScheduler(strava.NewRateLimiter(), config.Settings.HTTP.BulkReserve)
+			apiUsage.schedule(scheduler)
+			server := newWebhookServer(logger, config, dir, scheduler)
+			server.subscription = subscription
+			athletes, err := server.stores.Athletes(ctx)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			go server.run(ctx)
+
+			mux := http.NewServeMux()
+			mux.Handle("/webhook", strava.NewWebhookHandler(config.StravaWebhookVerifyToken, server.handle))
+			if publicURL != "" {
+				server.client = newStoreClient(logger, config, nil, scheduler)
+				server.publicURL = strings.TrimSuffix(publicURL, "/")
+				server.scopes = strava.ParseScopes(scopes)
+				mux.HandleFunc("/connect", server.connect)
+				mux.HandleFunc("/connect/callback", server.callback)
+			}
+			srv := &http.Server{Addr: listen, Handler: mux}
+			go func() {
+				<-ctx.Done()
+				srv.Shutdown(context.Background())
+			}()
+			logger.Printf("Serving webhook events for %d connected athletes on %s\n", len(athletes), listen)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal(fmt.Errorf("serving webhook: %w", err))
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", ":8080", "address to listen on")
+	cmd.Flags().StringVar(&dir, "athletes-dir", "athletes", "directory holding the token and cache of each connected athlete")
+	cmd.Flags().StringVar(&publicURL, "public-url", "", "URL the server is reachable at, to serve /connect, e.g. https://example.com")
+	cmd.Flags().StringVar(&scopes, "scopes", "read,activity:read_all", "scopes /connect asks athletes to grant")
+
+	return cmd
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/stravatest"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// TestAthleteResponseCachesAreSeparate syncs two athletes through one API
+// root, as the webhook server does, and checks neither is served the
+// other's cached activities
+func TestAthleteResponseCachesAreSeparate(t *testing.T) {
+	athletes := map[int64]strava.Activity{
+		1: {Id: 100, Name: "First athlete's run", Type: "Run", StartDate: "2024-01-01T06:00:00Z"},
+		2: {Id: 200, Name: "Second athlete's ride", Type: "Ride", StartDate: "2024-01-02T06:00:00Z"},
+	}
+
+	// Strava tells athletes apart by their token; stand in for that by
+	// routing to the fake of whichever athlete is syncing
+	fakes := make(map[int64]*stravatest.Server)
+	for id, activity := range athletes {
+		fake, err := stravatest.New(stravatest.WithActivities(activity))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fakes[id] = fake
+	}
+	var current atomic.Int64
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fakes[current.Load()].ServeHTTP(w, r)
+	}))
+	defer api.Close()
+
+	logger := log.New(io.Discard, "", 0)
+	dir := t.TempDir()
+	config := envVars{
+		StravaClientId:     stravatest.ClientID,
+		StravaClientSecret: stravatest.ClientSecret,
+		StravaBaseURL:      api.URL,
+	}
+	config.Settings.Fetch.CacheDir = filepath.Join(dir, "http")
+	config.Settings.Fetch.CacheTTL = time.Hour
+	s := newWebhookServer(logger, config, dir, strava.NewScheduler(strava.NewRateLimiter(), 0))
+
+	ctx := context.Background()
+	for _, id := range []int64{1, 2} {
+		current.Store(id)
+		store := s.stores.Athlete(id)
+		if err := store.Save(ctx, strava.Token{RefreshToken: stravatest.RefreshToken}); err != nil {
+			t.Fatal(err)
+		}
+		client := newStoreClient(logger, s.athleteConfig(id, true), store, s.scheduler)
+		if err := client.Authenticate(ctx); err != nil {
+			t.Fatal(err)
+		}
+		activities, err := client.ListAll(ctx, strava.ListActivitiesOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(activities) != 1 || activities[0].Id != athletes[id].Id {
+			t.Errorf("athlete %d listed %+v, want only activity %d", id, activities, athletes[id].Id)
+		}
+	}
+}
+
+// deauthorizationServer returns a webhook server for subscription 7 whose
+// athlete 1 holds token, talking to srv
+func deauthorizationServer(t *testing.T, srv *stravatest.Server, token strava.Token) *webhookServer {
+	t.Helper()
+	config := envVars{
+		StravaClientId:     stravatest.ClientID,
+		StravaClientSecret: stravatest.ClientSecret,
+		StravaBaseURL:      srv.URL,
+	}
+	s := newWebhookServer(log.New(io.Discard, "", 0), config, t.TempDir(), strava.NewScheduler(strava.NewRateLimiter(), 0))
+	s.subscription = 7
+	if err := s.stores.Athlete(1).Save(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestForgedDeauthorizationKeepsToken checks that deauthorization events
+// Strava did not send, for another subscription or for an athlete whose
+// token still works, leave the token in place
+func TestForgedDeauthorizationKeepsToken(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	s := deauthorizationServer(t, srv, strava.Token{RefreshToken: stravatest.RefreshToken})
+
+	event := strava.WebhookEvent{ObjectType: "athlete", ObjectID: 1, OwnerID: 1, Updates: map[string]string{"authorized": "false"}}
+	event.SubscriptionID = 8
+	s.handle(event)
+	s.deauthorize(context.Background(), event.OwnerID)
+
+	if _, err := s.stores.Athlete(1).Load(context.Background()); err != nil {
+		t.Errorf("token of athlete 1 after forged deauthorizations: %v", err)
+	}
+}
+
+// TestDeauthorizationForgetsRevokedToken checks that the token is deleted
+// once Strava rejects it
+func TestDeauthorizationForgetsRevokedToken(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	s := deauthorizationServer(t, srv, strava.Token{
+		AccessToken:  "revoked",
+		RefreshToken: "revoked",
+		ExpiresAt:    time.Now().Add(time.Hour).Unix(),
+	})
+
+	s.deauthorize(context.Background(), 1)
+
+	if _, err := s.stores.Athlete(1).Load(context.Background()); !errors.Is(err, strava.ErrNoToken) {
+		t.Errorf("loading the token of a deauthorized athlete = %v, want ErrNoToken", err)
+	}
+}
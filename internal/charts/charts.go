@@ -0,0 +1,225 @@
+// Package charts renders simple bar and line charts as SVG or PNG, for
+// embedding report graphs in Markdown and web pages without a plotting
+// library.
+//
+//	chart := charts.Chart{
+//		Title:  "Weekly distance",
+//		Unit:   "mi",
+//		Labels: []string{"Sep 28", "Oct 5"},
+//		Series: []charts.Series{{Name: "Distance", Values: []float64{12.5, 20}, Bars: true}},
+//	}
+//	svg := chart.SVG()
+package charts
+
+import (
+	"math"
+	"strconv"
+)
+
+// Default dimensions in pixels
+const (
+	DefaultWidth  = 640
+	DefaultHeight = 320
+)
+
+// Colors used when a series does not set one, Strava orange first
+var palette = []string{"#fc4c02", "#1f77b4", "#d62728", "#2ca02c", "#9467bd", "#7f7f7f"}
+
+// Chart is a set of series plotted over labelled points along the x axis.
+// Bar series are drawn first, side by side, then line series over them.
+type Chart struct {
+	Title string
+	// Unit labels the y axis
+	Unit string
+	// Labels name each point along the x axis; every series has one value
+	// per label
+	Labels []string
+	Series []Series
+	// Width and Height default to DefaultWidth and DefaultHeight
+	Width, Height int
+}
+
+// Series is one set of values on a chart
+type Series struct {
+	Name string
+	// Values has one entry per label; NaN leaves a gap
+	Values []float64
+	// Color is #rrggbb, picked from a palette when empty
+	Color  string
+	Bars   bool
+	Dashed bool
+}
+
+// canvas is a drawing surface, implemented for SVG and PNG
+type canvas interface {
+	rect(x, y, w, h float64, color string)
+	line(points [][2]float64, color string, width float64, dashed bool)
+	// text draws s with its baseline at y, anchored start, middle, or end
+	text(x, y float64, s string, size float64, anchor, color string)
+	// textWidth estimates the width of s in pixels
+	textWidth(s string, size float64) float64
+}
+
+const (
+	marginLeft   = 56.0
+	marginRight  = 16.0
+	marginTop    = 48.0
+	marginBottom = 32.0
+	labelSize    = 11.0
+	titleSize    = 15.0
+	gridColor    = "#e0e0e0"
+	axisColor    = "#555555"
+)
+
+func (c Chart) size() (float64, float64) {
+	w, h := c.Width, c.Height
+	if w <= 0 {
+		w = DefaultWidth
+	}
+	if h <= 0 {
+		h = DefaultHeight
+	}
+	return float64(w), float64(h)
+}
+
+// draw lays out the chart on cv
+func (c Chart) draw(cv canvas) {
+	w, h := c.size()
+	plotW, plotH := w-marginLeft-marginRight, h-marginTop-marginBottom
+	cv.rect(0, 0, w, h, "#ffffff")
+	cv.text(marginLeft, 20, c.Title, titleSize, "start", "#222222")
+
+	lo, hi := c.valueRange()
+	step := niceStep((hi - lo) / 5)
+	lo, hi = math.Floor(lo/step)*step, math.Ceil(hi/step)*step
+	if hi == lo {
+		hi = lo + step
+	}
+	y := func(v float64) float64 { return marginTop + plotH - (v-lo)/(hi-lo)*plotH }
+
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step)))
+	}
+	for v := lo; v <= hi+step/2; v += step {
+		cv.line([][2]float64{{marginLeft, y(v)}, {marginLeft + plotW, y(v)}}, gridColor, 1, false)
+		cv.text(marginLeft-6, y(v)+4, strconv.FormatFloat(v, 'f', decimals, 64), labelSize, "end", axisColor)
+	}
+	cv.text(marginLeft-6, marginTop-10, c.Unit, labelSize, "end", axisColor)
+
+	n := len(c.Labels)
+	if n == 0 {
+		return
+	}
+	slot := plotW / float64(n)
+	center := func(i int) float64 { return marginLeft + slot*(float64(i)+0.5) }
+
+	bars := 0
+	for _, s := range c.Series {
+		if s.Bars {
+			bars++
+		}
+	}
+	bar := 0
+	for i, s := range c.Series {
+		if !s.Bars {
+			continue
+		}
+		width := slot * 0.7 / float64(bars)
+		for j, v := range s.Values {
+			if j >= n || math.IsNaN(v) {
+				continue
+			}
+			x := center(j) - slot*0.35 + float64(bar)*width
+			top, bottom := y(math.Max(v, 0)), y(math.Min(v, 0))
+			cv.rect(x, top, width, bottom-top, c.color(i))
+		}
+		bar++
+	}
+	for i, s := range c.Series {
+		if s.Bars {
+			continue
+		}
+		points := make([][2]float64, 0, len(s.Values))
+		for j, v := range s.Values {
+			if j >= n || math.IsNaN(v) {
+				if len(points) > 0 {
+					cv.line(points, c.color(i), 2, s.Dashed)
+				}
+				points = points[:0]
+				continue
+			}
+			points = append(points, [2]float64{center(j), y(v)})
+		}
+		if len(points) > 0 {
+			cv.line(points, c.color(i), 2, s.Dashed)
+		}
+	}
+
+	base := y(math.Max(lo, math.Min(0, hi)))
+	cv.line([][2]float64{{marginLeft, base}, {marginLeft + plotW, base}}, axisColor, 1, false)
+
+	// label every point that fits without overlapping its neighbour
+	widest := 0.0
+	for _, l := range c.Labels {
+		widest = math.Max(widest, cv.textWidth(l, labelSize))
+	}
+	every := int(math.Ceil((widest + 8) / slot))
+	for i, l := range c.Labels {
+		if i%max(every, 1) == 0 {
+			cv.text(center(i), marginTop+plotH+16, l, labelSize, "middle", axisColor)
+		}
+	}
+
+	if len(c.Series) > 1 {
+		x := w - marginRight
+		for i := len(c.Series) - 1; i >= 0; i-- {
+			name := c.Series[i].Name
+			x -= cv.textWidth(name, labelSize)
+			cv.text(x, marginTop-10, name, labelSize, "start", axisColor)
+			x -= 14
+			cv.rect(x, marginTop-19, 10, 10, c.color(i))
+			x -= 12
+		}
+	}
+}
+
+func (c Chart) color(i int) string {
+	if c.Series[i].Color != "" {
+		return c.Series[i].Color
+	}
+	return palette[i%len(palette)]
+}
+
+// valueRange spans every value and zero, so bars start from the axis
+func (c Chart) valueRange() (float64, float64) {
+	lo, hi := 0.0, 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			if !math.IsNaN(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// niceStep rounds a grid interval up to 1, 2, or 5 times a power of ten
+func niceStep(raw float64) float64 {
+	if raw <= 0 || math.IsNaN(raw) || math.IsInf(raw, 0) {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch f := raw / exp; {
+	case f <= 1:
+		return exp
+	case f <= 2:
+		return 2 * exp
+	case f <= 5:
+		return 5 * exp
+	}
+	return 10 * exp
+}
@@ -0,0 +1,134 @@
+package charts
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PNG renders the chart as a PNG image. Text is drawn with a built-in
+// 3x5 pixel font covering digits, letters, and common punctuation.
+func (c Chart) PNG() ([]byte, error) {
+	w, h := c.size()
+	cv := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, int(w), int(h)))}
+	c.draw(cv)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, cv.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (cv *pngCanvas) rect(x, y, w, h float64, hex string) {
+	c := parseColor(hex)
+	for py := int(math.Round(y)); py < int(math.Round(y+h)); py++ {
+		for px := int(math.Round(x)); px < int(math.Round(x+w)); px++ {
+			cv.img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// line plots each segment with a square brush, skipping the gaps of a
+// 6 on, 4 off pattern when dashed
+func (cv *pngCanvas) line(points [][2]float64, hex string, width float64, dashed bool) {
+	c := parseColor(hex)
+	brush := int(math.Max(1, math.Round(width)))
+	travelled := 0.0
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+		length := math.Hypot(to[0]-from[0], to[1]-from[1])
+		steps := int(math.Ceil(length * 2))
+		for s := 0; s <= steps; s++ {
+			t := float64(s) / math.Max(float64(steps), 1)
+			if dashed && math.Mod(travelled+t*length, 10) >= 6 {
+				continue
+			}
+			x := int(math.Round(from[0]+(to[0]-from[0])*t)) - brush/2
+			y := int(math.Round(from[1]+(to[1]-from[1])*t)) - brush/2
+			for dy := 0; dy < brush; dy++ {
+				for dx := 0; dx < brush; dx++ {
+					cv.img.SetRGBA(x+dx, y+dy, c)
+				}
+			}
+		}
+		travelled += length
+	}
+}
+
+func (cv *pngCanvas) text(x, y float64, s string, size float64, anchor, hex string) {
+	scale := pixelScale(size)
+	switch anchor {
+	case "middle":
+		x -= cv.textWidth(s, size) / 2
+	case "end":
+		x -= cv.textWidth(s, size)
+	}
+	c := parseColor(hex)
+	top := int(math.Round(y)) - 5*scale
+	left := int(math.Round(x))
+	for _, r := range strings.ToUpper(s) {
+		glyph, ok := pixelFont[r]
+		if !ok && !unicode.IsSpace(r) {
+			glyph = pixelFont['?']
+		}
+		for i, bit := range glyph {
+			if bit != '#' {
+				continue
+			}
+			gx, gy := left+(i%3)*scale, top+(i/3)*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					cv.img.SetRGBA(gx+dx, gy+dy, c)
+				}
+			}
+		}
+		left += 4 * scale
+	}
+}
+
+func (cv *pngCanvas) textWidth(s string, size float64) float64 {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return float64((4*n - 1) * pixelScale(size))
+}
+
+// pixelScale sizes the 5 pixel tall font to roughly the requested height
+func pixelScale(size float64) int {
+	return max(1, int(math.Round(size/5.5)))
+}
+
+func parseColor(hex string) color.RGBA {
+	v, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+// pixelFont maps characters to 3x5 bitmaps, row by row
+var pixelFont = map[rune]string{
+	'0': "####.##.##.####", '1': ".#.##..#..#.###", '2': "###..#####..###", '3': "###..####..####",
+	'4': "#.##.####..#..#", '5': "####..###..####", '6': "####..####.####", '7': "###..#..#..#..#",
+	'8': "####.#####.####", '9': "####.####..####", 'A': ".#.#.#####.##.#", 'B': "##.#.###.#.###.",
+	'C': ".###..#..#...##", 'D': "##.#.##.##.###.", 'E': "####..##.#..###", 'F': "####..##.#..#..",
+	'G': ".###..#.##.#.##", 'H': "#.##.#####.##.#", 'I': "###.#..#..#.###", 'J': "..#..#..##.#.#.",
+	'K': "#.##.###.#.##.#", 'L': "#..#..#..#..###", 'M': "#.########.##.#", 'N': "##.#.##.##.##.#",
+	'O': ".#.#.##.##.#.#.", 'P': "##.#.###.#..#..", 'Q': ".#.#.##.###..##", 'R': "##.#.###.#.##.#",
+	'S': ".###...#...###.", 'T': "###.#..#..#..#.", 'U': "#.##.##.##.####", 'V': "#.##.##.##.#.#.",
+	'W': "#.##.########.#", 'X': "#.##.#.#.#.##.#", 'Y': "#.##.#.#..#..#.", 'Z': "###..#.#.#..###",
+	'-': "......###......", '.': ".............#.", ':': "....#.....#....", '/': "..#..#.#.#..#..",
+	'%': "#.#..#.#.#..#.#", '+': "....#.###.#....", ',': "..........#.#..", '(': ".#.#..#..#...#.",
+	')': ".#...#..#..#.#.", '?': "###..#.#.....#.", '°': "####.####......", '\'': ".#..#..........",
+}
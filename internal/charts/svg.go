@@ -0,0 +1,55 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// SVG renders the chart as a standalone SVG document
+func (c Chart) SVG() []byte {
+	w, h := c.size()
+	cv := &svgCanvas{}
+	fmt.Fprintf(&cv.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif">`, w, h, w, h)
+	cv.buf.WriteString("\n")
+	c.draw(cv)
+	cv.buf.WriteString("</svg>\n")
+	return cv.buf.Bytes()
+}
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func (cv *svgCanvas) rect(x, y, w, h float64, color string) {
+	fmt.Fprintf(&cv.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, color)
+}
+
+func (cv *svgCanvas) line(points [][2]float64, color string, width float64, dashed bool) {
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%.1f,%.1f", p[0], p[1])
+	}
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="6 4"`
+	}
+	fmt.Fprintf(&cv.buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%.0f" stroke-linejoin="round"%s/>`+"\n",
+		strings.Join(coords, " "), color, width, dash)
+}
+
+func (cv *svgCanvas) text(x, y float64, s string, size float64, anchor, color string) {
+	if s == "" {
+		return
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(s))
+	fmt.Fprintf(&cv.buf, `<text x="%.1f" y="%.1f" font-size="%.0f" text-anchor="%s" fill="%s">%s</text>`+"\n",
+		x, y, size, anchor, color, escaped.String())
+}
+
+// textWidth assumes the average sans-serif glyph is 0.6 em wide
+func (cv *svgCanvas) textWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.6
+}
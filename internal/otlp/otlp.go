@@ -0,0 +1,380 @@
+// Package otlp is a minimal OpenTelemetry tracer that exports spans to a
+// collector as OTLP/HTTP JSON, enough to see where a sync spends its time
+// without pulling in the full SDK.
+//
+//	tracer := otlp.New(otlp.Config{Endpoint: "http://localhost:4318", ServiceName: "strava-api"})
+//	defer tracer.Shutdown(context.Background())
+//	ctx, span := tracer.Start(ctx, "sync", otlp.Int("pages", 3))
+//	defer span.End()
+//
+// A nil *Tracer and the nil *Span it returns are valid and record nothing,
+// so instrumented code does not need to check whether tracing is enabled.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span kinds as numbered by the OTLP protocol
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+const (
+	// batchSize spans are exported together once this many have ended
+	batchSize = 512
+	// flushInterval is the longest an ended span waits to be exported
+	flushInterval = 5 * time.Second
+)
+
+// Config locates the collector
+type Config struct {
+	// Endpoint is the collector's base URL; spans are POSTed to
+	// <Endpoint>/v1/traces unless it already ends in that path
+	Endpoint string
+	// Headers are sent with every export, e.g. an API key
+	Headers     map[string]string
+	ServiceName string
+	HTTPClient  *http.Client
+}
+
+// ConfigFromEnv reads the standard OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+// OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS, and
+// OTEL_SERVICE_NAME variables. It reports false when no endpoint is set.
+func ConfigFromEnv(defaultService string) (Config, bool) {
+	cfg := Config{Endpoint: os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"), ServiceName: os.Getenv("OTEL_SERVICE_NAME")}
+	if cfg.Endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			cfg.Endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if cfg.Endpoint == "" {
+		return cfg, false
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = defaultService
+	}
+
+	// key1=value1,key2=value2 with URL-encoded values
+	cfg.Headers = make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if v, err := url.PathUnescape(value); err == nil {
+			value = v
+		}
+		cfg.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return cfg, true
+}
+
+// Tracer records spans and exports them in batches in the background
+type Tracer struct {
+	cfg  Config
+	mu   sync.Mutex
+	done []*Span
+	// Errors from background exports, reported by Shutdown
+	errs []error
+
+	flush chan struct{}
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New starts a tracer exporting to cfg.Endpoint
+func New(cfg Config) *Tracer {
+	if cfg.Endpoint != "" && !strings.HasSuffix(cfg.Endpoint, "/v1/traces") {
+		cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	t := &Tracer{cfg: cfg, flush: make(chan struct{}, 1), stop: make(chan struct{})}
+	t.wg.Add(1)
+	go t.loop()
+	return t
+}
+
+func (t *Tracer) loop() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-t.stop:
+			return
+		}
+		if err := t.export(context.Background()); err != nil {
+			t.mu.Lock()
+			t.errs = append(t.errs, err)
+			t.mu.Unlock()
+		}
+	}
+}
+
+// Shutdown exports the spans that have ended and stops the tracer. It
+// returns the first export error, as nothing else sees them.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	close(t.stop)
+	t.wg.Wait()
+	err := t.export(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.errs) > 0 {
+		return t.errs[0]
+	}
+	return err
+}
+
+type spanKey struct{}
+
+// Start begins a span, a child of the span in ctx if there is one, and
+// returns a context carrying it
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: KindInternal, start: time.Now(), attrs: attrs}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	s.spanID = randomHex(8)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanFromContext returns the span started by Start in ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Span is one timed operation. Its methods are safe for concurrent use.
+type Span struct {
+	tracer                    *Tracer
+	traceID, spanID, parentID string
+	name                      string
+	start                     time.Time
+
+	mu    sync.Mutex
+	kind  int
+	end   time.Time
+	attrs []Attribute
+	err   string
+	ended bool
+}
+
+// SetKind marks the span as a client or server call rather than internal
+func (s *Span) SetKind(kind int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.kind = kind
+	s.mu.Unlock()
+}
+
+// SetAttributes adds attributes, replacing any with the same key
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		replaced := false
+		for i := range s.attrs {
+			if s.attrs[i].Key == a.Key {
+				s.attrs[i], replaced = a, true
+			}
+		}
+		if !replaced {
+			s.attrs = append(s.attrs, a)
+		}
+	}
+}
+
+// RecordError sets the span's status to error when err is not nil
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end = true, time.Now()
+	s.mu.Unlock()
+
+	t := s.tracer
+	t.mu.Lock()
+	t.done = append(t.done, s)
+	full := len(t.done) >= batchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Attribute is a key and a string, int64, float64, or bool value
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String, Int, Float, and Bool build attributes of each value type
+func String(key, value string) Attribute        { return Attribute{key, value} }
+func Int(key string, value int) Attribute       { return Attribute{key, int64(value)} }
+func Float(key string, value float64) Attribute { return Attribute{key, value} }
+func Bool(key string, value bool) Attribute     { return Attribute{key, value} }
+
+// export sends the spans that have ended since the last export
+func (t *Tracer) export(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.done
+	t.done = nil
+	t.mu.Unlock()
+	if len(spans) == 0 || t.cfg.Endpoint == "" {
+		return nil
+	}
+
+	encoded := make([]jsonSpan, len(spans))
+	for i, s := range spans {
+		encoded[i] = s.encode()
+	}
+	body := map[string]interface{}{"resourceSpans": []interface{}{map[string]interface{}{
+		"resource":   map[string]interface{}{"attributes": encodeAttributes([]Attribute{String("service.name", t.cfg.ServiceName)})},
+		"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]string{"name": t.cfg.ServiceName}, "spans": encoded}},
+	}}}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.Endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	res, err := t.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp: exporting %d spans: %w", len(spans), err)
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("otlp: exporting %d spans: %s", len(spans), res.Status)
+	}
+	return nil
+}
+
+// jsonSpan is a span in the OTLP JSON encoding, with hex ids and times as
+// decimal strings of nanoseconds
+type jsonSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []jsonAttribute `json:"attributes,omitempty"`
+	Status       *jsonStatus     `json:"status,omitempty"`
+}
+
+type jsonStatus struct {
+	// Code 2 is error; unset statuses are left out
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type jsonAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func (s *Span) encode() jsonSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	js := jsonSpan{
+		TraceID:      s.traceID,
+		SpanID:       s.spanID,
+		ParentSpanID: s.parentID,
+		Name:         s.name,
+		Kind:         s.kind,
+		Start:        strconv.FormatInt(s.start.UnixNano(), 10),
+		End:          strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:   encodeAttributes(s.attrs),
+	}
+	if s.err != "" {
+		js.Status = &jsonStatus{Code: 2, Message: s.err}
+	}
+	return js
+}
+
+func encodeAttributes(attrs []Attribute) []jsonAttribute {
+	encoded := make([]jsonAttribute, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]interface{}
+		switch v := a.Value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case int64:
+			// 64-bit integers are strings in the JSON encoding
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, jsonAttribute{Key: a.Key, Value: value})
+	}
+	return encoded
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,694 @@
+// Package stravatest provides an in-process fake of the parts of the Strava
+// API the client uses, so code built on pkg/strava can be exercised without
+// real credentials or network access.
+//
+//	srv := stravatest.NewServer(stravatest.WithActivities(activities...))
+//	defer srv.Close()
+//	client := srv.Client()
+package stravatest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// Credentials accepted by the token endpoint unless overridden with
+// WithCredentials
+const (
+	ClientID     = "12345"
+	ClientSecret = "0123456789abcdef0123456789abcdef01234567"
+	RefreshToken = "fedcba9876543210fedcba9876543210fedcba98"
+	// AuthorizationCode is the code /oauth/authorize redirects with
+	AuthorizationCode = "0f1e2d3c4b5a69780f1e2d3c4b5a69780f1e2d3c"
+)
+
+// Fixtures is the data served by the fake. Activities, streams, and laps
+// are raw API payloads; streams and laps are keyed by activity id.
+type Fixtures struct {
+	Athlete    json.RawMessage            `json:"athlete"`
+	Activities []json.RawMessage          `json:"activities"`
+	Streams    map[string]json.RawMessage `json:"streams"`
+	Laps       map[string]json.RawMessage `json:"laps"`
+}
+
+// Server is a fake Strava API. It implements http.Handler, so it can be
+// mounted on any listener; NewServer starts it on a local httptest server.
+type Server struct {
+	// URL is the base URL to pass to strava.WithBaseURL. It is set by
+	// NewServer and empty for servers created with New.
+	URL string
+
+	srv *httptest.Server
+
+	mu           sync.Mutex
+	fixtures     Fixtures
+	activities   []activity
+	latency      time.Duration
+	clientID     string
+	clientSecret string
+	refreshToken string
+	accessToken  string
+	issued       int
+	shortLimit   int
+	dailyLimit   int
+	shortUsed    int
+	dailyUsed    int
+	requests     int
+	throttled    int
+	retryAfter   time.Duration
+}
+
+// activity is a fixture with the fields needed to filter and sort it
+type activity struct {
+	id    int
+	start time.Time
+	raw   json.RawMessage
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithFixtures replaces every fixture the server serves
+func WithFixtures(f Fixtures) Option {
+	return func(s *Server) {
+		s.fixtures = f
+	}
+}
+
+// WithActivities adds activities to the athlete's activity list, using
+// each one's Raw payload when it is set
+func WithActivities(activities ...strava.Activity) Option {
+	return func(s *Server) {
+		for _, a := range activities {
+			raw := a.Raw
+			if len(raw) == 0 {
+				raw, _ = json.Marshal(a)
+			}
+			s.fixtures.Activities = append(s.fixtures.Activities, raw)
+		}
+	}
+}
+
+// WithLatency delays every response by d
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// WithRateLimit sets the fifteen-minute and daily request limits reported
+// in the X-RateLimit headers. Requests past either limit get a 429 until
+// ResetRateLimit is called.
+func WithRateLimit(short, daily int) Option {
+	return func(s *Server) {
+		s.shortLimit = short
+		s.dailyLimit = daily
+	}
+}
+
+// WithCredentials sets the client ID, secret, and refresh token the token
+// endpoint accepts
+func WithCredentials(clientID, clientSecret, refreshToken string) Option {
+	return func(s *Server) {
+		s.clientID = clientID
+		s.clientSecret = clientSecret
+		s.refreshToken = refreshToken
+	}
+}
+
+// New returns a Server that is not listening, for use as an http.Handler
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		clientID:     ClientID,
+		clientSecret: ClientSecret,
+		refreshToken: RefreshToken,
+		shortLimit:   strava.DefaultShortTermLimit,
+		dailyLimit:   strava.DefaultDailyLimit,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.fixtures.Athlete) == 0 {
+		s.fixtures.Athlete = json.RawMessage(`{"id":1,"username":"test","firstname":"Test","lastname":"Athlete"}`)
+	}
+
+	for _, raw := range s.fixtures.Activities {
+		var a struct {
+			Id        int    `json:"id"`
+			StartDate string `json:"start_date"`
+		}
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return nil, fmt.Errorf("stravatest: activity fixture: %w", err)
+		}
+		start, err := time.Parse(time.RFC3339, a.StartDate)
+		if err != nil {
+			return nil, fmt.Errorf("stravatest: activity %d start_date: %w", a.Id, err)
+		}
+		s.activities = append(s.activities, activity{id: a.Id, start: start, raw: raw})
+	}
+	sort.SliceStable(s.activities, func(i, j int) bool {
+		return s.activities[i].start.Before(s.activities[j].start)
+	})
+
+	return s, nil
+}
+
+// NewServer starts a Server on a local port. It panics if the fixtures are
+// invalid, in the manner of httptest.NewServer.
+func NewServer(opts ...Option) *Server {
+	s, err := New(opts...)
+	if err != nil {
+		panic(err)
+	}
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down a server started with NewServer
+func (s *Server) Close() {
+	if s.srv != nil {
+		s.srv.Close()
+	}
+}
+
+// Client returns a strava.Client pointed at the server with credentials
+// it accepts. opts are applied after the defaults.
+func (s *Server) Client(opts ...strava.Option) *strava.Client {
+	s.mu.Lock()
+	defaults := []strava.Option{
+		strava.WithBaseURL(s.URL),
+		strava.WithCredentials(s.clientID, s.clientSecret),
+		strava.WithToken(strava.Token{RefreshToken: s.refreshToken}),
+	}
+	s.mu.Unlock()
+	return strava.New(append(defaults, opts...)...)
+}
+
+// Requests returns the number of API requests served, excluding token
+// refreshes
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// ResetRateLimit clears the usage counted against both rate limit windows
+func (s *Server) ResetRateLimit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shortUsed = 0
+	s.dailyUsed = 0
+}
+
+// ExpireToken invalidates the current access token so the next API request
+// gets a 401
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessToken = ""
+}
+
+// Throttle answers the next n API requests with a 429 asking the client
+// to retry after retryAfter, rounded up to whole seconds as in Retry-After
+func (s *Server) Throttle(n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttled = n
+	s.retryAfter = retryAfter
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.latency > 0 {
+		select {
+		case <-time.After(s.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	// Strava compresses responses for clients that accept gzip
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		gz := &gzipWriter{ResponseWriter: w}
+		defer gz.Close()
+		w = gz
+	}
+
+	if r.URL.Path == "/oauth/token" {
+		s.token(w, r)
+		return
+	}
+	if r.URL.Path == "/oauth/authorize" {
+		s.authorize(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	authorized := s.accessToken != "" && r.Header.Get("Authorization") == "Bearer "+s.accessToken
+	limited := s.shortUsed >= s.shortLimit || s.dailyUsed >= s.dailyLimit
+	throttled := !limited && s.throttled > 0
+	if throttled {
+		s.throttled--
+		seconds := (s.retryAfter + time.Second - 1) / time.Second
+		w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+	}
+	if !limited && !throttled {
+		s.shortUsed++
+		s.dailyUsed++
+	}
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d,%d", s.shortLimit, s.dailyLimit))
+	w.Header().Set("X-RateLimit-Usage", fmt.Sprintf("%d,%d", s.shortUsed, s.dailyUsed))
+	s.mu.Unlock()
+
+	switch {
+	case limited || throttled:
+		writeError(w, http.StatusTooManyRequests, "Rate Limit Exceeded", "Application", "rate limit", "exceeded")
+		return
+	case !authorized:
+		writeError(w, http.StatusUnauthorized, "Authorization Error", "Athlete", "access_token", "invalid")
+		return
+	case r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "", "", "")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodGet {
+		switch {
+		case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "activities":
+			s.createActivity(w, r)
+		case r.Method == http.MethodPut && len(parts) == 2 && parts[0] == "activities":
+			s.updateActivity(w, r, parts[1])
+		case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "activities":
+			s.deleteActivity(w, parts[1])
+		case r.Method == http.MethodPut && len(parts) == 1 && parts[0] == "athlete":
+			s.updateAthlete(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "", "", "")
+		}
+		return
+	}
+	switch {
+	case len(parts) == 1 && parts[0] == "athlete":
+		s.mu.Lock()
+		athlete := s.fixtures.Athlete
+		s.mu.Unlock()
+		writeJSON(w, athlete)
+	case len(parts) == 2 && parts[0] == "athlete" && parts[1] == "activities":
+		s.listActivities(w, r)
+	case len(parts) == 3 && parts[0] == "athletes" && parts[2] == "stats":
+		s.getStats(w)
+	case len(parts) == 3 && parts[0] == "athletes" && parts[2] == "routes":
+		// No route fixtures yet
+		writeJSON(w, []byte("[]"))
+	case len(parts) == 2 && parts[0] == "activities":
+		s.getActivity(w, parts[1])
+	case len(parts) == 3 && parts[0] == "activities" && parts[2] == "streams":
+		s.getStreams(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "activities" && parts[2] == "laps":
+		s.getByActivity(w, s.fixtures.Laps, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "Record Not Found", "resource", "path", "invalid")
+	}
+}
+
+// authorize grants every requested scope straight away, redirecting like
+// Strava does once the athlete clicks Authorize
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != s.clientID || redirect.Host == "" {
+		writeError(w, http.StatusBadRequest, "Bad Request", "Application", "redirect_uri", "invalid")
+		return
+	}
+	params := redirect.Query()
+	params.Set("state", q.Get("state"))
+	params.Set("code", AuthorizationCode)
+	params.Set("scope", q.Get("scope"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token handles the refresh token and authorization code grants, issuing a
+// new access token each time. The code grant also returns the athlete.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "", "", "")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", "", "", "")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.PostForm.Get("client_id") != s.clientID:
+		writeError(w, http.StatusBadRequest, "Bad Request", "Application", "client_id", "invalid")
+		return
+	case r.PostForm.Get("client_secret") != s.clientSecret:
+		writeError(w, http.StatusUnauthorized, "Bad Request", "Application", "client_secret", "invalid")
+		return
+	case r.PostForm.Get("grant_type") == "authorization_code" && r.PostForm.Get("code") != AuthorizationCode:
+		writeError(w, http.StatusBadRequest, "Bad Request", "AuthorizationCode", "code", "invalid")
+		return
+	case r.PostForm.Get("grant_type") == "refresh_token" && r.PostForm.Get("refresh_token") != s.refreshToken:
+		writeError(w, http.StatusBadRequest, "Bad Request", "RefreshToken", "refresh_token", "invalid")
+		return
+	case r.PostForm.Get("grant_type") != "refresh_token" && r.PostForm.Get("grant_type") != "authorization_code":
+		writeError(w, http.StatusBadRequest, "Bad Request", "RefreshToken", "grant_type", "invalid")
+		return
+	}
+
+	s.issued++
+	s.accessToken = fmt.Sprintf("access-token-%d", s.issued)
+	res := struct {
+		strava.Token
+		Athlete json.RawMessage `json:"athlete,omitempty"`
+	}{Token: strava.Token{
+		AccessToken:  s.accessToken,
+		RefreshToken: s.refreshToken,
+		ExpiresAt:    time.Now().Add(6 * time.Hour).Unix(),
+		TokenType:    "Bearer",
+	}}
+	if r.PostForm.Get("grant_type") == "authorization_code" {
+		res.Athlete = s.fixtures.Athlete
+	}
+	body, _ := json.Marshal(res)
+	writeJSON(w, body)
+}
+
+// listActivities pages through the fixtures like Strava does: newest first,
+// or oldest first when after is set
+func (s *Server) listActivities(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, perPage := 1, 30
+	if v, err := strconv.Atoi(q.Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(q.Get("per_page")); err == nil && v > 0 {
+		perPage = v
+	}
+	if perPage > strava.MaxPerPage {
+		perPage = strava.MaxPerPage
+	}
+	before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+	after, _ := strconv.ParseInt(q.Get("after"), 10, 64)
+
+	selected := make([]json.RawMessage, 0)
+	for _, a := range s.activities {
+		if before > 0 && a.start.Unix() >= before {
+			continue
+		}
+		if after > 0 && a.start.Unix() <= after {
+			continue
+		}
+		selected = append(selected, a.raw)
+	}
+	if after == 0 {
+		for i, j := 0, len(selected)-1; i < j; i, j = i+1, j-1 {
+			selected[i], selected[j] = selected[j], selected[i]
+		}
+	}
+
+	start := (page - 1) * perPage
+	if start > len(selected) {
+		start = len(selected)
+	}
+	end := start + perPage
+	if end > len(selected) {
+		end = len(selected)
+	}
+
+	body, _ := json.Marshal(selected[start:end])
+	writeJSON(w, body)
+}
+
+// getStats totals the ride, run, and swim fixtures the way the athlete
+// stats endpoint does
+func (s *Server) getStats(w http.ResponseWriter) {
+	var stats strava.ActivityStats
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.activities {
+		var act strava.Activity
+		if err := json.Unmarshal(a.raw, &act); err != nil {
+			continue
+		}
+		var recent, ytd, all *strava.ActivityTotal
+		switch act.Type {
+		case "Ride":
+			recent, ytd, all = &stats.RecentRideTotals, &stats.YtdRideTotals, &stats.AllRideTotals
+			stats.BiggestRideDistance = max(stats.BiggestRideDistance, act.Distance)
+		case "Run":
+			recent, ytd, all = &stats.RecentRunTotals, &stats.YtdRunTotals, &stats.AllRunTotals
+		case "Swim":
+			recent, ytd, all = &stats.RecentSwimTotals, &stats.YtdSwimTotals, &stats.AllSwimTotals
+		default:
+			continue
+		}
+		stats.BiggestClimbElevationGain = max(stats.BiggestClimbElevationGain, act.TotalElevationGain)
+		totals := []*strava.ActivityTotal{all}
+		if a.start.Year() == now.Year() {
+			totals = append(totals, ytd)
+		}
+		if now.Sub(a.start) < 28*24*time.Hour {
+			totals = append(totals, recent)
+		}
+		for _, t := range totals {
+			t.Count++
+			t.Distance += act.Distance
+			t.MovingTime += act.MovingTime
+			t.ElapsedTime += act.ElapsedTime
+			t.ElevationGain += act.TotalElevationGain
+		}
+	}
+	body, _ := json.Marshal(stats)
+	writeJSON(w, body)
+}
+
+func (s *Server) getActivity(w http.ResponseWriter, id string) {
+	for _, a := range s.activities {
+		if strconv.Itoa(a.id) == id {
+			writeJSON(w, a.raw)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "invalid")
+}
+
+// createActivity adds a manual activity with the next free ID. The start
+// is taken as UTC, as the server has no athlete time zone.
+func (s *Server) createActivity(w http.ResponseWriter, r *http.Request) {
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", "Activity", "body", "invalid")
+		return
+	}
+	for _, field := range []string{"name", "sport_type", "start_date_local", "elapsed_time"} {
+		if v, ok := fields[field]; !ok || v == "" {
+			writeError(w, http.StatusBadRequest, "Bad Request", "Activity", field, "missing")
+			return
+		}
+	}
+	local, _ := fields["start_date_local"].(string)
+	start, err := time.Parse("2006-01-02T15:04:05", strings.TrimSuffix(local, "Z"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", "Activity", "start_date_local", "invalid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := 1
+	for _, a := range s.activities {
+		id = max(id, a.id+1)
+	}
+	fields["id"] = id
+	fields["type"] = fields["sport_type"]
+	fields["start_date"] = start.Format(time.RFC3339)
+	fields["start_date_local"] = start.Format(time.RFC3339)
+	fields["moving_time"] = fields["elapsed_time"]
+	fields["manual"] = true
+	raw, _ := json.Marshal(fields)
+
+	i := sort.Search(len(s.activities), func(i int) bool { return s.activities[i].start.After(start) })
+	s.activities = append(s.activities[:i], append([]activity{{id: id, start: start, raw: raw}}, s.activities[i:]...)...)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(raw)
+}
+
+// updateActivity merges the fields of a PUT body into the activity, so
+// later list and detail requests return the changes
+func (s *Server) updateActivity(w http.ResponseWriter, r *http.Request, id string) {
+	var changes map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", "Activity", "body", "invalid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, a := range s.activities {
+		if strconv.Itoa(a.id) != id {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(a.raw, &fields); err != nil {
+			writeError(w, http.StatusInternalServerError, "Server Error", "Activity", "raw", "invalid")
+			return
+		}
+		for k, v := range changes {
+			fields[k] = v
+		}
+		s.activities[i].raw, _ = json.Marshal(fields)
+		writeJSON(w, s.activities[i].raw)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "invalid")
+}
+
+// updateAthlete sets the athlete's weight, the only field Strava lets
+// applications change
+func (s *Server) updateAthlete(w http.ResponseWriter, r *http.Request) {
+	var changes struct {
+		Weight *float64 `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil || changes.Weight == nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", "Athlete", "weight", "invalid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(s.fixtures.Athlete, &fields); err != nil {
+		writeError(w, http.StatusInternalServerError, "Server Error", "Athlete", "raw", "invalid")
+		return
+	}
+	fields["weight"], _ = json.Marshal(*changes.Weight)
+	s.fixtures.Athlete, _ = json.Marshal(fields)
+	writeJSON(w, s.fixtures.Athlete)
+}
+
+// deleteActivity removes an activity from the list and detail endpoints
+func (s *Server) deleteActivity(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, a := range s.activities {
+		if strconv.Itoa(a.id) == id {
+			s.activities = append(s.activities[:i], s.activities[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "invalid")
+}
+
+// getByActivity serves the fixture for an activity sub-resource
+func (s *Server) getByActivity(w http.ResponseWriter, fixtures map[string]json.RawMessage, id string) {
+	if body, ok := fixtures[id]; ok {
+		writeJSON(w, body)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "invalid")
+}
+
+// getStreams serves the streams fixture, keyed by stream type when the
+// request sets key_by_type as Strava does
+func (s *Server) getStreams(w http.ResponseWriter, r *http.Request, id string) {
+	body, ok := s.fixtures.Streams[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "invalid")
+		return
+	}
+	if r.URL.Query().Get("key_by_type") != "true" {
+		writeJSON(w, body)
+		return
+	}
+
+	var streams []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &streams); err != nil {
+		// Already keyed by type
+		writeJSON(w, body)
+		return
+	}
+	keyed := make(map[string]map[string]json.RawMessage, len(streams))
+	for _, stream := range streams {
+		var key string
+		json.Unmarshal(stream["type"], &key)
+		keyed[key] = stream
+	}
+	body, _ = json.Marshal(keyed)
+	writeJSON(w, body)
+}
+
+// gzipWriter compresses a response, starting with its first byte of body
+// so empty responses stay empty
+type gzipWriter struct {
+	http.ResponseWriter
+	zw          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Del("Content-Length")
+		if status != http.StatusNoContent && status != http.StatusNotModified {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.zw == nil {
+		w.zw = gzip.NewWriter(w.ResponseWriter)
+	}
+	return w.zw.Write(b)
+}
+
+func (w *gzipWriter) Close() error {
+	if w.zw == nil {
+		return nil
+	}
+	return w.zw.Close()
+}
+
+func writeJSON(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
+
+// writeError sends a fault in the shape Strava uses, which the client
+// decodes into strava.APIError
+func writeError(w http.ResponseWriter, status int, message, resource, field, code string) {
+	fault := strava.APIError{Message: message}
+	if resource != "" {
+		fault.Errors = []strava.FieldError{{Resource: resource, Field: field, Code: code}}
+	}
+	body, _ := json.Marshal(fault)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
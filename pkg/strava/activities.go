@@ -0,0 +1,371 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MaxPerPage is the largest page size the activities endpoint accepts
+const MaxPerPage = 200
+
+// Activity is a summary activity as returned by the athlete activities list
+type Activity struct {
+	Id          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Distance    float64 `json:"distance"`
+	MovingTime  int     `json:"moving_time"`
+	ElapsedTime int     `json:"elapsed_time"`
+	Type        string  `json:"type"`
+	StartDate   string  `json:"start_date"`
+	StartTime   string  `json:"start_time"`
+	EndDate     string  `json:"end_date"`
+	EndTime     string  `json:"end_time"`
+	// Counts of social interactions at the time the activity was fetched
+	KudosCount   int `json:"kudos_count"`
+	CommentCount int `json:"comment_count"`
+	// Map carries the encoded route; the list endpoint only fills
+	// SummaryPolyline
+	Map *PolylineMap `json:"map,omitempty"`
+	// Sensors recorded with the activity, to tell which streams exist
+	// without fetching them
+	HasHeartrate bool `json:"has_heartrate"`
+	DeviceWatts  bool `json:"device_watts"`
+	// StartDateLocal is the start time in the activity's time zone, with a
+	// misleading Z suffix
+	StartDateLocal string `json:"start_date_local"`
+	Commute        bool   `json:"commute"`
+	Trainer        bool   `json:"trainer"`
+	// SportType is the finer grained sport, e.g. TrailRun where Type is Run
+	SportType string `json:"sport_type"`
+	// GearId is the bike or shoes used, empty when none was set
+	GearId string `json:"gear_id"`
+	// StartLatlng is the first GPS point, empty for indoor activities
+	StartLatlng LatLng `json:"start_latlng"`
+	// Kilojoules is the work done, only set for rides with power
+	Kilojoules float64 `json:"kilojoules"`
+	// TotalElevationGain is the climbing in meters
+	TotalElevationGain float64 `json:"total_elevation_gain"`
+	// Private is set for activities only the athlete can see; Visibility
+	// is one of the Visibility constants
+	Private    bool   `json:"private"`
+	Visibility string `json:"visibility"`
+	// HideFromHome is set for activities muted from followers' feeds. Only
+	// the detail and update endpoints report it.
+	HideFromHome bool `json:"hide_from_home"`
+	// WorkoutType is one of the WorkoutType constants for runs and rides,
+	// zero when the athlete has not set it
+	WorkoutType int `json:"workout_type"`
+
+	// Raw is the activity exactly as returned by the API
+	Raw json.RawMessage `json:"-"`
+}
+
+// Who can see an activity, as reported in Activity.Visibility
+const (
+	VisibilityEveryone      = "everyone"
+	VisibilityFollowersOnly = "followers_only"
+	VisibilityOnlyMe        = "only_me"
+)
+
+// VisibilityLevel is the activity's visibility, worked out from Private for
+// activities cached before Strava reported the visibility field
+func (a Activity) VisibilityLevel() string {
+	switch {
+	case a.Visibility != "":
+		return a.Visibility
+	case a.Private:
+		return VisibilityOnlyMe
+	}
+	return VisibilityEveryone
+}
+
+// Indoor reports whether the activity was recorded indoors: marked as on a
+// trainer or of a virtual sport, such as a VirtualRide from Zwift
+func (a Activity) Indoor() bool {
+	return a.Trainer || strings.HasPrefix(a.SportType, "Virtual") || strings.HasPrefix(a.Type, "Virtual")
+}
+
+// Workout types as reported in Activity.WorkoutType. Runs and rides number
+// theirs apart, and only runs have long runs.
+const (
+	WorkoutTypeRunDefault  = 0
+	WorkoutTypeRace        = 1
+	WorkoutTypeLongRun     = 2
+	WorkoutTypeWorkout     = 3
+	WorkoutTypeRideDefault = 10
+	WorkoutTypeRideRace    = 11
+	WorkoutTypeRideWorkout = 12
+)
+
+// Workout names, as returned by Activity.Workout
+const (
+	WorkoutDefault = "default"
+	WorkoutRace    = "race"
+	WorkoutLongRun = "long_run"
+	WorkoutWorkout = "workout"
+)
+
+// Workout names the activity's workout type, whether it is a run or a ride
+func (a Activity) Workout() string {
+	switch a.WorkoutType {
+	case WorkoutTypeRace, WorkoutTypeRideRace:
+		return WorkoutRace
+	case WorkoutTypeLongRun:
+		return WorkoutLongRun
+	case WorkoutTypeWorkout, WorkoutTypeRideWorkout:
+		return WorkoutWorkout
+	}
+	return WorkoutDefault
+}
+
+// WorkoutTypeFor returns the workout type that names workout for an
+// activity of activityType, such as Run or VirtualRide. It returns false
+// for sports without workout types and for long runs of rides.
+func WorkoutTypeFor(activityType, workout string) (int, bool) {
+	switch {
+	case strings.HasSuffix(activityType, "Run"):
+		switch workout {
+		case WorkoutDefault:
+			return WorkoutTypeRunDefault, true
+		case WorkoutRace:
+			return WorkoutTypeRace, true
+		case WorkoutLongRun:
+			return WorkoutTypeLongRun, true
+		case WorkoutWorkout:
+			return WorkoutTypeWorkout, true
+		}
+	case strings.HasSuffix(activityType, "Ride"):
+		switch workout {
+		case WorkoutDefault:
+			return WorkoutTypeRideDefault, true
+		case WorkoutRace:
+			return WorkoutTypeRideRace, true
+		case WorkoutWorkout:
+			return WorkoutTypeRideWorkout, true
+		}
+	}
+	return 0, false
+}
+
+// DetailedActivity is an activity as returned by GetActivity, including
+// the fields the list endpoint leaves out
+type DetailedActivity struct {
+	Activity
+	TotalPhotoCount int             `json:"total_photo_count"`
+	SegmentEfforts  []SegmentEffort `json:"segment_efforts"`
+	Laps            []Lap           `json:"laps"`
+	// BestEfforts are the fastest times over standard distances within a
+	// run, named "1k", "5k", "Half-Marathon" and so on
+	BestEfforts []SegmentEffort `json:"best_efforts"`
+	// Calories is Strava's estimate of the energy burned, in kcal
+	Calories float64 `json:"calories"`
+	// DeviceName is the device or app that recorded the activity, e.g.
+	// "Garmin Edge 530" or "Zwift"
+	DeviceName string `json:"device_name"`
+	// SplitsMetric and SplitsStandard cut the activity into kilometres and
+	// miles. Strava only splits runs and walks recorded with GPS.
+	SplitsMetric   []Split `json:"splits_metric"`
+	SplitsStandard []Split `json:"splits_standard"`
+}
+
+// Split is one kilometre or mile of an activity, numbered from 1. The last
+// one is usually shorter.
+type Split struct {
+	Split       int     `json:"split"`
+	Distance    float64 `json:"distance"`
+	ElapsedTime int     `json:"elapsed_time"`
+	MovingTime  int     `json:"moving_time"`
+	// ElevationDifference is the net climb in meters, negative downhill
+	ElevationDifference float64 `json:"elevation_difference"`
+	// AverageSpeed is in meters per second, as is
+	// AverageGradeAdjustedSpeed, the flat equivalent of the effort
+	AverageSpeed              float64 `json:"average_speed"`
+	AverageGradeAdjustedSpeed float64 `json:"average_grade_adjusted_speed"`
+	AverageHeartrate          float64 `json:"average_heartrate"`
+	PaceZone                  int     `json:"pace_zone"`
+}
+
+// UpdatableActivity is the set of changes made by UpdateActivity. Nil
+// fields are left as they are.
+type UpdatableActivity struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Commute     *bool   `json:"commute,omitempty"`
+	Trainer     *bool   `json:"trainer,omitempty"`
+	// HideFromHome keeps the activity out of followers' feeds
+	HideFromHome *bool `json:"hide_from_home,omitempty"`
+	// WorkoutType is one of the WorkoutType constants, see WorkoutTypeFor
+	WorkoutType *int `json:"workout_type,omitempty"`
+}
+
+// CreatableActivity is a manual activity for CreateActivity, one logged
+// without a recording such as a treadmill session
+type CreatableActivity struct {
+	Name string `json:"name"`
+	// SportType is the sport, e.g. Run or VirtualRide
+	SportType string `json:"sport_type"`
+	// StartDateLocal is the start in the athlete's time zone, as ISO 8601
+	// without an offset, e.g. 2024-05-01T07:00:00
+	StartDateLocal string `json:"start_date_local"`
+	// ElapsedTime is in seconds and Distance in meters
+	ElapsedTime int     `json:"elapsed_time"`
+	Distance    float64 `json:"distance,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Trainer     bool    `json:"trainer,omitempty"`
+	Commute     bool    `json:"commute,omitempty"`
+}
+
+// ListActivitiesOptions selects a page of the athlete's activities
+type ListActivitiesOptions struct {
+	Page    int
+	PerPage int
+	// Before and After are Unix timestamps bounding the activity start time
+	Before int64
+	After  int64
+
+	// Prefetch is the number of pages the iterator fetches concurrently.
+	// Zero or one fetches pages one at a time. ListActivities ignores it.
+	Prefetch int
+}
+
+func (o ListActivitiesOptions) values() url.Values {
+	q := url.Values{}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	if o.Before > 0 {
+		q.Set("before", strconv.FormatInt(o.Before, 10))
+	}
+	if o.After > 0 {
+		q.Set("after", strconv.FormatInt(o.After, 10))
+	}
+	return q
+}
+
+// ListActivities returns a single page of the authenticated athlete's
+// activities
+func (c *Client) ListActivities(ctx context.Context, opts ListActivitiesOptions) ([]Activity, error) {
+	return c.listActivities(ctx, opts, make([]Activity, 0, max(opts.PerPage, 0)))
+}
+
+// listActivities decodes a page of activities into buf, reusing its
+// backing array when it is large enough
+func (c *Client) listActivities(ctx context.Context, opts ListActivitiesOptions, buf []Activity) ([]Activity, error) {
+	page := activityPage{activities: buf[:0]}
+	if err := c.get(ctx, opList, "/athlete/activities", opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return page.activities, nil
+}
+
+// activityPage decodes the activities list one element at a time, so a
+// page is never held as both a buffered body and decoded activities
+type activityPage struct {
+	activities []Activity
+}
+
+func (p *activityPage) decodeStream(dec *json.Decoder) error {
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("expected an array of activities, got %v", tok)
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		p.activities = append(p.activities, Activity{})
+		a := &p.activities[len(p.activities)-1]
+		if err := json.Unmarshal(raw, a); err != nil {
+			return err
+		}
+		a.Raw = raw
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// GetActivity returns the activity with the given id. includeAllEfforts
+// asks for every segment effort rather than only the notable ones.
+func (c *Client) GetActivity(ctx context.Context, id int64, includeAllEfforts bool) (DetailedActivity, error) {
+	var q url.Values
+	if includeAllEfforts {
+		q = url.Values{"include_all_efforts": {"true"}}
+	}
+
+	var raw json.RawMessage
+	if err := c.get(ctx, opDetail, "/activities/"+strconv.FormatInt(id, 10), q, &raw); err != nil {
+		return DetailedActivity{}, err
+	}
+
+	var activity DetailedActivity
+	if err := json.Unmarshal(raw, &activity); err != nil {
+		return DetailedActivity{}, err
+	}
+	activity.Raw = raw
+	return activity, nil
+}
+
+// CreateActivity adds a manual activity for the authenticated athlete and
+// returns it. It needs the activity:write scope.
+func (c *Client) CreateActivity(ctx context.Context, activity CreatableActivity) (DetailedActivity, error) {
+	if err := c.requireScope(ScopeActivityWrite); err != nil {
+		return DetailedActivity{}, err
+	}
+	var raw json.RawMessage
+	if err := c.send(ctx, opUpload, http.MethodPost, "/activities", activity, &raw); err != nil {
+		return DetailedActivity{}, err
+	}
+
+	var created DetailedActivity
+	if err := json.Unmarshal(raw, &created); err != nil {
+		return DetailedActivity{}, err
+	}
+	created.Raw = raw
+	return created, nil
+}
+
+// UpdateActivity changes an activity owned by the authenticated athlete
+// and returns it as updated. It needs the activity:write scope.
+func (c *Client) UpdateActivity(ctx context.Context, id int64, update UpdatableActivity) (DetailedActivity, error) {
+	if err := c.requireScope(ScopeActivityWrite); err != nil {
+		return DetailedActivity{}, err
+	}
+	var raw json.RawMessage
+	if err := c.send(ctx, opUpload, http.MethodPut, "/activities/"+strconv.FormatInt(id, 10), update, &raw); err != nil {
+		return DetailedActivity{}, err
+	}
+
+	var activity DetailedActivity
+	if err := json.Unmarshal(raw, &activity); err != nil {
+		return DetailedActivity{}, err
+	}
+	activity.Raw = raw
+	return activity, nil
+}
+
+// DeleteActivity removes an activity owned by the authenticated athlete.
+// It needs the activity:write scope, and cannot be undone.
+func (c *Client) DeleteActivity(ctx context.Context, id int64) error {
+	if err := c.requireScope(ScopeActivityWrite); err != nil {
+		return err
+	}
+	ctx, cancel := c.withTimeout(ctx, opUpload)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.baseURL+"/activities/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return err
+	}
+	return c.doAuthorized(req, nil)
+}
@@ -0,0 +1,94 @@
+package strava
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// GetAthlete returns the authenticated athlete
+func (c *Client) GetAthlete(ctx context.Context) (SummaryAthlete, error) {
+	var athlete SummaryAthlete
+	err := c.get(ctx, opDetail, "/athlete", nil, &athlete)
+	return athlete, err
+}
+
+// DetailedAthlete is the authenticated athlete's own profile, which others
+// do not see. Weight is in kilograms and FTP in watts; Strava only lets
+// the weight be changed through the API.
+type DetailedAthlete struct {
+	SummaryAthlete
+	FollowerCount int `json:"follower_count"`
+	FriendCount   int `json:"friend_count"`
+	// MeasurementPreference is feet or meters
+	MeasurementPreference string  `json:"measurement_preference"`
+	FTP                   int     `json:"ftp"`
+	Weight                float64 `json:"weight"`
+	Clubs                 []Club  `json:"clubs"`
+	Bikes                 []Gear  `json:"bikes"`
+	Shoes                 []Gear  `json:"shoes"`
+	CreatedAt             string  `json:"created_at"`
+	UpdatedAt             string  `json:"updated_at"`
+}
+
+// GetDetailedAthlete returns the authenticated athlete's full profile,
+// including weight, FTP, clubs, and gear. Clubs and gear need the
+// profile:read_all scope, and are empty without it.
+func (c *Client) GetDetailedAthlete(ctx context.Context) (DetailedAthlete, error) {
+	var athlete DetailedAthlete
+	err := c.get(ctx, opDetail, "/athlete", nil, &athlete)
+	return athlete, err
+}
+
+// UpdatableAthlete is the profile field Strava lets applications change
+type UpdatableAthlete struct {
+	// Weight is in kilograms
+	Weight float64 `json:"weight"`
+}
+
+// UpdateAthlete changes the authenticated athlete's profile and returns it
+// as updated. It needs the profile:write scope.
+func (c *Client) UpdateAthlete(ctx context.Context, update UpdatableAthlete) (DetailedAthlete, error) {
+	var athlete DetailedAthlete
+	if err := c.requireScope(ScopeProfileWrite); err != nil {
+		return athlete, err
+	}
+	err := c.send(ctx, opUpload, http.MethodPut, "/athlete", update, &athlete)
+	return athlete, err
+}
+
+// ActivityStats are an athlete's ride, run, and swim totals over the last
+// four weeks, the year to date, and all time. Other sport types are not
+// counted.
+type ActivityStats struct {
+	BiggestRideDistance       float64       `json:"biggest_ride_distance"`
+	BiggestClimbElevationGain float64       `json:"biggest_climb_elevation_gain"`
+	RecentRideTotals          ActivityTotal `json:"recent_ride_totals"`
+	RecentRunTotals           ActivityTotal `json:"recent_run_totals"`
+	RecentSwimTotals          ActivityTotal `json:"recent_swim_totals"`
+	YtdRideTotals             ActivityTotal `json:"ytd_ride_totals"`
+	YtdRunTotals              ActivityTotal `json:"ytd_run_totals"`
+	YtdSwimTotals             ActivityTotal `json:"ytd_swim_totals"`
+	AllRideTotals             ActivityTotal `json:"all_ride_totals"`
+	AllRunTotals              ActivityTotal `json:"all_run_totals"`
+	AllSwimTotals             ActivityTotal `json:"all_swim_totals"`
+}
+
+// ActivityTotal sums a set of activities. Distance and ElevationGain are in
+// meters, times in seconds.
+type ActivityTotal struct {
+	Count            int     `json:"count"`
+	Distance         float64 `json:"distance"`
+	MovingTime       int     `json:"moving_time"`
+	ElapsedTime      int     `json:"elapsed_time"`
+	ElevationGain    float64 `json:"elevation_gain"`
+	AchievementCount int     `json:"achievement_count"`
+}
+
+// GetAthleteStats returns the totals of the athlete with the given id, which
+// must be the authenticated athlete
+func (c *Client) GetAthleteStats(ctx context.Context, athleteID int64) (ActivityStats, error) {
+	var stats ActivityStats
+	err := c.get(ctx, opDetail, "/athletes/"+strconv.FormatInt(athleteID, 10)+"/stats", nil, &stats)
+	return stats, err
+}
@@ -0,0 +1,252 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token is an OAuth access token and the refresh token that renews it
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+	TokenType    string `json:"token_type"`
+	// Scope lists the scopes the athlete granted, comma-separated, when
+	// known. Strava reports them on the authorization redirect rather than
+	// in token responses.
+	Scope string `json:"scope,omitempty"`
+}
+
+// Expired reports whether the access token is missing or expires within the
+// next minute
+func (t Token) Expired() bool {
+	return t.AccessToken == "" || time.Unix(t.ExpiresAt, 0).Before(time.Now().Add(time.Minute))
+}
+
+// Token returns the token currently used by the client
+func (c *Client) Token() Token {
+	if t := c.token.Load(); t != nil {
+		return *t
+	}
+	return Token{}
+}
+
+// Authenticate makes sure the client holds a usable access token. The token
+// is loaded from the token store when one is configured, and refreshed when it
+// has expired.
+func (c *Client) Authenticate(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if c.tokenStore != nil {
+		stored, err := c.tokenStore.Load(ctx)
+		switch {
+		case errors.Is(err, ErrNoToken):
+			// Nothing saved yet - fall back to the token from WithToken
+		case err != nil:
+			return err
+		default:
+			c.token.Store(&stored)
+		}
+	}
+
+	token := c.Token()
+	if !token.Expired() {
+		return nil
+	}
+	if token.RefreshToken == "" {
+		return errors.New("strava: no refresh token available")
+	}
+
+	_, err := c.refresh(ctx, token.RefreshToken)
+	return err
+}
+
+// authorize sets the bearer token on req, first refreshing the token when
+// it has expired and the client is able to. Concurrent callers that find
+// it expired share a single refresh.
+func (c *Client) authorize(req *http.Request) error {
+	token := c.Token()
+	if token.Expired() && token.RefreshToken != "" && c.clientID != "" && c.clientSecret != "" {
+		c.refreshMu.Lock()
+		// Another goroutine may have refreshed it while this one waited
+		if token = c.Token(); token.Expired() {
+			var err error
+			token, err = c.refresh(req.Context(), token.RefreshToken)
+			if err != nil {
+				c.refreshMu.Unlock()
+				return err
+			}
+		}
+		c.refreshMu.Unlock()
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+// doAuthorized authorizes and sends req. When the access token is rejected
+// with a 401 before it was due to expire, as after the athlete revokes and
+// re-grants access, the token is refreshed and the request sent once more.
+// Concurrent callers rejected with the same token share a single refresh.
+func (c *Client) doAuthorized(req *http.Request, out interface{}) error {
+	if err := c.authorize(req); err != nil {
+		return err
+	}
+	err := c.do(req, out)
+
+	var apiErr *APIError
+	var scopeErr *ScopeError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || errors.As(err, &scopeErr) {
+		return err
+	}
+	token := c.Token()
+	if token.RefreshToken == "" || c.clientID == "" || c.clientSecret == "" {
+		return err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return err
+	}
+
+	c.refreshMu.Lock()
+	// Another goroutine may have refreshed it after the same rejection
+	if token = c.Token(); "Bearer "+token.AccessToken == req.Header.Get("Authorization") {
+		c.logger.Printf("%s %s: access token rejected, refreshing\n", req.Method, req.URL.Path)
+		var refreshErr error
+		if token, refreshErr = c.refresh(req.Context(), token.RefreshToken); refreshErr != nil {
+			c.refreshMu.Unlock()
+			return refreshErr
+		}
+	}
+	c.refreshMu.Unlock()
+
+	if req.GetBody != nil {
+		if req.Body, err = req.GetBody(); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return c.do(req, out)
+}
+
+// Refresh exchanges refreshToken for a new access token and starts using it.
+// Strava may rotate the refresh token, so the new token is saved to the token
+// store when one is configured.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (Token, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.refresh(ctx, refreshToken)
+}
+
+// refresh implements Refresh. c.refreshMu must be held.
+func (c *Client) refresh(ctx context.Context, refreshToken string) (Token, error) {
+	if c.clientID == "" || c.clientSecret == "" {
+		return Token{}, errors.New("strava: client ID and secret are required to refresh a token")
+	}
+
+	ctx, cancel := c.withTimeout(ctx, opDetail)
+	defer cancel()
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+
+	var token Token
+	if err := c.postToken(ctx, form, &token); err != nil {
+		return Token{}, err
+	}
+
+	if token.RefreshToken != refreshToken {
+		c.logger.Println("Refresh token rotated by Strava")
+	}
+	// A refreshed token keeps the scopes of the one it replaces
+	if token.Scope == "" {
+		token.Scope = c.Token().Scope
+	}
+
+	c.token.Store(&token)
+	if c.tokenStore != nil {
+		if err := c.tokenStore.Save(ctx, token); err != nil {
+			return token, fmt.Errorf("strava: saving refreshed token: %w", err)
+		}
+	}
+	return token, nil
+}
+
+// postToken sends a grant to the token endpoint with the client's
+// credentials and decodes the response into out
+func (c *Client) postToken(ctx context.Context, form url.Values, out interface{}) error {
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, out)
+}
+
+// DefaultAuthorizeURL is the page where athletes authorize an application
+const DefaultAuthorizeURL = "https://www.strava.com/oauth/authorize"
+
+// AuthorizeURL returns the page asking the athlete to grant the application
+// scopes. Strava then redirects to redirectURI, whose host must be the
+// application's Authorization Callback Domain, with state, a code for
+// Exchange, and the scopes granted, which may be fewer than asked for. A
+// client pointed at another API root with WithBaseURL, such as strava-mock,
+// uses its /oauth/authorize.
+func (c *Client) AuthorizeURL(redirectURI, state string, scopes ...string) string {
+	authorize := DefaultAuthorizeURL
+	if c.baseURL != DefaultBaseURL {
+		authorize = c.baseURL + "/oauth/authorize"
+	}
+	q := url.Values{}
+	q.Set("client_id", c.clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("response_type", "code")
+	q.Set("approval_prompt", "auto")
+	q.Set("scope", strings.Join(scopes, ","))
+	q.Set("state", state)
+	return authorize + "?" + q.Encode()
+}
+
+// Exchange trades the code of an authorization redirect for the token of
+// the athlete who granted it, returning the athlete as well. scope is the
+// scope parameter of the redirect, kept as the token's Scope since the
+// response does not repeat it. The client keeps using its own token.
+func (c *Client) Exchange(ctx context.Context, code, scope string) (Token, SummaryAthlete, error) {
+	if c.clientID == "" || c.clientSecret == "" {
+		return Token{}, SummaryAthlete{}, errors.New("strava: client ID and secret are required to exchange a code")
+	}
+
+	ctx, cancel := c.withTimeout(ctx, opDetail)
+	defer cancel()
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+
+	var res struct {
+		Token
+		Athlete SummaryAthlete `json:"athlete"`
+	}
+	if err := c.postToken(ctx, form, &res); err != nil {
+		return Token{}, SummaryAthlete{}, err
+	}
+	if res.Scope == "" {
+		res.Scope = scope
+	}
+	return res.Token, res.Athlete, nil
+}
+
+// Probe checks that the access token is accepted by fetching the
+// authenticated athlete. It always asks Strava, bypassing the response
+// cache. The response body is discarded.
+func (c *Client) Probe(ctx context.Context) error {
+	return c.get(withoutCache(ctx), opDetail, "/athlete", nil, nil)
+}
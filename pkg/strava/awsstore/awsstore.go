@@ -0,0 +1,164 @@
+// Package awsstore reads configuration from, and persists rotated tokens to,
+// AWS Secrets Manager or SSM Parameter Store. It is meant for running the
+// sync on Lambda, ECS, or other AWS compute where there is no writable env
+// file.
+package awsstore
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// SecretsManagerAPI is the subset of the Secrets Manager client used here
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+}
+
+// SecretsManagerStore keeps STRAVA_* values as a JSON object in a single
+// secret, for example {"STRAVA_CLIENT_ID": "...", "STRAVA_REFRESH_TOKEN": "..."}
+type SecretsManagerStore struct {
+	Client   SecretsManagerAPI
+	SecretID string
+}
+
+func NewSecretsManagerStore(cfg aws.Config, secretID string) *SecretsManagerStore {
+	return &SecretsManagerStore{Client: secretsmanager.NewFromConfig(cfg), SecretID: secretID}
+}
+
+// Values returns every key in the secret
+func (s *SecretsManagerStore) Values(ctx context.Context) (map[string]string, error) {
+	out, err := s.Client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(s.SecretID)})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	if out.SecretString == nil {
+		return values, nil
+	}
+	if err := json.Unmarshal([]byte(*out.SecretString), &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (s *SecretsManagerStore) Load(ctx context.Context) (strava.Token, error) {
+	values, err := s.Values(ctx)
+	if err != nil {
+		return strava.Token{}, err
+	}
+	return tokenFromValues(values)
+}
+
+// Save writes the token keys into the secret, keeping the other keys intact
+func (s *SecretsManagerStore) Save(ctx context.Context, token strava.Token) error {
+	values, err := s.Values(ctx)
+	if err != nil {
+		return err
+	}
+	for key, value := range tokenValues(token) {
+		values[key] = value
+	}
+
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(s.SecretID),
+		SecretString: aws.String(string(raw)),
+	})
+	return err
+}
+
+// SSMAPI is the subset of the SSM client used here
+type SSMAPI interface {
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+}
+
+// ParameterStore keeps one SecureString parameter per STRAVA_* key under
+// Path, for example /strava-api/STRAVA_REFRESH_TOKEN
+type ParameterStore struct {
+	Client SSMAPI
+	Path   string
+}
+
+func NewParameterStore(cfg aws.Config, path string) *ParameterStore {
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return &ParameterStore{Client: ssm.NewFromConfig(cfg), Path: path}
+}
+
+// Values returns every parameter directly under Path, keyed by its name
+// without the path prefix
+func (s *ParameterStore) Values(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+	paginator := ssm.NewGetParametersByPathPaginator(s.Client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(s.Path),
+		WithDecryption: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Parameters {
+			values[strings.TrimPrefix(aws.ToString(p.Name), s.Path)] = aws.ToString(p.Value)
+		}
+	}
+	return values, nil
+}
+
+func (s *ParameterStore) Load(ctx context.Context) (strava.Token, error) {
+	values, err := s.Values(ctx)
+	if err != nil {
+		return strava.Token{}, err
+	}
+	return tokenFromValues(values)
+}
+
+func (s *ParameterStore) Save(ctx context.Context, token strava.Token) error {
+	for key, value := range tokenValues(token) {
+		_, err := s.Client.PutParameter(ctx, &ssm.PutParameterInput{
+			Name:      aws.String(s.Path + key),
+			Value:     aws.String(value),
+			Type:      ssmtypes.ParameterTypeSecureString,
+			Overwrite: aws.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tokenFromValues(values map[string]string) (strava.Token, error) {
+	token := strava.Token{
+		RefreshToken: values[strava.EnvRefreshToken],
+		AccessToken:  values[strava.EnvAccessToken],
+	}
+	token.ExpiresAt, _ = strconv.ParseInt(values[strava.EnvTokenExpiresAt], 10, 64)
+	if token.RefreshToken == "" {
+		return strava.Token{}, strava.ErrNoToken
+	}
+	return token, nil
+}
+
+func tokenValues(token strava.Token) map[string]string {
+	return map[string]string{
+		strava.EnvRefreshToken:   token.RefreshToken,
+		strava.EnvAccessToken:    token.AccessToken,
+		strava.EnvTokenExpiresAt: strconv.FormatInt(token.ExpiresAt, 10),
+	}
+}
@@ -0,0 +1,369 @@
+// Package strava is a client for the Strava v3 API. Its exported API follows
+// semantic versioning: within a major version it only grows, as described
+// under "Versioning and API stability" in the repository README.
+package strava
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the Strava v3 API root
+	DefaultBaseURL = "https://www.strava.com/api/v3"
+	// DefaultUserAgent is sent when WithUserAgent is not provided
+	DefaultUserAgent = "strava-api (+https://github.com/brandtkeller/strava-api)"
+)
+
+// Client talks to the Strava API. Create one with New. A Client is safe for
+// concurrent use by multiple goroutines.
+type Client struct {
+	httpClient   *http.Client
+	limiter      RateLimiter
+	baseURL      string
+	logger       *log.Logger
+	userAgent    string
+	clientID     string
+	clientSecret string
+	token        atomic.Pointer[Token]
+	tokenStore   TokenStore
+	// refreshMu lets one goroutine refresh an expired token while the
+	// others wait for its result
+	refreshMu   sync.Mutex
+	cacheDir    string
+	cacheTTL    time.Duration
+	timeouts    Timeouts
+	breaker     *breaker
+	logPayloads bool
+	// maxRetries and retryBackoff are set by WithRetries
+	maxRetries   int
+	retryBackoff time.Duration
+	// dryRun receives the mutations WithDryRun keeps from being sent
+	dryRun io.Writer
+	// scopes are the scopes set by WithScopes, and missingScopes those a
+	// response said the token lacks
+	scopes        []string
+	missingScopes sync.Map
+
+	requestHooks  []func(*http.Request)
+	responseHooks []func(*http.Response)
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// New returns a Client configured by opts. Without options it uses
+// http.DefaultClient, the Strava default rate limits, and log.Default().
+func New(opts ...Option) *Client {
+	c := &Client{
+		httpClient: http.DefaultClient,
+		baseURL:    DefaultBaseURL,
+		logger:     log.Default(),
+		userAgent:  DefaultUserAgent,
+		timeouts:   DefaultTimeouts,
+		breaker:    newBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+
+		maxRetries:   DefaultMaxRetries,
+		retryBackoff: DefaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.limiter == nil {
+		c.limiter = NewRateLimiter()
+	}
+	// Limiters that track server-reported usage observe every response
+	if u, ok := c.limiter.(interface{ Update(http.Header) }); ok {
+		c.responseHooks = append([]func(*http.Response){func(res *http.Response) {
+			u.Update(res.Header)
+		}}, c.responseHooks...)
+	}
+	// Wrap a copy so the caller's client is left untouched. The limiter and
+	// hooks sit below the cache and dry run so that responses answered
+	// locally are neither paced nor counted as API calls.
+	httpClient := *c.httpClient
+	httpClient.Transport = &meteredTransport{client: c, next: httpClient.Transport}
+	if c.cacheDir != "" {
+		httpClient.Transport = newCachingTransport(c.cacheDir, c.cacheTTL, httpClient.Transport)
+	}
+	if c.dryRun != nil {
+		httpClient.Transport = &dryRunTransport{next: httpClient.Transport, out: c.dryRun}
+	}
+	c.httpClient = &httpClient
+	return c
+}
+
+// RateLimit reports the rate limiter's usage. It returns false when the
+// limiter, such as one supplied with WithRateLimiter, does not track usage.
+func (c *Client) RateLimit() (RateLimitUsage, bool) {
+	if u, ok := c.limiter.(interface{ Usage() RateLimitUsage }); ok {
+		return u.Usage(), true
+	}
+	return RateLimitUsage{}, false
+}
+
+// WithHTTPClient sets the HTTP client used for every request, which lets
+// callers supply their own transport or proxy policy. Prefer WithTimeouts
+// over http.Client.Timeout so slow stream downloads and uploads are not cut
+// off by a limit meant for list calls.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRateLimiter replaces the default rate limiter
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithBaseURL points the client at a different API root, such as a test
+// server. The OAuth token endpoint is resolved relative to it.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithLogger sets the logger used for diagnostic output
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRequestHook registers fn to be called with every request just before
+// it is sent to Strava, after the client has set its own headers. Requests
+// answered from the response cache or by a dry run are not sent and do not
+// reach the hook. Hooks may add
+// headers, for example for tracing, but must not consume the body.
+func WithRequestHook(fn func(*http.Request)) Option {
+	return func(c *Client) {
+		c.requestHooks = append(c.requestHooks, fn)
+	}
+}
+
+// WithResponseHook registers fn to be called with every response from
+// Strava before its body is read, for example to record metrics or audit
+// logs. Cached responses and dry run stubs are not passed to the hook. Hooks must not
+// consume the body.
+func WithResponseHook(fn func(*http.Response)) Option {
+	return func(c *Client) {
+		c.responseHooks = append(c.responseHooks, fn)
+	}
+}
+
+// WithCredentials sets the application client ID and secret used to refresh
+// access tokens
+func WithCredentials(clientID string, clientSecret string) Option {
+	return func(c *Client) {
+		c.clientID = clientID
+		c.clientSecret = clientSecret
+	}
+}
+
+// WithToken sets the initial token, for example one loaded from disk
+func WithToken(token Token) Option {
+	return func(c *Client) {
+		c.token.Store(&token)
+	}
+}
+
+// get issues an authenticated GET for path with query and decodes the JSON
+// response into out, bounded by the timeout for op
+func (c *Client) get(ctx context.Context, op operation, path string, query url.Values, out interface{}) error {
+	ctx, cancel := c.withTimeout(ctx, op)
+	defer cancel()
+
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.doAuthorized(req, out)
+}
+
+// send issues an authenticated request for path with body encoded as
+// JSON, and decodes the JSON response into out, bounded by the timeout for op
+func (c *Client) send(ctx context.Context, op operation, method, path string, body interface{}, out interface{}) error {
+	ctx, cancel := c.withTimeout(ctx, op)
+	defer cancel()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.doAuthorized(req, out)
+}
+
+// do sends req, retrying it as configured by WithRetries, and decodes a
+// successful JSON response into out as it is read. Non-2xx responses are
+// returned as *APIError.
+func (c *Client) do(req *http.Request, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		err := c.roundTrip(req, out)
+		wait, ok := c.retryWait(req, attempt, err)
+		if !ok {
+			return err
+		}
+		c.logger.Printf("%s %s: %v, retrying in %s\n", req.Method, req.URL.Path, err, wait)
+		if sleep(req.Context(), wait) != nil {
+			return err
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// meteredTransport is the bottom of the client's transport chain: it waits
+// on the rate limiter and runs the request and response hooks for requests
+// that actually leave the process
+type meteredTransport struct {
+	client *Client
+	next   http.RoundTripper
+}
+
+// limiterError marks an error returned by the rate limiter so roundTrip can
+// tell it apart from a transport failure
+type limiterError struct{ err error }
+
+func (e *limiterError) Error() string { return e.err.Error() }
+func (e *limiterError) Unwrap() error { return e.err }
+
+func (t *meteredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.client
+	if err := c.limiter.Wait(req.Context()); err != nil {
+		return nil, &limiterError{err}
+	}
+
+	// Hooks may add headers, so give them a copy rather than the caller's
+	// request
+	req = req.Clone(req.Context())
+	for _, hook := range c.requestHooks {
+		hook(req)
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range c.responseHooks {
+		hook(res)
+	}
+	return res, nil
+}
+
+// roundTrip sends req once after checking the circuit breaker. The rate
+// limiter is waited on by meteredTransport, so cached responses and dry run
+// stubs are returned without it.
+func (c *Client) roundTrip(req *http.Request, out interface{}) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		var limited *limiterError
+		switch {
+		case errors.As(err, &limited):
+			c.breaker.abort()
+			return limited.err
+		case req.Context().Err() != nil:
+			// Our own cancellation says nothing about Strava's health
+			c.breaker.abort()
+		default:
+			c.breaker.record(true)
+		}
+		return err
+	}
+	defer res.Body.Close()
+
+	c.breaker.record(res.StatusCode >= 500)
+
+	body, err := newPayload(res)
+	if err != nil {
+		return fmt.Errorf("decompressing %s %s response: %w", req.Method, req.URL.Path, err)
+	}
+	defer body.Close()
+	if c.logPayloads {
+		defer func() {
+			c.logger.Printf("%s %s: %d bytes received, %d decoded\n", req.Method, req.URL.Path, body.wire.n, body.decoded.n)
+		}()
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		return c.scopeError(newAPIError(res, raw))
+	}
+
+	// File downloads such as route exports are returned as is
+	if file, ok := out.(*[]byte); ok {
+		*file, err = io.ReadAll(body)
+		return err
+	}
+	if out != nil {
+		dec := json.NewDecoder(body)
+		if stream, ok := out.(streamDecoder); ok {
+			err = stream.decodeStream(dec)
+		} else {
+			err = dec.Decode(out)
+		}
+		if err != nil {
+			return fmt.Errorf("decoding %s %s response: %w", req.Method, req.URL.Path, err)
+		}
+	}
+	// Drain whatever is left so the connection can be reused
+	_, err = io.Copy(io.Discard, body)
+	return err
+}
+
+// streamDecoder is implemented by responses that decode themselves as the
+// body is read, rather than after it has been buffered in full
+type streamDecoder interface {
+	decodeStream(dec *json.Decoder) error
+}
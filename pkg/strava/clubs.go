@@ -0,0 +1,95 @@
+package strava
+
+import (
+	"context"
+	"strconv"
+)
+
+// Club is a summary club, as listed for the authenticated athlete
+type Club struct {
+	Id            int64    `json:"id"`
+	Name          string   `json:"name"`
+	ProfileMedium string   `json:"profile_medium"`
+	CoverPhoto    string   `json:"cover_photo"`
+	SportType     string   `json:"sport_type"`
+	ActivityTypes []string `json:"activity_types"`
+	City          string   `json:"city"`
+	State         string   `json:"state"`
+	Country       string   `json:"country"`
+	Private       bool     `json:"private"`
+	MemberCount   int      `json:"member_count"`
+	Featured      bool     `json:"featured"`
+	Verified      bool     `json:"verified"`
+	URL           string   `json:"url"`
+}
+
+// DetailedClub is a club as returned by GetClub
+type DetailedClub struct {
+	Club
+	Membership     string `json:"membership"`
+	Admin          bool   `json:"admin"`
+	Owner          bool   `json:"owner"`
+	FollowingCount int    `json:"following_count"`
+}
+
+// ClubAthlete is a club member. Strava only exposes names and roles.
+type ClubAthlete struct {
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+	Member    string `json:"member"`
+	Admin     bool   `json:"admin"`
+	Owner     bool   `json:"owner"`
+}
+
+// ClubActivity is the reduced activity model returned for club feeds. It
+// has no id or start date, and the athlete is identified by name only.
+type ClubActivity struct {
+	Athlete struct {
+		Firstname string `json:"firstname"`
+		Lastname  string `json:"lastname"`
+	} `json:"athlete"`
+	Name               string  `json:"name"`
+	Distance           float64 `json:"distance"`
+	MovingTime         int     `json:"moving_time"`
+	ElapsedTime        int     `json:"elapsed_time"`
+	TotalElevationGain float64 `json:"total_elevation_gain"`
+	Type               string  `json:"type"`
+	SportType          string  `json:"sport_type"`
+	WorkoutType        *int    `json:"workout_type"`
+}
+
+// ListAthleteClubs returns a page of the clubs the authenticated athlete
+// belongs to
+func (c *Client) ListAthleteClubs(ctx context.Context, opts PageOptions) ([]Club, error) {
+	clubs := make([]Club, 0)
+	if err := c.get(ctx, opList, "/athlete/clubs", opts.values(), &clubs); err != nil {
+		return nil, err
+	}
+	return clubs, nil
+}
+
+// GetClub returns the club with the given id
+func (c *Client) GetClub(ctx context.Context, id int64) (DetailedClub, error) {
+	var club DetailedClub
+	err := c.get(ctx, opDetail, "/clubs/"+strconv.FormatInt(id, 10), nil, &club)
+	return club, err
+}
+
+// ListClubMembers returns a page of a club's members
+func (c *Client) ListClubMembers(ctx context.Context, id int64, opts PageOptions) ([]ClubAthlete, error) {
+	members := make([]ClubAthlete, 0)
+	if err := c.get(ctx, opList, "/clubs/"+strconv.FormatInt(id, 10)+"/members", opts.values(), &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ListClubActivities returns a page of recent activities by club members,
+// newest first. Strava only serves the most recent activities this way.
+func (c *Client) ListClubActivities(ctx context.Context, id int64, opts PageOptions) ([]ClubActivity, error) {
+	activities := make([]ClubActivity, 0)
+	if err := c.get(ctx, opList, "/clubs/"+strconv.FormatInt(id, 10)+"/activities", opts.values(), &activities); err != nil {
+		return nil, err
+	}
+	return activities, nil
+}
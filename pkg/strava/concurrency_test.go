@@ -0,0 +1,155 @@
+package strava_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/stravatest"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+var discard = log.New(io.Discard, "", 0)
+
+// parallel runs fn on n goroutines at once and returns their errors
+func parallel(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+func TestConcurrentListActivities(t *testing.T) {
+	srv := stravatest.NewServer(stravatest.WithActivities(testActivities(250)...))
+	defer srv.Close()
+	client := srv.Client(strava.WithLogger(discard))
+
+	errs := parallel(8, func(i int) error {
+		activities, err := client.ListAll(context.Background(), strava.ListActivitiesOptions{PerPage: 30, Prefetch: i % 3})
+		if err != nil {
+			return err
+		}
+		if len(activities) != 250 {
+			return fmt.Errorf("listed %d activities, want 250", len(activities))
+		}
+		for j, a := range activities {
+			if a.Id != 250-j {
+				return fmt.Errorf("activity %d has id %d, want %d", j, a.Id, 250-j)
+			}
+		}
+		return nil
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	// Every goroutine found the token missing, and they shared one refresh
+	if got := client.Token().AccessToken; got != "access-token-1" {
+		t.Errorf("access token = %q, want access-token-1 from a single refresh", got)
+	}
+}
+
+func TestConcurrentTokenRefresh(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	client := srv.Client(strava.WithLogger(discard))
+
+	// Half the goroutines make requests while the rest force refreshes,
+	// so requests read the token while it is being replaced
+	errs := parallel(16, func(i int) error {
+		for j := 0; j < 5; j++ {
+			if i%2 == 0 {
+				if _, err := client.Refresh(context.Background(), stravatest.RefreshToken); err != nil {
+					return err
+				}
+				continue
+			}
+			athlete, err := client.GetAthlete(context.Background())
+			var apiErr *strava.APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+				// The token was replaced between authorizing and serving
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if athlete.Id != 1 {
+				return fmt.Errorf("athlete id = %d, want 1", athlete.Id)
+			}
+		}
+		return nil
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if token := client.Token(); !strings.HasPrefix(token.AccessToken, "access-token-") || token.Expired() {
+		t.Errorf("token after concurrent refreshes = %+v, want a fresh one from the server", token)
+	}
+}
+
+func TestConcurrentRateLimiter(t *testing.T) {
+	const limit = 40
+	srv := stravatest.NewServer(stravatest.WithRateLimit(limit, 1000))
+	defer srv.Close()
+	client := srv.Client(strava.WithLogger(discard))
+
+	// A deadline before the quarter-hour reset makes the limiter fail fast
+	// rather than wait once the quota is used up
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	succeeded := 0
+	errs := parallel(8, func(i int) error {
+		for j := 0; j < 10; j++ {
+			_, err := client.GetAthlete(ctx)
+			var apiErr *strava.APIError
+			switch {
+			case err == nil:
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			case errors.Is(err, strava.ErrRateLimited):
+			case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+			default:
+				return err
+			}
+			if _, ok := client.RateLimit(); !ok {
+				return errors.New("default limiter does not report usage")
+			}
+		}
+		return nil
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if succeeded == 0 || succeeded > limit {
+		t.Errorf("%d requests succeeded, want between 1 and %d", succeeded, limit)
+	}
+	usage, _ := client.RateLimit()
+	if usage.ShortLimit != limit {
+		t.Errorf("limiter short limit = %d, want %d from the server's headers", usage.ShortLimit, limit)
+	}
+}
@@ -0,0 +1,180 @@
+package strava
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugTransport writes a sanitized dump of every request and response,
+// with httptrace timings, to Out. Authorization headers and OAuth secrets in
+// bodies are redacted and bodies are truncated to MaxBody bytes.
+type DebugTransport struct {
+	Next    http.RoundTripper
+	Out     io.Writer
+	MaxBody int
+
+	mu sync.Mutex
+}
+
+// NewDebugTransport wraps next, or http.DefaultTransport when nil
+func NewDebugTransport(next http.RoundTripper, out io.Writer, maxBody int) *DebugTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &DebugTransport{Next: next, Out: out, MaxBody: maxBody}
+}
+
+// secretFields are redacted from form bodies
+var secretFields = []string{"client_secret", "refresh_token", "access_token", "code"}
+
+// jsonSecretFields are redacted from JSON bodies. Authorization codes only
+// travel in forms, and "code" in a JSON fault is the error code.
+var jsonSecretFields = []string{"client_secret", "refresh_token", "access_token"}
+
+var jsonSecretPattern = regexp.MustCompile(`("(?:` + strings.Join(jsonSecretFields, "|") + `)"\s*:\s*)"[^"]*"`)
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err == nil {
+			reqBody, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	var timings traceTimings
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timings.trace(start)))
+
+	res, err := t.Next.RoundTrip(req)
+	total := time.Since(start)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "> %s %s\n", req.Method, req.URL.Redacted())
+	writeHeaders(&buf, "> ", req.Header)
+	if len(reqBody) > 0 {
+		fmt.Fprintf(&buf, ">\n%s\n", t.truncate(redactBody(req.Header, reqBody)))
+	}
+
+	if err != nil {
+		fmt.Fprintf(&buf, "! error after %s: %v\n", total.Round(time.Millisecond), err)
+	} else {
+		fmt.Fprintf(&buf, "< %s\n", res.Status)
+		writeHeaders(&buf, "< ", res.Header)
+
+		body, readErr := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		if readErr != nil {
+			err, res = readErr, nil
+		} else if len(body) > 0 {
+			fmt.Fprintf(&buf, "<\n%s\n", t.truncate(redactBody(res.Header, decompressed(res.Header, body))))
+		}
+	}
+	fmt.Fprintf(&buf, "* %s total %s\n\n", timings.String(), total.Round(time.Millisecond))
+
+	t.mu.Lock()
+	t.Out.Write(buf.Bytes())
+	t.mu.Unlock()
+
+	return res, err
+}
+
+func (t *DebugTransport) truncate(body []byte) string {
+	if t.MaxBody >= 0 && len(body) > t.MaxBody {
+		return fmt.Sprintf("%s... (%d bytes truncated)", body[:t.MaxBody], len(body)-t.MaxBody)
+	}
+	return string(body)
+}
+
+func writeHeaders(w io.Writer, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := strings.Join(h[key], ", ")
+		if strings.EqualFold(key, "Authorization") || strings.EqualFold(key, "Cookie") || strings.EqualFold(key, "Set-Cookie") {
+			value = "REDACTED"
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", prefix, key, value)
+	}
+}
+
+// decompressed returns a gzipped body as text for the dump, leaving the
+// body passed on to the client compressed
+func decompressed(h http.Header, body []byte) []byte {
+	if !strings.EqualFold(h.Get("Content-Encoding"), "gzip") {
+		return body
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return body
+	}
+	return plain
+}
+
+// redactBody masks OAuth secrets in a body sent with header h. Bodies
+// declared as form encoded are parsed as forms, anything else is treated as
+// JSON.
+func redactBody(h http.Header, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
+		return jsonSecretPattern.ReplaceAll(body, []byte(`$1"REDACTED"`))
+	}
+	if form, err := url.ParseQuery(string(body)); err == nil {
+		for _, field := range secretFields {
+			if form.Has(field) {
+				form.Set(field, "REDACTED")
+			}
+		}
+		return []byte(form.Encode())
+	}
+	// A malformed form may still carry secrets, so drop it
+	return []byte("(unparsable form body redacted)")
+}
+
+// traceTimings records connection phase durations for one request
+type traceTimings struct {
+	dns, connect, tls, ttfb time.Duration
+	reused                  bool
+}
+
+func (tt *traceTimings) trace(start time.Time) *httptrace.ClientTrace {
+	var dnsStart, connectStart, tlsStart time.Time
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { tt.dns = time.Since(dnsStart) },
+		ConnectStart:      func(string, string) { connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { tt.connect = time.Since(connectStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { tt.tls = time.Since(tlsStart) },
+		GotConn:           func(info httptrace.GotConnInfo) { tt.reused = info.Reused },
+		GotFirstResponseByte: func() {
+			tt.ttfb = time.Since(start)
+		},
+	}
+}
+
+func (tt *traceTimings) String() string {
+	return fmt.Sprintf("dns %s connect %s tls %s ttfb %s reused %t",
+		tt.dns.Round(time.Millisecond), tt.connect.Round(time.Millisecond), tt.tls.Round(time.Millisecond), tt.ttfb.Round(time.Millisecond), tt.reused)
+}
@@ -0,0 +1,50 @@
+package strava
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	form := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	json := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   string
+	}{
+		{
+			name:   "token form",
+			header: form,
+			body:   "client_id=1&client_secret=s3cret&code=abc&grant_type=authorization_code",
+			want:   "client_id=1&client_secret=REDACTED&code=REDACTED&grant_type=authorization_code",
+		},
+		{
+			name:   "token response",
+			header: json,
+			body:   `{"access_token":"a1","refresh_token":"r1","expires_at":1700000000}`,
+			want:   `{"access_token":"REDACTED","refresh_token":"REDACTED","expires_at":1700000000}`,
+		},
+		{
+			name:   "fault keeps its error code",
+			header: json,
+			body:   `{"message":"Bad Request","errors":[{"resource":"Activity","field":"name","code":"invalid"}]}`,
+			want:   `{"message":"Bad Request","errors":[{"resource":"Activity","field":"name","code":"invalid"}]}`,
+		},
+		{
+			name:   "plain text is not parsed as a form",
+			header: http.Header{"Content-Type": {"text/plain"}},
+			body:   "upstream timeout",
+			want:   "upstream timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(redactBody(tt.header, []byte(tt.body))); got != tt.want {
+				t.Errorf("redactBody() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
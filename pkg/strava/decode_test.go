@@ -0,0 +1,90 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// unlimited lets every request through, so benchmarks are not paced by
+// the default fifteen-minute quota
+type unlimited struct{}
+
+func (unlimited) Wait(ctx context.Context) error { return ctx.Err() }
+
+// pageServer serves pages full pages of MaxPerPage activities, then an
+// empty one
+func pageServer(b *testing.B, pages int) *httptest.Server {
+	activities := make([]map[string]interface{}, MaxPerPage)
+	for i := range activities {
+		activities[i] = map[string]interface{}{
+			"id":           i + 1,
+			"name":         fmt.Sprintf("Morning Run %d", i+1),
+			"type":         "Run",
+			"sport_type":   "Run",
+			"start_date":   time.Date(2024, time.January, 1, 6, i, 0, 0, time.UTC).Format(time.RFC3339),
+			"distance":     10000.0,
+			"moving_time":  3000,
+			"elapsed_time": 3100,
+		}
+	}
+	body, err := json.Marshal(activities)
+	if err != nil {
+		b.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if page, _ := strconv.Atoi(r.URL.Query().Get("page")); page > pages {
+			w.Write([]byte("[]"))
+			return
+		}
+		w.Write(body)
+	}))
+	b.Cleanup(srv.Close)
+	return srv
+}
+
+// benchmarkFullFetch walks twenty full pages, returning the pages the
+// iterator moves past to pagePool when pooled is set
+func benchmarkFullFetch(b *testing.B, pooled bool) {
+	srv := pageServer(b, 20)
+	c := New(
+		WithBaseURL(srv.URL),
+		WithToken(Token{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour).Unix()}),
+		WithRateLimiter(unlimited{}),
+		WithLogger(log.New(io.Discard, "", 0)),
+	)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := c.Activities(ctx, ListActivitiesOptions{})
+		it.pooled = pooled
+		n := 0
+		for it.Next() {
+			n++
+		}
+		if err := it.Err(); err != nil {
+			b.Fatal(err)
+		}
+		if n != 20*MaxPerPage {
+			b.Fatalf("iterated %d activities, want %d", n, 20*MaxPerPage)
+		}
+	}
+}
+
+func BenchmarkFullFetchPooled(b *testing.B) {
+	benchmarkFullFetch(b, true)
+}
+
+func BenchmarkFullFetchUnpooled(b *testing.B) {
+	benchmarkFullFetch(b, false)
+}
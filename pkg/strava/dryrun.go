@@ -0,0 +1,124 @@
+package strava
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+)
+
+// WithDryRun stops the client from changing anything on Strava. Requests
+// that create, update, or delete are written to w, with their payloads,
+// instead of being sent, and answered as Strava would: an update returns
+// the activity as it is now with the changes applied, a creation returns
+// the payload with ID 0, and a delete succeeds. Reads and token refreshes
+// go through as usual.
+func WithDryRun(w io.Writer) Option {
+	return func(c *Client) {
+		c.dryRun = w
+	}
+}
+
+type dryRunTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+	mu   sync.Mutex
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead || path.Base(req.URL.Path) == "token" {
+		return t.base().RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	t.mu.Lock()
+	fmt.Fprintf(t.out, "DRY RUN %s %s\n", req.Method, req.URL)
+	if len(body) > 0 {
+		fmt.Fprintf(t.out, "%s\n", body)
+	}
+	t.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodDelete:
+		return dryRunResponse(req, http.StatusNoContent, nil), nil
+	case http.MethodPut:
+		return dryRunResponse(req, http.StatusOK, t.updated(req, body)), nil
+	}
+	created := mergeJSON([]byte(`{"id":0}`), body)
+	return dryRunResponse(req, http.StatusCreated, created), nil
+}
+
+func (t *dryRunTransport) base() http.RoundTripper {
+	if t.next == nil {
+		return http.DefaultTransport
+	}
+	return t.next
+}
+
+// updated reads the resource being updated and applies the changes to it,
+// falling back to the changes alone with the ID from the path
+func (t *dryRunTransport) updated(req *http.Request, changes []byte) []byte {
+	get, err := http.NewRequestWithContext(req.Context(), http.MethodGet, req.URL.String(), nil)
+	if err != nil {
+		return changes
+	}
+	for key, values := range req.Header {
+		// Leave compression to the transport, which then decodes it
+		if key != "Content-Type" && key != "Accept-Encoding" {
+			get.Header[key] = values
+		}
+	}
+	res, err := t.base().RoundTrip(get)
+	if err == nil {
+		defer res.Body.Close()
+	}
+	if err != nil || res.StatusCode != http.StatusOK || res.Header.Get("Content-Encoding") != "" {
+		id, _ := strconv.ParseInt(path.Base(req.URL.Path), 10, 64)
+		return mergeJSON([]byte(fmt.Sprintf(`{"id":%d}`, id)), changes)
+	}
+	current, _ := io.ReadAll(res.Body)
+	return mergeJSON(current, changes)
+}
+
+// mergeJSON sets the fields of the changes object on the base object
+func mergeJSON(base, changes []byte) []byte {
+	var fields, set map[string]json.RawMessage
+	if json.Unmarshal(base, &fields) != nil || fields == nil {
+		fields = make(map[string]json.RawMessage)
+	}
+	json.Unmarshal(changes, &set)
+	for key, value := range set {
+		fields[key] = value
+	}
+	merged, _ := json.Marshal(fields)
+	return merged
+}
+
+func dryRunResponse(req *http.Request, status int, body []byte) *http.Response {
+	header := http.Header{}
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,81 @@
+package strava
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MetaActivity and MetaAthlete are the id-only references embedded in
+// efforts
+type MetaActivity struct {
+	Id int64 `json:"id"`
+}
+
+type MetaAthlete struct {
+	Id int64 `json:"id"`
+}
+
+// Achievement is a PR or KOM awarded for an effort
+type Achievement struct {
+	TypeId int    `json:"type_id"`
+	Type   string `json:"type"`
+	Rank   int    `json:"rank"`
+}
+
+// SegmentEffort is one traversal of a segment within an activity
+type SegmentEffort struct {
+	Id             int64         `json:"id"`
+	Name           string        `json:"name"`
+	Activity       MetaActivity  `json:"activity"`
+	Athlete        MetaAthlete   `json:"athlete"`
+	Segment        Segment       `json:"segment"`
+	ElapsedTime    int           `json:"elapsed_time"`
+	MovingTime     int           `json:"moving_time"`
+	StartDate      string        `json:"start_date"`
+	StartDateLocal string        `json:"start_date_local"`
+	Distance       float64       `json:"distance"`
+	StartIndex     int           `json:"start_index"`
+	EndIndex       int           `json:"end_index"`
+	KOMRank        *int          `json:"kom_rank"`
+	PRRank         *int          `json:"pr_rank"`
+	Achievements   []Achievement `json:"achievements"`
+	Hidden         bool          `json:"hidden"`
+}
+
+// IsPR reports whether the effort was the athlete's fastest on the segment
+// when it was recorded
+func (e SegmentEffort) IsPR() bool {
+	return e.PRRank != nil && *e.PRRank == 1
+}
+
+// SegmentEffortsOptions selects the athlete's efforts on a segment
+type SegmentEffortsOptions struct {
+	// Start and End bound the effort start time; zero values are open ended
+	Start   time.Time
+	End     time.Time
+	PerPage int
+}
+
+// ListSegmentEfforts returns the authenticated athlete's efforts on a
+// segment. Strava requires a subscription for this endpoint.
+func (c *Client) ListSegmentEfforts(ctx context.Context, segmentID int64, opts SegmentEffortsOptions) ([]SegmentEffort, error) {
+	q := url.Values{}
+	q.Set("segment_id", strconv.FormatInt(segmentID, 10))
+	if !opts.Start.IsZero() {
+		q.Set("start_date_local", opts.Start.Format(time.RFC3339))
+	}
+	if !opts.End.IsZero() {
+		q.Set("end_date_local", opts.End.Format(time.RFC3339))
+	}
+	if opts.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(opts.PerPage))
+	}
+
+	efforts := make([]SegmentEffort, 0)
+	if err := c.get(ctx, opList, "/segment_efforts", q, &efforts); err != nil {
+		return nil, err
+	}
+	return efforts, nil
+}
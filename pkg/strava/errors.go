@@ -0,0 +1,41 @@
+package strava
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// APIError is returned for any non-2xx response from Strava
+type APIError struct {
+	StatusCode int          `json:"-"`
+	Message    string       `json:"message"`
+	Errors     []FieldError `json:"errors"`
+	// RetryAfter is how long the response asked clients to wait before
+	// trying again, zero when it did not say
+	RetryAfter time.Duration `json:"-"`
+}
+
+// FieldError is a single entry in a Strava fault response
+type FieldError struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+}
+
+func newAPIError(res *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: res.StatusCode, RetryAfter: parseRetryAfter(res.Header, time.Now())}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = http.StatusText(res.StatusCode)
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) > 0 {
+		fe := e.Errors[0]
+		return fmt.Sprintf("strava: %d %s (%s %s %s)", e.StatusCode, e.Message, fe.Resource, fe.Field, fe.Code)
+	}
+	return fmt.Sprintf("strava: %d %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,233 @@
+package fit
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// Session fields
+const (
+	sessionStartTime    = 2
+	sessionSport        = 5
+	sessionSubSport     = 6
+	sessionElapsedTime  = 7
+	sessionTimerTime    = 8
+	sessionDistance     = 9
+	sessionActivityName = 110
+)
+
+// Record fields
+const (
+	recordLat              = 0
+	recordLong             = 1
+	recordAltitude         = 2
+	recordHeartRate        = 3
+	recordCadence          = 4
+	recordDistance         = 5
+	recordSpeed            = 6
+	recordPower            = 7
+	recordTemperature      = 13
+	recordEnhancedSpeed    = 73
+	recordEnhancedAltitude = 78
+)
+
+// subSportVirtual marks indoor virtual activities such as Zwift rides
+const subSportVirtual = 58
+
+// sportTypes maps FIT sports to Strava activity types
+var sportTypes = map[float64]string{
+	0:  "Workout",
+	1:  "Run",
+	2:  "Ride",
+	4:  "Workout",
+	5:  "Swim",
+	10: "Workout",
+	11: "Walk",
+	12: "NordicSki",
+	13: "AlpineSki",
+	14: "Snowboard",
+	15: "Rowing",
+	17: "Hike",
+	19: "Kayaking",
+	21: "EBikeRide",
+	37: "StandUpPaddling",
+	38: "Surfing",
+}
+
+// ErrNoSession is returned when the file holds no activity session
+var ErrNoSession = errors.New("fit: file has no session message")
+
+// Activity builds the summary activity from the file's first session. The
+// id is zero since FIT files know nothing of Strava ids.
+func (f *File) Activity() (strava.Activity, error) {
+	sessions := f.Filter(MesgSession)
+	if len(sessions) == 0 {
+		return strava.Activity{}, ErrNoSession
+	}
+	s := sessions[0]
+
+	a := strava.Activity{Type: "Workout"}
+	if t, ok := sportTypes[s.Fields[sessionSport]]; ok {
+		a.Type = t
+	}
+	if s.Fields[sessionSubSport] == subSportVirtual {
+		switch a.Type {
+		case "Ride":
+			a.Type = "VirtualRide"
+		case "Run":
+			a.Type = "VirtualRun"
+		}
+	}
+	a.Name = s.Strings[sessionActivityName]
+
+	if start, ok := s.Time(sessionStartTime); ok {
+		a.StartDate = start.Format(time.RFC3339)
+	}
+	if v, ok := s.Value(sessionElapsedTime); ok {
+		a.ElapsedTime = int(math.Round(v / 1000))
+	}
+	if v, ok := s.Value(sessionTimerTime); ok {
+		a.MovingTime = int(math.Round(v / 1000))
+	}
+	if v, ok := s.Value(sessionDistance); ok {
+		a.Distance = v / 100
+	}
+	return a, nil
+}
+
+// Streams builds Strava style streams from the record messages. Strava
+// aligns every stream on one index, so samples missing a value repeat the
+// previous one and streams never recorded are left nil.
+func (f *File) Streams() strava.Streams {
+	records := f.Filter(MesgRecord)
+
+	var start float64
+	for _, r := range records {
+		if ts, ok := r.Value(FieldTimestamp); ok {
+			start = ts
+			break
+		}
+	}
+
+	var (
+		times              []int
+		distance, altitude []float64
+		speed              []float64
+		latlng             []strava.LatLng
+		hr, cadence, watts []int
+		temp               []int
+		has                = map[string]bool{}
+	)
+	var last struct {
+		time                      int
+		distance, altitude, speed float64
+		latlng                    strava.LatLng
+		hr, cadence, watts, temp  int
+	}
+
+	for _, r := range records {
+		if ts, ok := r.Value(FieldTimestamp); ok {
+			last.time = int(ts - start)
+			has[strava.StreamTime] = true
+		}
+		if v, ok := r.Value(recordDistance); ok {
+			last.distance = v / 100
+			has[strava.StreamDistance] = true
+		}
+		if v, ok := r.Value(recordEnhancedAltitude); ok {
+			last.altitude = v/5 - 500
+			has[strava.StreamAltitude] = true
+		} else if v, ok := r.Value(recordAltitude); ok {
+			last.altitude = v/5 - 500
+			has[strava.StreamAltitude] = true
+		}
+		if v, ok := r.Value(recordEnhancedSpeed); ok {
+			last.speed = v / 1000
+			has[strava.StreamVelocitySmooth] = true
+		} else if v, ok := r.Value(recordSpeed); ok {
+			last.speed = v / 1000
+			has[strava.StreamVelocitySmooth] = true
+		}
+		lat, okLat := r.Value(recordLat)
+		long, okLong := r.Value(recordLong)
+		if okLat && okLong {
+			last.latlng = strava.LatLng{semicircles(lat), semicircles(long)}
+			has[strava.StreamLatLng] = true
+		}
+		if v, ok := r.Value(recordHeartRate); ok {
+			last.hr = int(v)
+			has[strava.StreamHeartrate] = true
+		}
+		if v, ok := r.Value(recordCadence); ok {
+			last.cadence = int(v)
+			has[strava.StreamCadence] = true
+		}
+		if v, ok := r.Value(recordPower); ok {
+			last.watts = int(v)
+			has[strava.StreamWatts] = true
+		}
+		if v, ok := r.Value(recordTemperature); ok {
+			last.temp = int(v)
+			has[strava.StreamTemp] = true
+		}
+
+		times = append(times, last.time)
+		distance = append(distance, last.distance)
+		altitude = append(altitude, last.altitude)
+		speed = append(speed, last.speed)
+		latlng = append(latlng, last.latlng)
+		hr = append(hr, last.hr)
+		cadence = append(cadence, last.cadence)
+		watts = append(watts, last.watts)
+		temp = append(temp, last.temp)
+	}
+
+	// Points recorded before the first GPS fix take the first position
+	for i := range latlng {
+		if latlng[i] != nil {
+			for j := 0; j < i; j++ {
+				latlng[j] = latlng[i]
+			}
+			break
+		}
+	}
+
+	info := strava.StreamInfo{OriginalSize: len(records), Resolution: "high", SeriesType: "time"}
+	var streams strava.Streams
+	if has[strava.StreamTime] {
+		streams.Time = &strava.IntStream{StreamInfo: info, Data: times}
+	}
+	if has[strava.StreamDistance] {
+		streams.Distance = &strava.FloatStream{StreamInfo: info, Data: distance}
+	}
+	if has[strava.StreamLatLng] {
+		streams.LatLng = &strava.LatLngStream{StreamInfo: info, Data: latlng}
+	}
+	if has[strava.StreamAltitude] {
+		streams.Altitude = &strava.FloatStream{StreamInfo: info, Data: altitude}
+	}
+	if has[strava.StreamVelocitySmooth] {
+		streams.VelocitySmooth = &strava.FloatStream{StreamInfo: info, Data: speed}
+	}
+	if has[strava.StreamHeartrate] {
+		streams.Heartrate = &strava.IntStream{StreamInfo: info, Data: hr}
+	}
+	if has[strava.StreamCadence] {
+		streams.Cadence = &strava.IntStream{StreamInfo: info, Data: cadence}
+	}
+	if has[strava.StreamWatts] {
+		streams.Watts = &strava.IntStream{StreamInfo: info, Data: watts}
+	}
+	if has[strava.StreamTemp] {
+		streams.Temp = &strava.IntStream{StreamInfo: info, Data: temp}
+	}
+	return streams
+}
+
+// semicircles converts a FIT position to degrees
+func semicircles(v float64) float64 {
+	return v * 180 / math.Pow(2, 31)
+}
@@ -0,0 +1,348 @@
+// Package fit decodes Garmin FIT activity files into the activity and
+// stream models of the strava package, so uploaded or exported files can be
+// analysed offline the same way as API data.
+//
+// The decoder is minimal: it reads every message and numeric or string
+// field, but only knows the meaning of the messages needed to build an
+// activity. Developer fields are skipped.
+package fit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Global message numbers used by the converter
+const (
+	MesgFileID   = 0
+	MesgSession  = 18
+	MesgLap      = 19
+	MesgRecord   = 20
+	MesgActivity = 34
+)
+
+// FieldTimestamp is the timestamp field number shared by all messages
+const FieldTimestamp = 253
+
+// fitEpoch is the FIT time origin, 1989-12-31T00:00:00Z
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// ErrNotFIT is returned when the input does not carry the FIT signature
+var ErrNotFIT = errors.New("fit: not a FIT file")
+
+// File is a decoded FIT file
+type File struct {
+	ProtocolVersion uint8
+	ProfileVersion  uint16
+	Messages        []Message
+}
+
+// Message is one data message. Fields holds numeric fields as raw,
+// unscaled values; fields set to their invalid value are left out.
+type Message struct {
+	Num     uint16
+	Fields  map[uint8]float64
+	Strings map[uint8]string
+}
+
+// Value returns a numeric field and whether it was present
+func (m Message) Value(field uint8) (float64, bool) {
+	v, ok := m.Fields[field]
+	return v, ok
+}
+
+// Time returns a timestamp field as a time
+func (m Message) Time(field uint8) (time.Time, bool) {
+	v, ok := m.Fields[field]
+	if !ok {
+		return time.Time{}, false
+	}
+	return fitTime(v), true
+}
+
+func fitTime(v float64) time.Time {
+	return fitEpoch.Add(time.Duration(v) * time.Second)
+}
+
+// Filter returns the messages with the given global number
+func (f *File) Filter(num uint16) []Message {
+	messages := make([]Message, 0)
+	for _, m := range f.Messages {
+		if m.Num == num {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+type fieldDef struct {
+	num      uint8
+	size     uint8
+	baseType uint8
+}
+
+type definition struct {
+	num       uint16
+	byteOrder binary.ByteOrder
+	fields    []fieldDef
+	devSize   int
+}
+
+// Decode reads a FIT file. Gzipped files, as found in Strava bulk exports,
+// are decompressed transparently. Chained FIT files are not supported; only
+// the first is read.
+func Decode(r io.Reader) (*File, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	var header [14]byte
+	if _, err := io.ReadFull(br, header[:12]); err != nil {
+		return nil, ErrNotFIT
+	}
+	size := int(header[0])
+	if size < 12 || !bytes.Equal(header[8:12], []byte(".FIT")) {
+		return nil, ErrNotFIT
+	}
+	if _, err := io.CopyN(io.Discard, br, int64(size-12)); err != nil {
+		return nil, ErrNotFIT
+	}
+
+	file := &File{
+		ProtocolVersion: header[1],
+		ProfileVersion:  binary.LittleEndian.Uint16(header[2:4]),
+	}
+	dataSize := binary.LittleEndian.Uint32(header[4:8])
+
+	d := decoder{r: &io.LimitedReader{R: br, N: int64(dataSize)}, file: file}
+	if err := d.run(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+type decoder struct {
+	r           *io.LimitedReader
+	file        *File
+	definitions [16]*definition
+	lastTime    uint32
+}
+
+func (d *decoder) run() error {
+	var b [1]byte
+	for d.r.N > 0 {
+		if _, err := io.ReadFull(d.r, b[:]); err != nil {
+			return fmt.Errorf("fit: truncated file: %w", err)
+		}
+		header := b[0]
+
+		switch {
+		case header&0x80 != 0:
+			// Compressed timestamp header
+			local := (header >> 5) & 0x03
+			offset := uint32(header & 0x1f)
+			ts := d.lastTime&^0x1f + offset
+			if offset < d.lastTime&0x1f {
+				ts += 0x20
+			}
+			if err := d.data(local, &ts); err != nil {
+				return err
+			}
+		case header&0x40 != 0:
+			if err := d.define(header&0x0f, header&0x20 != 0); err != nil {
+				return err
+			}
+		default:
+			if err := d.data(header&0x0f, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *decoder) define(local uint8, developer bool) error {
+	var fixed [5]byte
+	if _, err := io.ReadFull(d.r, fixed[:]); err != nil {
+		return fmt.Errorf("fit: truncated definition: %w", err)
+	}
+	def := &definition{byteOrder: binary.LittleEndian}
+	if fixed[1] == 1 {
+		def.byteOrder = binary.BigEndian
+	}
+	def.num = def.byteOrder.Uint16(fixed[2:4])
+
+	raw := make([]byte, int(fixed[4])*3)
+	if _, err := io.ReadFull(d.r, raw); err != nil {
+		return fmt.Errorf("fit: truncated definition: %w", err)
+	}
+	for i := 0; i < len(raw); i += 3 {
+		def.fields = append(def.fields, fieldDef{num: raw[i], size: raw[i+1], baseType: raw[i+2]})
+	}
+
+	if developer {
+		var n [1]byte
+		if _, err := io.ReadFull(d.r, n[:]); err != nil {
+			return fmt.Errorf("fit: truncated definition: %w", err)
+		}
+		dev := make([]byte, int(n[0])*3)
+		if _, err := io.ReadFull(d.r, dev); err != nil {
+			return fmt.Errorf("fit: truncated definition: %w", err)
+		}
+		for i := 0; i < len(dev); i += 3 {
+			def.devSize += int(dev[i+1])
+		}
+	}
+
+	d.definitions[local] = def
+	return nil
+}
+
+func (d *decoder) data(local uint8, compressedTime *uint32) error {
+	def := d.definitions[local]
+	if def == nil {
+		return fmt.Errorf("fit: data message for undefined local type %d", local)
+	}
+
+	m := Message{Num: def.num, Fields: make(map[uint8]float64)}
+	for _, f := range def.fields {
+		buf := make([]byte, f.size)
+		if _, err := io.ReadFull(d.r, buf); err != nil {
+			return fmt.Errorf("fit: truncated message: %w", err)
+		}
+		if f.baseType&0x1f == 0x07 {
+			if s := string(bytes.TrimRight(buf, "\x00")); s != "" {
+				if m.Strings == nil {
+					m.Strings = make(map[uint8]string)
+				}
+				m.Strings[f.num] = s
+			}
+			continue
+		}
+		if v, ok := decodeValue(buf, f.baseType, def.byteOrder); ok {
+			m.Fields[f.num] = v
+		}
+	}
+	if def.devSize > 0 {
+		if _, err := io.CopyN(io.Discard, d.r, int64(def.devSize)); err != nil {
+			return fmt.Errorf("fit: truncated message: %w", err)
+		}
+	}
+
+	if compressedTime != nil {
+		m.Fields[FieldTimestamp] = float64(*compressedTime)
+		d.lastTime = *compressedTime
+	} else if ts, ok := m.Fields[FieldTimestamp]; ok {
+		d.lastTime = uint32(ts)
+	}
+
+	d.file.Messages = append(d.file.Messages, m)
+	return nil
+}
+
+// decodeValue reads the first element of a field, reporting false for the
+// base type's invalid value or a size that does not match the type
+func decodeValue(buf []byte, baseType uint8, order binary.ByteOrder) (float64, bool) {
+	switch baseType & 0x1f {
+	case 0x00, 0x02, 0x0a, 0x0d: // enum, uint8, uint8z, byte
+		if len(buf) < 1 {
+			return 0, false
+		}
+		v := buf[0]
+		if v == 0xff || (baseType&0x1f == 0x0a && v == 0) {
+			return 0, false
+		}
+		return float64(v), true
+	case 0x01: // sint8
+		if len(buf) < 1 || buf[0] == 0x7f {
+			return 0, false
+		}
+		return float64(int8(buf[0])), true
+	case 0x03: // sint16
+		if len(buf) < 2 {
+			return 0, false
+		}
+		v := order.Uint16(buf)
+		if v == 0x7fff {
+			return 0, false
+		}
+		return float64(int16(v)), true
+	case 0x04, 0x0b: // uint16, uint16z
+		if len(buf) < 2 {
+			return 0, false
+		}
+		v := order.Uint16(buf)
+		if v == 0xffff || (baseType&0x1f == 0x0b && v == 0) {
+			return 0, false
+		}
+		return float64(v), true
+	case 0x05: // sint32
+		if len(buf) < 4 {
+			return 0, false
+		}
+		v := order.Uint32(buf)
+		if v == 0x7fffffff {
+			return 0, false
+		}
+		return float64(int32(v)), true
+	case 0x06, 0x0c: // uint32, uint32z
+		if len(buf) < 4 {
+			return 0, false
+		}
+		v := order.Uint32(buf)
+		if v == 0xffffffff || (baseType&0x1f == 0x0c && v == 0) {
+			return 0, false
+		}
+		return float64(v), true
+	case 0x08: // float32
+		if len(buf) < 4 {
+			return 0, false
+		}
+		v := order.Uint32(buf)
+		if v == 0xffffffff {
+			return 0, false
+		}
+		return float64(math.Float32frombits(v)), true
+	case 0x09: // float64
+		if len(buf) < 8 {
+			return 0, false
+		}
+		v := order.Uint64(buf)
+		if v == 0xffffffffffffffff {
+			return 0, false
+		}
+		return math.Float64frombits(v), true
+	case 0x0e: // sint64
+		if len(buf) < 8 {
+			return 0, false
+		}
+		v := order.Uint64(buf)
+		if v == 0x7fffffffffffffff {
+			return 0, false
+		}
+		return float64(int64(v)), true
+	case 0x0f, 0x10: // uint64, uint64z
+		if len(buf) < 8 {
+			return 0, false
+		}
+		v := order.Uint64(buf)
+		if v == 0xffffffffffffffff || (baseType&0x1f == 0x10 && v == 0) {
+			return 0, false
+		}
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,23 @@
+package strava
+
+import "context"
+
+// Gear is a bike or pair of shoes. Distance is in meters.
+type Gear struct {
+	Id          string  `json:"id"`
+	Primary     bool    `json:"primary"`
+	Name        string  `json:"name"`
+	Distance    float64 `json:"distance"`
+	BrandName   string  `json:"brand_name"`
+	ModelName   string  `json:"model_name"`
+	FrameType   int     `json:"frame_type"`
+	Description string  `json:"description"`
+	Retired     bool    `json:"retired"`
+}
+
+// GetGear returns the gear with the given id, such as "b12345" or "g67890"
+func (c *Client) GetGear(ctx context.Context, id string) (Gear, error) {
+	var gear Gear
+	err := c.get(ctx, opDetail, "/gear/"+id, nil, &gear)
+	return gear, err
+}
@@ -0,0 +1,70 @@
+package strava
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithPayloadLogging logs the size of every response body as received and
+// after decompression, for seeing what gzip saves on large pages
+func WithPayloadLogging(enabled bool) Option {
+	return func(c *Client) {
+		c.logPayloads = enabled
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// payload is a response body, decompressed when the server gzipped it.
+// Requests ask for gzip themselves, which turns off the transport's
+// transparent decompression, so both sizes can be seen.
+type payload struct {
+	wire    *countingReader
+	decoded *countingReader
+	gzip    *gzip.Reader
+}
+
+func newPayload(res *http.Response) (*payload, error) {
+	p := &payload{wire: &countingReader{r: res.Body}}
+	p.decoded = p.wire
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return p, nil
+	}
+
+	zr, err := gzip.NewReader(p.wire)
+	if errors.Is(err, io.EOF) {
+		// Nothing was compressed, as with 204 No Content
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.gzip = zr
+	p.decoded = &countingReader{r: zr}
+	return p, nil
+}
+
+func (p *payload) Read(b []byte) (int, error) {
+	return p.decoded.Read(b)
+}
+
+// Close releases the decompressor. The response body is closed by its owner.
+func (p *payload) Close() error {
+	if p.gzip != nil {
+		return p.gzip.Close()
+	}
+	return nil
+}
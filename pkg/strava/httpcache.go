@@ -0,0 +1,175 @@
+package strava
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithResponseCache caches GET responses on disk under dir. Within ttl a
+// cached response is served without contacting Strava; after that it is
+// revalidated with If-None-Match / If-Modified-Since so an unchanged page
+// costs a 304 instead of a full payload. A ttl of zero always revalidates.
+func WithResponseCache(dir string, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cacheDir = dir
+		c.cacheTTL = ttl
+	}
+}
+
+// cachedResponse is the on-disk form of a cached GET response
+type cachedResponse struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+type cachingTransport struct {
+	dir  string
+	ttl  time.Duration
+	next http.RoundTripper
+	now  func() time.Time
+}
+
+func newCachingTransport(dir string, ttl time.Duration, next http.RoundTripper) *cachingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &cachingTransport{dir: dir, ttl: ttl, next: next, now: time.Now}
+}
+
+// bypassCache marks a request context whose GETs must reach Strava even
+// when a fresh cached response exists
+type bypassCache struct{}
+
+// withoutCache returns a copy of ctx whose requests skip the response cache
+func withoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCache{}, true)
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Context().Value(bypassCache{}) != nil {
+		return t.next.RoundTrip(req)
+	}
+
+	path := t.path(req.URL.String())
+	entry := t.load(path)
+	if entry != nil && t.now().Sub(entry.StoredAt) < t.ttl {
+		return entry.response(req, nil), nil
+	}
+
+	outgoing := req
+	if entry != nil {
+		outgoing = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			outgoing.Header.Set("If-None-Match", etag)
+		}
+		if modified := entry.Header.Get("Last-Modified"); modified != "" {
+			outgoing.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	res, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && entry != nil {
+		res.Body.Close()
+		entry.StoredAt = t.now()
+		t.save(path, entry)
+		// Keep the fresh rate limit headers from the 304
+		return entry.response(req, res.Header), nil
+	}
+
+	cacheable := res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != "" || t.ttl > 0
+	if res.StatusCode != http.StatusOK || !cacheable {
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.save(path, &cachedResponse{
+		URL:        req.URL.String(),
+		StatusCode: res.StatusCode,
+		Header:     withoutRateLimit(res.Header),
+		Body:       body,
+		StoredAt:   t.now(),
+	})
+	return res, nil
+}
+
+func (t *cachingTransport) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the cached entry at path, or nil if there is none or it
+// cannot be read
+func (t *cachingTransport) load(path string) *cachedResponse {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cachedResponse
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// save writes entry to path. Failures are ignored because the cache is only
+// an optimisation.
+func (t *cachingTransport) save(path string, entry *cachedResponse) {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return
+	}
+	writeFileAtomic(path, raw, 0600)
+}
+
+// response rebuilds an *http.Response for req, overlaying extra headers
+func (e *cachedResponse) response(req *http.Request, extra http.Header) *http.Response {
+	header := withoutRateLimit(e.Header)
+	for key, values := range extra {
+		header[key] = values
+	}
+	return &http.Response{
+		Status:        http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// withoutRateLimit returns a copy of h without the rate limit headers, which
+// describe the usage when a response was fetched and would be stale when it
+// is replayed from the cache
+func withoutRateLimit(h http.Header) http.Header {
+	h = h.Clone()
+	h.Del("X-RateLimit-Limit")
+	h.Del("X-RateLimit-Usage")
+	return h
+}
@@ -0,0 +1,94 @@
+package strava_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/stravatest"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// apiResponses returns a response hook counting responses to API requests,
+// leaving out token refreshes
+func apiResponses() (func(*http.Response), *atomic.Int64) {
+	var n atomic.Int64
+	return func(res *http.Response) {
+		if !strings.HasPrefix(res.Request.URL.Path, "/oauth/") {
+			n.Add(1)
+		}
+	}, &n
+}
+
+func TestCachedResponsesSkipLimiterAndHooks(t *testing.T) {
+	srv := stravatest.NewServer(stravatest.WithActivities(testActivities(1)...))
+	defer srv.Close()
+
+	hook, responses := apiResponses()
+	limiter := strava.NewRateLimiter()
+	client := srv.Client(
+		strava.WithLogger(discard),
+		strava.WithRateLimiter(limiter),
+		strava.WithResponseCache(t.TempDir(), time.Hour),
+		strava.WithResponseHook(hook),
+	)
+
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		if _, err := client.GetActivity(ctx, 1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := srv.Requests(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+	if got := responses.Load(); got != 1 {
+		t.Errorf("response hook saw %d responses, want 1", got)
+	}
+	if got := limiter.Usage().ShortUsed; got != 1 {
+		t.Errorf("short term usage = %d, want 1", got)
+	}
+
+	// A probe must reach Strava even with the athlete's data cached
+	for i := 0; i < 2; i++ {
+		if err := client.Probe(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := srv.Requests(); got != 3 {
+		t.Errorf("server saw %d requests after probing, want 3", got)
+	}
+	if got := limiter.Usage().ShortUsed; got != 3 {
+		t.Errorf("short term usage after probing = %d, want 3", got)
+	}
+}
+
+func TestDryRunStubsSkipHooks(t *testing.T) {
+	srv := stravatest.NewServer(stravatest.WithActivities(testActivities(1)...))
+	defer srv.Close()
+
+	hook, responses := apiResponses()
+	var out bytes.Buffer
+	client := srv.Client(
+		strava.WithLogger(discard),
+		strava.WithDryRun(&out),
+		strava.WithResponseHook(hook),
+	)
+
+	if err := client.DeleteActivity(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Requests(); got != 0 {
+		t.Errorf("server saw %d requests, want 0", got)
+	}
+	if got := responses.Load(); got != 0 {
+		t.Errorf("response hook saw %d responses, want 0", got)
+	}
+	if out.Len() == 0 {
+		t.Error("dry run wrote nothing")
+	}
+}
@@ -0,0 +1,48 @@
+package strava
+
+import "context"
+
+// ClientInterface is the public surface of Client. Applications embedding
+// the client can depend on it instead of *Client and substitute a fake,
+// such as stravamock.Client, in their own unit tests.
+type ClientInterface interface {
+	Token() Token
+	Authenticate(ctx context.Context) error
+	Refresh(ctx context.Context, refreshToken string) (Token, error)
+	Probe(ctx context.Context) error
+	GetAthlete(ctx context.Context) (SummaryAthlete, error)
+	GetDetailedAthlete(ctx context.Context) (DetailedAthlete, error)
+	UpdateAthlete(ctx context.Context, update UpdatableAthlete) (DetailedAthlete, error)
+	GetAthleteStats(ctx context.Context, athleteID int64) (ActivityStats, error)
+	RateLimit() (RateLimitUsage, bool)
+	ListActivities(ctx context.Context, opts ListActivitiesOptions) ([]Activity, error)
+	Activities(ctx context.Context, opts ListActivitiesOptions) *ActivityIterator
+	ListAll(ctx context.Context, opts ListActivitiesOptions) ([]Activity, error)
+	ListActivitiesPages(ctx context.Context, opts ListActivitiesOptions, fn func(page []Activity) error) error
+	GetActivity(ctx context.Context, id int64, includeAllEfforts bool) (DetailedActivity, error)
+	ListActivityLaps(ctx context.Context, id int64) ([]Lap, error)
+	GetActivityZones(ctx context.Context, id int64) ([]ActivityZone, error)
+	GetAthleteZones(ctx context.Context) (Zones, error)
+	ListActivityKudoers(ctx context.Context, id int64, opts PageOptions) ([]SummaryAthlete, error)
+	ListActivityComments(ctx context.Context, id int64, opts PageOptions) ([]Comment, error)
+	ListAthleteClubs(ctx context.Context, opts PageOptions) ([]Club, error)
+	GetClub(ctx context.Context, id int64) (DetailedClub, error)
+	ListClubMembers(ctx context.Context, id int64, opts PageOptions) ([]ClubAthlete, error)
+	ListClubActivities(ctx context.Context, id int64, opts PageOptions) ([]ClubActivity, error)
+	ListAthleteRoutes(ctx context.Context, athleteID int64, opts PageOptions) ([]Route, error)
+	GetRoute(ctx context.Context, id int64) (Route, error)
+	ExportRoute(ctx context.Context, id int64, format string) ([]byte, error)
+	ListActivityPhotos(ctx context.Context, id int64, size int) ([]Photo, error)
+	GetActivityStreams(ctx context.Context, id int64, keys ...string) (Streams, error)
+	GetActivityStreamsWithOptions(ctx context.Context, id int64, opts StreamOptions, keys ...string) (Streams, error)
+	GetGear(ctx context.Context, id string) (Gear, error)
+	CreateActivity(ctx context.Context, activity CreatableActivity) (DetailedActivity, error)
+	UpdateActivity(ctx context.Context, id int64, update UpdatableActivity) (DetailedActivity, error)
+	DeleteActivity(ctx context.Context, id int64) error
+	GetSegment(ctx context.Context, id int64) (DetailedSegment, error)
+	ListStarredSegments(ctx context.Context, opts PageOptions) ([]Segment, error)
+	ExploreSegments(ctx context.Context, bounds Bounds, opts ExploreOptions) ([]ExplorerSegment, error)
+	ListSegmentEfforts(ctx context.Context, segmentID int64, opts SegmentEffortsOptions) ([]SegmentEffort, error)
+}
+
+var _ ClientInterface = (*Client)(nil)
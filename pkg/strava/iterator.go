@@ -0,0 +1,201 @@
+package strava
+
+import (
+	"context"
+	"sync"
+)
+
+// ActivityIterator walks the athlete's activities, fetching pages lazily as
+// they are consumed. Requests are paced by the client's rate limiter. With
+// ListActivitiesOptions.Prefetch set, pages are fetched in concurrent
+// batches and reassembled in order.
+//
+//	it := client.Activities(ctx, strava.ListActivitiesOptions{})
+//	for it.Next() {
+//		a := it.Activity()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ActivityIterator struct {
+	client  *Client
+	pooled  bool
+	ctx     context.Context
+	opts    ListActivitiesOptions
+	page    []Activity
+	queue   [][]Activity
+	index   int
+	current Activity
+	pending []Activity
+	last    bool
+	err     error
+}
+
+// Activities returns an iterator over the activities selected by opts.
+// Page defaults to 1 and PerPage to MaxPerPage.
+func (c *Client) Activities(ctx context.Context, opts ListActivitiesOptions) *ActivityIterator {
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PerPage < 1 {
+		opts.PerPage = MaxPerPage
+	}
+	return &ActivityIterator{client: c, ctx: ctx, opts: opts, pooled: true}
+}
+
+// pagePool recycles the backing arrays of pages an iterator has moved past,
+// which keeps a full fetch of a long history from allocating a fresh page
+// of MaxPerPage activities for every request
+var pagePool = sync.Pool{
+	New: func() interface{} { return make([]Activity, 0, MaxPerPage) },
+}
+
+// advance moves on to the next queued page, returning the current one to
+// the pool
+func (it *ActivityIterator) advance() {
+	if it.pooled && cap(it.page) > 0 {
+		clear(it.page[:cap(it.page)])
+		pagePool.Put(it.page[:0])
+	}
+	it.page, it.queue = it.queue[0], it.queue[1:]
+	it.index = 0
+}
+
+// NewSliceIterator returns an iterator over activities that makes no API
+// calls, followed by err if it is not nil. It lets fakes of
+// ClientInterface return an ActivityIterator.
+func NewSliceIterator(activities []Activity, err error) *ActivityIterator {
+	return &ActivityIterator{page: activities, last: true, err: err}
+}
+
+// Next advances to the next activity, fetching the next page when the
+// current one is exhausted. It returns false when there are no more
+// activities or an error occurred. Pages fetched before an error are still
+// returned first.
+func (it *ActivityIterator) Next() bool {
+	for it.index >= len(it.page) {
+		if len(it.queue) > 0 {
+			it.advance()
+			continue
+		}
+		if it.last || it.err != nil {
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+
+	it.current = it.page[it.index]
+	it.index++
+	return true
+}
+
+// NextPage advances to the next page of activities, fetching it when none
+// are queued. After a call to Next, the rest of the current page is
+// returned first. It returns false when there are no more activities or an
+// error occurred. The page is reused once the iterator moves past it, so
+// copy any activities that must outlive the next call.
+func (it *ActivityIterator) NextPage() bool {
+	for it.index >= len(it.page) {
+		if len(it.queue) > 0 {
+			it.advance()
+			continue
+		}
+		if it.last || it.err != nil {
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+
+	it.pending = it.page[it.index:]
+	it.index = len(it.page)
+	return true
+}
+
+// Page returns the activities NextPage advanced to
+func (it *ActivityIterator) Page() []Activity {
+	return it.pending
+}
+
+// Activity returns the activity Next advanced to
+func (it *ActivityIterator) Activity() Activity {
+	return it.current
+}
+
+// Err returns the first error encountered while fetching pages
+func (it *ActivityIterator) Err() error {
+	return it.err
+}
+
+// fetch queues the next page, or the next batch of pages when prefetching
+func (it *ActivityIterator) fetch() bool {
+	batch := it.opts.Prefetch
+	if batch < 1 {
+		batch = 1
+	}
+
+	pages := make([][]Activity, batch)
+	errs := make([]error, batch)
+
+	var wg sync.WaitGroup
+	for i := 0; i < batch; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			opts := it.opts
+			opts.Page += i
+			pages[i], errs[i] = it.client.listActivities(it.ctx, opts, pagePool.Get().([]Activity))
+		}(i)
+	}
+	wg.Wait()
+
+	// Reassemble in page order, stopping at the first error or short page.
+	// Pages past a short page are empty and their errors do not matter.
+	for i := 0; i < batch; i++ {
+		if errs[i] != nil {
+			it.err = errs[i]
+			return len(it.queue) > 0
+		}
+
+		it.client.logger.Printf("Page %d retrieved with %d activities\n", it.opts.Page, len(pages[i]))
+		it.queue = append(it.queue, pages[i])
+		it.opts.Page++
+
+		// A short page means there is nothing after it
+		if len(pages[i]) < it.opts.PerPage {
+			it.last = true
+			break
+		}
+	}
+	return true
+}
+
+// ListActivitiesPages calls fn with each page of the activities selected by
+// opts as it arrives, so pages can be stored as they come in rather than
+// held in memory until the last one. Pages are recycled once fn returns, so
+// copy any activities fn needs to keep. It stops at the first error from
+// the API or from fn and returns it; pages already passed to fn stay
+// processed.
+func (c *Client) ListActivitiesPages(ctx context.Context, opts ListActivitiesOptions, fn func(page []Activity) error) error {
+	it := c.Activities(ctx, opts)
+	for it.NextPage() {
+		if err := fn(it.Page()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// ListAll collects every page over opts and returns every activity. On
+// error the activities fetched so far are returned along with it.
+func (c *Client) ListAll(ctx context.Context, opts ListActivitiesOptions) ([]Activity, error) {
+	activities := make([]Activity, 0)
+	err := c.ListActivitiesPages(ctx, opts, func(page []Activity) error {
+		activities = append(activities, page...)
+		return nil
+	})
+	return activities, err
+}
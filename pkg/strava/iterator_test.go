@@ -0,0 +1,73 @@
+package strava_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brandtkeller/strava-api/internal/stravatest"
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// unlimited lets every request through, so benchmarks are not paced by
+// the default fifteen-minute quota
+type unlimited struct{}
+
+func (unlimited) Wait(ctx context.Context) error { return ctx.Err() }
+
+// testActivities returns n runs an hour apart with ids from 1
+func testActivities(n int) []strava.Activity {
+	start := time.Date(2024, time.January, 1, 6, 0, 0, 0, time.UTC)
+	activities := make([]strava.Activity, n)
+	for i := range activities {
+		activities[i] = strava.Activity{
+			Id:         i + 1,
+			Name:       "Morning Run",
+			Type:       "Run",
+			SportType:  "Run",
+			StartDate:  start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			Distance:   10000,
+			MovingTime: 3000,
+		}
+	}
+	return activities
+}
+
+// benchmarkActivityIterator walks ten full pages and a short one from a
+// server that takes latency to answer each request
+func benchmarkActivityIterator(b *testing.B, prefetch int) {
+	const perPage = 50
+	srv := stravatest.NewServer(
+		stravatest.WithActivities(testActivities(10*perPage+perPage/2)...),
+		stravatest.WithLatency(5*time.Millisecond),
+		stravatest.WithRateLimit(math.MaxInt32, math.MaxInt32),
+	)
+	defer srv.Close()
+	client := srv.Client(strava.WithRateLimiter(unlimited{}), strava.WithLogger(discard))
+	ctx := context.Background()
+	opts := strava.ListActivitiesOptions{PerPage: perPage, Prefetch: prefetch}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := client.Activities(ctx, opts)
+		n := 0
+		for it.Next() {
+			n++
+		}
+		if err := it.Err(); err != nil {
+			b.Fatal(err)
+		}
+		if n != 10*perPage+perPage/2 {
+			b.Fatalf("iterated %d activities, want %d", n, 10*perPage+perPage/2)
+		}
+	}
+}
+
+func BenchmarkActivityIteratorSequential(b *testing.B) {
+	benchmarkActivityIterator(b, 0)
+}
+
+func BenchmarkActivityIteratorPrefetch(b *testing.B) {
+	benchmarkActivityIterator(b, 4)
+}
@@ -0,0 +1,90 @@
+// Package keyring provides a strava.TokenStore backed by the operating
+// system credential store: the macOS Keychain, Windows Credential Manager, or
+// the Secret Service (GNOME Keyring, KWallet) on Linux.
+package keyring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+	gokeyring "github.com/zalando/go-keyring"
+)
+
+// DefaultService is the keyring service name entries are stored under
+const DefaultService = "strava-api"
+
+const (
+	tokenKey        = "token"
+	clientSecretKey = "client_secret"
+)
+
+// Store keeps the token and, optionally, the application client secret in the
+// OS keyring. Account namespaces the entries so several athletes or profiles
+// can share one keyring.
+type Store struct {
+	Service string
+	Account string
+}
+
+// New returns a Store for account under DefaultService
+func New(account string) *Store {
+	if account == "" {
+		account = "default"
+	}
+	return &Store{Service: DefaultService, Account: account}
+}
+
+func (s *Store) Load(ctx context.Context) (strava.Token, error) {
+	raw, err := gokeyring.Get(s.Service, s.key(tokenKey))
+	if errors.Is(err, gokeyring.ErrNotFound) {
+		return strava.Token{}, strava.ErrNoToken
+	}
+	if err != nil {
+		return strava.Token{}, err
+	}
+
+	var token strava.Token
+	if err := json.Unmarshal([]byte(raw), &token); err != nil {
+		return strava.Token{}, err
+	}
+	return token, nil
+}
+
+func (s *Store) Save(ctx context.Context, token strava.Token) error {
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return gokeyring.Set(s.Service, s.key(tokenKey), string(raw))
+}
+
+// ClientSecret returns the stored application client secret, or an empty
+// string when none has been saved
+func (s *Store) ClientSecret() (string, error) {
+	secret, err := gokeyring.Get(s.Service, s.key(clientSecretKey))
+	if errors.Is(err, gokeyring.ErrNotFound) {
+		return "", nil
+	}
+	return secret, err
+}
+
+// SetClientSecret saves the application client secret
+func (s *Store) SetClientSecret(secret string) error {
+	return gokeyring.Set(s.Service, s.key(clientSecretKey), secret)
+}
+
+// Delete removes every entry for the account
+func (s *Store) Delete() error {
+	for _, key := range []string{tokenKey, clientSecretKey} {
+		if err := gokeyring.Delete(s.Service, s.key(key)); err != nil && !errors.Is(err, gokeyring.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) key(name string) string {
+	return s.Account + "/" + name
+}
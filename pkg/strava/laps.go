@@ -0,0 +1,42 @@
+package strava
+
+import (
+	"context"
+	"strconv"
+)
+
+// Lap is a lap of an activity, either recorded by the device or
+// auto-generated by Strava
+type Lap struct {
+	Id                 int64        `json:"id"`
+	Name               string       `json:"name"`
+	Activity           MetaActivity `json:"activity"`
+	Athlete            MetaAthlete  `json:"athlete"`
+	LapIndex           int          `json:"lap_index"`
+	Split              int          `json:"split"`
+	ElapsedTime        int          `json:"elapsed_time"`
+	MovingTime         int          `json:"moving_time"`
+	StartDate          string       `json:"start_date"`
+	StartDateLocal     string       `json:"start_date_local"`
+	Distance           float64      `json:"distance"`
+	StartIndex         int          `json:"start_index"`
+	EndIndex           int          `json:"end_index"`
+	TotalElevationGain float64      `json:"total_elevation_gain"`
+	AverageSpeed       float64      `json:"average_speed"`
+	MaxSpeed           float64      `json:"max_speed"`
+	AverageCadence     float64      `json:"average_cadence"`
+	AverageWatts       float64      `json:"average_watts"`
+	DeviceWatts        bool         `json:"device_watts"`
+	AverageHeartrate   float64      `json:"average_heartrate"`
+	MaxHeartrate       float64      `json:"max_heartrate"`
+	PaceZone           int          `json:"pace_zone"`
+}
+
+// ListActivityLaps returns the laps of an activity
+func (c *Client) ListActivityLaps(ctx context.Context, id int64) ([]Lap, error) {
+	laps := make([]Lap, 0)
+	if err := c.get(ctx, opDetail, "/activities/"+strconv.FormatInt(id, 10)+"/laps", nil, &laps); err != nil {
+		return nil, err
+	}
+	return laps, nil
+}
@@ -0,0 +1,12 @@
+// Package models holds a Go type for every model in Strava's Swagger spec,
+// generated by cmd/strava-modelgen. The client in pkg/strava maps the fields
+// it uses by hand; these types cover the rest of the API, for decoding the
+// Raw JSON kept on activities or responses of endpoints the client has no
+// method for. They are generated from swagger/swagger.json, a copy of the
+// definitions of https://developers.strava.com/swagger/swagger.json; update
+// it and regenerate them whenever the spec changes:
+//
+//	go generate ./pkg/strava/models
+package models
+
+//go:generate go run ../../../cmd/strava-modelgen --spec swagger/swagger.json --out models.go
@@ -0,0 +1,1243 @@
+// Code generated by strava-modelgen from swagger/swagger.json. DO NOT EDIT.
+
+package models
+
+import (
+	"time"
+)
+
+// ActivityStats is a set of rolled-up statistics and totals for an athlete
+type ActivityStats struct {
+	// The all time ride stats for the athlete.
+	AllRideTotals *ActivityTotal `json:"all_ride_totals,omitempty"`
+	// The all time run stats for the athlete.
+	AllRunTotals *ActivityTotal `json:"all_run_totals,omitempty"`
+	// The all time swim stats for the athlete.
+	AllSwimTotals *ActivityTotal `json:"all_swim_totals,omitempty"`
+	// The highest climb ridden by the athlete.
+	BiggestClimbElevationGain float64 `json:"biggest_climb_elevation_gain,omitempty"`
+	// The longest distance ridden by the athlete.
+	BiggestRideDistance float64 `json:"biggest_ride_distance,omitempty"`
+	// The recent (last 4 weeks) ride stats for the athlete.
+	RecentRideTotals *ActivityTotal `json:"recent_ride_totals,omitempty"`
+	// The recent (last 4 weeks) run stats for the athlete.
+	RecentRunTotals *ActivityTotal `json:"recent_run_totals,omitempty"`
+	// The recent (last 4 weeks) swim stats for the athlete.
+	RecentSwimTotals *ActivityTotal `json:"recent_swim_totals,omitempty"`
+	// The year to date ride stats for the athlete.
+	YtdRideTotals *ActivityTotal `json:"ytd_ride_totals,omitempty"`
+	// The year to date run stats for the athlete.
+	YtdRunTotals *ActivityTotal `json:"ytd_run_totals,omitempty"`
+	// The year to date swim stats for the athlete.
+	YtdSwimTotals *ActivityTotal `json:"ytd_swim_totals,omitempty"`
+}
+
+// ActivityTotal is a roll-up of metrics pertaining to a set of activities.
+// Values are in seconds and meters.
+type ActivityTotal struct {
+	// The total number of achievements of the considered activities.
+	AchievementCount int `json:"achievement_count,omitempty"`
+	// The number of activities considered in this total.
+	Count int `json:"count,omitempty"`
+	// The total distance covered by the considered activities.
+	Distance float64 `json:"distance,omitempty"`
+	// The total elapsed time of the considered activities.
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The total elevation gain of the considered activities.
+	ElevationGain float64 `json:"elevation_gain,omitempty"`
+	// The total moving time of the considered activities.
+	MovingTime int `json:"moving_time,omitempty"`
+}
+
+// ActivityType is an enumeration of the types an activity may have. Note that
+// this enumeration does not include new sport types (e.g. MountainBikeRide,
+// EMountainBikeRide), activities with these sport types will have the
+// corresponding activity type (e.g. Ride for MountainBikeRide, EBikeRide for
+// EMountainBikeRide)
+type ActivityType string
+
+// The values of ActivityType
+const (
+	ActivityTypeAlpineSki       ActivityType = "AlpineSki"
+	ActivityTypeBackcountrySki  ActivityType = "BackcountrySki"
+	ActivityTypeCanoeing        ActivityType = "Canoeing"
+	ActivityTypeCrossfit        ActivityType = "Crossfit"
+	ActivityTypeEBikeRide       ActivityType = "EBikeRide"
+	ActivityTypeElliptical      ActivityType = "Elliptical"
+	ActivityTypeGolf            ActivityType = "Golf"
+	ActivityTypeHandcycle       ActivityType = "Handcycle"
+	ActivityTypeHike            ActivityType = "Hike"
+	ActivityTypeIceSkate        ActivityType = "IceSkate"
+	ActivityTypeInlineSkate     ActivityType = "InlineSkate"
+	ActivityTypeKayaking        ActivityType = "Kayaking"
+	ActivityTypeKitesurf        ActivityType = "Kitesurf"
+	ActivityTypeNordicSki       ActivityType = "NordicSki"
+	ActivityTypeRide            ActivityType = "Ride"
+	ActivityTypeRockClimbing    ActivityType = "RockClimbing"
+	ActivityTypeRollerSki       ActivityType = "RollerSki"
+	ActivityTypeRowing          ActivityType = "Rowing"
+	ActivityTypeRun             ActivityType = "Run"
+	ActivityTypeSail            ActivityType = "Sail"
+	ActivityTypeSkateboard      ActivityType = "Skateboard"
+	ActivityTypeSnowboard       ActivityType = "Snowboard"
+	ActivityTypeSnowshoe        ActivityType = "Snowshoe"
+	ActivityTypeSoccer          ActivityType = "Soccer"
+	ActivityTypeStairStepper    ActivityType = "StairStepper"
+	ActivityTypeStandUpPaddling ActivityType = "StandUpPaddling"
+	ActivityTypeSurfing         ActivityType = "Surfing"
+	ActivityTypeSwim            ActivityType = "Swim"
+	ActivityTypeVelomobile      ActivityType = "Velomobile"
+	ActivityTypeVirtualRide     ActivityType = "VirtualRide"
+	ActivityTypeVirtualRun      ActivityType = "VirtualRun"
+	ActivityTypeWalk            ActivityType = "Walk"
+	ActivityTypeWeightTraining  ActivityType = "WeightTraining"
+	ActivityTypeWheelchair      ActivityType = "Wheelchair"
+	ActivityTypeWindsurf        ActivityType = "Windsurf"
+	ActivityTypeWorkout         ActivityType = "Workout"
+	ActivityTypeYoga            ActivityType = "Yoga"
+)
+
+// ActivityZone is generated from the spec
+type ActivityZone struct {
+	CustomZones         bool                  `json:"custom_zones,omitempty"`
+	DistributionBuckets TimedZoneDistribution `json:"distribution_buckets,omitempty"`
+	Max                 int                   `json:"max,omitempty"`
+	Points              int                   `json:"points,omitempty"`
+	Score               int                   `json:"score,omitempty"`
+	SensorBased         bool                  `json:"sensor_based,omitempty"`
+	// One of heartrate, power.
+	Type string `json:"type,omitempty"`
+}
+
+// AltitudeStream is generated from the spec
+type AltitudeStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of altitude values for this stream, in meters
+	Data []float64 `json:"data,omitempty"`
+}
+
+// BaseStream is generated from the spec
+type BaseStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+}
+
+// CadenceStream is generated from the spec
+type CadenceStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of cadence values for this stream, in rotations per minute
+	Data []int `json:"data,omitempty"`
+}
+
+// ClubActivity is generated from the spec
+type ClubActivity struct {
+	Athlete *MetaAthlete `json:"athlete,omitempty"`
+	// The activity's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The activity's elapsed time, in seconds
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The activity's moving time, in seconds
+	MovingTime int `json:"moving_time,omitempty"`
+	// The name of the activity
+	Name      string    `json:"name,omitempty"`
+	SportType SportType `json:"sport_type,omitempty"`
+	// The activity's total elevation gain.
+	TotalElevationGain float64      `json:"total_elevation_gain,omitempty"`
+	Type               ActivityType `json:"type,omitempty"`
+	// The activity's workout type
+	WorkoutType int `json:"workout_type,omitempty"`
+}
+
+// ClubAthlete is generated from the spec
+type ClubAthlete struct {
+	// Whether the athlete is a club admin.
+	Admin bool `json:"admin,omitempty"`
+	// The athlete's first name.
+	Firstname string `json:"firstname,omitempty"`
+	// The athlete's last initial.
+	Lastname string `json:"lastname,omitempty"`
+	// The athlete's member status.
+	Member string `json:"member,omitempty"`
+	// Whether the athlete is club owner.
+	Owner bool `json:"owner,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 1 -> "meta", 2
+	// -> "summary", 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+}
+
+// Comment is generated from the spec
+type Comment struct {
+	// The identifier of the activity this comment is related to
+	ActivityId int64           `json:"activity_id,omitempty"`
+	Athlete    *SummaryAthlete `json:"athlete,omitempty"`
+	// The time at which this comment was created.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// The unique identifier of this comment
+	Id int64 `json:"id,omitempty"`
+	// The content of the comment
+	Text string `json:"text,omitempty"`
+}
+
+// DetailedActivity is generated from the spec
+type DetailedActivity struct {
+	// The unique identifier of the activity
+	Id int64 `json:"id,omitempty"`
+	// The number of achievements gained during this activity
+	AchievementCount int          `json:"achievement_count,omitempty"`
+	Athlete          *MetaAthlete `json:"athlete,omitempty"`
+	// The number of athletes for taking part in a group activity
+	AthleteCount int `json:"athlete_count,omitempty"`
+	// The activity's average speed, in meters per second
+	AverageSpeed float64 `json:"average_speed,omitempty"`
+	// Average power output in watts during this activity. Rides only
+	AverageWatts float64 `json:"average_watts,omitempty"`
+	// The number of comments for this activity
+	CommentCount int `json:"comment_count,omitempty"`
+	// Whether this activity is a commute
+	Commute bool `json:"commute,omitempty"`
+	// Whether the watts are from a power meter, false if estimated
+	DeviceWatts bool `json:"device_watts,omitempty"`
+	// The activity's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The activity's elapsed time, in seconds
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The activity's highest elevation, in meters
+	ElevHigh float64 `json:"elev_high,omitempty"`
+	// The activity's lowest elevation, in meters
+	ElevLow   float64 `json:"elev_low,omitempty"`
+	EndLatlng LatLng  `json:"end_latlng,omitempty"`
+	// The identifier provided at upload time
+	ExternalId string `json:"external_id,omitempty"`
+	// Whether this activity is flagged
+	Flagged bool `json:"flagged,omitempty"`
+	// The id of the gear for the activity
+	GearId string `json:"gear_id,omitempty"`
+	// Whether the logged-in athlete has kudoed this activity
+	HasKudoed bool `json:"has_kudoed,omitempty"`
+	// Whether the activity is muted
+	HideFromHome bool `json:"hide_from_home,omitempty"`
+	// The total work done in kilojoules during this activity. Rides only
+	Kilojoules float64 `json:"kilojoules,omitempty"`
+	// The number of kudos given for this activity
+	KudosCount int `json:"kudos_count,omitempty"`
+	// Whether this activity was created manually
+	Manual bool         `json:"manual,omitempty"`
+	Map    *PolylineMap `json:"map,omitempty"`
+	// The activity's max speed, in meters per second
+	MaxSpeed float64 `json:"max_speed,omitempty"`
+	// Rides with power meter data only
+	MaxWatts int `json:"max_watts,omitempty"`
+	// The activity's moving time, in seconds
+	MovingTime int `json:"moving_time,omitempty"`
+	// The name of the activity
+	Name string `json:"name,omitempty"`
+	// The number of Instagram photos for this activity
+	PhotoCount int `json:"photo_count,omitempty"`
+	// Whether this activity is private
+	Private   bool      `json:"private,omitempty"`
+	SportType SportType `json:"sport_type,omitempty"`
+	// The time at which the activity was started.
+	StartDate time.Time `json:"start_date,omitempty"`
+	// The time at which the activity was started in the local timezone.
+	StartDateLocal time.Time `json:"start_date_local,omitempty"`
+	StartLatlng    LatLng    `json:"start_latlng,omitempty"`
+	// The timezone of the activity
+	Timezone string `json:"timezone,omitempty"`
+	// The activity's total elevation gain.
+	TotalElevationGain float64 `json:"total_elevation_gain,omitempty"`
+	// The number of Instagram and Strava photos for this activity
+	TotalPhotoCount int `json:"total_photo_count,omitempty"`
+	// Whether this activity was recorded on a training machine
+	Trainer bool `json:"trainer,omitempty"`
+	// Deprecated. Prefer to use sport_type
+	Type ActivityType `json:"type,omitempty"`
+	// The identifier of the upload that resulted in this activity
+	UploadId int64 `json:"upload_id,omitempty"`
+	// The unique identifier of the upload in string format
+	UploadIdStr string `json:"upload_id_str,omitempty"`
+	// Similar to Normalized Power. Rides with power meter data only
+	WeightedAverageWatts int `json:"weighted_average_watts,omitempty"`
+	// The activity's workout type
+	WorkoutType int                      `json:"workout_type,omitempty"`
+	BestEfforts []*DetailedSegmentEffort `json:"best_efforts,omitempty"`
+	// The number of kilocalories consumed during this activity
+	Calories float64 `json:"calories,omitempty"`
+	// The description of the activity
+	Description string `json:"description,omitempty"`
+	// The name of the device used to record the activity
+	DeviceName string `json:"device_name,omitempty"`
+	// The token used to embed a Strava activity
+	EmbedToken     string                   `json:"embed_token,omitempty"`
+	Gear           *SummaryGear             `json:"gear,omitempty"`
+	Laps           []*Lap                   `json:"laps,omitempty"`
+	Photos         *PhotosSummary           `json:"photos,omitempty"`
+	SegmentEfforts []*DetailedSegmentEffort `json:"segment_efforts,omitempty"`
+	// The splits of this activity in metric units (for runs)
+	SplitsMetric []*Split `json:"splits_metric,omitempty"`
+	// The splits of this activity in imperial units (for runs)
+	SplitsStandard []*Split `json:"splits_standard,omitempty"`
+}
+
+// DetailedAthlete is generated from the spec
+type DetailedAthlete struct {
+	// The unique identifier of the athlete
+	Id int64 `json:"id,omitempty"`
+	// The athlete's city.
+	City string `json:"city,omitempty"`
+	// The athlete's country.
+	Country string `json:"country,omitempty"`
+	// The time at which the athlete was created.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// The athlete's first name.
+	Firstname string `json:"firstname,omitempty"`
+	// The athlete's last name.
+	Lastname string `json:"lastname,omitempty"`
+	// Deprecated. Use summit field instead. Whether the athlete has any Summit
+	// subscription.
+	Premium bool `json:"premium,omitempty"`
+	// URL to a 124x124 pixel profile picture.
+	Profile string `json:"profile,omitempty"`
+	// URL to a 62x62 pixel profile picture.
+	ProfileMedium string `json:"profile_medium,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 1 -> "meta", 2
+	// -> "summary", 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+	// The athlete's sex. One of M, F.
+	Sex string `json:"sex,omitempty"`
+	// The athlete's state or geographical region.
+	State string `json:"state,omitempty"`
+	// Whether the athlete has any Summit subscription.
+	Summit bool `json:"summit,omitempty"`
+	// The time at which the athlete was last updated.
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// The athlete's bikes.
+	Bikes []*SummaryGear `json:"bikes,omitempty"`
+	// The athlete's clubs.
+	Clubs []*SummaryClub `json:"clubs,omitempty"`
+	// The athlete's follower count.
+	FollowerCount int `json:"follower_count,omitempty"`
+	// The athlete's friend count.
+	FriendCount int `json:"friend_count,omitempty"`
+	// The athlete's FTP (Functional Threshold Power).
+	Ftp int `json:"ftp,omitempty"`
+	// The athlete's preferred unit system. One of feet, meters.
+	MeasurementPreference string `json:"measurement_preference,omitempty"`
+	// The athlete's shoes.
+	Shoes []*SummaryGear `json:"shoes,omitempty"`
+	// The athlete's weight.
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// DetailedClub is generated from the spec
+type DetailedClub struct {
+	// The club's unique identifier.
+	Id int64 `json:"id,omitempty"`
+	// The club's name.
+	Name string `json:"name,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 1 -> "meta", 2
+	// -> "summary", 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+	// The activity types that count for a club. This takes precedence over
+	// sport_type.
+	ActivityTypes []ActivityType `json:"activity_types,omitempty"`
+	// The club's city.
+	City string `json:"city,omitempty"`
+	// The club's country.
+	Country string `json:"country,omitempty"`
+	// URL to a ~1185x580 pixel cover photo.
+	CoverPhoto string `json:"cover_photo,omitempty"`
+	// URL to a ~360x176 pixel cover photo.
+	CoverPhotoSmall string `json:"cover_photo_small,omitempty"`
+	// Whether the club is featured or not.
+	Featured bool `json:"featured,omitempty"`
+	// The club's member count.
+	MemberCount int `json:"member_count,omitempty"`
+	// Whether the club is private.
+	Private bool `json:"private,omitempty"`
+	// URL to a 60x60 pixel profile picture.
+	ProfileMedium string `json:"profile_medium,omitempty"`
+	// Deprecated. Prefer to use activity_types. One of cycling, running,
+	// triathlon, other.
+	SportType string `json:"sport_type,omitempty"`
+	// The club's state or geographical region.
+	State string `json:"state,omitempty"`
+	// The club's vanity URL.
+	URL string `json:"url,omitempty"`
+	// Whether the club is verified or not.
+	Verified bool `json:"verified,omitempty"`
+	// Whether the currently logged-in athlete is an administrator of this club.
+	Admin bool `json:"admin,omitempty"`
+	// The number of athletes in the club that the logged-in athlete follows.
+	FollowingCount int `json:"following_count,omitempty"`
+	// The membership status of the logged-in athlete. One of member, pending.
+	Membership string `json:"membership,omitempty"`
+	// Whether the currently logged-in athlete is the owner of this club.
+	Owner bool `json:"owner,omitempty"`
+}
+
+// DetailedGear is generated from the spec
+type DetailedGear struct {
+	// The distance logged with this gear.
+	Distance float64 `json:"distance,omitempty"`
+	// The gear's unique identifier.
+	Id string `json:"id,omitempty"`
+	// The gear's name.
+	Name string `json:"name,omitempty"`
+	// Whether this gear's is the owner's default one.
+	Primary bool `json:"primary,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 2 -> "summary",
+	// 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+	// The gear's brand name.
+	BrandName string `json:"brand_name,omitempty"`
+	// The gear's description.
+	Description string `json:"description,omitempty"`
+	// The gear's frame type (bike only).
+	FrameType int `json:"frame_type,omitempty"`
+	// The gear's model name.
+	ModelName string `json:"model_name,omitempty"`
+}
+
+// DetailedSegment is generated from the spec
+type DetailedSegment struct {
+	// One of Ride, Run.
+	ActivityType        string                  `json:"activity_type,omitempty"`
+	AthletePrEffort     *SummaryPRSegmentEffort `json:"athlete_pr_effort,omitempty"`
+	AthleteSegmentStats *SummarySegmentEffort   `json:"athlete_segment_stats,omitempty"`
+	// The segment's average grade, in percents
+	AverageGrade float64 `json:"average_grade,omitempty"`
+	// The segments's city.
+	City string `json:"city,omitempty"`
+	// The category of the climb [0, 5]. Higher is harder ie. 5 is Hors
+	// catégorie, 0 is uncategorized in climb_category.
+	ClimbCategory int `json:"climb_category,omitempty"`
+	// The segment's country.
+	Country string `json:"country,omitempty"`
+	// The segment's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The segments's highest elevation, in meters
+	ElevationHigh float64 `json:"elevation_high,omitempty"`
+	// The segments's lowest elevation, in meters
+	ElevationLow float64 `json:"elevation_low,omitempty"`
+	EndLatlng    LatLng  `json:"end_latlng,omitempty"`
+	// The unique identifier of this segment
+	Id int64 `json:"id,omitempty"`
+	// The segments's maximum grade, in percents
+	MaximumGrade float64 `json:"maximum_grade,omitempty"`
+	// The name of this segment
+	Name string `json:"name,omitempty"`
+	// Whether this segment is private.
+	Private     bool   `json:"private,omitempty"`
+	StartLatlng LatLng `json:"start_latlng,omitempty"`
+	// The segments's state or geographical region.
+	State string `json:"state,omitempty"`
+	// The number of unique athletes who have an effort for this segment
+	AthleteCount int `json:"athlete_count,omitempty"`
+	// The time at which the segment was created.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// The total number of efforts for this segment
+	EffortCount int `json:"effort_count,omitempty"`
+	// Whether this segment is considered hazardous
+	Hazardous bool         `json:"hazardous,omitempty"`
+	Map       *PolylineMap `json:"map,omitempty"`
+	// The number of stars for this segment
+	StarCount int `json:"star_count,omitempty"`
+	// The segment's total elevation gain.
+	TotalElevationGain float64 `json:"total_elevation_gain,omitempty"`
+	// The time at which the segment was last updated.
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// DetailedSegmentEffort is generated from the spec
+type DetailedSegmentEffort struct {
+	// The unique identifier of the activity related to this effort
+	ActivityId int64 `json:"activity_id,omitempty"`
+	// The effort's distance in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The effort's elapsed time
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The unique identifier of this effort
+	Id int64 `json:"id,omitempty"`
+	// Whether this effort is the current best on the leaderboard
+	IsKom bool `json:"is_kom,omitempty"`
+	// The time at which the effort was started.
+	StartDate time.Time `json:"start_date,omitempty"`
+	// The time at which the effort was started in the local timezone.
+	StartDateLocal time.Time     `json:"start_date_local,omitempty"`
+	Activity       *MetaActivity `json:"activity,omitempty"`
+	Athlete        *MetaAthlete  `json:"athlete,omitempty"`
+	// The effort's average cadence
+	AverageCadence float64 `json:"average_cadence,omitempty"`
+	// The heart heart rate of the athlete during this effort
+	AverageHeartrate float64 `json:"average_heartrate,omitempty"`
+	// The average wattage of this effort
+	AverageWatts float64 `json:"average_watts,omitempty"`
+	// For riding efforts, whether the wattage was reported by a dedicated
+	// recording device
+	DeviceWatts bool `json:"device_watts,omitempty"`
+	// The end index of this effort in its activity's stream
+	EndIndex int `json:"end_index,omitempty"`
+	// Whether this effort should be hidden when viewed within an activity
+	Hidden bool `json:"hidden,omitempty"`
+	// The rank of the effort on the global leaderboard if it belongs in the top
+	// 10 at the time of upload
+	KomRank int `json:"kom_rank,omitempty"`
+	// The maximum heart rate of the athlete during this effort
+	MaxHeartrate float64 `json:"max_heartrate,omitempty"`
+	// The effort's moving time
+	MovingTime int `json:"moving_time,omitempty"`
+	// The name of the segment on which this effort was performed
+	Name string `json:"name,omitempty"`
+	// The rank of the effort on the athlete's leaderboard if it belongs in the
+	// top 3 at the time of upload
+	PrRank  int             `json:"pr_rank,omitempty"`
+	Segment *SummarySegment `json:"segment,omitempty"`
+	// The start index of this effort in its activity's stream
+	StartIndex int `json:"start_index,omitempty"`
+}
+
+// DistanceStream is generated from the spec
+type DistanceStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of distance values for this stream, in meters
+	Data []float64 `json:"data,omitempty"`
+}
+
+// Error is generated from the spec
+type Error struct {
+	// The code associated with this error.
+	Code string `json:"code,omitempty"`
+	// The specific field or aspect of the resource associated with this error.
+	Field string `json:"field,omitempty"`
+	// The type of resource associated with this error.
+	Resource string `json:"resource,omitempty"`
+}
+
+// ExplorerResponse is generated from the spec
+type ExplorerResponse struct {
+	// The set of segments matching an explorer request
+	Segments []*ExplorerSegment `json:"segments,omitempty"`
+}
+
+// ExplorerSegment is generated from the spec
+type ExplorerSegment struct {
+	// The segment's average grade, in percents
+	AvgGrade float64 `json:"avg_grade,omitempty"`
+	// The category of the climb [0, 5]. Higher is harder ie. 5 is Hors
+	// catégorie, 0 is uncategorized in climb_category. If climb_category = 5,
+	// climb_category_desc = HC. If climb_category = 2, climb_category_desc = 3.
+	ClimbCategory int `json:"climb_category,omitempty"`
+	// The description for the category of the climb One of NC, 4, 3, 2, 1, HC.
+	ClimbCategoryDesc string `json:"climb_category_desc,omitempty"`
+	// The segment's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The segments's evelation difference, in meters
+	ElevDifference float64 `json:"elev_difference,omitempty"`
+	EndLatlng      LatLng  `json:"end_latlng,omitempty"`
+	// The unique identifier of this segment
+	Id int64 `json:"id,omitempty"`
+	// The name of this segment
+	Name string `json:"name,omitempty"`
+	// The polyline of the segment
+	Points      string `json:"points,omitempty"`
+	StartLatlng LatLng `json:"start_latlng,omitempty"`
+}
+
+// Fault: Encapsulates the errors that may be returned from the API.
+type Fault struct {
+	// The set of specific errors associated with this fault, if any.
+	Errors []*Error `json:"errors,omitempty"`
+	// The message of the fault.
+	Message string `json:"message,omitempty"`
+}
+
+// HeartRateZoneRanges is generated from the spec
+type HeartRateZoneRanges struct {
+	// Whether the athlete has set their own custom heart rate zones
+	CustomZones bool       `json:"custom_zones,omitempty"`
+	Zones       ZoneRanges `json:"zones,omitempty"`
+}
+
+// HeartrateStream is generated from the spec
+type HeartrateStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of heart rate values for this stream, in beats per minute
+	Data []int `json:"data,omitempty"`
+}
+
+// Lap is generated from the spec
+type Lap struct {
+	Activity *MetaActivity `json:"activity,omitempty"`
+	Athlete  *MetaAthlete  `json:"athlete,omitempty"`
+	// The lap's average cadence
+	AverageCadence float64 `json:"average_cadence,omitempty"`
+	// The lap's average speed
+	AverageSpeed float64 `json:"average_speed,omitempty"`
+	// The lap's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The lap's elapsed time, in seconds
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The end index of this effort in its activity's stream
+	EndIndex int `json:"end_index,omitempty"`
+	// The unique identifier of this lap
+	Id int64 `json:"id,omitempty"`
+	// The index of this lap in the activity it belongs to
+	LapIndex int `json:"lap_index,omitempty"`
+	// The maximum speed of this lat, in meters per second
+	MaxSpeed float64 `json:"max_speed,omitempty"`
+	// The lap's moving time, in seconds
+	MovingTime int `json:"moving_time,omitempty"`
+	// The name of the lap
+	Name string `json:"name,omitempty"`
+	// The athlete's pace zone during this lap
+	PaceZone int `json:"pace_zone,omitempty"`
+	Split    int `json:"split,omitempty"`
+	// The time at which the lap was started.
+	StartDate time.Time `json:"start_date,omitempty"`
+	// The time at which the lap was started in the local timezone.
+	StartDateLocal time.Time `json:"start_date_local,omitempty"`
+	// The start index of this effort in its activity's stream
+	StartIndex int `json:"start_index,omitempty"`
+	// The elevation gain of this lap, in meters
+	TotalElevationGain float64 `json:"total_elevation_gain,omitempty"`
+}
+
+// LatLng is a collection of float objects. A pair of latitude/longitude
+// coordinates, represented as an array of 2 floating point numbers.
+type LatLng []float64
+
+// LatLngStream is generated from the spec
+type LatLngStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of lat/long values for this stream
+	Data []LatLng `json:"data,omitempty"`
+}
+
+// MetaActivity is generated from the spec
+type MetaActivity struct {
+	// The unique identifier of the activity
+	Id int64 `json:"id,omitempty"`
+}
+
+// MetaAthlete is generated from the spec
+type MetaAthlete struct {
+	// The unique identifier of the athlete
+	Id int64 `json:"id,omitempty"`
+}
+
+// MetaClub is generated from the spec
+type MetaClub struct {
+	// The club's unique identifier.
+	Id int64 `json:"id,omitempty"`
+	// The club's name.
+	Name string `json:"name,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 1 -> "meta", 2
+	// -> "summary", 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+}
+
+// MovingStream is generated from the spec
+type MovingStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of moving values for this stream, as boolean values
+	Data []bool `json:"data,omitempty"`
+}
+
+// PhotosSummary is generated from the spec
+type PhotosSummary struct {
+	// The number of photos
+	Count   int                   `json:"count,omitempty"`
+	Primary *PhotosSummaryPrimary `json:"primary,omitempty"`
+}
+
+// PhotosSummaryPrimary is generated from the spec
+type PhotosSummaryPrimary struct {
+	Id       int64             `json:"id,omitempty"`
+	Source   int               `json:"source,omitempty"`
+	UniqueId string            `json:"unique_id,omitempty"`
+	Urls     map[string]string `json:"urls,omitempty"`
+}
+
+// PolylineMap is generated from the spec
+type PolylineMap struct {
+	// The identifier of the map
+	Id string `json:"id,omitempty"`
+	// The polyline of the map, only returned on detailed representation of an
+	// object
+	Polyline string `json:"polyline,omitempty"`
+	// The summary polyline of the map
+	SummaryPolyline string `json:"summary_polyline,omitempty"`
+}
+
+// PowerStream is generated from the spec
+type PowerStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of power values for this stream, in watts
+	Data []int `json:"data,omitempty"`
+}
+
+// PowerZoneRanges is generated from the spec
+type PowerZoneRanges struct {
+	Zones ZoneRanges `json:"zones,omitempty"`
+}
+
+// Route is generated from the spec
+type Route struct {
+	Athlete *SummaryAthlete `json:"athlete,omitempty"`
+	// The time at which the route was created
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// The description of the route
+	Description string `json:"description,omitempty"`
+	// The route's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The route's elevation gain.
+	ElevationGain float64 `json:"elevation_gain,omitempty"`
+	// Estimated time in seconds for the authenticated athlete to complete route
+	EstimatedMovingTime int `json:"estimated_moving_time,omitempty"`
+	// The unique identifier of this route
+	Id int64 `json:"id,omitempty"`
+	// The unique identifier of the route in string format
+	IdStr string       `json:"id_str,omitempty"`
+	Map   *PolylineMap `json:"map,omitempty"`
+	// The name of this route
+	Name string `json:"name,omitempty"`
+	// Whether this route is private
+	Private bool `json:"private,omitempty"`
+	// The segments traversed by this route
+	Segments []*SummarySegment `json:"segments,omitempty"`
+	// Whether this route is starred by the logged-in athlete
+	Starred bool `json:"starred,omitempty"`
+	// This route's sub-type (1 for road, 2 for mountain bike, 3 for cross, 4 for
+	// trail, 5 for mixed)
+	SubType int `json:"sub_type,omitempty"`
+	// An epoch timestamp of when the route was created
+	Timestamp int `json:"timestamp,omitempty"`
+	// This route's type (1 for ride, 2 for runs)
+	Type int `json:"type,omitempty"`
+	// The time at which the route was last updated
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// The custom waypoints along this route
+	Waypoints []*Waypoint `json:"waypoints,omitempty"`
+}
+
+// SmoothGradeStream is generated from the spec
+type SmoothGradeStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of grade values for this stream, as percents of a grade
+	Data []float64 `json:"data,omitempty"`
+}
+
+// SmoothVelocityStream is generated from the spec
+type SmoothVelocityStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of velocity values for this stream, in meters per second
+	Data []float64 `json:"data,omitempty"`
+}
+
+// Split is generated from the spec
+type Split struct {
+	// The average speed of this split, in meters per second
+	AverageSpeed float64 `json:"average_speed,omitempty"`
+	// The distance of this split, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The elapsed time of this split, in seconds
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The elevation difference of this split, in meters
+	ElevationDifference float64 `json:"elevation_difference,omitempty"`
+	// The moving time of this split, in seconds
+	MovingTime int `json:"moving_time,omitempty"`
+	// The pacing zone of this split
+	PaceZone int `json:"pace_zone,omitempty"`
+	// N/A
+	Split int `json:"split,omitempty"`
+}
+
+// SportType is an enumeration of the sport types an activity may have.
+// Distinct from ActivityType in that it has new types (e.g. MountainBikeRide)
+type SportType string
+
+// The values of SportType
+const (
+	SportTypeAlpineSki                     SportType = "AlpineSki"
+	SportTypeBackcountrySki                SportType = "BackcountrySki"
+	SportTypeBadminton                     SportType = "Badminton"
+	SportTypeCanoeing                      SportType = "Canoeing"
+	SportTypeCrossfit                      SportType = "Crossfit"
+	SportTypeEBikeRide                     SportType = "EBikeRide"
+	SportTypeElliptical                    SportType = "Elliptical"
+	SportTypeEMountainBikeRide             SportType = "EMountainBikeRide"
+	SportTypeGolf                          SportType = "Golf"
+	SportTypeGravelRide                    SportType = "GravelRide"
+	SportTypeHandcycle                     SportType = "Handcycle"
+	SportTypeHighIntensityIntervalTraining SportType = "HighIntensityIntervalTraining"
+	SportTypeHike                          SportType = "Hike"
+	SportTypeIceSkate                      SportType = "IceSkate"
+	SportTypeInlineSkate                   SportType = "InlineSkate"
+	SportTypeKayaking                      SportType = "Kayaking"
+	SportTypeKitesurf                      SportType = "Kitesurf"
+	SportTypeMountainBikeRide              SportType = "MountainBikeRide"
+	SportTypeNordicSki                     SportType = "NordicSki"
+	SportTypePickleball                    SportType = "Pickleball"
+	SportTypePilates                       SportType = "Pilates"
+	SportTypeRacquetball                   SportType = "Racquetball"
+	SportTypeRide                          SportType = "Ride"
+	SportTypeRockClimbing                  SportType = "RockClimbing"
+	SportTypeRollerSki                     SportType = "RollerSki"
+	SportTypeRowing                        SportType = "Rowing"
+	SportTypeRun                           SportType = "Run"
+	SportTypeSail                          SportType = "Sail"
+	SportTypeSkateboard                    SportType = "Skateboard"
+	SportTypeSnowboard                     SportType = "Snowboard"
+	SportTypeSnowshoe                      SportType = "Snowshoe"
+	SportTypeSoccer                        SportType = "Soccer"
+	SportTypeSquash                        SportType = "Squash"
+	SportTypeStairStepper                  SportType = "StairStepper"
+	SportTypeStandUpPaddling               SportType = "StandUpPaddling"
+	SportTypeSurfing                       SportType = "Surfing"
+	SportTypeSwim                          SportType = "Swim"
+	SportTypeTableTennis                   SportType = "TableTennis"
+	SportTypeTennis                        SportType = "Tennis"
+	SportTypeTrailRun                      SportType = "TrailRun"
+	SportTypeVelomobile                    SportType = "Velomobile"
+	SportTypeVirtualRide                   SportType = "VirtualRide"
+	SportTypeVirtualRow                    SportType = "VirtualRow"
+	SportTypeVirtualRun                    SportType = "VirtualRun"
+	SportTypeWalk                          SportType = "Walk"
+	SportTypeWeightTraining                SportType = "WeightTraining"
+	SportTypeWheelchair                    SportType = "Wheelchair"
+	SportTypeWindsurf                      SportType = "Windsurf"
+	SportTypeWorkout                       SportType = "Workout"
+	SportTypeYoga                          SportType = "Yoga"
+)
+
+// StreamSet is generated from the spec
+type StreamSet struct {
+	Altitude       *AltitudeStream       `json:"altitude,omitempty"`
+	Cadence        *CadenceStream        `json:"cadence,omitempty"`
+	Distance       *DistanceStream       `json:"distance,omitempty"`
+	GradeSmooth    *SmoothGradeStream    `json:"grade_smooth,omitempty"`
+	Heartrate      *HeartrateStream      `json:"heartrate,omitempty"`
+	Latlng         *LatLngStream         `json:"latlng,omitempty"`
+	Moving         *MovingStream         `json:"moving,omitempty"`
+	Temp           *TemperatureStream    `json:"temp,omitempty"`
+	Time           *TimeStream           `json:"time,omitempty"`
+	VelocitySmooth *SmoothVelocityStream `json:"velocity_smooth,omitempty"`
+	Watts          *PowerStream          `json:"watts,omitempty"`
+}
+
+// SummaryActivity is generated from the spec
+type SummaryActivity struct {
+	// The unique identifier of the activity
+	Id int64 `json:"id,omitempty"`
+	// The number of achievements gained during this activity
+	AchievementCount int          `json:"achievement_count,omitempty"`
+	Athlete          *MetaAthlete `json:"athlete,omitempty"`
+	// The number of athletes for taking part in a group activity
+	AthleteCount int `json:"athlete_count,omitempty"`
+	// The activity's average speed, in meters per second
+	AverageSpeed float64 `json:"average_speed,omitempty"`
+	// Average power output in watts during this activity. Rides only
+	AverageWatts float64 `json:"average_watts,omitempty"`
+	// The number of comments for this activity
+	CommentCount int `json:"comment_count,omitempty"`
+	// Whether this activity is a commute
+	Commute bool `json:"commute,omitempty"`
+	// Whether the watts are from a power meter, false if estimated
+	DeviceWatts bool `json:"device_watts,omitempty"`
+	// The activity's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The activity's elapsed time, in seconds
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The activity's highest elevation, in meters
+	ElevHigh float64 `json:"elev_high,omitempty"`
+	// The activity's lowest elevation, in meters
+	ElevLow   float64 `json:"elev_low,omitempty"`
+	EndLatlng LatLng  `json:"end_latlng,omitempty"`
+	// The identifier provided at upload time
+	ExternalId string `json:"external_id,omitempty"`
+	// Whether this activity is flagged
+	Flagged bool `json:"flagged,omitempty"`
+	// The id of the gear for the activity
+	GearId string `json:"gear_id,omitempty"`
+	// Whether the logged-in athlete has kudoed this activity
+	HasKudoed bool `json:"has_kudoed,omitempty"`
+	// Whether the activity is muted
+	HideFromHome bool `json:"hide_from_home,omitempty"`
+	// The total work done in kilojoules during this activity. Rides only
+	Kilojoules float64 `json:"kilojoules,omitempty"`
+	// The number of kudos given for this activity
+	KudosCount int `json:"kudos_count,omitempty"`
+	// Whether this activity was created manually
+	Manual bool         `json:"manual,omitempty"`
+	Map    *PolylineMap `json:"map,omitempty"`
+	// The activity's max speed, in meters per second
+	MaxSpeed float64 `json:"max_speed,omitempty"`
+	// Rides with power meter data only
+	MaxWatts int `json:"max_watts,omitempty"`
+	// The activity's moving time, in seconds
+	MovingTime int `json:"moving_time,omitempty"`
+	// The name of the activity
+	Name string `json:"name,omitempty"`
+	// The number of Instagram photos for this activity
+	PhotoCount int `json:"photo_count,omitempty"`
+	// Whether this activity is private
+	Private   bool      `json:"private,omitempty"`
+	SportType SportType `json:"sport_type,omitempty"`
+	// The time at which the activity was started.
+	StartDate time.Time `json:"start_date,omitempty"`
+	// The time at which the activity was started in the local timezone.
+	StartDateLocal time.Time `json:"start_date_local,omitempty"`
+	StartLatlng    LatLng    `json:"start_latlng,omitempty"`
+	// The timezone of the activity
+	Timezone string `json:"timezone,omitempty"`
+	// The activity's total elevation gain.
+	TotalElevationGain float64 `json:"total_elevation_gain,omitempty"`
+	// The number of Instagram and Strava photos for this activity
+	TotalPhotoCount int `json:"total_photo_count,omitempty"`
+	// Whether this activity was recorded on a training machine
+	Trainer bool `json:"trainer,omitempty"`
+	// Deprecated. Prefer to use sport_type
+	Type ActivityType `json:"type,omitempty"`
+	// The identifier of the upload that resulted in this activity
+	UploadId int64 `json:"upload_id,omitempty"`
+	// The unique identifier of the upload in string format
+	UploadIdStr string `json:"upload_id_str,omitempty"`
+	// Similar to Normalized Power. Rides with power meter data only
+	WeightedAverageWatts int `json:"weighted_average_watts,omitempty"`
+	// The activity's workout type
+	WorkoutType int `json:"workout_type,omitempty"`
+}
+
+// SummaryAthlete is generated from the spec
+type SummaryAthlete struct {
+	// The unique identifier of the athlete
+	Id int64 `json:"id,omitempty"`
+	// The athlete's city.
+	City string `json:"city,omitempty"`
+	// The athlete's country.
+	Country string `json:"country,omitempty"`
+	// The time at which the athlete was created.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// The athlete's first name.
+	Firstname string `json:"firstname,omitempty"`
+	// The athlete's last name.
+	Lastname string `json:"lastname,omitempty"`
+	// Deprecated. Use summit field instead. Whether the athlete has any Summit
+	// subscription.
+	Premium bool `json:"premium,omitempty"`
+	// URL to a 124x124 pixel profile picture.
+	Profile string `json:"profile,omitempty"`
+	// URL to a 62x62 pixel profile picture.
+	ProfileMedium string `json:"profile_medium,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 1 -> "meta", 2
+	// -> "summary", 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+	// The athlete's sex. One of M, F.
+	Sex string `json:"sex,omitempty"`
+	// The athlete's state or geographical region.
+	State string `json:"state,omitempty"`
+	// Whether the athlete has any Summit subscription.
+	Summit bool `json:"summit,omitempty"`
+	// The time at which the athlete was last updated.
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// SummaryClub is generated from the spec
+type SummaryClub struct {
+	// The club's unique identifier.
+	Id int64 `json:"id,omitempty"`
+	// The club's name.
+	Name string `json:"name,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 1 -> "meta", 2
+	// -> "summary", 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+	// The activity types that count for a club. This takes precedence over
+	// sport_type.
+	ActivityTypes []ActivityType `json:"activity_types,omitempty"`
+	// The club's city.
+	City string `json:"city,omitempty"`
+	// The club's country.
+	Country string `json:"country,omitempty"`
+	// URL to a ~1185x580 pixel cover photo.
+	CoverPhoto string `json:"cover_photo,omitempty"`
+	// URL to a ~360x176 pixel cover photo.
+	CoverPhotoSmall string `json:"cover_photo_small,omitempty"`
+	// Whether the club is featured or not.
+	Featured bool `json:"featured,omitempty"`
+	// The club's member count.
+	MemberCount int `json:"member_count,omitempty"`
+	// Whether the club is private.
+	Private bool `json:"private,omitempty"`
+	// URL to a 60x60 pixel profile picture.
+	ProfileMedium string `json:"profile_medium,omitempty"`
+	// Deprecated. Prefer to use activity_types. One of cycling, running,
+	// triathlon, other.
+	SportType string `json:"sport_type,omitempty"`
+	// The club's state or geographical region.
+	State string `json:"state,omitempty"`
+	// The club's vanity URL.
+	URL string `json:"url,omitempty"`
+	// Whether the club is verified or not.
+	Verified bool `json:"verified,omitempty"`
+}
+
+// SummaryGear is generated from the spec
+type SummaryGear struct {
+	// The distance logged with this gear.
+	Distance float64 `json:"distance,omitempty"`
+	// The gear's unique identifier.
+	Id string `json:"id,omitempty"`
+	// The gear's name.
+	Name string `json:"name,omitempty"`
+	// Whether this gear's is the owner's default one.
+	Primary bool `json:"primary,omitempty"`
+	// Resource state, indicates level of detail. Possible values: 2 -> "summary",
+	// 3 -> "detail"
+	ResourceState int `json:"resource_state,omitempty"`
+}
+
+// SummaryPRSegmentEffort is generated from the spec
+type SummaryPRSegmentEffort struct {
+	// Number of efforts by the authenticated athlete on this segment.
+	EffortCount int `json:"effort_count,omitempty"`
+	// The unique identifier of the activity related to the PR effort.
+	PrActivityId int64 `json:"pr_activity_id,omitempty"`
+	// The time at which the PR effort was started.
+	PrDate time.Time `json:"pr_date,omitempty"`
+	// The elapsed time ot the PR effort.
+	PrElapsedTime int `json:"pr_elapsed_time,omitempty"`
+}
+
+// SummarySegment is generated from the spec
+type SummarySegment struct {
+	// One of Ride, Run.
+	ActivityType        string                  `json:"activity_type,omitempty"`
+	AthletePrEffort     *SummaryPRSegmentEffort `json:"athlete_pr_effort,omitempty"`
+	AthleteSegmentStats *SummarySegmentEffort   `json:"athlete_segment_stats,omitempty"`
+	// The segment's average grade, in percents
+	AverageGrade float64 `json:"average_grade,omitempty"`
+	// The segments's city.
+	City string `json:"city,omitempty"`
+	// The category of the climb [0, 5]. Higher is harder ie. 5 is Hors
+	// catégorie, 0 is uncategorized in climb_category.
+	ClimbCategory int `json:"climb_category,omitempty"`
+	// The segment's country.
+	Country string `json:"country,omitempty"`
+	// The segment's distance, in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The segments's highest elevation, in meters
+	ElevationHigh float64 `json:"elevation_high,omitempty"`
+	// The segments's lowest elevation, in meters
+	ElevationLow float64 `json:"elevation_low,omitempty"`
+	EndLatlng    LatLng  `json:"end_latlng,omitempty"`
+	// The unique identifier of this segment
+	Id int64 `json:"id,omitempty"`
+	// The segments's maximum grade, in percents
+	MaximumGrade float64 `json:"maximum_grade,omitempty"`
+	// The name of this segment
+	Name string `json:"name,omitempty"`
+	// Whether this segment is private.
+	Private     bool   `json:"private,omitempty"`
+	StartLatlng LatLng `json:"start_latlng,omitempty"`
+	// The segments's state or geographical region.
+	State string `json:"state,omitempty"`
+}
+
+// SummarySegmentEffort is generated from the spec
+type SummarySegmentEffort struct {
+	// The unique identifier of the activity related to this effort
+	ActivityId int64 `json:"activity_id,omitempty"`
+	// The effort's distance in meters
+	Distance float64 `json:"distance,omitempty"`
+	// The effort's elapsed time
+	ElapsedTime int `json:"elapsed_time,omitempty"`
+	// The unique identifier of this effort
+	Id int64 `json:"id,omitempty"`
+	// Whether this effort is the current best on the leaderboard
+	IsKom bool `json:"is_kom,omitempty"`
+	// The time at which the effort was started.
+	StartDate time.Time `json:"start_date,omitempty"`
+	// The time at which the effort was started in the local timezone.
+	StartDateLocal time.Time `json:"start_date_local,omitempty"`
+}
+
+// TemperatureStream is generated from the spec
+type TemperatureStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of temperature values for this stream, in celsius degrees
+	Data []int `json:"data,omitempty"`
+}
+
+// TimeStream is generated from the spec
+type TimeStream struct {
+	// The number of data points in this stream
+	OriginalSize int `json:"original_size,omitempty"`
+	// The level of detail (sampling) in which this stream was returned One of
+	// low, medium, high.
+	Resolution string `json:"resolution,omitempty"`
+	// The base series used in the case the stream was downsampled One of
+	// distance, time.
+	SeriesType string `json:"series_type,omitempty"`
+	// The sequence of time values for this stream, in seconds
+	Data []int `json:"data,omitempty"`
+}
+
+// TimedZoneDistribution: Stores the exclusive ranges representing zones and
+// the time spent in each.
+type TimedZoneDistribution []*TimedZoneRange
+
+// TimedZoneRange is a union type representing the time spent in a given zone.
+type TimedZoneRange struct {
+	// The maximum value in the range.
+	Max int `json:"max,omitempty"`
+	// The minimum value in the range.
+	Min int `json:"min,omitempty"`
+	// The number of seconds spent in this zone
+	Time int `json:"time,omitempty"`
+}
+
+// UpdatableActivity is generated from the spec
+type UpdatableActivity struct {
+	// Whether this activity is a commute
+	Commute bool `json:"commute,omitempty"`
+	// The description of the activity
+	Description string `json:"description,omitempty"`
+	// Identifier for the gear associated with the activity. 'none' clears gear
+	// from activity
+	GearId string `json:"gear_id,omitempty"`
+	// Whether this activity is muted
+	HideFromHome bool `json:"hide_from_home,omitempty"`
+	// The name of the activity
+	Name      string    `json:"name,omitempty"`
+	SportType SportType `json:"sport_type,omitempty"`
+	// Whether this activity was recorded on a training machine
+	Trainer bool `json:"trainer,omitempty"`
+	// Deprecated. Prefer to use sport_type. In a request where both type and
+	// sport_type are present, this field will be ignored
+	Type ActivityType `json:"type,omitempty"`
+}
+
+// Upload is generated from the spec
+type Upload struct {
+	// The identifier of the activity this upload resulted into
+	ActivityId int64 `json:"activity_id,omitempty"`
+	// The error associated with this upload
+	Error string `json:"error,omitempty"`
+	// The external identifier of the upload
+	ExternalId string `json:"external_id,omitempty"`
+	// The unique identifier of the upload
+	Id int64 `json:"id,omitempty"`
+	// The unique identifier of the upload in string format
+	IdStr string `json:"id_str,omitempty"`
+	// The status of this upload
+	Status string `json:"status,omitempty"`
+}
+
+// Waypoint is generated from the spec
+type Waypoint struct {
+	// Categories that the waypoint belongs to
+	Categories []string `json:"categories,omitempty"`
+	// A description of the waypoint (optional)
+	Description string `json:"description,omitempty"`
+	// The number meters along the route that the waypoint is located
+	DistanceIntoRoute int `json:"distance_into_route,omitempty"`
+	// The location along the route that the waypoint is closest to
+	Latlng LatLng `json:"latlng,omitempty"`
+	// A location off of the route that the waypoint is (optional)
+	TargetLatlng LatLng `json:"target_latlng,omitempty"`
+	// A title for the waypoint
+	Title string `json:"title,omitempty"`
+}
+
+// ZoneRange is generated from the spec
+type ZoneRange struct {
+	// The maximum value in the range.
+	Max int `json:"max,omitempty"`
+	// The minimum value in the range.
+	Min int `json:"min,omitempty"`
+}
+
+// ZoneRanges is a collection of ZoneRange objects.
+type ZoneRanges []*ZoneRange
+
+// Zones is generated from the spec
+type Zones struct {
+	HeartRate *HeartRateZoneRanges `json:"heart_rate,omitempty"`
+	Power     *PowerZoneRanges     `json:"power,omitempty"`
+}
@@ -0,0 +1,36 @@
+package strava
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Photo is a photo attached to an activity. URLs maps the requested size
+// to the image address.
+type Photo struct {
+	UniqueId   string            `json:"unique_id"`
+	ActivityId int64             `json:"activity_id"`
+	Source     int               `json:"source"`
+	Caption    string            `json:"caption"`
+	URLs       map[string]string `json:"urls"`
+	Location   LatLng            `json:"location"`
+	CreatedAt  string            `json:"created_at"`
+	UploadedAt string            `json:"uploaded_at"`
+}
+
+// ListActivityPhotos returns the photos of an activity with URLs for the
+// largest side of size pixels, or the thumbnail size when size is 0
+func (c *Client) ListActivityPhotos(ctx context.Context, id int64, size int) ([]Photo, error) {
+	q := url.Values{}
+	q.Set("photo_sources", "true")
+	if size > 0 {
+		q.Set("size", strconv.Itoa(size))
+	}
+
+	photos := make([]Photo, 0)
+	if err := c.get(ctx, opDetail, "/activities/"+strconv.FormatInt(id, 10)+"/photos", q, &photos); err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
@@ -0,0 +1,53 @@
+package strava
+
+import "fmt"
+
+// DecodePolyline decodes a route in Google's encoded polyline format, as
+// used by PolylineMap, into latitude/longitude pairs
+func DecodePolyline(encoded string) ([]LatLng, error) {
+	points := make([]LatLng, 0, len(encoded)/4)
+	var lat, lng int
+
+	for i := 0; i < len(encoded); {
+		var deltas [2]int
+		for j := range deltas {
+			var result, shift int
+			for {
+				if i >= len(encoded) {
+					return nil, fmt.Errorf("strava: truncated polyline at byte %d", i)
+				}
+				b := int(encoded[i]) - 63
+				i++
+				if b < 0 || b > 63 {
+					return nil, fmt.Errorf("strava: invalid polyline byte %q at %d", encoded[i-1], i-1)
+				}
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[j] = ^(result >> 1)
+			} else {
+				deltas[j] = result >> 1
+			}
+		}
+
+		lat += deltas[0]
+		lng += deltas[1]
+		points = append(points, LatLng{float64(lat) / 1e5, float64(lng) / 1e5})
+	}
+	return points, nil
+}
+
+// Points decodes the most detailed polyline available on the map
+func (m *PolylineMap) Points() ([]LatLng, error) {
+	if m == nil {
+		return nil, nil
+	}
+	if m.Polyline != "" {
+		return DecodePolyline(m.Polyline)
+	}
+	return DecodePolyline(m.SummaryPolyline)
+}
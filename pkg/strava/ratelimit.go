@@ -0,0 +1,178 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strava's default read limits for an application
+const (
+	DefaultShortTermLimit = 100
+	DefaultDailyLimit     = 1000
+)
+
+// ErrRateLimited is returned by Limiter.Wait when the quota resets only
+// after the context's deadline, so waiting could not succeed
+var ErrRateLimited = errors.New("strava: rate limit reached")
+
+// RateLimiter paces requests to stay within the API quota. Wait blocks until
+// a request may be sent or ctx is done.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Limiter is the default RateLimiter. It counts requests against Strava's
+// fifteen-minute and daily windows, which reset on the quarter hour and at
+// midnight UTC, and corrects its counts from the X-RateLimit-Usage header of
+// each response.
+type Limiter struct {
+	mu         sync.Mutex
+	shortLimit int
+	dailyLimit int
+	shortUsed  int
+	dailyUsed  int
+	shortReset time.Time
+	dailyReset time.Time
+	now        func() time.Time
+}
+
+// NewRateLimiter returns a Limiter using Strava's default read limits
+func NewRateLimiter() *Limiter {
+	return &Limiter{
+		shortLimit: DefaultShortTermLimit,
+		dailyLimit: DefaultDailyLimit,
+		now:        time.Now,
+	}
+}
+
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		l.roll()
+		var until time.Time
+		switch {
+		case l.dailyUsed >= l.dailyLimit:
+			until = l.dailyReset
+		case l.shortUsed >= l.shortLimit:
+			until = l.shortReset
+		default:
+			l.shortUsed++
+			l.dailyUsed++
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+			return fmt.Errorf("%w until %s", ErrRateLimited, until.Local().Format(time.RFC3339))
+		}
+		timer := time.NewTimer(until.Sub(l.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Update reads the X-RateLimit-Limit and X-RateLimit-Usage headers, each a
+// "fifteen-minute,daily" pair, so the limiter tracks requests made by other
+// processes sharing the application.
+func (l *Limiter) Update(h http.Header) {
+	limitShort, limitDaily, ok := parseRatePair(h.Get("X-RateLimit-Limit"))
+	usedShort, usedDaily, usageOk := parseRatePair(h.Get("X-RateLimit-Usage"))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	if ok {
+		l.shortLimit, l.dailyLimit = limitShort, limitDaily
+	}
+	if usageOk {
+		l.shortUsed, l.dailyUsed = usedShort, usedDaily
+	}
+}
+
+// RateLimitUsage is a snapshot of the requests counted against each window
+type RateLimitUsage struct {
+	ShortUsed  int
+	ShortLimit int
+	ShortReset time.Time
+	DailyUsed  int
+	DailyLimit int
+	DailyReset time.Time
+}
+
+// ShortRemaining is the number of requests left in the fifteen-minute window
+func (u RateLimitUsage) ShortRemaining() int {
+	return max(u.ShortLimit-u.ShortUsed, 0)
+}
+
+// DailyRemaining is the number of requests left today
+func (u RateLimitUsage) DailyRemaining() int {
+	return max(u.DailyLimit-u.DailyUsed, 0)
+}
+
+// Usage returns the current counts and when each window resets
+func (l *Limiter) Usage() RateLimitUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	return RateLimitUsage{
+		ShortUsed:  l.shortUsed,
+		ShortLimit: l.shortLimit,
+		ShortReset: l.shortReset,
+		DailyUsed:  l.dailyUsed,
+		DailyLimit: l.dailyLimit,
+		DailyReset: l.dailyReset,
+	}
+}
+
+// ParseRateLimit reads the limits and usage Strava reports in the
+// X-RateLimit-Limit and X-RateLimit-Usage headers of a response. The reset
+// times are left zero. It returns false unless both headers are present.
+func ParseRateLimit(h http.Header) (RateLimitUsage, bool) {
+	limitShort, limitDaily, ok := parseRatePair(h.Get("X-RateLimit-Limit"))
+	usedShort, usedDaily, usageOk := parseRatePair(h.Get("X-RateLimit-Usage"))
+	if !ok || !usageOk {
+		return RateLimitUsage{}, false
+	}
+	return RateLimitUsage{ShortUsed: usedShort, ShortLimit: limitShort, DailyUsed: usedDaily, DailyLimit: limitDaily}, true
+}
+
+// roll resets any window that has elapsed. l.mu must be held.
+func (l *Limiter) roll() {
+	now := l.now()
+	if !now.Before(l.shortReset) {
+		l.shortUsed = 0
+		l.shortReset = now.Truncate(15 * time.Minute).Add(15 * time.Minute)
+	}
+	if !now.Before(l.dailyReset) {
+		l.dailyUsed = 0
+		y, m, d := now.UTC().Date()
+		l.dailyReset = time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func parseRatePair(v string) (int, int, bool) {
+	short, daily, found := strings.Cut(v, ",")
+	if !found {
+		return 0, 0, false
+	}
+	s, err := strconv.Atoi(strings.TrimSpace(short))
+	if err != nil {
+		return 0, 0, false
+	}
+	d, err := strconv.Atoi(strings.TrimSpace(daily))
+	if err != nil {
+		return 0, 0, false
+	}
+	return s, d, true
+}
@@ -0,0 +1,99 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry defaults
+const (
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = time.Second
+	// maxRetryBackoff caps the growing wait between attempts. A longer
+	// Retry-After is still honored.
+	maxRetryBackoff = 30 * time.Second
+)
+
+// WithRetries retries idempotent requests, GET, PUT, and DELETE, that fail
+// with a 500, 502, 503, or 504, or with a 429 that says when to try again,
+// up to maxRetries times. The wait starts at backoff and doubles with every
+// attempt up to thirty seconds, unless the response says how long to wait
+// with Retry-After, as Strava does during maintenance. Retries go through
+// the rate limiter like any request, and a retry that would have to wait
+// past the context's deadline is not made. Zero maxRetries disables
+// retries.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries, c.retryBackoff = maxRetries, backoff
+	}
+}
+
+// retryWait returns how long to wait before retrying req, whose attempt
+// numbered attempt, counting from zero, failed with err, or false when it
+// should not be retried
+func (c *Client) retryWait(req *http.Request, attempt int, err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if attempt >= c.maxRetries || !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	// A 429 is only worth retrying when the response says when to
+	if !retryableStatus(apiErr.StatusCode) && (apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter <= 0) {
+		return 0, false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+	default:
+		return 0, false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return 0, false
+	}
+
+	wait := apiErr.RetryAfter
+	if wait <= 0 {
+		wait = min(c.retryBackoff<<attempt, maxRetryBackoff)
+	}
+	if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(wait).After(deadline) {
+		return 0, false
+	}
+	return wait, true
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date, returning zero when it is missing or malformed
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package strava
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Route types
+const (
+	RouteTypeRide = 1
+	RouteTypeRun  = 2
+)
+
+// Route is a route created by an athlete
+type Route struct {
+	Id                  int64          `json:"id"`
+	Name                string         `json:"name"`
+	Description         string         `json:"description"`
+	Athlete             SummaryAthlete `json:"athlete"`
+	Distance            float64        `json:"distance"`
+	ElevationGain       float64        `json:"elevation_gain"`
+	Type                int            `json:"type"`
+	SubType             int            `json:"sub_type"`
+	Private             bool           `json:"private"`
+	Starred             bool           `json:"starred"`
+	Timestamp           int64          `json:"timestamp"`
+	CreatedAt           string         `json:"created_at"`
+	UpdatedAt           string         `json:"updated_at"`
+	EstimatedMovingTime int            `json:"estimated_moving_time"`
+	Map                 *PolylineMap   `json:"map,omitempty"`
+	Segments            []Segment      `json:"segments,omitempty"`
+}
+
+// ListAthleteRoutes returns a page of the routes created by an athlete
+func (c *Client) ListAthleteRoutes(ctx context.Context, athleteID int64, opts PageOptions) ([]Route, error) {
+	routes := make([]Route, 0)
+	if err := c.get(ctx, opList, "/athletes/"+strconv.FormatInt(athleteID, 10)+"/routes", opts.values(), &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// GetRoute returns the route with the given id
+func (c *Client) GetRoute(ctx context.Context, id int64) (Route, error) {
+	var route Route
+	err := c.get(ctx, opDetail, "/routes/"+strconv.FormatInt(id, 10), nil, &route)
+	return route, err
+}
+
+// ExportRoute returns a route as a GPX or TCX file. format is "gpx" or
+// "tcx".
+func (c *Client) ExportRoute(ctx context.Context, id int64, format string) ([]byte, error) {
+	if format != "gpx" && format != "tcx" {
+		return nil, fmt.Errorf("strava: unknown route export format %q, expected gpx or tcx", format)
+	}
+
+	var file []byte
+	if err := c.get(ctx, opStreams, "/routes/"+strconv.FormatInt(id, 10)+"/export_"+format, nil, &file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
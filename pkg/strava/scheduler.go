@@ -0,0 +1,130 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Priority ranks requests sharing one application's rate limits, such as a
+// long-running server answering users while it backfills history
+type Priority int
+
+const (
+	// PriorityInteractive is for requests someone is waiting on, the default
+	PriorityInteractive Priority = iota
+	// PrioritySync is for scheduled syncs
+	PrioritySync
+	// PriorityBulk is for backfills that can wait, such as fetching the
+	// streams of old activities. Bulk requests are deferred when the
+	// quota runs low.
+	PriorityBulk
+)
+
+// ErrDeferred is returned by Scheduler.Wait for bulk requests when what is
+// left of the daily limit is kept for other requests, or the context ends
+// before the fifteen-minute window resets. Callers should stop and pick the
+// work up on a later run.
+var ErrDeferred = errors.New("strava: bulk request deferred to keep quota for interactive requests")
+
+// DefaultBulkReserve is the share of each window kept from bulk requests
+const DefaultBulkReserve = 0.2
+
+type priorityKey struct{}
+
+// WithPriority marks the requests made with ctx as having priority p
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set by WithPriority, or
+// PriorityInteractive
+func PriorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// Scheduler is a RateLimiter that orders requests by the priority of their
+// context. Requests wait while any of higher priority are waiting, and bulk
+// requests leave the last reserve share of each window to the others: they
+// wait for the fifteen-minute window to reset, and fail with ErrDeferred
+// when the daily one is that low. Usage reported by Strava includes other
+// processes using the application, so bulk work also backs off for them.
+type Scheduler struct {
+	limiter *Limiter
+	reserve float64
+
+	mu      sync.Mutex
+	waiting [PriorityBulk + 1]int
+	// changed is closed and replaced whenever a request stops waiting
+	changed chan struct{}
+}
+
+// NewScheduler schedules requests against limiter, keeping reserve, a
+// fraction between 0 and 1, of each window from bulk requests
+func NewScheduler(limiter *Limiter, reserve float64) *Scheduler {
+	return &Scheduler{limiter: limiter, reserve: reserve, changed: make(chan struct{})}
+}
+
+func (s *Scheduler) Wait(ctx context.Context) error {
+	p := min(max(PriorityFromContext(ctx), PriorityInteractive), PriorityBulk)
+	s.mu.Lock()
+	s.waiting[p]++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.waiting[p]--
+		close(s.changed)
+		s.changed = make(chan struct{})
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		ahead := false
+		for q := PriorityInteractive; q < p; q++ {
+			ahead = ahead || s.waiting[q] > 0
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		if !ahead {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+
+	for p == PriorityBulk {
+		u := s.limiter.Usage()
+		until := u.ShortReset
+		switch {
+		case float64(u.DailyRemaining()) <= s.reserve*float64(u.DailyLimit):
+			return fmt.Errorf("%w until %s", ErrDeferred, u.DailyReset.Local().Format(time.RFC3339))
+		case float64(u.ShortRemaining()) > s.reserve*float64(u.ShortLimit):
+			return s.limiter.Wait(ctx)
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+			return fmt.Errorf("%w until %s", ErrDeferred, until.Local().Format(time.RFC3339))
+		}
+		timer := time.NewTimer(time.Until(until))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return s.limiter.Wait(ctx)
+}
+
+// Update passes the rate limit headers of a response to the limiter
+func (s *Scheduler) Update(h http.Header) { s.limiter.Update(h) }
+
+// Usage returns the limiter's usage
+func (s *Scheduler) Usage() RateLimitUsage { return s.limiter.Usage() }
@@ -0,0 +1,115 @@
+package strava
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The OAuth scopes an athlete can grant an application
+const (
+	ScopeRead            = "read"
+	ScopeReadAll         = "read_all"
+	ScopeProfileReadAll  = "profile:read_all"
+	ScopeProfileWrite    = "profile:write"
+	ScopeActivityRead    = "activity:read"
+	ScopeActivityReadAll = "activity:read_all"
+	ScopeActivityWrite   = "activity:write"
+)
+
+// widerScopes are the scopes that include another
+var widerScopes = map[string]string{
+	ScopeRead:         ScopeReadAll,
+	ScopeActivityRead: ScopeActivityReadAll,
+}
+
+// ScopeError is returned for calls the token was not granted the scope
+// for. When the granted scopes are known, from the token or WithScopes, it
+// is returned before the request is sent; otherwise it wraps the *APIError
+// of the 401 saying which scope is missing, and later calls needing that
+// scope fail without a request.
+type ScopeError struct {
+	// Scope is the scope the call needs
+	Scope string
+	// Granted are the token's scopes, empty when unknown
+	Granted []string
+	err     error
+}
+
+func (e *ScopeError) Error() string {
+	if len(e.Granted) == 0 {
+		return fmt.Sprintf("strava: needs %s, which the token was not granted", e.Scope)
+	}
+	return fmt.Sprintf("strava: needs %s, token only has %s", e.Scope, strings.Join(e.Granted, ", "))
+}
+
+func (e *ScopeError) Unwrap() error { return e.err }
+
+// WithScopes sets the scopes the token was granted, for tokens that do not
+// carry them. Strava lists them in the scope parameter of the redirect
+// after authorization, e.g. read,activity:read_all.
+func WithScopes(scopes ...string) Option {
+	return func(c *Client) {
+		c.scopes = scopes
+	}
+}
+
+// ParseScopes splits a comma-separated scope list, as in the authorization
+// redirect or Token.Scope
+func ParseScopes(list string) []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(list, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// HasScope reports whether scopes grant scope, directly or through a wider
+// scope such as activity:read_all for activity:read
+func HasScope(scopes []string, scope string) bool {
+	for _, granted := range scopes {
+		if granted == scope || granted == widerScopes[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes returns the scopes the token was granted, and false when they are
+// not known
+func (c *Client) Scopes() ([]string, bool) {
+	if scope := c.Token().Scope; scope != "" {
+		return ParseScopes(scope), true
+	}
+	return c.scopes, len(c.scopes) > 0
+}
+
+// requireScope fails when the token is known to lack scope, from its
+// granted scopes or an earlier response
+func (c *Client) requireScope(scope string) error {
+	granted, known := c.Scopes()
+	if _, missing := c.missingScopes.Load(scope); missing || (known && !HasScope(granted, scope)) {
+		return &ScopeError{Scope: scope, Granted: granted}
+	}
+	return nil
+}
+
+// scopeError turns a 401 naming a missing scope, such as
+// {"resource": "AccessToken", "field": "activity:write_permission", "code": "missing"},
+// into a ScopeError and remembers the scope as missing
+func (c *Client) scopeError(apiErr *APIError) error {
+	if apiErr.StatusCode != http.StatusUnauthorized {
+		return apiErr
+	}
+	for _, fe := range apiErr.Errors {
+		scope, ok := strings.CutSuffix(fe.Field, "_permission")
+		if ok && fe.Code == "missing" {
+			c.missingScopes.Store(scope, true)
+			granted, _ := c.Scopes()
+			return &ScopeError{Scope: scope, Granted: granted, err: apiErr}
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,153 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// LatLng is a [latitude, longitude] pair. Strava sends an empty array when
+// the location is unknown.
+type LatLng []float64
+
+// PolylineMap is the encoded route of an activity or segment
+type PolylineMap struct {
+	Id              string `json:"id"`
+	Polyline        string `json:"polyline"`
+	SummaryPolyline string `json:"summary_polyline"`
+}
+
+// PRSegmentEffort is the authenticated athlete's best effort on a segment
+type PRSegmentEffort struct {
+	PRActivityId  int64  `json:"pr_activity_id"`
+	PRElapsedTime int    `json:"pr_elapsed_time"`
+	PRDate        string `json:"pr_date"`
+	EffortCount   int    `json:"effort_count"`
+}
+
+// Segment is a summary segment, as listed by the starred segments endpoint
+type Segment struct {
+	Id              int64            `json:"id"`
+	Name            string           `json:"name"`
+	ActivityType    string           `json:"activity_type"`
+	Distance        float64          `json:"distance"`
+	AverageGrade    float64          `json:"average_grade"`
+	MaximumGrade    float64          `json:"maximum_grade"`
+	ElevationHigh   float64          `json:"elevation_high"`
+	ElevationLow    float64          `json:"elevation_low"`
+	StartLatLng     LatLng           `json:"start_latlng"`
+	EndLatLng       LatLng           `json:"end_latlng"`
+	ClimbCategory   int              `json:"climb_category"`
+	City            string           `json:"city"`
+	State           string           `json:"state"`
+	Country         string           `json:"country"`
+	Private         bool             `json:"private"`
+	Starred         bool             `json:"starred"`
+	AthletePREffort *PRSegmentEffort `json:"athlete_pr_effort,omitempty"`
+}
+
+// DetailedSegment is a segment as returned by GetSegment
+type DetailedSegment struct {
+	Segment
+	CreatedAt          string       `json:"created_at"`
+	UpdatedAt          string       `json:"updated_at"`
+	TotalElevationGain float64      `json:"total_elevation_gain"`
+	Map                *PolylineMap `json:"map,omitempty"`
+	EffortCount        int          `json:"effort_count"`
+	AthleteCount       int          `json:"athlete_count"`
+	Hazardous          bool         `json:"hazardous"`
+	StarCount          int          `json:"star_count"`
+}
+
+// ExplorerSegment is a segment found by ExploreSegments
+type ExplorerSegment struct {
+	Id                int64   `json:"id"`
+	Name              string  `json:"name"`
+	ClimbCategory     int     `json:"climb_category"`
+	ClimbCategoryDesc string  `json:"climb_category_desc"`
+	AverageGrade      float64 `json:"avg_grade"`
+	StartLatLng       LatLng  `json:"start_latlng"`
+	EndLatLng         LatLng  `json:"end_latlng"`
+	ElevDifference    float64 `json:"elev_difference"`
+	Distance          float64 `json:"distance"`
+	Points            string  `json:"points"`
+}
+
+// PageOptions selects a page of a paginated list. Zero values use the
+// API defaults.
+type PageOptions struct {
+	Page    int
+	PerPage int
+}
+
+func (o PageOptions) values() url.Values {
+	q := url.Values{}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	return q
+}
+
+// Bounds is the rectangle searched by ExploreSegments
+type Bounds struct {
+	SouthWest LatLng
+	NorthEast LatLng
+}
+
+// ExploreOptions narrows an ExploreSegments search
+type ExploreOptions struct {
+	// ActivityType is "running" or "riding"; empty searches both
+	ActivityType string
+	// MinCategory and MaxCategory bound the climb category, 0 to 5
+	MinCategory int
+	MaxCategory int
+}
+
+// GetSegment returns the segment with the given id
+func (c *Client) GetSegment(ctx context.Context, id int64) (DetailedSegment, error) {
+	var segment DetailedSegment
+	err := c.get(ctx, opDetail, "/segments/"+strconv.FormatInt(id, 10), nil, &segment)
+	return segment, err
+}
+
+// ListStarredSegments returns a page of the segments starred by the
+// authenticated athlete
+func (c *Client) ListStarredSegments(ctx context.Context, opts PageOptions) ([]Segment, error) {
+	segments := make([]Segment, 0)
+	if err := c.get(ctx, opList, "/segments/starred", opts.values(), &segments); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// ExploreSegments returns up to ten popular segments within bounds
+func (c *Client) ExploreSegments(ctx context.Context, bounds Bounds, opts ExploreOptions) ([]ExplorerSegment, error) {
+	if len(bounds.SouthWest) != 2 || len(bounds.NorthEast) != 2 {
+		return nil, errors.New("strava: explore bounds need south west and north east corners")
+	}
+
+	q := url.Values{}
+	q.Set("bounds", fmt.Sprintf("%g,%g,%g,%g", bounds.SouthWest[0], bounds.SouthWest[1], bounds.NorthEast[0], bounds.NorthEast[1]))
+	if opts.ActivityType != "" {
+		q.Set("activity_type", opts.ActivityType)
+	}
+	if opts.MinCategory > 0 {
+		q.Set("min_cat", strconv.Itoa(opts.MinCategory))
+	}
+	if opts.MaxCategory > 0 {
+		q.Set("max_cat", strconv.Itoa(opts.MaxCategory))
+	}
+
+	var res struct {
+		Segments []ExplorerSegment `json:"segments"`
+	}
+	if err := c.get(ctx, opList, "/segments/explore", q, &res); err != nil {
+		return nil, err
+	}
+	return res.Segments, nil
+}
@@ -0,0 +1,49 @@
+package strava
+
+import (
+	"context"
+	"strconv"
+)
+
+// SummaryAthlete is the public profile of an athlete, as seen by others
+type SummaryAthlete struct {
+	Id        int64  `json:"id"`
+	Username  string `json:"username"`
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+	City      string `json:"city"`
+	State     string `json:"state"`
+	Country   string `json:"country"`
+	Sex       string `json:"sex"`
+	Premium   bool   `json:"premium"`
+	Summit    bool   `json:"summit"`
+	Profile   string `json:"profile"`
+}
+
+// Comment is a comment left on an activity
+type Comment struct {
+	Id         int64          `json:"id"`
+	ActivityId int64          `json:"activity_id"`
+	Text       string         `json:"text"`
+	Athlete    SummaryAthlete `json:"athlete"`
+	CreatedAt  string         `json:"created_at"`
+}
+
+// ListActivityKudoers returns a page of the athletes who gave an activity
+// kudos
+func (c *Client) ListActivityKudoers(ctx context.Context, id int64, opts PageOptions) ([]SummaryAthlete, error) {
+	athletes := make([]SummaryAthlete, 0)
+	if err := c.get(ctx, opList, "/activities/"+strconv.FormatInt(id, 10)+"/kudos", opts.values(), &athletes); err != nil {
+		return nil, err
+	}
+	return athletes, nil
+}
+
+// ListActivityComments returns a page of the comments on an activity
+func (c *Client) ListActivityComments(ctx context.Context, id int64, opts PageOptions) ([]Comment, error) {
+	comments := make([]Comment, 0)
+	if err := c.get(ctx, opList, "/activities/"+strconv.FormatInt(id, 10)+"/comments", opts.values(), &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
@@ -0,0 +1,390 @@
+// Package stravamock provides a configurable fake of strava.ClientInterface
+// for unit testing code that uses the Strava client.
+//
+//	client := &stravamock.Client{
+//		ListAllFunc: func(ctx context.Context, opts strava.ListActivitiesOptions) ([]strava.Activity, error) {
+//			return fixtures, nil
+//		},
+//	}
+//
+// Methods without a Func return zero values. Activities and
+// ListActivitiesPages fall back to ListAllFunc's result, returned as a single
+// page, so setting ListAllFunc covers all three.
+package stravamock
+
+import (
+	"context"
+	"sync"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// Client is a fake strava.ClientInterface. Calls records the name of every
+// method invoked, in order.
+type Client struct {
+	TokenFunc           func() strava.Token
+	AuthenticateFunc    func(ctx context.Context) error
+	RefreshFunc         func(ctx context.Context, refreshToken string) (strava.Token, error)
+	ProbeFunc           func(ctx context.Context) error
+	GetAthleteFunc      func(ctx context.Context) (strava.SummaryAthlete, error)
+	GetAthleteStatsFunc func(ctx context.Context, athleteID int64) (strava.ActivityStats, error)
+	RateLimitFunc       func() (strava.RateLimitUsage, bool)
+	ListActivitiesFunc  func(ctx context.Context, opts strava.ListActivitiesOptions) ([]strava.Activity, error)
+	ActivitiesFunc      func(ctx context.Context, opts strava.ListActivitiesOptions) *strava.ActivityIterator
+	ListAllFunc         func(ctx context.Context, opts strava.ListActivitiesOptions) ([]strava.Activity, error)
+
+	ListActivitiesPagesFunc func(ctx context.Context, opts strava.ListActivitiesOptions, fn func(page []strava.Activity) error) error
+
+	GetDetailedAthleteFunc            func(ctx context.Context) (strava.DetailedAthlete, error)
+	UpdateAthleteFunc                 func(ctx context.Context, update strava.UpdatableAthlete) (strava.DetailedAthlete, error)
+	GetActivityFunc                   func(ctx context.Context, id int64, includeAllEfforts bool) (strava.DetailedActivity, error)
+	ListActivityLapsFunc              func(ctx context.Context, id int64) ([]strava.Lap, error)
+	GetActivityZonesFunc              func(ctx context.Context, id int64) ([]strava.ActivityZone, error)
+	GetAthleteZonesFunc               func(ctx context.Context) (strava.Zones, error)
+	ListActivityKudoersFunc           func(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.SummaryAthlete, error)
+	ListActivityCommentsFunc          func(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.Comment, error)
+	ListAthleteClubsFunc              func(ctx context.Context, opts strava.PageOptions) ([]strava.Club, error)
+	GetClubFunc                       func(ctx context.Context, id int64) (strava.DetailedClub, error)
+	ListClubMembersFunc               func(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.ClubAthlete, error)
+	ListClubActivitiesFunc            func(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.ClubActivity, error)
+	ListAthleteRoutesFunc             func(ctx context.Context, athleteID int64, opts strava.PageOptions) ([]strava.Route, error)
+	GetRouteFunc                      func(ctx context.Context, id int64) (strava.Route, error)
+	ExportRouteFunc                   func(ctx context.Context, id int64, format string) ([]byte, error)
+	ListActivityPhotosFunc            func(ctx context.Context, id int64, size int) ([]strava.Photo, error)
+	GetActivityStreamsFunc            func(ctx context.Context, id int64, keys ...string) (strava.Streams, error)
+	GetActivityStreamsWithOptionsFunc func(ctx context.Context, id int64, opts strava.StreamOptions, keys ...string) (strava.Streams, error)
+	GetGearFunc                       func(ctx context.Context, id string) (strava.Gear, error)
+	CreateActivityFunc                func(ctx context.Context, activity strava.CreatableActivity) (strava.DetailedActivity, error)
+	UpdateActivityFunc                func(ctx context.Context, id int64, update strava.UpdatableActivity) (strava.DetailedActivity, error)
+	DeleteActivityFunc                func(ctx context.Context, id int64) error
+	GetSegmentFunc                    func(ctx context.Context, id int64) (strava.DetailedSegment, error)
+	ListStarredSegmentsFunc           func(ctx context.Context, opts strava.PageOptions) ([]strava.Segment, error)
+	ExploreSegmentsFunc               func(ctx context.Context, bounds strava.Bounds, opts strava.ExploreOptions) ([]strava.ExplorerSegment, error)
+	ListSegmentEffortsFunc            func(ctx context.Context, segmentID int64, opts strava.SegmentEffortsOptions) ([]strava.SegmentEffort, error)
+
+	mu    sync.Mutex
+	calls []string
+}
+
+var _ strava.ClientInterface = (*Client)(nil)
+
+// Calls returns the methods invoked so far
+func (c *Client) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+func (c *Client) record(method string) {
+	c.mu.Lock()
+	c.calls = append(c.calls, method)
+	c.mu.Unlock()
+}
+
+func (c *Client) Token() strava.Token {
+	c.record("Token")
+	if c.TokenFunc != nil {
+		return c.TokenFunc()
+	}
+	return strava.Token{}
+}
+
+func (c *Client) Authenticate(ctx context.Context) error {
+	c.record("Authenticate")
+	if c.AuthenticateFunc != nil {
+		return c.AuthenticateFunc(ctx)
+	}
+	return nil
+}
+
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (strava.Token, error) {
+	c.record("Refresh")
+	if c.RefreshFunc != nil {
+		return c.RefreshFunc(ctx, refreshToken)
+	}
+	return strava.Token{}, nil
+}
+
+func (c *Client) Probe(ctx context.Context) error {
+	c.record("Probe")
+	if c.ProbeFunc != nil {
+		return c.ProbeFunc(ctx)
+	}
+	return nil
+}
+
+func (c *Client) GetAthlete(ctx context.Context) (strava.SummaryAthlete, error) {
+	c.record("GetAthlete")
+	if c.GetAthleteFunc != nil {
+		return c.GetAthleteFunc(ctx)
+	}
+	return strava.SummaryAthlete{}, nil
+}
+
+func (c *Client) GetDetailedAthlete(ctx context.Context) (strava.DetailedAthlete, error) {
+	c.record("GetDetailedAthlete")
+	if c.GetDetailedAthleteFunc != nil {
+		return c.GetDetailedAthleteFunc(ctx)
+	}
+	return strava.DetailedAthlete{}, nil
+}
+
+func (c *Client) UpdateAthlete(ctx context.Context, update strava.UpdatableAthlete) (strava.DetailedAthlete, error) {
+	c.record("UpdateAthlete")
+	if c.UpdateAthleteFunc != nil {
+		return c.UpdateAthleteFunc(ctx, update)
+	}
+	return strava.DetailedAthlete{}, nil
+}
+
+func (c *Client) GetAthleteStats(ctx context.Context, athleteID int64) (strava.ActivityStats, error) {
+	c.record("GetAthleteStats")
+	if c.GetAthleteStatsFunc != nil {
+		return c.GetAthleteStatsFunc(ctx, athleteID)
+	}
+	return strava.ActivityStats{}, nil
+}
+
+func (c *Client) RateLimit() (strava.RateLimitUsage, bool) {
+	c.record("RateLimit")
+	if c.RateLimitFunc != nil {
+		return c.RateLimitFunc()
+	}
+	return strava.RateLimitUsage{}, false
+}
+
+func (c *Client) ListActivities(ctx context.Context, opts strava.ListActivitiesOptions) ([]strava.Activity, error) {
+	c.record("ListActivities")
+	if c.ListActivitiesFunc != nil {
+		return c.ListActivitiesFunc(ctx, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) Activities(ctx context.Context, opts strava.ListActivitiesOptions) *strava.ActivityIterator {
+	c.record("Activities")
+	if c.ActivitiesFunc != nil {
+		return c.ActivitiesFunc(ctx, opts)
+	}
+	if c.ListAllFunc != nil {
+		return strava.NewSliceIterator(c.ListAllFunc(ctx, opts))
+	}
+	return strava.NewSliceIterator(nil, nil)
+}
+
+func (c *Client) ListAll(ctx context.Context, opts strava.ListActivitiesOptions) ([]strava.Activity, error) {
+	c.record("ListAll")
+	if c.ListAllFunc != nil {
+		return c.ListAllFunc(ctx, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) ListActivitiesPages(ctx context.Context, opts strava.ListActivitiesOptions, fn func(page []strava.Activity) error) error {
+	c.record("ListActivitiesPages")
+	if c.ListActivitiesPagesFunc != nil {
+		return c.ListActivitiesPagesFunc(ctx, opts, fn)
+	}
+	if c.ListAllFunc != nil {
+		activities, err := c.ListAllFunc(ctx, opts)
+		if len(activities) > 0 {
+			if err := fn(activities); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Client) GetSegment(ctx context.Context, id int64) (strava.DetailedSegment, error) {
+	c.record("GetSegment")
+	if c.GetSegmentFunc != nil {
+		return c.GetSegmentFunc(ctx, id)
+	}
+	return strava.DetailedSegment{}, nil
+}
+
+func (c *Client) ListStarredSegments(ctx context.Context, opts strava.PageOptions) ([]strava.Segment, error) {
+	c.record("ListStarredSegments")
+	if c.ListStarredSegmentsFunc != nil {
+		return c.ListStarredSegmentsFunc(ctx, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) ExploreSegments(ctx context.Context, bounds strava.Bounds, opts strava.ExploreOptions) ([]strava.ExplorerSegment, error) {
+	c.record("ExploreSegments")
+	if c.ExploreSegmentsFunc != nil {
+		return c.ExploreSegmentsFunc(ctx, bounds, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) GetActivity(ctx context.Context, id int64, includeAllEfforts bool) (strava.DetailedActivity, error) {
+	c.record("GetActivity")
+	if c.GetActivityFunc != nil {
+		return c.GetActivityFunc(ctx, id, includeAllEfforts)
+	}
+	return strava.DetailedActivity{}, nil
+}
+
+func (c *Client) ListSegmentEfforts(ctx context.Context, segmentID int64, opts strava.SegmentEffortsOptions) ([]strava.SegmentEffort, error) {
+	c.record("ListSegmentEfforts")
+	if c.ListSegmentEffortsFunc != nil {
+		return c.ListSegmentEffortsFunc(ctx, segmentID, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) ListActivityLaps(ctx context.Context, id int64) ([]strava.Lap, error) {
+	c.record("ListActivityLaps")
+	if c.ListActivityLapsFunc != nil {
+		return c.ListActivityLapsFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (c *Client) GetActivityZones(ctx context.Context, id int64) ([]strava.ActivityZone, error) {
+	c.record("GetActivityZones")
+	if c.GetActivityZonesFunc != nil {
+		return c.GetActivityZonesFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (c *Client) GetAthleteZones(ctx context.Context) (strava.Zones, error) {
+	c.record("GetAthleteZones")
+	if c.GetAthleteZonesFunc != nil {
+		return c.GetAthleteZonesFunc(ctx)
+	}
+	return strava.Zones{}, nil
+}
+
+func (c *Client) ListActivityKudoers(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.SummaryAthlete, error) {
+	c.record("ListActivityKudoers")
+	if c.ListActivityKudoersFunc != nil {
+		return c.ListActivityKudoersFunc(ctx, id, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) ListActivityComments(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.Comment, error) {
+	c.record("ListActivityComments")
+	if c.ListActivityCommentsFunc != nil {
+		return c.ListActivityCommentsFunc(ctx, id, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) ListAthleteClubs(ctx context.Context, opts strava.PageOptions) ([]strava.Club, error) {
+	c.record("ListAthleteClubs")
+	if c.ListAthleteClubsFunc != nil {
+		return c.ListAthleteClubsFunc(ctx, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) GetClub(ctx context.Context, id int64) (strava.DetailedClub, error) {
+	c.record("GetClub")
+	if c.GetClubFunc != nil {
+		return c.GetClubFunc(ctx, id)
+	}
+	return strava.DetailedClub{}, nil
+}
+
+func (c *Client) ListClubMembers(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.ClubAthlete, error) {
+	c.record("ListClubMembers")
+	if c.ListClubMembersFunc != nil {
+		return c.ListClubMembersFunc(ctx, id, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) ListClubActivities(ctx context.Context, id int64, opts strava.PageOptions) ([]strava.ClubActivity, error) {
+	c.record("ListClubActivities")
+	if c.ListClubActivitiesFunc != nil {
+		return c.ListClubActivitiesFunc(ctx, id, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) ListAthleteRoutes(ctx context.Context, athleteID int64, opts strava.PageOptions) ([]strava.Route, error) {
+	c.record("ListAthleteRoutes")
+	if c.ListAthleteRoutesFunc != nil {
+		return c.ListAthleteRoutesFunc(ctx, athleteID, opts)
+	}
+	return nil, nil
+}
+
+func (c *Client) GetRoute(ctx context.Context, id int64) (strava.Route, error) {
+	c.record("GetRoute")
+	if c.GetRouteFunc != nil {
+		return c.GetRouteFunc(ctx, id)
+	}
+	return strava.Route{}, nil
+}
+
+func (c *Client) ExportRoute(ctx context.Context, id int64, format string) ([]byte, error) {
+	c.record("ExportRoute")
+	if c.ExportRouteFunc != nil {
+		return c.ExportRouteFunc(ctx, id, format)
+	}
+	return nil, nil
+}
+
+func (c *Client) ListActivityPhotos(ctx context.Context, id int64, size int) ([]strava.Photo, error) {
+	c.record("ListActivityPhotos")
+	if c.ListActivityPhotosFunc != nil {
+		return c.ListActivityPhotosFunc(ctx, id, size)
+	}
+	return nil, nil
+}
+
+func (c *Client) GetActivityStreams(ctx context.Context, id int64, keys ...string) (strava.Streams, error) {
+	c.record("GetActivityStreams")
+	if c.GetActivityStreamsFunc != nil {
+		return c.GetActivityStreamsFunc(ctx, id, keys...)
+	}
+	return strava.Streams{}, nil
+}
+
+func (c *Client) GetActivityStreamsWithOptions(ctx context.Context, id int64, opts strava.StreamOptions, keys ...string) (strava.Streams, error) {
+	c.record("GetActivityStreamsWithOptions")
+	if c.GetActivityStreamsWithOptionsFunc != nil {
+		return c.GetActivityStreamsWithOptionsFunc(ctx, id, opts, keys...)
+	}
+	return strava.Streams{}, nil
+}
+
+func (c *Client) GetGear(ctx context.Context, id string) (strava.Gear, error) {
+	c.record("GetGear")
+	if c.GetGearFunc != nil {
+		return c.GetGearFunc(ctx, id)
+	}
+	return strava.Gear{}, nil
+}
+
+func (c *Client) CreateActivity(ctx context.Context, activity strava.CreatableActivity) (strava.DetailedActivity, error) {
+	c.record("CreateActivity")
+	if c.CreateActivityFunc != nil {
+		return c.CreateActivityFunc(ctx, activity)
+	}
+	return strava.DetailedActivity{}, nil
+}
+
+func (c *Client) UpdateActivity(ctx context.Context, id int64, update strava.UpdatableActivity) (strava.DetailedActivity, error) {
+	c.record("UpdateActivity")
+	if c.UpdateActivityFunc != nil {
+		return c.UpdateActivityFunc(ctx, id, update)
+	}
+	return strava.DetailedActivity{}, nil
+}
+
+func (c *Client) DeleteActivity(ctx context.Context, id int64) error {
+	c.record("DeleteActivity")
+	if c.DeleteActivityFunc != nil {
+		return c.DeleteActivityFunc(ctx, id)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package strava
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Stream types accepted by GetActivityStreams
+const (
+	StreamTime           = "time"
+	StreamDistance       = "distance"
+	StreamLatLng         = "latlng"
+	StreamAltitude       = "altitude"
+	StreamVelocitySmooth = "velocity_smooth"
+	StreamHeartrate      = "heartrate"
+	StreamCadence        = "cadence"
+	StreamWatts          = "watts"
+	StreamTemp           = "temp"
+	StreamMoving         = "moving"
+	StreamGradeSmooth    = "grade_smooth"
+)
+
+// AllStreams lists every stream type
+var AllStreams = []string{
+	StreamTime, StreamDistance, StreamLatLng, StreamAltitude, StreamVelocitySmooth,
+	StreamHeartrate, StreamCadence, StreamWatts, StreamTemp, StreamMoving, StreamGradeSmooth,
+}
+
+// Stream resolutions accepted in StreamOptions. Strava samples the streams
+// down to about 100, 1000, or 10000 points.
+const (
+	ResolutionLow    = "low"
+	ResolutionMedium = "medium"
+	ResolutionHigh   = "high"
+)
+
+// Series types accepted in StreamOptions, the stream a reduced resolution
+// is sampled along
+const (
+	SeriesTypeTime     = "time"
+	SeriesTypeDistance = "distance"
+)
+
+// StreamOptions ask Strava for fewer samples. The zero value returns every
+// sample.
+type StreamOptions struct {
+	// Resolution is ResolutionLow, ResolutionMedium, or ResolutionHigh
+	Resolution string
+	// SeriesType is SeriesTypeTime or SeriesTypeDistance, distance by default
+	SeriesType string
+}
+
+// StreamInfo describes how a stream was sampled
+type StreamInfo struct {
+	OriginalSize int    `json:"original_size"`
+	Resolution   string `json:"resolution"`
+	SeriesType   string `json:"series_type"`
+}
+
+type IntStream struct {
+	StreamInfo
+	Data []int `json:"data"`
+}
+
+type FloatStream struct {
+	StreamInfo
+	Data []float64 `json:"data"`
+}
+
+type LatLngStream struct {
+	StreamInfo
+	Data []LatLng `json:"data"`
+}
+
+type BoolStream struct {
+	StreamInfo
+	Data []bool `json:"data"`
+}
+
+// Streams holds the sampled data of an activity, one sample per index
+// across all streams. Streams the activity did not record are nil.
+type Streams struct {
+	Time           *IntStream    `json:"time,omitempty"`
+	Distance       *FloatStream  `json:"distance,omitempty"`
+	LatLng         *LatLngStream `json:"latlng,omitempty"`
+	Altitude       *FloatStream  `json:"altitude,omitempty"`
+	VelocitySmooth *FloatStream  `json:"velocity_smooth,omitempty"`
+	Heartrate      *IntStream    `json:"heartrate,omitempty"`
+	Cadence        *IntStream    `json:"cadence,omitempty"`
+	Watts          *IntStream    `json:"watts,omitempty"`
+	Temp           *IntStream    `json:"temp,omitempty"`
+	Moving         *BoolStream   `json:"moving,omitempty"`
+	GradeSmooth    *FloatStream  `json:"grade_smooth,omitempty"`
+}
+
+// GetActivityStreams returns the requested streams of an activity, or
+// every stream when no keys are given
+func (c *Client) GetActivityStreams(ctx context.Context, id int64, keys ...string) (Streams, error) {
+	return c.GetActivityStreamsWithOptions(ctx, id, StreamOptions{}, keys...)
+}
+
+// GetActivityStreamsWithOptions is GetActivityStreams at the resolution
+// set in opts
+func (c *Client) GetActivityStreamsWithOptions(ctx context.Context, id int64, opts StreamOptions, keys ...string) (Streams, error) {
+	if len(keys) == 0 {
+		keys = AllStreams
+	}
+
+	q := url.Values{}
+	q.Set("keys", strings.Join(keys, ","))
+	q.Set("key_by_type", "true")
+	if opts.Resolution != "" {
+		q.Set("resolution", opts.Resolution)
+	}
+	if opts.SeriesType != "" {
+		q.Set("series_type", opts.SeriesType)
+	}
+
+	var streams Streams
+	err := c.get(ctx, opStreams, "/activities/"+strconv.FormatInt(id, 10)+"/streams", q, &streams)
+	return streams, err
+}
+
+// Downsample keeps one sample per interval seconds of the time stream: the
+// first, each one at least interval after the last kept, and the last, so
+// the activity keeps its start, end, and totals. Without a time stream,
+// or with an interval of a second or less, s is returned as it is.
+func (s Streams) Downsample(interval int) Streams {
+	if interval <= 1 || s.Time == nil || len(s.Time.Data) < 3 {
+		return s
+	}
+	times := s.Time.Data
+	keep := []int{0}
+	last := times[0]
+	for i := 1; i < len(times); i++ {
+		if times[i]-last >= interval || i == len(times)-1 {
+			keep = append(keep, i)
+			last = times[i]
+		}
+	}
+
+	return Streams{
+		Time:           sampleInts(s.Time, keep),
+		Distance:       sampleFloats(s.Distance, keep),
+		LatLng:         sampleLatLngs(s.LatLng, keep),
+		Altitude:       sampleFloats(s.Altitude, keep),
+		VelocitySmooth: sampleFloats(s.VelocitySmooth, keep),
+		Heartrate:      sampleInts(s.Heartrate, keep),
+		Cadence:        sampleInts(s.Cadence, keep),
+		Watts:          sampleInts(s.Watts, keep),
+		Temp:           sampleInts(s.Temp, keep),
+		Moving:         sampleBools(s.Moving, keep),
+		GradeSmooth:    sampleFloats(s.GradeSmooth, keep),
+	}
+}
+
+// sample returns the elements of data at the indexes in keep, skipping
+// indexes past its end
+func sample[T any](data []T, keep []int) []T {
+	out := make([]T, 0, len(keep))
+	for _, i := range keep {
+		if i < len(data) {
+			out = append(out, data[i])
+		}
+	}
+	return out
+}
+
+func sampleInts(s *IntStream, keep []int) *IntStream {
+	if s == nil {
+		return nil
+	}
+	return &IntStream{StreamInfo: s.StreamInfo, Data: sample(s.Data, keep)}
+}
+
+func sampleFloats(s *FloatStream, keep []int) *FloatStream {
+	if s == nil {
+		return nil
+	}
+	return &FloatStream{StreamInfo: s.StreamInfo, Data: sample(s.Data, keep)}
+}
+
+func sampleLatLngs(s *LatLngStream, keep []int) *LatLngStream {
+	if s == nil {
+		return nil
+	}
+	return &LatLngStream{StreamInfo: s.StreamInfo, Data: sample(s.Data, keep)}
+}
+
+func sampleBools(s *BoolStream, keep []int) *BoolStream {
+	if s == nil {
+		return nil
+	}
+	return &BoolStream{StreamInfo: s.StreamInfo, Data: sample(s.Data, keep)}
+}
@@ -0,0 +1,80 @@
+package strava
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bounds each class of API call. The deadline is applied to the
+// request context, so a deadline already set by the caller still wins when it
+// is sooner. A negative value, such as NoTimeout, disables the timeout for
+// that class.
+type Timeouts struct {
+	// List covers paginated list endpoints
+	List time.Duration
+	// Detail covers single resource reads, the athlete, and token refreshes
+	Detail time.Duration
+	// Streams covers activity stream downloads, which can be large
+	Streams time.Duration
+	// Upload covers file uploads and other writes
+	Upload time.Duration
+}
+
+// DefaultTimeouts are used when WithTimeouts is not provided
+var DefaultTimeouts = Timeouts{
+	List:    30 * time.Second,
+	Detail:  30 * time.Second,
+	Streams: 2 * time.Minute,
+	Upload:  5 * time.Minute,
+}
+
+// NoTimeout disables the timeout of a class when given to WithTimeouts
+const NoTimeout time.Duration = -1
+
+// WithTimeouts sets the per operation class timeouts. Zero fields keep
+// their defaults and negative ones disable the timeout.
+func WithTimeouts(t Timeouts) Option {
+	return func(c *Client) {
+		if t.List != 0 {
+			c.timeouts.List = t.List
+		}
+		if t.Detail != 0 {
+			c.timeouts.Detail = t.Detail
+		}
+		if t.Streams != 0 {
+			c.timeouts.Streams = t.Streams
+		}
+		if t.Upload != 0 {
+			c.timeouts.Upload = t.Upload
+		}
+	}
+}
+
+// operation is the class of an API call, used to pick its timeout
+type operation int
+
+const (
+	opList operation = iota
+	opDetail
+	opStreams
+	opUpload
+)
+
+// withTimeout derives a context bounded by the timeout for op
+func (c *Client) withTimeout(ctx context.Context, op operation) (context.Context, context.CancelFunc) {
+	var timeout time.Duration
+	switch op {
+	case opList:
+		timeout = c.timeouts.List
+	case opDetail:
+		timeout = c.timeouts.Detail
+	case opStreams:
+		timeout = c.timeouts.Streams
+	case opUpload:
+		timeout = c.timeouts.Upload
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,257 @@
+package strava
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoToken is returned by TokenStore.Load when nothing has been saved yet
+var ErrNoToken = errors.New("strava: no token stored")
+
+// TokenStore persists the OAuth token between runs. The client saves through
+// it every time the token is refreshed, because Strava may rotate the refresh
+// token and the old one stops working.
+type TokenStore interface {
+	Load(ctx context.Context) (Token, error)
+	Save(ctx context.Context, token Token) error
+}
+
+// WithTokenStore sets the store the client loads its token from and saves
+// refreshed tokens to
+func WithTokenStore(store TokenStore) Option {
+	return func(c *Client) {
+		c.tokenStore = store
+	}
+}
+
+// MemoryTokenStore keeps the token in memory only
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *Token
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+func (s *MemoryTokenStore) Load(ctx context.Context) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == nil {
+		return Token{}, ErrNoToken
+	}
+	return *s.token, nil
+}
+
+func (s *MemoryTokenStore) Save(ctx context.Context, token Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = &token
+	return nil
+}
+
+// FileTokenStore keeps the token as JSON in a file readable only by the owner
+type FileTokenStore struct {
+	Path string
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+func (s *FileTokenStore) Load(ctx context.Context) (Token, error) {
+	raw, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Token{}, ErrNoToken
+	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	var token Token
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return Token{}, err
+	}
+	return token, nil
+}
+
+func (s *FileTokenStore) Save(ctx context.Context, token Token) error {
+	raw, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, raw, 0600)
+}
+
+// AthleteTokenStore keeps a token per athlete, for applications acting for
+// many athletes, keyed by athlete ID (the owner_id of webhook events)
+type AthleteTokenStore interface {
+	// Athlete returns the store of one athlete's token
+	Athlete(id int64) TokenStore
+	// Athletes lists the athletes with a stored token
+	Athletes(ctx context.Context) ([]int64, error)
+	// Forget deletes an athlete's token, e.g. once they deauthorize the
+	// application
+	Forget(ctx context.Context, id int64) error
+}
+
+// DirTokenStore keeps each athlete's token as JSON in <id>.json in Dir,
+// which is created when the first token is saved
+type DirTokenStore struct {
+	Dir string
+}
+
+func NewDirTokenStore(dir string) *DirTokenStore {
+	return &DirTokenStore{Dir: dir}
+}
+
+func (s *DirTokenStore) Athlete(id int64) TokenStore {
+	return athleteFileStore{&FileTokenStore{Path: filepath.Join(s.Dir, strconv.FormatInt(id, 10)+".json")}}
+}
+
+func (s *DirTokenStore) Athletes(ctx context.Context) ([]int64, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if id, err := strconv.ParseInt(name, 10, 64); ok && err == nil && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (s *DirTokenStore) Forget(ctx context.Context, id int64) error {
+	err := os.Remove(filepath.Join(s.Dir, strconv.FormatInt(id, 10)+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// athleteFileStore creates the directory of a DirTokenStore on first save
+type athleteFileStore struct {
+	*FileTokenStore
+}
+
+func (s athleteFileStore) Save(ctx context.Context, token Token) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	return s.FileTokenStore.Save(ctx, token)
+}
+
+// Keys written by EnvFileTokenStore
+const (
+	EnvRefreshToken   = "STRAVA_REFRESH_TOKEN"
+	EnvAccessToken    = "STRAVA_ACCESS_TOKEN"
+	EnvTokenExpiresAt = "STRAVA_TOKEN_EXPIRES_AT"
+)
+
+// EnvFileTokenStore keeps the token in a KEY=VALUE env file such as
+// strava.env, rewriting only the token keys and preserving every other line
+type EnvFileTokenStore struct {
+	Path string
+}
+
+func NewEnvFileTokenStore(path string) *EnvFileTokenStore {
+	return &EnvFileTokenStore{Path: path}
+}
+
+func (s *EnvFileTokenStore) Load(ctx context.Context) (Token, error) {
+	raw, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Token{}, ErrNoToken
+	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	var token Token
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.TrimSpace(key) {
+		case EnvRefreshToken:
+			token.RefreshToken = value
+		case EnvAccessToken:
+			token.AccessToken = value
+		case EnvTokenExpiresAt:
+			token.ExpiresAt, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Token{}, err
+	}
+
+	if token.RefreshToken == "" {
+		return Token{}, ErrNoToken
+	}
+	return token, nil
+}
+
+func (s *EnvFileTokenStore) Save(ctx context.Context, token Token) error {
+	raw, err := os.ReadFile(s.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	values := map[string]string{
+		EnvRefreshToken:   token.RefreshToken,
+		EnvAccessToken:    token.AccessToken,
+		EnvTokenExpiresAt: strconv.FormatInt(token.ExpiresAt, 10),
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, _, _ := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if value, ok := values[key]; ok {
+			line = key + "=" + value
+			delete(values, key)
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, key := range []string{EnvRefreshToken, EnvAccessToken, EnvTokenExpiresAt} {
+		if value, ok := values[key]; ok {
+			out.WriteString(key + "=" + value + "\n")
+		}
+	}
+
+	return writeFileAtomic(s.Path, out.Bytes(), 0600)
+}
+
+// writeFileAtomic writes to a temporary file and renames it over path so a
+// crash mid-write never leaves a truncated token behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
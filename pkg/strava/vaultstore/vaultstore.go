@@ -0,0 +1,228 @@
+// Package vaultstore reads configuration from, and persists rotated tokens
+// to, a HashiCorp Vault KV secret. It authenticates with a Vault token or an
+// AppRole and talks to the Vault HTTP API directly.
+package vaultstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// Store keeps STRAVA_* values as the key/value pairs of one KV secret
+type Store struct {
+	// Address is the Vault server, for example https://vault.example.com:8200
+	Address string
+	// Namespace is sent as X-Vault-Namespace when set (Vault Enterprise)
+	Namespace string
+	// Mount is the KV secrets engine mount, "secret" by default
+	Mount string
+	// Path is the secret path within the mount
+	Path string
+	// KVVersion selects the KV engine API version, 1 or 2 (default)
+	KVVersion int
+
+	// Token authenticates directly. When empty, RoleID and SecretID are used
+	// to log in through the AppRole auth method.
+	Token    string
+	RoleID   string
+	SecretID string
+
+	HTTPClient *http.Client
+
+	mu sync.Mutex
+}
+
+// Values returns every key in the secret
+func (s *Store) Values(ctx context.Context) (map[string]string, error) {
+	var out struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := s.do(ctx, http.MethodGet, s.dataPath(), nil, &out); err != nil {
+		return nil, err
+	}
+
+	// A secret that does not exist yet has no data
+	if len(out.Data) == 0 || string(out.Data) == "null" {
+		return make(map[string]string), nil
+	}
+	data := out.Data
+	if s.kvVersion() == 2 {
+		var v2 struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(out.Data, &v2); err != nil {
+			return nil, err
+		}
+		data = v2.Data
+	}
+
+	raw := make(map[string]interface{})
+	if len(data) > 0 && string(data) != "null" {
+		// Numbers keep their digits, so an expiry written as a number
+		// does not come back as 1.7e+09
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[key] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+func (s *Store) Load(ctx context.Context) (strava.Token, error) {
+	values, err := s.Values(ctx)
+	if err != nil {
+		return strava.Token{}, err
+	}
+
+	token := strava.Token{
+		RefreshToken: values[strava.EnvRefreshToken],
+		AccessToken:  values[strava.EnvAccessToken],
+	}
+	token.ExpiresAt, _ = strconv.ParseInt(values[strava.EnvTokenExpiresAt], 10, 64)
+	if token.RefreshToken == "" {
+		return strava.Token{}, strava.ErrNoToken
+	}
+	return token, nil
+}
+
+// Save writes the token keys into the secret, keeping the other keys intact.
+// With KV v2 this creates a new secret version.
+func (s *Store) Save(ctx context.Context, token strava.Token) error {
+	values, err := s.Values(ctx)
+	if err != nil {
+		return err
+	}
+	values[strava.EnvRefreshToken] = token.RefreshToken
+	values[strava.EnvAccessToken] = token.AccessToken
+	values[strava.EnvTokenExpiresAt] = strconv.FormatInt(token.ExpiresAt, 10)
+
+	var payload interface{} = values
+	if s.kvVersion() == 2 {
+		payload = map[string]interface{}{"data": values}
+	}
+	return s.do(ctx, http.MethodPost, s.dataPath(), payload, nil)
+}
+
+func (s *Store) dataPath() string {
+	mount := strings.Trim(s.Mount, "/")
+	if mount == "" {
+		mount = "secret"
+	}
+	path := strings.Trim(s.Path, "/")
+	if s.kvVersion() == 2 {
+		return "/v1/" + mount + "/data/" + path
+	}
+	return "/v1/" + mount + "/" + path
+}
+
+func (s *Store) kvVersion() int {
+	if s.KVVersion == 1 {
+		return 1
+	}
+	return 2
+}
+
+// login exchanges the AppRole credentials for a client token unless a token
+// is already available
+func (s *Store) login(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Token != "" {
+		return s.Token, nil
+	}
+	if s.RoleID == "" || s.SecretID == "" {
+		return "", errors.New("vault: a token or AppRole role_id and secret_id are required")
+	}
+
+	var out struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	payload := map[string]string{"role_id": s.RoleID, "secret_id": s.SecretID}
+	if err := s.request(ctx, "", http.MethodPost, "/v1/auth/approle/login", payload, &out); err != nil {
+		return "", fmt.Errorf("vault approle login: %w", err)
+	}
+
+	s.Token = out.Auth.ClientToken
+	return s.Token, nil
+}
+
+func (s *Store) do(ctx context.Context, method string, path string, payload interface{}, out interface{}) error {
+	token, err := s.login(ctx)
+	if err != nil {
+		return err
+	}
+	return s.request(ctx, token, method, path, payload, out)
+}
+
+func (s *Store) request(ctx context.Context, token string, method string, path string, payload interface{}, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.Address, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if s.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.Namespace)
+	}
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		// A secret that does not exist yet reads as empty
+		return nil
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(raw, &vaultErr)
+		return fmt.Errorf("vault: %s %s: %s %s", method, path, res.Status, strings.Join(vaultErr.Errors, "; "))
+	}
+
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,127 @@
+package vaultstore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brandtkeller/strava-api/pkg/strava"
+)
+
+// fakeVault serves KV secrets from memory, answering 404 for secrets not
+// written yet the way Vault does
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]json.RawMessage
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	v := &fakeVault{secrets: make(map[string]json.RawMessage)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			secret, ok := v.secrets[r.URL.Path]
+			if !ok {
+				http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+				return
+			}
+			data := secret
+			if strings.Contains(r.URL.Path, "/data/") {
+				// KV v2 nests the values below data.data
+				data, _ = json.Marshal(map[string]json.RawMessage{"data": secret})
+			}
+			json.NewEncoder(w).Encode(map[string]json.RawMessage{"data": data})
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(r.URL.Path, "/data/") {
+				var v2 struct {
+					Data json.RawMessage `json:"data"`
+				}
+				if err := json.Unmarshal(body, &v2); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				body = v2.Data
+			}
+			v.secrets[r.URL.Path] = body
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return v, srv
+}
+
+func TestValuesOfMissingSecretIsEmpty(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		_, srv := newFakeVault(t)
+		store := &Store{Address: srv.URL, Path: "strava", KVVersion: version, Token: "test-token"}
+
+		values, err := store.Values(context.Background())
+		if err != nil {
+			t.Fatalf("KV v%d: Values of a missing secret: %v", version, err)
+		}
+		if values == nil || len(values) != 0 {
+			t.Fatalf("KV v%d: Values of a missing secret = %v, want an empty map", version, values)
+		}
+		if _, err := store.Load(context.Background()); err != strava.ErrNoToken {
+			t.Fatalf("KV v%d: Load of a missing secret: got %v, want ErrNoToken", version, err)
+		}
+	}
+}
+
+func TestSaveCreatesFirstToken(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		_, srv := newFakeVault(t)
+		store := &Store{Address: srv.URL, Path: "strava", KVVersion: version, Token: "test-token"}
+		token := strava.Token{RefreshToken: "refresh", AccessToken: "access", ExpiresAt: 1700000000}
+
+		if err := store.Save(context.Background(), token); err != nil {
+			t.Fatalf("KV v%d: Save into a missing secret: %v", version, err)
+		}
+		got, err := store.Load(context.Background())
+		if err != nil {
+			t.Fatalf("KV v%d: Load: %v", version, err)
+		}
+		if got != token {
+			t.Fatalf("KV v%d: Load = %+v, want %+v", version, got, token)
+		}
+	}
+}
+
+func TestValuesKeepNumbers(t *testing.T) {
+	v, srv := newFakeVault(t)
+	v.secrets["/v1/secret/data/strava"] = json.RawMessage(`{"STRAVA_REFRESH_TOKEN":"refresh","STRAVA_TOKEN_EXPIRES_AT":1700000000,"STRAVA_CLIENT_ID":12345}`)
+	store := &Store{Address: srv.URL, Path: "strava", Token: "test-token"}
+
+	values, err := store.Values(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := values[strava.EnvTokenExpiresAt]; got != "1700000000" {
+		t.Errorf("%s = %q, want 1700000000", strava.EnvTokenExpiresAt, got)
+	}
+	if got := values["STRAVA_CLIENT_ID"]; got != "12345" {
+		t.Errorf("STRAVA_CLIENT_ID = %q, want 12345", got)
+	}
+
+	token, err := store.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ExpiresAt != 1700000000 {
+		t.Errorf("ExpiresAt = %d, want 1700000000", token.ExpiresAt)
+	}
+}
@@ -0,0 +1,60 @@
+package strava
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WebhookEvent is a push subscription event Strava POSTs to the callback
+// URL of the application. Events carry no details: fetch the object
+// with the token of the athlete named by OwnerID.
+type WebhookEvent struct {
+	// ObjectType is activity or athlete
+	ObjectType string `json:"object_type"`
+	ObjectID   int64  `json:"object_id"`
+	// AspectType is create, update, or delete
+	AspectType string `json:"aspect_type"`
+	// Updates holds the changed fields of an update, e.g. title, type,
+	// private, or authorized
+	Updates        map[string]string `json:"updates"`
+	OwnerID        int64             `json:"owner_id"`
+	SubscriptionID int64             `json:"subscription_id"`
+	EventTime      int64             `json:"event_time"`
+}
+
+// Deauthorized reports whether the event says the athlete revoked the
+// application's access
+func (e WebhookEvent) Deauthorized() bool {
+	return e.ObjectType == "athlete" && e.Updates["authorized"] == "false"
+}
+
+// NewWebhookHandler returns the handler of a push subscription callback.
+// It answers the GET Strava sends to validate a new subscription when
+// hub.verify_token matches verifyToken, and passes each POSTed event to
+// handle. Strava expects the POST to be answered within two seconds, so
+// handle should queue the work rather than do it.
+func NewWebhookHandler(verifyToken string, handle func(WebhookEvent)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			if q.Get("hub.mode") != "subscribe" || verifyToken == "" || q.Get("hub.verify_token") != verifyToken {
+				http.Error(w, "verify token mismatch", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"hub.challenge": q.Get("hub.challenge")})
+		case http.MethodPost:
+			var event WebhookEvent
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&event); err != nil {
+				http.Error(w, "malformed event: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			handle(event)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package strava
+
+import (
+	"context"
+	"strconv"
+)
+
+// ZoneRange is one zone boundary pair. Max is -1 for the open top zone.
+type ZoneRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// TimedZoneRange is a zone with the seconds spent in it
+type TimedZoneRange struct {
+	Min  int `json:"min"`
+	Max  int `json:"max"`
+	Time int `json:"time"`
+}
+
+// ActivityZone is the distribution of an activity across heart rate or
+// power zones
+type ActivityZone struct {
+	// Type is "heartrate" or "power"
+	Type                string           `json:"type"`
+	Score               int              `json:"score"`
+	SensorBased         bool             `json:"sensor_based"`
+	Points              int              `json:"points"`
+	CustomZones         bool             `json:"custom_zones"`
+	Max                 int              `json:"max"`
+	DistributionBuckets []TimedZoneRange `json:"distribution_buckets"`
+}
+
+// HeartRateZoneRanges are the athlete's heart rate zones
+type HeartRateZoneRanges struct {
+	CustomZones bool        `json:"custom_zones"`
+	Zones       []ZoneRange `json:"zones"`
+}
+
+// PowerZoneRanges are the athlete's power zones
+type PowerZoneRanges struct {
+	Zones []ZoneRange `json:"zones"`
+}
+
+// Zones are the authenticated athlete's configured zones
+type Zones struct {
+	HeartRate *HeartRateZoneRanges `json:"heart_rate,omitempty"`
+	Power     *PowerZoneRanges     `json:"power,omitempty"`
+}
+
+// GetActivityZones returns the time spent in each zone during an activity.
+// Strava requires a subscription for this endpoint.
+func (c *Client) GetActivityZones(ctx context.Context, id int64) ([]ActivityZone, error) {
+	zones := make([]ActivityZone, 0)
+	if err := c.get(ctx, opDetail, "/activities/"+strconv.FormatInt(id, 10)+"/zones", nil, &zones); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// GetAthleteZones returns the authenticated athlete's heart rate and power
+// zones. The token needs the profile:read_all scope.
+func (c *Client) GetAthleteZones(ctx context.Context) (Zones, error) {
+	var zones Zones
+	if err := c.requireScope(ScopeProfileReadAll); err != nil {
+		return zones, err
+	}
+	err := c.get(ctx, opDetail, "/athlete/zones", nil, &zones)
+	return zones, err
+}
@@ -0,0 +1,202 @@
+// The strava-api gRPC service exposes the local activity cache, the run
+// summary, and syncing to other programs. Distances are in meters, times in
+// seconds, and dates are RFC 3339 strings in UTC unless noted.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: strava/v1/strava.proto
+
+package stravapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	StravaService_ListActivities_FullMethodName = "/strava.v1.StravaService/ListActivities"
+	StravaService_GetSummary_FullMethodName     = "/strava.v1.StravaService/GetSummary"
+	StravaService_Sync_FullMethodName           = "/strava.v1.StravaService/Sync"
+)
+
+// StravaServiceClient is the client API for StravaService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type StravaServiceClient interface {
+	// ListActivities returns cached activities, newest first
+	ListActivities(ctx context.Context, in *ListActivitiesRequest, opts ...grpc.CallOption) (*ListActivitiesResponse, error)
+	// GetSummary computes the run summary of the cached activities: the
+	// totals matched by the settings file rules, the streak, and goal
+	// progress, optionally with totals per period
+	GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error)
+	// Sync fetches new and changed activities from Strava into the cache.
+	// Only one sync runs at a time; concurrent calls wait for it.
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error)
+}
+
+type stravaServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStravaServiceClient(cc grpc.ClientConnInterface) StravaServiceClient {
+	return &stravaServiceClient{cc}
+}
+
+func (c *stravaServiceClient) ListActivities(ctx context.Context, in *ListActivitiesRequest, opts ...grpc.CallOption) (*ListActivitiesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListActivitiesResponse)
+	err := c.cc.Invoke(ctx, StravaService_ListActivities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stravaServiceClient) GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSummaryResponse)
+	err := c.cc.Invoke(ctx, StravaService_GetSummary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stravaServiceClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncResponse)
+	err := c.cc.Invoke(ctx, StravaService_Sync_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StravaServiceServer is the server API for StravaService service.
+// All implementations must embed UnimplementedStravaServiceServer
+// for forward compatibility
+type StravaServiceServer interface {
+	// ListActivities returns cached activities, newest first
+	ListActivities(context.Context, *ListActivitiesRequest) (*ListActivitiesResponse, error)
+	// GetSummary computes the run summary of the cached activities: the
+	// totals matched by the settings file rules, the streak, and goal
+	// progress, optionally with totals per period
+	GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error)
+	// Sync fetches new and changed activities from Strava into the cache.
+	// Only one sync runs at a time; concurrent calls wait for it.
+	Sync(context.Context, *SyncRequest) (*SyncResponse, error)
+	mustEmbedUnimplementedStravaServiceServer()
+}
+
+// UnimplementedStravaServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedStravaServiceServer struct {
+}
+
+func (UnimplementedStravaServiceServer) ListActivities(context.Context, *ListActivitiesRequest) (*ListActivitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListActivities not implemented")
+}
+func (UnimplementedStravaServiceServer) GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSummary not implemented")
+}
+func (UnimplementedStravaServiceServer) Sync(context.Context, *SyncRequest) (*SyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedStravaServiceServer) mustEmbedUnimplementedStravaServiceServer() {}
+
+// UnsafeStravaServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StravaServiceServer will
+// result in compilation errors.
+type UnsafeStravaServiceServer interface {
+	mustEmbedUnimplementedStravaServiceServer()
+}
+
+func RegisterStravaServiceServer(s grpc.ServiceRegistrar, srv StravaServiceServer) {
+	s.RegisterService(&StravaService_ServiceDesc, srv)
+}
+
+func _StravaService_ListActivities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListActivitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StravaServiceServer).ListActivities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StravaService_ListActivities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StravaServiceServer).ListActivities(ctx, req.(*ListActivitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StravaService_GetSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StravaServiceServer).GetSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StravaService_GetSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StravaServiceServer).GetSummary(ctx, req.(*GetSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StravaService_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StravaServiceServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StravaService_Sync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StravaServiceServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StravaService_ServiceDesc is the grpc.ServiceDesc for StravaService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StravaService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "strava.v1.StravaService",
+	HandlerType: (*StravaServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListActivities",
+			Handler:    _StravaService_ListActivities_Handler,
+		},
+		{
+			MethodName: "GetSummary",
+			Handler:    _StravaService_GetSummary_Handler,
+		},
+		{
+			MethodName: "Sync",
+			Handler:    _StravaService_Sync_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "strava/v1/strava.proto",
+}